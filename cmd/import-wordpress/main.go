@@ -0,0 +1,84 @@
+// Command import-wordpress imports a WordPress export (WXR) as blogs of an author,
+// the same way the admin endpoint does but without going through the API:
+//
+//	go run ./cmd/import-wordpress -author username [-dry-run] export.xml
+//
+// Posts imported before are skipped, so running it again is safe.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"resqiar.com-server/constants"
+	"resqiar.com-server/db"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/services"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	author := flag.String("author", "", "username of the author the blogs are imported for")
+	dryRun := flag.Bool("dry-run", false, "report what would be imported without importing anything")
+	flag.Parse()
+
+	if *author == "" || flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: import-wordpress -author username [-dry-run] export.xml")
+		os.Exit(2)
+	}
+
+	export, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Load env variables from .env file
+	godotenv.Load()
+
+	DB := db.InitDB() // init Postgres db
+	db.InitRedis()    // init Redis db
+
+	blogRepository := repositories.InitBlogRepo(DB)
+
+	importService := services.ImportServiceImpl{
+		UtilService:    services.InitUtilService(),
+		Repository:     repositories.InitImportRepo(DB),
+		BlogRepository: blogRepository,
+		RelatedService: &services.RelatedServiceImpl{
+			Repository:     repositories.InitRelatedRepo(DB),
+			BlogRepository: blogRepository,
+		},
+		CacheService:   services.InitCacheService(db.RedisStore.Conn()),
+		UserRepository: repositories.InitUserRepo(DB),
+	}
+
+	job, err := importService.RunWordPressImport(export, *author, *dryRun)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, item := range job.Items {
+		switch {
+		case item.Error != "":
+			fmt.Printf("failed   %s: %s\n", item.Path, item.Error)
+		case item.Skipped:
+			fmt.Printf("skipped  %s (imported before)\n", item.Path)
+		default:
+			fmt.Printf("%-8s %s -> %s\n", item.State, item.Path, item.Slug)
+		}
+
+		// images stay where they are until re-hosted
+		for _, image := range item.Images {
+			fmt.Printf("         image %s\n", image)
+		}
+	}
+
+	fmt.Printf("\n%d created, %d skipped, %d failed of %d posts\n", job.Created, job.Skipped, job.Failed, job.Total)
+
+	if job.Status == constants.ImportFailed {
+		log.Fatal(job.Error)
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 var (
 	illegalUsernameRegex = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+	validSlugRegex       = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
 )
 
 func InitCustomValidation(validate *validator.Validate) {
@@ -37,4 +38,11 @@ func InitCustomValidation(validate *validator.Validate) {
 
 		return true
 	})
+
+	// lowercase words separated by single hyphens, as FormatToURL generates them
+	validate.RegisterValidation("slug", func(fl validator.FieldLevel) bool {
+		value := fl.Field().Interface().(string)
+
+		return validSlugRegex.MatchString(value)
+	})
 }
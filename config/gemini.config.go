@@ -0,0 +1,45 @@
+package config
+
+import (
+	"os"
+
+	"resqiar.com-server/constants"
+)
+
+// GeminiCapsule is where the Gemini capsule listens, it is served with its own certificate
+// since Gemini clients trust the first certificate they see rather than certificate authorities.
+type GeminiCapsule struct {
+	Host     string
+	Port     string
+	CertPath string
+	KeyPath  string
+}
+
+// GeminiConfig returns nil unless the capsule is configured, it is optional.
+func GeminiConfig() *GeminiCapsule {
+	capsule := &GeminiCapsule{
+		Host:     os.Getenv("GEMINI_HOST"),
+		Port:     os.Getenv("GEMINI_PORT"),
+		CertPath: os.Getenv("GEMINI_CERT_PATH"),
+		KeyPath:  os.Getenv("GEMINI_KEY_PATH"),
+	}
+
+	if capsule.Host == "" || capsule.CertPath == "" || capsule.KeyPath == "" {
+		return nil
+	}
+
+	if capsule.Port == "" {
+		capsule.Port = constants.GeminiDefaultPort
+	}
+
+	return capsule
+}
+
+// URL is the base URL of the capsule, links within it are absolute.
+func (capsule *GeminiCapsule) URL() string {
+	if capsule.Port == constants.GeminiDefaultPort {
+		return "gemini://" + capsule.Host
+	}
+
+	return "gemini://" + capsule.Host + ":" + capsule.Port
+}
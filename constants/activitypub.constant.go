@@ -0,0 +1,55 @@
+package constants
+
+import "time"
+
+const (
+	ActivityPubContentType = "application/activity+json"
+
+	// servers may also ask for the longer JSON-LD content type
+	ActivityPubLDContentType = `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`
+
+	ActivityStreamsContext = "https://www.w3.org/ns/activitystreams"
+	SecurityContext        = "https://w3id.org/security/v1"
+
+	// addressing an activity to the public collection makes it public
+	ActivityPubPublic = "https://www.w3.org/ns/activitystreams#Public"
+)
+
+// Statuses of an activity delivery
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+const (
+	// size of the RSA keys actors sign their requests with
+	ActivityPubKeyBits = 2048
+
+	// how many of the latest blogs the outbox lists
+	ActivityPubOutboxLimit = 20
+
+	// activities bigger than this are refused by the inbox
+	ActivityPubMaxBodySize = 1 << 20
+
+	// signed requests older or newer than this are refused, they may be replayed
+	ActivityPubSignatureMaxAge = 1 * time.Hour
+
+	// timeout of the requests to remote servers, fetching actors and delivering
+	ActivityPubRequestTimeout = 10 * time.Second
+
+	// how often the delivery job looks for pending deliveries
+	DeliveryJobInterval = 30 * time.Second
+
+	// a claimed delivery is given this long before another instance may claim it again
+	DeliveryJobTimeout = 5 * time.Minute
+
+	// failed deliveries are retried after DeliveryRetryDelay, doubled on every attempt,
+	// until they failed DeliveryMaxAttempts times
+	DeliveryRetryDelay  = 1 * time.Minute
+	DeliveryMaxAttempts = 8
+
+	// Update deliveries wait this long before their first attempt,
+	// every update of the blog meanwhile is merged in the one already waiting
+	DeliveryUpdateDelay = 1 * time.Minute
+)
@@ -0,0 +1,14 @@
+package constants
+
+import "time"
+
+const (
+	// an autosave is flushed to Postgres once the author stopped typing for AutosaveDebounce,
+	// due flushes are looked for every AutosaveFlushInterval.
+	AutosaveDebounce      = 30 * time.Second
+	AutosaveFlushInterval = 5 * time.Second
+	AutosaveFlushBatch    = 100
+
+	// unsaved autosaves stay recoverable for AutosaveTTL
+	AutosaveTTL = 7 * 24 * time.Hour
+)
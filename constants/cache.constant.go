@@ -0,0 +1,22 @@
+package constants
+
+import "time"
+
+const (
+	CacheTTL = 10 * time.Minute
+
+	// only one instance recomputes a missing entry, the others wait for it
+	// at most CacheLockTimeout before giving up and computing it themselves.
+	CacheLockTimeout  = 10 * time.Second
+	CachePollInterval = 50 * time.Millisecond
+
+	// lists of published blogs change membership whenever a blog gets published,
+	// lists including drafts also change whenever a blog gets created.
+	CacheTagPublished = "published"
+	CacheTagDrafts    = "drafts"
+
+	// clients and proxies may store public responses but have to revalidate them
+	// on every use, which is answered with a 304 when nothing changed.
+	CacheControlPublic  = "public, max-age=0, must-revalidate"
+	CacheControlPrivate = "private, no-cache"
+)
@@ -0,0 +1,6 @@
+package constants
+
+const (
+	CoAuthorEditor = "editor" // edits and publishes the blog like its author
+	CoAuthorViewer = "viewer" // only reads the blog, drafts included
+)
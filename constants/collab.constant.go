@@ -0,0 +1,33 @@
+package constants
+
+import "time"
+
+const (
+	// a collaborative session is persisted to the blog CollabPersistDelay after its first unsaved edit,
+	// due sessions are looked for every CollabPersistInterval.
+	CollabPersistDelay    = 10 * time.Second
+	CollabPersistInterval = 5 * time.Second
+	CollabPersistBatch    = 100
+
+	// sessions nobody edits anymore are dropped from Redis after CollabSessionTTL
+	CollabSessionTTL = time.Hour
+
+	// same limit as UpdateBlogInput.Content, counted in characters
+	CollabMaxContentLength = 50000
+
+	// connections are pinged every CollabPingInterval and dropped after missing two pongs,
+	// a client falling CollabClientBuffer messages behind is dropped as well.
+	CollabPingInterval   = 30 * time.Second
+	CollabMaxMessageSize = 64 * 1024
+	CollabClientBuffer   = 256
+)
+
+const (
+	CollabMessageSnapshot  = "snapshot"
+	CollabMessageOperation = "operation"
+	CollabMessageAck       = "ack"
+	CollabMessageCursor    = "cursor"
+	CollabMessageJoin      = "join"
+	CollabMessageLeave     = "leave"
+	CollabMessageError     = "error"
+)
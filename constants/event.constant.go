@@ -0,0 +1,14 @@
+package constants
+
+const (
+	EventFollow         = "follow"
+	EventComment        = "comment"
+	EventReaction       = "reaction"
+	EventCoAuthorInvite = "coauthor.invite"
+)
+
+const (
+	EventBlogPublished   = "blog.published"
+	EventBlogUpdated     = "blog.updated" // a published blog was edited
+	EventBlogUnpublished = "blog.unpublished"
+)
@@ -0,0 +1,18 @@
+package constants
+
+import "time"
+
+// Formats of an export
+const (
+	ExportMarkdown = "markdown" // zip archive of Markdown files with front matter, importable back
+	ExportSite     = "site"     // zip archive of a static HTML site of the published blogs
+)
+
+const (
+	// an EPUB holds at most this many blogs, a series is cut off after as many
+	EpubMaxBlogs = 200
+
+	// the cover image is downloaded into the EPUB, a slow or large one is left out
+	EpubCoverMaxSize = 5 << 20
+	EpubCoverTimeout = 10 * time.Second
+)
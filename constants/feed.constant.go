@@ -0,0 +1,6 @@
+package constants
+
+const (
+	DefaultFeedLimit = 10
+	MaxFeedLimit     = 50
+)
@@ -0,0 +1,27 @@
+package constants
+
+import "time"
+
+// Status codes of Gemini responses, the meta of a success is the MIME type of the body
+const (
+	GeminiSuccess          = 20
+	GeminiRedirect         = 31 // permanent
+	GeminiTemporaryFailure = 40
+	GeminiNotFound         = 51
+	GeminiProxyRefused     = 53
+	GeminiBadRequest       = 59
+)
+
+const (
+	GeminiDefaultPort = "1965"
+	GeminiMimeType    = "text/gemini; charset=utf-8"
+
+	// a request is a single URL of at most 1024 bytes followed by CRLF
+	GeminiMaxRequestSize = 1024
+
+	// clients are expected to send their request right away and read the response as fast
+	GeminiTimeout = 30 * time.Second
+
+	// how many of the latest blogs the index and the feeds list
+	GeminiIndexLimit = 50
+)
@@ -0,0 +1,34 @@
+package constants
+
+import "time"
+
+// Formats of an imported file
+const (
+	ImportMarkdown  = "markdown"  // zip archive of Markdown files with front matter
+	ImportWordPress = "wordpress" // WordPress export (WXR)
+)
+
+// Statuses of an import job
+const (
+	ImportPending = "pending"
+	ImportRunning = "running"
+	ImportDone    = "done"
+	ImportFailed  = "failed"
+)
+
+const (
+	// limits of an uploaded archive, posts bigger than ImportMaxFileSize are reported as failed
+	ImportMaxArchiveSize = 16 << 20
+	ImportMaxFiles       = 1000
+	ImportMaxFileSize    = 1 << 20
+
+	// the tags of imported posts beyond this are dropped
+	ImportMaxTags = 10
+
+	// how often the import job looks for pending imports
+	ImportJobInterval = 10 * time.Second
+
+	// a claimed import which is still running after this long
+	// belongs to a crashed instance and may be claimed again
+	ImportJobTimeout = 30 * time.Minute
+)
@@ -0,0 +1,14 @@
+package constants
+
+const (
+	MailLog  = "log"
+	MailSMTP = "smtp"
+
+	// kinds of email a user can unsubscribe from
+	UnsubscribeNewPost = "new_post"
+	UnsubscribeDigest  = "digest"
+	UnsubscribeAll     = "all"
+
+	// maximum blogs listed in a single weekly digest
+	MaxDigestBlogs = 20
+)
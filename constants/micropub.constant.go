@@ -0,0 +1,56 @@
+package constants
+
+import "time"
+
+// Scopes a Micropub client can be granted through IndieAuth
+const (
+	ScopeCreate = "create"
+	ScopeUpdate = "update"
+	ScopeDelete = "delete"
+	ScopeMedia  = "media"
+
+	// draft only allows creating drafts, create covers them as well
+	ScopeDraft = "draft"
+)
+
+// IndieAuthScopes lists every scope in the order they are shown for consent.
+var IndieAuthScopes = []string{ScopeCreate, ScopeDraft, ScopeUpdate, ScopeDelete, ScopeMedia}
+
+const (
+	// the only challenge method of PKCE the authorization endpoint accepts
+	PKCEMethodS256 = "S256"
+
+	// authorization codes must be exchanged for a token within this long
+	IndieAuthCodeLifetime = 10 * time.Minute
+
+	// access tokens are valid for this long, authorizing again gives a new one
+	AccessTokenLifetime = 90 * 24 * time.Hour
+)
+
+// Values of the post-status property
+const (
+	MicropubPublished = "published"
+	MicropubDraft     = "draft"
+)
+
+const (
+	// titles derived from the content of untitled notes are cut at this many characters
+	MicropubTitleLength = 60
+
+	// media bigger than this are refused by the media endpoint
+	MicropubMaxMediaSize = 10 << 20
+
+	// requests carrying uploads are allowed past the default body limit up to this
+	MicropubMaxBodySize = 16 << 20
+
+	// at most this many photos can be uploaded along with a post
+	MicropubMaxPhotos = 4
+)
+
+// MicropubMediaTypes lists the content types the media endpoint stores.
+var MicropubMediaTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
@@ -0,0 +1,15 @@
+package constants
+
+import "time"
+
+const (
+	// how often the sending job looks for pending newsletter issues
+	NewsletterJobInterval = 1 * time.Minute
+
+	// a claimed issue which is still not sent after this long
+	// belongs to a crashed instance and may be claimed again
+	NewsletterIssueTimeout = 1 * time.Hour
+
+	// subscribing again within this long after a confirmation link was emailed sends nothing
+	NewsletterConfirmationCooldown = 15 * time.Minute
+)
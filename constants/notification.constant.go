@@ -0,0 +1,13 @@
+package constants
+
+import "time"
+
+const (
+	DefaultNotificationLimit = 20
+	MaxNotificationLimit     = 100
+
+	// every SSE stream sends a comment line periodically,
+	// this keeps proxies from closing idle connections and
+	// lets us notice clients which are gone.
+	NotificationKeepAlive = 30 * time.Second
+)
@@ -0,0 +1,13 @@
+package constants
+
+const (
+	// RendererVersion identifies the Markdown rendering configuration,
+	// bump it whenever ParseMD output changes so every stored HTML gets re-rendered.
+	RendererVersion = 2
+
+	// number of stale blogs re-rendered per query by the bulk job
+	RenderBatchSize = 100
+
+	// average reading speed the reading time is estimated with
+	ReadingWordsPerMinute = 200
+)
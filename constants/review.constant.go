@@ -0,0 +1,51 @@
+package constants
+
+// States a blog moves through on its way to readers,
+// only published blogs are visible to the public.
+const (
+	BlogDraft            = "draft"
+	BlogInReview         = "in_review"
+	BlogChangesRequested = "changes_requested"
+	BlogApproved         = "approved"
+	BlogPublished        = "published"
+	BlogArchived         = "archived"
+)
+
+// Roles a user can hold on a blog, a user may hold several.
+// Authors are the author of the blog and its editing co-authors,
+// reviewers are the users flagged as reviewers and admins.
+const (
+	RoleAuthor   = "author"
+	RoleReviewer = "reviewer"
+)
+
+// BlogTransitions lists every state a blog can move to from its current state,
+// along with the roles allowed to move it there.
+var BlogTransitions = map[string]map[string][]string{
+	BlogDraft: {
+		BlogInReview:  {RoleAuthor},
+		BlogPublished: {RoleAuthor}, // the review is opt-in, authors may publish right away
+		BlogArchived:  {RoleAuthor},
+	},
+	BlogInReview: {
+		BlogDraft:            {RoleAuthor},
+		BlogChangesRequested: {RoleReviewer},
+		BlogApproved:         {RoleReviewer},
+	},
+	BlogChangesRequested: {
+		BlogDraft:    {RoleAuthor},
+		BlogInReview: {RoleAuthor},
+	},
+	BlogApproved: {
+		BlogDraft:     {RoleAuthor},
+		BlogInReview:  {RoleAuthor},
+		BlogPublished: {RoleAuthor, RoleReviewer},
+	},
+	BlogPublished: {
+		BlogDraft:    {RoleAuthor, RoleReviewer},
+		BlogArchived: {RoleAuthor, RoleReviewer},
+	},
+	BlogArchived: {
+		BlogDraft: {RoleAuthor},
+	},
+}
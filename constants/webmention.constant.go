@@ -0,0 +1,38 @@
+package constants
+
+import "time"
+
+// Statuses of a received webmention
+const (
+	WebmentionPending  = "pending"
+	WebmentionVerified = "verified"
+	WebmentionRejected = "rejected" // the source does not link to the target (anymore)
+)
+
+const (
+	// how many webmentions a single host may send within WebmentionHostWindow
+	WebmentionHostLimit  = 20
+	WebmentionHostWindow = 1 * time.Hour
+
+	// at most this many links of a blog are sent webmentions
+	WebmentionMaxLinks = 50
+
+	// an edited blog only sends to the same target again after this long
+	WebmentionResendInterval = 10 * time.Minute
+
+	// sources and endpoints bigger than this are only read up to it
+	WebmentionMaxBodySize = 1 << 20
+
+	// timeout of the requests verifying sources and sending webmentions
+	WebmentionRequestTimeout = 10 * time.Second
+
+	// how often the verification job looks for pending webmentions
+	WebmentionJobInterval = 30 * time.Second
+
+	// a claimed webmention is given this long before another instance may claim it again
+	WebmentionJobTimeout = 5 * time.Minute
+
+	// sources which cannot be fetched are retried after WebmentionRetryDelay, doubled on every attempt
+	WebmentionRetryDelay  = 1 * time.Minute
+	WebmentionMaxAttempts = 5
+)
@@ -3,6 +3,7 @@ package db
 import (
 	"fmt"
 	"os"
+	"resqiar.com-server/constants"
 	"resqiar.com-server/entities"
 
 	"gorm.io/driver/postgres"
@@ -29,7 +30,36 @@ func InitDB() *gorm.DB {
 		panic("Failed connecting to the database...")
 	}
 
-	DB.AutoMigrate(&entities.User{}, &entities.Blog{})
+	DB.AutoMigrate(
+		&entities.User{},
+		&entities.Blog{},
+		&entities.BlogSlug{},
+		&entities.BlogAutosave{},
+		&entities.CoAuthor{},
+		&entities.BlogTransition{},
+		&entities.ReviewNote{},
+		&entities.RelatedBlog{},
+		&entities.Follow{},
+		&entities.Notification{},
+		&entities.NotificationPreference{},
+		&entities.Subscriber{},
+		&entities.NewsletterIssue{},
+		&entities.ImportJob{},
+		&entities.ImportedPost{},
+		&entities.ActorKey{},
+		&entities.RemoteFollower{},
+		&entities.ActivityDelivery{},
+		&entities.Webmention{},
+		&entities.SentWebmention{},
+		&entities.IndieAuthCode{},
+		&entities.AccessToken{},
+		&entities.MediaFile{},
+	)
+
+	// blogs published before the review workflow existed start out as drafts
+	DB.Model(&entities.Blog{}).
+		Where("published = ? AND state = ?", true, constants.BlogDraft).
+		Update("state", constants.BlogPublished)
 
 	return DB
 }
@@ -0,0 +1,19 @@
+package dto
+
+import "time"
+
+type CoAuthorOutput struct {
+	UserID     string
+	Username   string
+	PictureURL string
+	Role       string
+	AcceptedAt *time.Time
+}
+
+type InvitationOutput struct {
+	BlogID    string
+	BlogTitle string
+	InvitedBy string // username of the author who sent the invitation
+	Role      string
+	CreatedAt time.Time
+}
@@ -0,0 +1,9 @@
+package dto
+
+import "time"
+
+type EpubOutput struct {
+	FileName  string
+	Data      []byte
+	UpdatedAt time.Time
+}
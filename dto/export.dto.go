@@ -0,0 +1,14 @@
+package dto
+
+import (
+	"time"
+
+	"resqiar.com-server/entities"
+)
+
+// BlogExport is everything an export of the author is written from.
+type BlogExport struct {
+	Author     *entities.SafeUser
+	Blogs      []entities.Blog
+	ExportedAt time.Time
+}
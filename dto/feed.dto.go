@@ -0,0 +1,8 @@
+package dto
+
+import "resqiar.com-server/entities"
+
+type FeedOutput struct {
+	Blogs      []entities.SafeBlogAuthor
+	NextCursor string
+}
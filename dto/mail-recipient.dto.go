@@ -0,0 +1,7 @@
+package dto
+
+type MailRecipient struct {
+	UserID   string
+	Email    string
+	Username string
+}
@@ -0,0 +1,13 @@
+package dto
+
+import "resqiar.com-server/types"
+
+type ParsedHTMLOutput struct {
+	HTML        string
+	FrontMatter *types.FrontMatter `json:",omitempty"`
+}
+
+type ParsedDocumentOutput struct {
+	*types.RenderedMarkdown
+	FrontMatter *types.FrontMatter `json:",omitempty"`
+}
@@ -0,0 +1,22 @@
+package dto
+
+import "time"
+
+type TransitionOutput struct {
+	FromState     string
+	ToState       string
+	Note          string
+	ActorUsername string
+	CreatedAt     time.Time
+}
+
+type ReviewNoteOutput struct {
+	ID               string
+	Version          int
+	StartLine        int
+	EndLine          int
+	Body             string
+	ReviewerUsername string
+	CreatedAt        time.Time
+	ResolvedAt       *time.Time
+}
@@ -0,0 +1,48 @@
+package entities
+
+import "time"
+
+// ActorKey is the key pair the user signs activities with as an ActivityPub actor,
+// it is generated the first time the actor is needed.
+type ActorKey struct {
+	UserID    string `gorm:"type:uuid; primaryKey; not null"`
+	CreatedAt time.Time
+
+	PublicKey  string `gorm:"type:text; not null"` // PEM encoded
+	PrivateKey string `gorm:"type:text; not null" json:"-"`
+}
+
+// RemoteFollower is an actor of another server following the user,
+// activities of the user are delivered to its inbox.
+type RemoteFollower struct {
+	UserID    string `gorm:"type:uuid; primaryKey; not null"` // user being followed
+	ActorID   string `gorm:"type:text; primaryKey; not null"` // URI of the remote actor
+	CreatedAt time.Time
+
+	Inbox string `gorm:"type:text; not null"`
+
+	// servers share an inbox between their actors, delivering to it once is enough
+	SharedInbox string `gorm:"type:text"`
+}
+
+// ActivityDelivery is an activity waiting to be posted to a remote inbox,
+// failed deliveries are retried later with a growing delay.
+type ActivityDelivery struct {
+	ID        string `gorm:"type:uuid; primaryKey; default:gen_random_uuid()"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	UserID   string `gorm:"type:uuid; not null"` // actor signing the delivery
+	Inbox    string `gorm:"type:text; not null"`
+	Activity []byte `gorm:"type:bytea; not null"`
+
+	// set on Update deliveries only, a later update of the blog replaces the activity while it waits
+	BlogID string `gorm:"type:text; not null; default:''; index"`
+
+	Status        string    `gorm:"type:varchar(20); not null; default:pending; index:idx_delivery_pending,priority:1"`
+	NextAttemptAt time.Time `gorm:"not null; index:idx_delivery_pending,priority:2"`
+	Attempts      int       `gorm:"type:int; not null; default:0"`
+	Error         string    `gorm:"type:text"`
+
+	DeliveredAt *time.Time
+}
@@ -0,0 +1,20 @@
+package entities
+
+import "time"
+
+// BlogAutosave is the last flushed autosave of a blog by one of its editors,
+// it is kept apart from the blog which only changes on an explicit save.
+type BlogAutosave struct {
+	BlogID string `gorm:"type:text; primaryKey; not null"`
+	UserID string `gorm:"type:text; primaryKey; not null"`
+
+	Title    string `gorm:"type:varchar(100)"`
+	Summary  string `gorm:"type:text"`
+	Content  string `gorm:"type:text"`
+	CoverURL string `gorm:"type:text"`
+	Prev     string `gorm:"type:varchar(32)"`
+	Next     string `gorm:"type:varchar(32)"`
+
+	Base    int       `gorm:"type:int; not null"` // version of the blog the editor started from
+	SavedAt time.Time `gorm:"not null"`
+}
@@ -0,0 +1,12 @@
+package entities
+
+import "time"
+
+// BlogSlug is a slug a blog was known by before its current one,
+// links using it are redirected to the blog's current slug.
+type BlogSlug struct {
+	AuthorID  string `gorm:"type:uuid; primaryKey; not null"`
+	Slug      string `gorm:"type:varchar(100); primaryKey; not null"`
+	BlogID    string `gorm:"type:text; not null; index"`
+	CreatedAt time.Time
+}
@@ -3,6 +3,8 @@ package entities
 import (
 	"time"
 
+	"resqiar.com-server/types"
+
 	gonanoid "github.com/matoous/go-nanoid/v2"
 	"gorm.io/gorm"
 )
@@ -15,16 +17,36 @@ type Blog struct {
 	PublishedAt time.Time
 	DeletedAt   gorm.DeletedAt
 
-	Title     string `gorm:"type:varchar(100); not null"`
-	Summary   string `gorm:"type:text"`
-	Content   string `gorm:"type:text"`
-	Published bool   `gorm:"type:bool; default:false"`
-	CoverURL  string `gorm:"type:text"`
+	Title     string     `gorm:"type:varchar(100); not null"`
+	Summary   string     `gorm:"type:text"`
+	Content   string     `gorm:"type:text"`
+	Published bool       `gorm:"type:bool; default:false"` // kept in sync with State, true only when published
+	CoverURL  string     `gorm:"type:text"`
+	Tags      types.Tags `gorm:"type:jsonb"`
+
+	// Content rendered into sanitized HTML when it is saved,
+	// it is re-rendered whenever RendererVersion is behind constants.RendererVersion.
+	ContentHTML     string `gorm:"type:text" json:"-"`
+	RendererVersion int    `gorm:"type:int; default:0" json:"-"`
+
+	// Collected from the content while rendering it
+	TOC         types.TableOfContents `gorm:"type:jsonb"`
+	WordCount   int                   `gorm:"type:int; default:0"`
+	ReadingTime int                   `gorm:"type:int; default:0"` // in minutes
 
 	Prev string `gorm:"type:varchar(32)"`
 	Next string `gorm:"type:varchar(32)"`
 
 	AuthorID string `gorm:"type:text; not null"`
+
+	// Version is bumped on every edit, an edit based on an older version is rejected
+	// so concurrent editors never silently overwrite each other.
+	Version int `gorm:"type:int; not null; default:1"`
+
+	// State is where the blog stands in the editorial workflow, see constants.BlogTransitions.
+	// ApprovedVersion is the version a reviewer approved, edits after approval need a new review.
+	State           string `gorm:"type:varchar(20); not null; default:draft"`
+	ApprovedVersion int    `gorm:"type:int; not null; default:0"`
 }
 
 func (blog *Blog) BeforeCreate(tx *gorm.DB) error {
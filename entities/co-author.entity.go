@@ -0,0 +1,16 @@
+package entities
+
+import "time"
+
+// CoAuthor shares a blog with a user invited by its author,
+// the invitation is pending until AcceptedAt is set.
+type CoAuthor struct {
+	BlogID    string `gorm:"type:text; primaryKey; not null"`
+	UserID    string `gorm:"type:uuid; primaryKey; not null; index"`
+	InvitedBy string `gorm:"type:uuid; not null"`
+	Role      string `gorm:"type:varchar(16); not null"` // constants.CoAuthorEditor or constants.CoAuthorViewer
+
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	AcceptedAt *time.Time
+}
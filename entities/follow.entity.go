@@ -0,0 +1,9 @@
+package entities
+
+import "time"
+
+type Follow struct {
+	FollowerID  string `gorm:"type:uuid; primaryKey; not null"`        // user who follows
+	FollowingID string `gorm:"type:uuid; primaryKey; not null; index"` // user being followed
+	CreatedAt   time.Time
+}
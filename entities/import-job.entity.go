@@ -0,0 +1,46 @@
+package entities
+
+import (
+	"time"
+
+	"resqiar.com-server/types"
+)
+
+// ImportJob imports the posts of an uploaded file in the background,
+// the file is kept until the job is finished so any instance can run it.
+// UserID requested the import, the blogs are created for AuthorID.
+type ImportJob struct {
+	ID        string `gorm:"type:uuid; primaryKey; default:gen_random_uuid()"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	UserID   string `gorm:"type:text; not null; index"`
+	AuthorID string `gorm:"type:text; not null"`
+	Format   string `gorm:"type:varchar(20); not null; default:markdown"`
+	DryRun   bool   `gorm:"type:bool; not null; default:false"`
+	Archive  []byte `gorm:"type:bytea" json:"-"`
+
+	Status    string            `gorm:"type:varchar(20); not null; default:pending"`
+	Total     int               `gorm:"type:int; not null; default:0"`
+	Processed int               `gorm:"type:int; not null; default:0"`
+	Created   int               `gorm:"type:int; not null; default:0"`
+	Failed    int               `gorm:"type:int; not null; default:0"`
+	Skipped   int               `gorm:"type:int; not null; default:0"`
+	Items     types.ImportItems `gorm:"type:jsonb"`
+	Error     string            `gorm:"type:text"`
+
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+}
+
+// ImportedPost remembers the blog a post was imported as for the author,
+// importing the same post again is skipped instead of duplicating it.
+// Other authors importing the same export get their own copy.
+type ImportedPost struct {
+	Source     string `gorm:"type:varchar(20); primaryKey"`
+	AuthorID   string `gorm:"type:text; primaryKey"`
+	ExternalID string `gorm:"type:text; primaryKey"`
+	CreatedAt  time.Time
+
+	BlogID string `gorm:"type:text; not null"`
+}
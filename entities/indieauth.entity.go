@@ -0,0 +1,33 @@
+package entities
+
+import "time"
+
+// IndieAuthCode is an authorization code given to a client the user approved,
+// only a hash of the code is stored and it can be exchanged once.
+type IndieAuthCode struct {
+	Code      string `gorm:"type:text; primaryKey"`
+	CreatedAt time.Time
+
+	UserID      string `gorm:"type:uuid; not null"`
+	ClientID    string `gorm:"type:text; not null"`
+	RedirectURI string `gorm:"type:text; not null"`
+	Scope       string `gorm:"type:text; not null"`
+
+	// PKCE challenge the client proves it owns when exchanging the code
+	CodeChallenge string `gorm:"type:text; not null"`
+
+	ExpiresAt time.Time `gorm:"not null"`
+}
+
+// AccessToken is a bearer token a client acts on behalf of the user with,
+// only a hash of the token is stored.
+type AccessToken struct {
+	Token     string `gorm:"type:text; primaryKey" json:"-"`
+	CreatedAt time.Time
+
+	UserID   string `gorm:"type:uuid; not null; index"`
+	ClientID string `gorm:"type:text; not null"`
+	Scope    string `gorm:"type:text; not null"` // space separated
+
+	ExpiresAt time.Time `gorm:"not null"`
+}
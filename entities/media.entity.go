@@ -0,0 +1,15 @@
+package entities
+
+import "time"
+
+// MediaFile is a file uploaded through the Micropub media endpoint,
+// posts link to it by its URL.
+type MediaFile struct {
+	ID        string `gorm:"type:uuid; primaryKey; default:gen_random_uuid()"`
+	CreatedAt time.Time
+
+	UserID      string `gorm:"type:uuid; not null; index"`
+	ContentType string `gorm:"type:varchar(50); not null"`
+	Size        int    `gorm:"type:int; not null"`
+	Data        []byte `gorm:"type:bytea; not null" json:"-"`
+}
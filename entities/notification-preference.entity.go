@@ -0,0 +1,21 @@
+package entities
+
+import "time"
+
+// NotificationPreference holds the email preferences of a user.
+// Users without a row receive every email, see DefaultNotificationPreference.
+type NotificationPreference struct {
+	UserID    string `gorm:"type:uuid; primaryKey; not null"`
+	UpdatedAt time.Time
+
+	EmailNewPost bool `gorm:"type:bool; not null"` // followed authors publish a new blog
+	EmailDigest  bool `gorm:"type:bool; not null"` // weekly digest of followed authors
+}
+
+func DefaultNotificationPreference(userID string) *NotificationPreference {
+	return &NotificationPreference{
+		UserID:       userID,
+		EmailNewPost: true,
+		EmailDigest:  true,
+	}
+}
@@ -0,0 +1,15 @@
+package entities
+
+import "time"
+
+type Notification struct {
+	ID        string `gorm:"type:uuid; primaryKey; default:gen_random_uuid()"`
+	CreatedAt time.Time
+
+	UserID  string `gorm:"type:uuid; not null; index"` // user who receives the notification
+	ActorID string `gorm:"type:uuid; not null"`        // user who triggered the notification
+	Type    string `gorm:"type:varchar(32); not null"`
+	BlogID  string `gorm:"type:text; nullable"`
+
+	Read bool `gorm:"type:bool; default:false"`
+}
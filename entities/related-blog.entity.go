@@ -0,0 +1,12 @@
+package entities
+
+import "time"
+
+type RelatedBlog struct {
+	BlogID    string `gorm:"type:text; primaryKey; not null"`
+	RelatedID string `gorm:"type:text; primaryKey; not null"`
+	CreatedAt time.Time
+
+	Score float64 `gorm:"type:double precision; not null"`
+	Rank  int     `gorm:"type:int; not null"`
+}
@@ -0,0 +1,29 @@
+package entities
+
+import "time"
+
+// BlogTransition records a blog moving from one state to another.
+type BlogTransition struct {
+	ID        string `gorm:"type:uuid; primaryKey; default:gen_random_uuid()"`
+	BlogID    string `gorm:"type:text; not null; index"`
+	ActorID   string `gorm:"type:uuid; not null"`
+	FromState string `gorm:"type:varchar(20); not null"`
+	ToState   string `gorm:"type:varchar(20); not null"`
+	Note      string `gorm:"type:text"`
+	CreatedAt time.Time
+}
+
+// ReviewNote is a reviewer's note on a range of lines of the blog Markdown,
+// the lines refer to the content at the given Version.
+type ReviewNote struct {
+	ID         string `gorm:"type:uuid; primaryKey; default:gen_random_uuid()"`
+	BlogID     string `gorm:"type:text; not null; index"`
+	ReviewerID string `gorm:"type:uuid; not null"`
+	Version    int    `gorm:"type:int; not null"`
+	StartLine  int    `gorm:"type:int; not null"`
+	EndLine    int    `gorm:"type:int; not null"`
+	Body       string `gorm:"type:text; not null"`
+
+	CreatedAt  time.Time
+	ResolvedAt *time.Time
+}
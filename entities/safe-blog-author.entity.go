@@ -3,4 +3,7 @@ package entities
 type SafeBlogAuthor struct {
 	SafeBlog
 	Author SafeUser
+
+	// Authors lists the author followed by the co-authors editing the blog
+	Authors []SafeUser
 }
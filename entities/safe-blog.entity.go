@@ -1,6 +1,10 @@
 package entities
 
-import "time"
+import (
+	"time"
+
+	"resqiar.com-server/types"
+)
 
 type SafeBlog struct {
 	ID          string
@@ -13,9 +17,19 @@ type SafeBlog struct {
 	Summary  string
 	Content  string
 	CoverURL string
+	Tags     types.Tags
+
+	ContentHTML     string `json:"-"`
+	RendererVersion int    `json:"-"`
+
+	// only loaded along with the content
+	TOC         types.TableOfContents `json:",omitempty"`
+	WordCount   int                   `json:",omitempty"`
+	ReadingTime int                   `json:",omitempty"`
 
 	Prev string
 	Next string
 
 	AuthorID string
+	Version  int
 }
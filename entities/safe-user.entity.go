@@ -21,4 +21,7 @@ type SafeUser struct {
 	YoutubeURL   string
 
 	IsTester bool
+
+	FollowerCount  int64
+	FollowingCount int64
 }
@@ -0,0 +1,35 @@
+package entities
+
+import "time"
+
+// Subscriber is an anonymous reader subscribed to the newsletter by email only.
+// An empty AuthorID means the reader subscribed to every author (site-wide).
+type Subscriber struct {
+	ID        string `gorm:"type:uuid; primaryKey; default:gen_random_uuid()"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Email    string `gorm:"type:varchar(100); not null; uniqueIndex:idx_subscriber_email_author"`
+	AuthorID string `gorm:"type:text; not null; default:''; uniqueIndex:idx_subscriber_email_author"`
+
+	// random token of the unsubscribe links, it only goes out once the subscription is confirmed
+	Token string `gorm:"type:varchar(64); not null; uniqueIndex"`
+
+	// random token of the confirmation link, replaced when subscribing again after unsubscribing
+	ConfirmToken       string     `gorm:"type:varchar(64); not null; default:''; index"`
+	ConfirmationSentAt *time.Time // when the last confirmation link was emailed
+
+	ConfirmedAt    *time.Time // nil until the double opt-in is confirmed
+	UnsubscribedAt *time.Time
+}
+
+// NewsletterIssue tracks the sending job of a published blog,
+// every blog is only ever sent once to the newsletter.
+type NewsletterIssue struct {
+	BlogID    string `gorm:"type:text; primaryKey; not null"`
+	CreatedAt time.Time
+	StartedAt *time.Time
+	SentAt    *time.Time
+
+	Recipients int `gorm:"type:int; default:0"`
+}
@@ -32,5 +32,8 @@ type User struct {
 	IsAdmin  bool `gorm:"type:bool; default:false"`
 	IsTester bool `gorm:"type:bool; default:false"`
 
+	// reviewers move blogs of other authors through the review, admins always can
+	IsReviewer bool `gorm:"type:bool; default:false"`
+
 	Blogs []Blog `gorm:"foreignKey:AuthorID"` // has many relationship with blog
 }
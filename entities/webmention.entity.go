@@ -0,0 +1,39 @@
+package entities
+
+import "time"
+
+// Webmention is a page of another site mentioning a published blog,
+// it is only displayed once the source was verified to link to the blog.
+type Webmention struct {
+	ID        string `gorm:"type:uuid; primaryKey; default:gen_random_uuid()"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Source     string `gorm:"type:text; not null; uniqueIndex:idx_webmention_source_target"`
+	Target     string `gorm:"type:text; not null; uniqueIndex:idx_webmention_source_target"`
+	SourceHost string `gorm:"type:text; not null; index"`
+	BlogID     string `gorm:"type:text; not null; index"`
+
+	// Title of the source page, shown along with the link
+	Title string `gorm:"type:text"`
+
+	Status        string    `gorm:"type:varchar(20); not null; default:pending"`
+	NextAttemptAt time.Time `gorm:"not null; index"`
+	Attempts      int       `gorm:"type:int; not null; default:0" json:"-"`
+	Error         string    `gorm:"type:text" json:"-"`
+
+	VerifiedAt *time.Time
+}
+
+// SentWebmention is a webmention sent for a link of a blog,
+// links removed by an edit are sent again for their page to drop the mention.
+type SentWebmention struct {
+	BlogID    string `gorm:"type:text; primaryKey"`
+	Target    string `gorm:"type:text; primaryKey"`
+	UpdatedAt time.Time
+
+	// Endpoint is empty when the target does not accept webmentions
+	Endpoint   string `gorm:"type:text"`
+	StatusCode int    `gorm:"type:int; not null; default:0"`
+	SentAt     time.Time
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"resqiar.com-server/constants"
+	"resqiar.com-server/services"
+	"resqiar.com-server/types"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ActivityPubHandler interface {
+	SendWebFinger(c *fiber.Ctx) error
+	SendActor(c *fiber.Ctx) error
+	SendOutbox(c *fiber.Ctx) error
+	SendFollowers(c *fiber.Ctx) error
+	SendArticle(c *fiber.Ctx) error
+	SendInbox(c *fiber.Ctx) error
+}
+
+type ActivityPubHandlerImpl struct {
+	ActivityPubService services.ActivityPubService
+}
+
+// SendWebFinger answers the lookups of remote servers, e.g. ?resource=acct:username@domain
+func (handler *ActivityPubHandlerImpl) SendWebFinger(c *fiber.Ctx) error {
+	finger, err := handler.ActivityPubService.GetWebFinger(c.Query("resource"))
+	if err != nil {
+		return sendActivityPubError(c, err)
+	}
+
+	return sendActivityJSON(c, "application/jrd+json", finger)
+}
+
+func (handler *ActivityPubHandlerImpl) SendActor(c *fiber.Ctx) error {
+	actor, err := handler.ActivityPubService.GetActor(c.Params("id"))
+	if err != nil {
+		return sendActivityPubError(c, err)
+	}
+
+	return sendActivityJSON(c, constants.ActivityPubContentType, actor)
+}
+
+func (handler *ActivityPubHandlerImpl) SendOutbox(c *fiber.Ctx) error {
+	outbox, err := handler.ActivityPubService.GetOutbox(c.Params("id"))
+	if err != nil {
+		return sendActivityPubError(c, err)
+	}
+
+	return sendActivityJSON(c, constants.ActivityPubContentType, outbox)
+}
+
+func (handler *ActivityPubHandlerImpl) SendFollowers(c *fiber.Ctx) error {
+	followers, err := handler.ActivityPubService.GetFollowers(c.Params("id"))
+	if err != nil {
+		return sendActivityPubError(c, err)
+	}
+
+	return sendActivityJSON(c, constants.ActivityPubContentType, followers)
+}
+
+func (handler *ActivityPubHandlerImpl) SendArticle(c *fiber.Ctx) error {
+	article, err := handler.ActivityPubService.GetArticle(c.Params("id"))
+	if err != nil {
+		// the blog is either missing or unpublished
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	return sendActivityJSON(c, constants.ActivityPubContentType, article)
+}
+
+func (handler *ActivityPubHandlerImpl) SendInbox(c *fiber.Ctx) error {
+	if len(c.Body()) > constants.ActivityPubMaxBodySize {
+		return c.SendStatus(fiber.StatusRequestEntityTooLarge)
+	}
+
+	header := make(http.Header)
+
+	c.Request().Header.VisitAll(func(key []byte, value []byte) {
+		header.Add(string(key), string(value))
+	})
+
+	// the host is part of the signature, whether the client sent it as a header or not
+	header.Set("Host", string(c.Request().Host()))
+
+	err := handler.ActivityPubService.ReceiveActivity(c.Params("id"), &types.SignedRequest{
+		Method: c.Method(),
+		Target: c.OriginalURL(),
+		Header: header,
+		Body:   c.Body(),
+	})
+	if err != nil {
+		return sendActivityPubError(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+func sendActivityJSON(c *fiber.Ctx, contentType string, document interface{}) error {
+	data, err := json.Marshal(document)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	c.Set("Content-Type", contentType)
+
+	return c.Status(fiber.StatusOK).Send(data)
+}
+
+func sendActivityPubError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrActorNotFound):
+		return c.SendStatus(fiber.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidSignature):
+		return c.SendStatus(fiber.StatusUnauthorized)
+	case errors.Is(err, services.ErrInvalidActivity):
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err.Error(),
+		})
+	default:
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+}
@@ -1,6 +1,11 @@
 package handlers
 
 import (
+	"errors"
+	"log"
+	"net/url"
+	"strconv"
+
 	"resqiar.com-server/constants"
 	"resqiar.com-server/inputs"
 	"resqiar.com-server/services"
@@ -23,11 +28,18 @@ type BlogHandler interface {
 	SendUnpublishBlog(c *fiber.Ctx) error
 	SendMyBlog(c *fiber.Ctx) error
 	SendUpdateBlog(c *fiber.Ctx) error
+	SendUpdateSlug(c *fiber.Ctx) error
+	SendRelatedBlogs(c *fiber.Ctx) error
+	SendFeed(c *fiber.Ctx) error
+	SendAutosave(c *fiber.Ctx) error
+	SendUnsavedAutosave(c *fiber.Ctx) error
 }
 
 type BlogHandlerImpl struct {
-	BlogService services.BlogService
-	UtilService services.UtilService
+	BlogService     services.BlogService
+	UtilService     services.UtilService
+	RelatedService  services.RelatedService
+	AutosaveService services.AutosaveService
 }
 
 func (handler *BlogHandlerImpl) SendBlogList(c *fiber.Ctx) error {
@@ -74,15 +86,33 @@ func (handler *BlogHandlerImpl) SendPublishedBlog(c *fiber.Ctx) error {
 	blogAuthor := c.Params("author")
 	blogSlug := c.Params("slug")
 
+	opts := &types.GetBlogOpts{
+		UseID:          "",
+		BlogAuthor:     blogAuthor,
+		BlogSlug:       blogSlug,
+		IncludeContent: true,
+		Published:      true,
+	}
+
+	// answer conditional requests from the cache
+	validator, err := handler.BlogService.GetBlogValidator(opts)
+	if err != nil {
+		// the blog may have been published under this slug before
+		current, err := handler.BlogService.GetSlugRedirect(blogAuthor, blogSlug)
+		if err != nil {
+			return c.SendStatus(fiber.StatusNotFound)
+		}
+
+		return c.Redirect("/blog/get/"+url.PathEscape(blogAuthor)+"/"+url.PathEscape(current), fiber.StatusMovedPermanently)
+	}
+
+	if handler.UtilService.CheckNotModified(c, validator, constants.CacheControlPublic) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
 	result, err := handler.BlogService.GetBlogDetail(&types.BlogDetailOpts{
-		GetBlogOpts: &types.GetBlogOpts{
-			UseID:          "",
-			BlogAuthor:     blogAuthor,
-			BlogSlug:       blogSlug,
-			IncludeContent: true,
-			Published:      true,
-		},
-		ReturnHTML: true,
+		GetBlogOpts: opts,
+		ReturnHTML:  true,
 	})
 
 	if err != nil {
@@ -102,6 +132,15 @@ func (handler *BlogHandlerImpl) SendPublishedBlogs(c *fiber.Ctx) error {
 		qOrder = string(constants.DESC)
 	}
 
+	validator, err := handler.BlogService.GetBlogsValidator("")
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	if handler.UtilService.CheckNotModified(c, validator, constants.CacheControlPublic, qOrder) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
 	// send only PUBLISHED and SAFE blogs
 	result, err := handler.BlogService.GetAllBlogs(true, constants.Order(qOrder))
 	if err != nil {
@@ -122,6 +161,15 @@ func (handler *BlogHandlerImpl) SendAuthorPublishedBlogs(c *fiber.Ctx) error {
 		qOrder = string(constants.DESC)
 	}
 
+	validator, err := handler.BlogService.GetBlogsValidator(author)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	if handler.UtilService.CheckNotModified(c, validator, constants.CacheControlPublic, qOrder) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
 	// send only PUBLISHED and SAFE blogs for specified author
 	result, err := handler.BlogService.GetAllUserBlogs(author, constants.Order(qOrder))
 	if err != nil {
@@ -158,6 +206,17 @@ func (handler *BlogHandlerImpl) SendBlogCreate(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 	}
 
+	// metadata in the front matter fills the fields left empty and is not stored in the content
+	matter, content, err := handler.UtilService.ParseFrontMatter(payload.Content)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	payload.Content = content
+	payload.ApplyFrontMatter(matter)
+
 	// validate the payload using class-validator
 	if err := handler.UtilService.ValidateInput(payload); err != "" {
 		return c.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
@@ -166,6 +225,11 @@ func (handler *BlogHandlerImpl) SendBlogCreate(c *fiber.Ctx) error {
 	}
 
 	result, err := handler.BlogService.CreateBlog(&payload, userID.(string))
+	if errors.Is(err, services.ErrSlugTaken) {
+		return c.Status(fiber.StatusConflict).JSON(&fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
 			"error": err,
@@ -284,6 +348,39 @@ func (handler *BlogHandlerImpl) SendUnpublishBlog(c *fiber.Ctx) error {
 	return c.SendStatus(fiber.StatusOK)
 }
 
+func (handler *BlogHandlerImpl) SendUpdateSlug(c *fiber.Ctx) error {
+	// get current user ID
+	userID := c.Locals("userID")
+
+	// define body payload
+	var payload inputs.UpdateSlugInput
+
+	// bind the body parser into payload
+	if err := c.BodyParser(&payload); err != nil {
+		// send raw error (unprocessable entity)
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	// validate the payload using class-validator
+	if err := handler.UtilService.ValidateInput(payload); err != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err,
+		})
+	}
+
+	err := handler.BlogService.UpdateSlug(&payload, userID.(string))
+	if errors.Is(err, services.ErrSlugTaken) {
+		return c.Status(fiber.StatusConflict).JSON(&fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
 func (handler *BlogHandlerImpl) SendMyBlog(c *fiber.Ctx) error {
 	// get current user ID
 	userID := c.Locals("userID")
@@ -318,12 +415,14 @@ func (handler *BlogHandlerImpl) SendMyBlog(c *fiber.Ctx) error {
 		return c.SendStatus(fiber.StatusNotFound)
 	}
 
-	// if current requested blog is not the same author
-	// as the one who request, return 404
-	if blog.Author.ID != userID {
+	// if the one who request can not edit the blog, return 404
+	if !handler.BlogService.CanEditBlog(blog.ID, userID.(string)) {
 		return c.SendStatus(fiber.StatusNotFound)
 	}
 
+	// the client sends it back as If-Match when updating
+	c.Set(fiber.HeaderETag, handler.UtilService.VersionETag(blog.Version))
+
 	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
 		"result": blog,
 	})
@@ -342,6 +441,17 @@ func (handler *BlogHandlerImpl) SendUpdateBlog(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 	}
 
+	// metadata in the front matter fills the fields left empty and is not stored in the content
+	matter, content, err := handler.UtilService.ParseFrontMatter(payload.Content)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	payload.Content = content
+	payload.ApplyFrontMatter(matter)
+
 	// validate the payload using class-validator
 	if err := handler.UtilService.ValidateInput(payload); err != "" {
 		return c.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
@@ -349,10 +459,166 @@ func (handler *BlogHandlerImpl) SendUpdateBlog(c *fiber.Ctx) error {
 		})
 	}
 
-	err := handler.BlogService.EditBlog(&payload, userID.(string))
+	// the version can be given in the body or as If-Match
+	if payload.Version == 0 {
+		payload.Version = handler.UtilService.ParseVersionETag(c.Get(fiber.HeaderIfMatch))
+	}
+
+	if payload.Version == 0 {
+		return c.Status(fiber.StatusPreconditionRequired).JSON(&fiber.Map{
+			"error": "Version field is required",
+		})
+	}
+
+	err = handler.BlogService.EditBlog(&payload, userID.(string))
+	if errors.Is(err, services.ErrVersionConflict) {
+		// send the current blog back so the client can merge
+		current, err := handler.BlogService.GetBlogDetail(&types.BlogDetailOpts{
+			GetBlogOpts: &types.GetBlogOpts{
+				UseID:          payload.ID,
+				IncludeContent: true,
+				Published:      false,
+			},
+			ReturnHTML: false,
+		})
+		if err != nil {
+			return c.SendStatus(fiber.StatusNotFound)
+		}
+
+		c.Set(fiber.HeaderETag, handler.UtilService.VersionETag(current.Version))
+
+		return c.Status(fiber.StatusConflict).JSON(&fiber.Map{
+			"error":  services.ErrVersionConflict.Error(),
+			"result": current,
+		})
+	}
+	if errors.Is(err, services.ErrSlugTaken) {
+		return c.Status(fiber.StatusConflict).JSON(&fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	if err != nil {
 		return c.SendStatus(fiber.StatusNotFound)
 	}
 
-	return c.SendStatus(fiber.StatusOK)
+	// the explicit save supersedes any pending autosave
+	if err := handler.AutosaveService.DiscardAutosave(payload.ID, userID.(string)); err != nil {
+		log.Println("Error discarding autosave:", err)
+	}
+
+	// every successful edit bumps the version by one
+	c.Set(fiber.HeaderETag, handler.UtilService.VersionETag(payload.Version+1))
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"result": &fiber.Map{
+			"Version": payload.Version + 1,
+		},
+	})
+}
+
+func (handler *BlogHandlerImpl) SendRelatedBlogs(c *fiber.Ctx) error {
+	blogAuthor := c.Params("author")
+	blogSlug := c.Params("slug")
+
+	result, err := handler.RelatedService.GetRelatedBlogs(blogAuthor, blogSlug)
+	if err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"result": result,
+	})
+}
+
+func (handler *BlogHandlerImpl) SendFeed(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	cursor := c.Query("cursor")
+	limit := c.QueryInt("limit", constants.DefaultFeedLimit)
+
+	// if limit is out of range, set to default value
+	if limit <= 0 || limit > constants.MaxFeedLimit {
+		limit = constants.DefaultFeedLimit
+	}
+
+	validator, err := handler.BlogService.GetFeedValidator(userID.(string), cursor, limit)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	// the feed is personal, it must never be shared between users
+	if handler.UtilService.CheckNotModified(c, validator, constants.CacheControlPrivate, userID.(string), cursor, strconv.Itoa(limit)) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	result, err := handler.BlogService.GetFeed(userID.(string), cursor, limit)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"result": result.Blogs,
+		"next":   result.NextCursor,
+	})
+}
+
+func (handler *BlogHandlerImpl) SendAutosave(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	// define body payload
+	var payload inputs.AutosaveBlogInput
+
+	// bind the body parser into payload
+	if err := c.BodyParser(&payload); err != nil {
+		// send raw error (unprocessable entity)
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	// validate the payload using class-validator
+	if err := handler.UtilService.ValidateInput(payload); err != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err,
+		})
+	}
+
+	result, err := handler.AutosaveService.Autosave(&payload, userID.(string))
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"result": &fiber.Map{
+			"SavedAt": result.SavedAt,
+			"Version": result.Base,
+		},
+	})
+}
+
+func (handler *BlogHandlerImpl) SendUnsavedAutosave(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	// define body payload
+	var payload inputs.BlogIDInput
+
+	// bind the body parser into payload
+	if err := c.BodyParser(&payload); err != nil {
+		// send raw error (unprocessable entity)
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	// validate the payload using class-validator
+	if err := handler.UtilService.ValidateInput(payload); err != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err,
+		})
+	}
+
+	result, err := handler.AutosaveService.GetUnsavedAutosave(payload.ID, userID.(string))
+	if err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"result": result,
+	})
 }
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"resqiar.com-server/inputs"
+	"resqiar.com-server/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CoAuthorHandler interface {
+	SendInvite(c *fiber.Ctx) error
+	SendAcceptInvitation(c *fiber.Ctx) error
+	SendDeclineInvitation(c *fiber.Ctx) error
+	SendRemoveCoAuthor(c *fiber.Ctx) error
+	SendCoAuthors(c *fiber.Ctx) error
+	SendInvitations(c *fiber.Ctx) error
+}
+
+type CoAuthorHandlerImpl struct {
+	CoAuthorService services.CoAuthorService
+	UtilService     services.UtilService
+}
+
+func (handler *CoAuthorHandlerImpl) SendInvite(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	// define body payload
+	var payload inputs.InviteCoAuthorInput
+
+	// bind the body parser into payload
+	if err := c.BodyParser(&payload); err != nil {
+		// send raw error (unprocessable entity)
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	// validate the payload using class-validator
+	if err := handler.UtilService.ValidateInput(payload); err != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err,
+		})
+	}
+
+	if err := handler.CoAuthorService.Invite(&payload, userID.(string)); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func (handler *CoAuthorHandlerImpl) SendAcceptInvitation(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	// define body payload
+	var payload inputs.BlogIDInput
+
+	// bind the body parser into payload
+	if err := c.BodyParser(&payload); err != nil {
+		// send raw error (unprocessable entity)
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	// validate the payload using class-validator
+	if err := handler.UtilService.ValidateInput(payload); err != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err,
+		})
+	}
+
+	if err := handler.CoAuthorService.AcceptInvitation(payload.ID, userID.(string)); err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func (handler *CoAuthorHandlerImpl) SendDeclineInvitation(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	// define body payload
+	var payload inputs.BlogIDInput
+
+	// bind the body parser into payload
+	if err := c.BodyParser(&payload); err != nil {
+		// send raw error (unprocessable entity)
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	// validate the payload using class-validator
+	if err := handler.UtilService.ValidateInput(payload); err != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err,
+		})
+	}
+
+	if err := handler.CoAuthorService.DeclineInvitation(payload.ID, userID.(string)); err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func (handler *CoAuthorHandlerImpl) SendRemoveCoAuthor(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	// define body payload
+	var payload inputs.RemoveCoAuthorInput
+
+	// bind the body parser into payload
+	if err := c.BodyParser(&payload); err != nil {
+		// send raw error (unprocessable entity)
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	// validate the payload using class-validator
+	if err := handler.UtilService.ValidateInput(payload); err != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err,
+		})
+	}
+
+	if err := handler.CoAuthorService.RemoveCoAuthor(&payload, userID.(string)); err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func (handler *CoAuthorHandlerImpl) SendCoAuthors(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	// define body payload
+	var payload inputs.BlogIDInput
+
+	// bind the body parser into payload
+	if err := c.BodyParser(&payload); err != nil {
+		// send raw error (unprocessable entity)
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	// validate the payload using class-validator
+	if err := handler.UtilService.ValidateInput(payload); err != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err,
+		})
+	}
+
+	result, err := handler.CoAuthorService.GetCoAuthors(payload.ID, userID.(string))
+	if err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"result": result,
+	})
+}
+
+func (handler *CoAuthorHandlerImpl) SendInvitations(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	result, err := handler.CoAuthorService.GetInvitations(userID.(string))
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"result": result,
+	})
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"resqiar.com-server/constants"
+	"resqiar.com-server/services"
+	"resqiar.com-server/types"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+type CollabHandler interface {
+	SendCollabUpgrade(c *fiber.Ctx) error
+	SendCollabSession(conn *websocket.Conn)
+}
+
+type CollabHandlerImpl struct {
+	CollabService services.CollabService
+}
+
+// SendCollabUpgrade only lets WebSocket handshakes through to the session.
+func (handler *CollabHandlerImpl) SendCollabUpgrade(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return c.SendStatus(fiber.StatusUpgradeRequired)
+	}
+
+	return c.Next()
+}
+
+// SendCollabSession connects the current user to the collaborative editing session of the blog,
+// messages are JSON encoded types.CollabMessage in both directions.
+func (handler *CollabHandlerImpl) SendCollabSession(conn *websocket.Conn) {
+	userID := conn.Locals("userID").(string)
+
+	client, err := handler.CollabService.Join(conn.Params("id"), userID)
+	if err != nil {
+		conn.WriteJSON(&types.CollabMessage{
+			Type:  constants.CollabMessageError,
+			Error: err.Error(),
+		})
+
+		return
+	}
+
+	// the connection is only ever written to from here
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		defer conn.Close()
+
+		ping := time.NewTicker(constants.CollabPingInterval)
+		defer ping.Stop()
+
+		for {
+			select {
+			case message, ok := <-client.Messages:
+				// left, or too slow to keep up with the session
+				if !ok {
+					return
+				}
+
+				if err := conn.WriteJSON(&message); err != nil {
+					return
+				}
+			case <-ping.C:
+				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	// a client answering neither messages nor pings is gone
+	deadline := func() error {
+		return conn.SetReadDeadline(time.Now().Add(2 * constants.CollabPingInterval))
+	}
+
+	deadline()
+	conn.SetPongHandler(func(string) error {
+		return deadline()
+	})
+	conn.SetReadLimit(constants.CollabMaxMessageSize)
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+
+		deadline()
+
+		var message types.CollabMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			client.SendError(errors.New("Message is not valid JSON"))
+			continue
+		}
+
+		client.Handle(&message)
+	}
+
+	client.Leave()
+	<-done
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"net/url"
+
+	"resqiar.com-server/dto"
+	"resqiar.com-server/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type EpubHandler interface {
+	SendAuthorEpub(c *fiber.Ctx) error
+	SendTagEpub(c *fiber.Ctx) error
+	SendSeriesEpub(c *fiber.Ctx) error
+}
+
+type EpubHandlerImpl struct {
+	EpubService services.EpubService
+}
+
+func (handler *EpubHandlerImpl) SendAuthorEpub(c *fiber.Ctx) error {
+	book, err := handler.EpubService.GetAuthorEpub(c.Params("author"))
+
+	return sendEpub(c, book, err)
+}
+
+func (handler *EpubHandlerImpl) SendTagEpub(c *fiber.Ctx) error {
+	// tags may contain anything, they arrive escaped
+	tag, err := url.PathUnescape(c.Params("tag"))
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	book, err := handler.EpubService.GetTagEpub(tag)
+
+	return sendEpub(c, book, err)
+}
+
+// SendSeriesEpub sends the series the blog is part of, whichever blog of it is given.
+func (handler *EpubHandlerImpl) SendSeriesEpub(c *fiber.Ctx) error {
+	book, err := handler.EpubService.GetSeriesEpub(c.Params("id"))
+	if err != nil {
+		// the blog is either missing or unpublished
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	return sendEpub(c, book, nil)
+}
+
+func sendEpub(c *fiber.Ctx, book *dto.EpubOutput, err error) error {
+	if errors.Is(err, services.ErrEmptyCollection) {
+		return c.Status(fiber.StatusNotFound).JSON(&fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	c.Set("Content-Type", "application/epub+zip")
+	c.Set("Content-Disposition", `attachment; filename="`+book.FileName+`"`)
+
+	return c.Status(fiber.StatusOK).Send(book.Data)
+}
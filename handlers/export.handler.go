@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"bufio"
+	"log"
+	"net/url"
+
+	"resqiar.com-server/constants"
+	"resqiar.com-server/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+type ExportHandler interface {
+	SendExport(c *fiber.Ctx) error
+}
+
+type ExportHandlerImpl struct {
+	ExportService services.ExportService
+}
+
+// SendExport streams a zip archive of every blog of the user,
+// the "Format" query picks Markdown files or a static site whose feeds link to the "BaseURL" query.
+func (handler *ExportHandlerImpl) SendExport(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	format := c.Query("Format", constants.ExportMarkdown)
+	baseURL := c.Query("BaseURL")
+
+	if format != constants.ExportMarkdown && format != constants.ExportSite {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": "Format must be either markdown or site",
+		})
+	}
+
+	// the feeds of the site link to it, only absolute http(s) URLs make sense there
+	if baseURL != "" {
+		parsed, err := url.Parse(baseURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+				"error": "BaseURL must be an absolute http or https URL",
+			})
+		}
+	}
+
+	export, err := handler.ExportService.GetExport(userID.(string))
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	c.Set("Content-Type", "application/zip")
+	c.Set("Content-Disposition", `attachment; filename="`+export.Author.Username+"-"+format+`.zip"`)
+	c.Set("Cache-Control", "no-store")
+
+	// fiber context must not be used inside the stream writer
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		var err error
+
+		if format == constants.ExportSite {
+			err = handler.ExportService.WriteSite(w, export, baseURL)
+		} else {
+			err = handler.ExportService.WriteMarkdown(w, export)
+		}
+
+		// the status is long gone, the client is left with a broken archive
+		if err != nil {
+			log.Println("Error writing export:", err)
+		}
+	}))
+
+	return nil
+}
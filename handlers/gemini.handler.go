@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"resqiar.com-server/constants"
+	"resqiar.com-server/services"
+	"resqiar.com-server/types"
+)
+
+// GeminiHandler answers the requests of the Gemini capsule,
+// unlike the other handlers it is not served by Fiber.
+type GeminiHandler interface {
+	ServeGemini(path string) *types.GeminiResponse
+}
+
+type GeminiHandlerImpl struct {
+	GeminiService services.GeminiService
+	BlogService   services.BlogService
+}
+
+// ServeGemini routes the path of the requested URL, paths mirror the ones of the website:
+// /, /atom.xml, /blog/<author>/, /blog/<author>/atom.xml and /blog/<author>/<slug>
+func (handler *GeminiHandlerImpl) ServeGemini(path string) *types.GeminiResponse {
+	if path == "" || path == "/" {
+		page, err := handler.GeminiService.GetIndexPage()
+
+		return sendGemtext(page, err)
+	}
+
+	if path == "/atom.xml" {
+		feed, err := handler.GeminiService.GetFeed("")
+
+		return sendGeminiFeed(feed, err)
+	}
+
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if segments[0] != "blog" || len(segments) < 2 || len(segments) > 3 {
+		return &types.GeminiResponse{Status: constants.GeminiNotFound, Meta: "Not found"}
+	}
+
+	for i, segment := range segments {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			return &types.GeminiResponse{Status: constants.GeminiBadRequest, Meta: "Bad request"}
+		}
+
+		segments[i] = unescaped
+	}
+
+	username := segments[1]
+
+	switch {
+	case len(segments) == 2 || segments[2] == "":
+		page, err := handler.GeminiService.GetAuthorPage(username)
+
+		return sendGemtext(page, err)
+	case segments[2] == "atom.xml":
+		feed, err := handler.GeminiService.GetFeed(username)
+
+		return sendGeminiFeed(feed, err)
+	}
+
+	slug := segments[2]
+
+	page, err := handler.GeminiService.GetBlogPage(username, slug)
+	if err != nil && err.Error() == "404" {
+		// the blog may have been renamed since the link was shared
+		if current, err := handler.BlogService.GetSlugRedirect(username, slug); err == nil && current != "" {
+			return &types.GeminiResponse{
+				Status: constants.GeminiRedirect,
+				Meta:   "/blog/" + url.PathEscape(username) + "/" + url.PathEscape(current),
+			}
+		}
+	}
+
+	return sendGemtext(page, err)
+}
+
+func sendGemtext(page string, err error) *types.GeminiResponse {
+	if errors.Is(err, services.ErrAuthorNotFound) || (err != nil && err.Error() == "404") {
+		return &types.GeminiResponse{Status: constants.GeminiNotFound, Meta: "Not found"}
+	}
+	if err != nil {
+		return &types.GeminiResponse{Status: constants.GeminiTemporaryFailure, Meta: "Temporary failure"}
+	}
+
+	return &types.GeminiResponse{
+		Status: constants.GeminiSuccess,
+		Meta:   constants.GeminiMimeType,
+		Body:   []byte(page),
+	}
+}
+
+func sendGeminiFeed(feed []byte, err error) *types.GeminiResponse {
+	if err != nil {
+		return sendGemtext("", err)
+	}
+
+	return &types.GeminiResponse{
+		Status: constants.GeminiSuccess,
+		Meta:   "application/atom+xml",
+		Body:   feed,
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"strconv"
+
+	"resqiar.com-server/constants"
+	"resqiar.com-server/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ImportHandler interface {
+	SendCreateImport(c *fiber.Ctx) error
+	SendImport(c *fiber.Ctx) error
+	SendCreateWordPressImport(c *fiber.Ctx) error
+}
+
+type ImportHandlerImpl struct {
+	ImportService services.ImportService
+}
+
+// SendCreateImport queues the zip archive uploaded as the "archive" form file,
+// the "DryRun" form value only reports what would be created.
+func (handler *ImportHandlerImpl) SendCreateImport(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	header, err := c.FormFile("archive")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": "Archive field is required",
+		})
+	}
+
+	if header.Size > constants.ImportMaxArchiveSize {
+		return c.SendStatus(fiber.StatusRequestEntityTooLarge)
+	}
+
+	dryRun, _ := strconv.ParseBool(c.FormValue("DryRun"))
+
+	file, err := header.Open()
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	defer file.Close()
+
+	archive, err := io.ReadAll(file)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	job, err := handler.ImportService.CreateImport(archive, userID.(string), dryRun)
+	if errors.Is(err, services.ErrInvalidArchive) || errors.Is(err, services.ErrEmptyArchive) || errors.Is(err, services.ErrTooManyFiles) {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	// the import runs in the background, its progress is polled through SendImport
+	return c.Status(fiber.StatusAccepted).JSON(&fiber.Map{
+		"result": job,
+	})
+}
+
+// SendCreateWordPressImport queues the WordPress export uploaded as the "export" form file,
+// the posts are imported as blogs of the "Username" form value.
+func (handler *ImportHandlerImpl) SendCreateWordPressImport(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	header, err := c.FormFile("export")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": "Export field is required",
+		})
+	}
+
+	if header.Size > constants.ImportMaxArchiveSize {
+		return c.SendStatus(fiber.StatusRequestEntityTooLarge)
+	}
+
+	dryRun, _ := strconv.ParseBool(c.FormValue("DryRun"))
+
+	file, err := header.Open()
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	defer file.Close()
+
+	export, err := io.ReadAll(file)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	job, err := handler.ImportService.CreateWordPressImport(export, userID.(string), c.FormValue("Username"), dryRun)
+	if errors.Is(err, services.ErrInvalidExport) || errors.Is(err, services.ErrEmptyExport) || errors.Is(err, services.ErrTooManyPosts) {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if errors.Is(err, services.ErrAuthorNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(&fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(&fiber.Map{
+		"result": job,
+	})
+}
+
+func (handler *ImportHandlerImpl) SendImport(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	job, err := handler.ImportService.GetImport(c.Params("id"), userID.(string))
+	if err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"result": job,
+	})
+}
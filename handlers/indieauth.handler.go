@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+
+	"resqiar.com-server/inputs"
+	"resqiar.com-server/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type IndieAuthHandler interface {
+	SendMetadata(c *fiber.Ctx) error
+	SendProfile(c *fiber.Ctx) error
+	SendAuthorization(c *fiber.Ctx) error
+	SendApproveAuthorization(c *fiber.Ctx) error
+	SendToken(c *fiber.Ctx) error
+	SendVerifyToken(c *fiber.Ctx) error
+	SendRevokeToken(c *fiber.Ctx) error
+}
+
+type IndieAuthHandlerImpl struct {
+	IndieAuthService services.IndieAuthService
+}
+
+func (handler *IndieAuthHandlerImpl) SendMetadata(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(handler.IndieAuthService.GetMetadata())
+}
+
+// SendProfile is the page clients discover the endpoints of the user from,
+// both as Link headers and as link elements for the clients reading the HTML only.
+func (handler *IndieAuthHandlerImpl) SendProfile(c *fiber.Ctx) error {
+	profile, err := handler.IndieAuthService.GetProfile(c.Params("username"))
+	if err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	links := [][2]string{
+		{"indieauth-metadata", profile.Metadata},
+		{"authorization_endpoint", profile.AuthorizationEndpoint},
+		{"token_endpoint", profile.TokenEndpoint},
+		{"micropub", profile.Micropub},
+	}
+
+	var header []string
+	var body strings.Builder
+
+	body.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+
+	for _, link := range links {
+		header = append(header, fmt.Sprintf("<%s>; rel=\"%s\"", link[1], link[0]))
+		fmt.Fprintf(&body, "<link rel=\"%s\" href=\"%s\">\n", link[0], html.EscapeString(link[1]))
+	}
+
+	fmt.Fprintf(&body, "<title>%s</title>\n</head>\n<body>\n", html.EscapeString(profile.Name))
+	fmt.Fprintf(&body, "<a class=\"h-card\" rel=\"me\" href=\"%s\">%s</a>\n", html.EscapeString(profile.ProfileURL), html.EscapeString(profile.Name))
+	body.WriteString("</body>\n</html>\n")
+
+	c.Set(fiber.HeaderLink, strings.Join(header, ", "))
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+
+	return c.Status(fiber.StatusOK).SendString(body.String())
+}
+
+// SendAuthorization checks the authorization request the consent page of the client received,
+// the page shows the result to the signed in user before approving it.
+func (handler *IndieAuthHandlerImpl) SendAuthorization(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	var payload inputs.AuthorizationInput
+
+	if err := c.QueryParser(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	prompt, err := handler.IndieAuthService.ValidateAuthorization(&payload, userID.(string))
+	if err != nil {
+		return sendOAuthError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"result": prompt,
+	})
+}
+
+// SendApproveAuthorization issues the authorization code for the scopes the user granted,
+// the consent page sends the user to the returned redirect URI.
+func (handler *IndieAuthHandlerImpl) SendApproveAuthorization(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	var payload inputs.AuthorizationInput
+
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	redirect, err := handler.IndieAuthService.Authorize(&payload, userID.(string))
+	if err != nil {
+		return sendOAuthError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"result": redirect,
+	})
+}
+
+func (handler *IndieAuthHandlerImpl) SendToken(c *fiber.Ctx) error {
+	var payload inputs.TokenInput
+
+	if err := c.BodyParser(&payload); err != nil {
+		return sendOAuthError(c, services.ErrInvalidAuthorization)
+	}
+
+	token, err := handler.IndieAuthService.ExchangeCode(&payload)
+	if err != nil {
+		return sendOAuthError(c, err)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	return c.Status(fiber.StatusOK).JSON(token)
+}
+
+// SendVerifyToken tells who the bearer token belongs to, for clients verifying their token.
+func (handler *IndieAuthHandlerImpl) SendVerifyToken(c *fiber.Ctx) error {
+	token, _ := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+
+	verified, err := handler.IndieAuthService.VerifyToken(token)
+	if err != nil {
+		return sendOAuthError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(verified)
+}
+
+func (handler *IndieAuthHandlerImpl) SendRevokeToken(c *fiber.Ctx) error {
+	if err := handler.IndieAuthService.RevokeToken(c.FormValue("token")); err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// sendOAuthError answers with the error code of OAuth along with its description.
+func sendOAuthError(c *fiber.Ctx, err error) error {
+	var status int
+	var code string
+
+	switch {
+	case errors.Is(err, services.ErrInvalidAuthorization):
+		status, code = fiber.StatusBadRequest, "invalid_request"
+	case errors.Is(err, services.ErrInvalidScope):
+		status, code = fiber.StatusBadRequest, "invalid_scope"
+	case errors.Is(err, services.ErrInvalidGrant):
+		status, code = fiber.StatusBadRequest, "invalid_grant"
+	case errors.Is(err, services.ErrInvalidToken):
+		status, code = fiber.StatusUnauthorized, "invalid_token"
+	default:
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.Status(status).JSON(&fiber.Map{
+		"error":             code,
+		"error_description": err.Error(),
+	})
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"html/template"
+
+	"resqiar.com-server/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(
+	`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Unsubscribe</title></head>
+<body>
+<form method="POST" action="?token={{.}}">
+<p>Do you want to stop receiving these emails?</p>
+<button type="submit">Unsubscribe</button>
+</form>
+</body>
+</html>
+`))
+
+type MailHandler interface {
+	SendUnsubscribePage(c *fiber.Ctx) error
+	SendUnsubscribe(c *fiber.Ctx) error
+}
+
+type MailHandlerImpl struct {
+	MailService services.MailService
+}
+
+// SendUnsubscribePage only asks for confirmation, link scanners
+// prefetching the unsubscribe URL must not unsubscribe anyone.
+func (handler *MailHandlerImpl) SendUnsubscribePage(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	c.Type("html")
+	return unsubscribePage.Execute(c, token)
+}
+
+// SendUnsubscribe handles both the confirmation form and
+// the one-click unsubscribe POST from mail clients (RFC 8058).
+func (handler *MailHandlerImpl) SendUnsubscribe(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	if err := handler.MailService.Unsubscribe(token); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).SendString("You have been unsubscribed")
+}
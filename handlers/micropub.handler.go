@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"strings"
+
+	"resqiar.com-server/constants"
+	"resqiar.com-server/services"
+	"resqiar.com-server/types"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type MicropubHandler interface {
+	SendQuery(c *fiber.Ctx) error
+	SendMicropub(c *fiber.Ctx) error
+	SendUploadMedia(c *fiber.Ctx) error
+	SendMedia(c *fiber.Ctx) error
+	SendPost(c *fiber.Ctx) error
+}
+
+type MicropubHandlerImpl struct {
+	MicropubService services.MicropubService
+}
+
+// SendQuery answers ?q=config, ?q=source&url=... and ?q=syndicate-to
+func (handler *MicropubHandlerImpl) SendQuery(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	switch c.Query("q") {
+	case "config":
+		return c.Status(fiber.StatusOK).JSON(handler.MicropubService.GetConfig())
+
+	case "syndicate-to":
+		return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+			"syndicate-to": []interface{}{},
+		})
+
+	case "source":
+		var properties []string
+
+		c.Context().QueryArgs().VisitAll(func(key []byte, value []byte) {
+			if name := string(key); name == "properties" || name == "properties[]" {
+				properties = append(properties, string(value))
+			}
+		})
+
+		source, err := handler.MicropubService.GetSource(c.Query("url"), properties, userID.(string))
+		if err != nil {
+			return sendMicropubError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(source)
+
+	default:
+		return sendMicropubError(c, services.ErrInvalidMicropubRequest)
+	}
+}
+
+// SendMicropub creates, updates, deletes or undeletes a post,
+// the request is either JSON or form encoded with photos possibly uploaded along.
+func (handler *MicropubHandlerImpl) SendMicropub(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	scope, _ := c.Locals("scope").(string)
+
+	request, err := handler.parseRequest(c, userID, scope)
+	if err != nil {
+		return sendMicropubError(c, err)
+	}
+
+	switch request.Action {
+	case "":
+		result, err := handler.MicropubService.CreatePost(request, userID, scope)
+		if err != nil {
+			return sendMicropubError(c, err)
+		}
+
+		return sendMicropubResult(c, result, fiber.StatusCreated)
+
+	case "update":
+		result, err := handler.MicropubService.UpdatePost(request, userID, scope)
+		if err != nil {
+			return sendMicropubError(c, err)
+		}
+
+		// a new slug moves the post
+		if result.URL != request.URL {
+			return sendMicropubResult(c, result, fiber.StatusCreated)
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+
+	case "delete":
+		if err := handler.MicropubService.DeletePost(request.URL, userID, scope); err != nil {
+			return sendMicropubError(c, err)
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+
+	case "undelete":
+		result, err := handler.MicropubService.UndeletePost(request.URL, userID, scope)
+		if err != nil {
+			return sendMicropubError(c, err)
+		}
+
+		return sendMicropubResult(c, result, fiber.StatusOK)
+
+	default:
+		return sendMicropubError(c, services.ErrInvalidMicropubRequest)
+	}
+}
+
+// SendUploadMedia stores the "file" form file and answers with its URL as Location.
+func (handler *MicropubHandlerImpl) SendUploadMedia(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+	scope, _ := c.Locals("scope").(string)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error":             "invalid_request",
+			"error_description": "File field is required",
+		})
+	}
+
+	location, err := handler.uploadFile(header, userID.(string), scope)
+	if err != nil {
+		return sendMicropubError(c, err)
+	}
+
+	c.Location(location)
+
+	return c.SendStatus(fiber.StatusCreated)
+}
+
+func (handler *MicropubHandlerImpl) SendMedia(c *fiber.Ctx) error {
+	media, err := handler.MicropubService.GetMedia(c.Params("id"))
+	if err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	// media never change, a new upload gets a new URL
+	c.Set(fiber.HeaderContentType, media.ContentType)
+	c.Set(fiber.HeaderCacheControl, "public, max-age=31536000, immutable")
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+
+	return c.Status(fiber.StatusOK).Send(media.Data)
+}
+
+// SendPost sends the URL of a post given while it was a draft to its page once published.
+func (handler *MicropubHandlerImpl) SendPost(c *fiber.Ctx) error {
+	location, err := handler.MicropubService.GetPostURL(c.Params("id"))
+	if err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	return c.Redirect(location, fiber.StatusFound)
+}
+
+func (handler *MicropubHandlerImpl) parseRequest(c *fiber.Ctx, userID string, scope string) (*types.MicropubRequest, error) {
+	request := types.MicropubRequest{}
+
+	if c.Is("json") {
+		if err := json.Unmarshal(c.Body(), &request); err != nil {
+			return nil, services.ErrInvalidMicropubRequest
+		}
+
+		return &request, nil
+	}
+
+	values := map[string][]string{}
+	var files []*multipart.FileHeader
+
+	if form, err := c.MultipartForm(); err == nil {
+		values = form.Value
+		files = append(form.File["photo"], form.File["photo[]"]...)
+	} else {
+		c.Request().PostArgs().VisitAll(func(key []byte, value []byte) {
+			values[string(key)] = append(values[string(key)], string(value))
+		})
+	}
+
+	request.Properties = map[string][]interface{}{}
+
+	for key, list := range values {
+		// properties with multiple values are sent as name[]
+		name := strings.TrimSuffix(key, "[]")
+
+		switch name {
+		case "access_token":
+		case "h":
+			request.Type = []string{"h-" + list[0]}
+		case "action":
+			request.Action = list[0]
+		case "url":
+			request.URL = list[0]
+		default:
+			for _, value := range list {
+				request.Properties[name] = append(request.Properties[name], value)
+			}
+		}
+	}
+
+	if len(files) > constants.MicropubMaxPhotos {
+		return nil, services.ErrInvalidMicropubRequest
+	}
+
+	for _, header := range files {
+		location, err := handler.uploadFile(header, userID, scope)
+		if err != nil {
+			return nil, err
+		}
+
+		request.Properties["photo"] = append(request.Properties["photo"], location)
+	}
+
+	return &request, nil
+}
+
+func (handler *MicropubHandlerImpl) uploadFile(header *multipart.FileHeader, userID string, scope string) (string, error) {
+	if header.Size > constants.MicropubMaxMediaSize {
+		return "", services.ErrMediaTooLarge
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+
+	return handler.MicropubService.UploadMedia(data, userID, scope)
+}
+
+// sendMicropubResult answers with the URL of the post as Location.
+func sendMicropubResult(c *fiber.Ctx, result *types.MicropubResult, status int) error {
+	c.Location(result.URL)
+
+	return c.SendStatus(status)
+}
+
+func sendMicropubError(c *fiber.Ctx, err error) error {
+	var status int
+	var code string
+
+	switch {
+	case errors.Is(err, services.ErrInvalidMicropubRequest),
+		errors.Is(err, services.ErrPostNotFound),
+		errors.Is(err, services.ErrSlugTaken),
+		errors.Is(err, services.ErrVersionConflict),
+		errors.Is(err, services.ErrInvalidTransition),
+		errors.Is(err, services.ErrApprovalOutdated):
+		status, code = fiber.StatusBadRequest, "invalid_request"
+	case errors.Is(err, services.ErrInsufficientScope):
+		status, code = fiber.StatusForbidden, "insufficient_scope"
+	case errors.Is(err, services.ErrUnsupportedMedia):
+		status, code = fiber.StatusUnsupportedMediaType, "invalid_request"
+	case errors.Is(err, services.ErrMediaTooLarge):
+		status, code = fiber.StatusRequestEntityTooLarge, "invalid_request"
+	default:
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.Status(status).JSON(&fiber.Map{
+		"error":             code,
+		"error_description": err.Error(),
+	})
+}
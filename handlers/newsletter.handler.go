@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"resqiar.com-server/inputs"
+	"resqiar.com-server/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type NewsletterHandler interface {
+	SendSubscribe(c *fiber.Ctx) error
+	SendConfirm(c *fiber.Ctx) error
+	SendUnsubscribePage(c *fiber.Ctx) error
+	SendUnsubscribe(c *fiber.Ctx) error
+	SendAuthorExport(c *fiber.Ctx) error
+	SendSiteExport(c *fiber.Ctx) error
+}
+
+type NewsletterHandlerImpl struct {
+	NewsletterService services.NewsletterService
+	UtilService       services.UtilService
+}
+
+func (handler *NewsletterHandlerImpl) SendSubscribe(c *fiber.Ctx) error {
+	// define body payload
+	var payload inputs.SubscribeNewsletterInput
+
+	// bind the body parser into payload
+	if err := c.BodyParser(&payload); err != nil {
+		// send raw error (unprocessable entity)
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	// validate the payload using class-validator
+	if err := handler.UtilService.ValidateInput(payload); err != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err,
+		})
+	}
+
+	if err := handler.NewsletterService.Subscribe(&payload); err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func (handler *NewsletterHandlerImpl) SendConfirm(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	if err := handler.NewsletterService.Confirm(token); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid or expired confirmation link")
+	}
+
+	return c.Status(fiber.StatusOK).SendString("Your subscription is confirmed")
+}
+
+// SendUnsubscribePage only asks for confirmation, link scanners
+// prefetching the unsubscribe URL must not unsubscribe anyone.
+func (handler *NewsletterHandlerImpl) SendUnsubscribePage(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	c.Type("html")
+	return unsubscribePage.Execute(c, token)
+}
+
+func (handler *NewsletterHandlerImpl) SendUnsubscribe(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	if err := handler.NewsletterService.Unsubscribe(token); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid unsubscribe link")
+	}
+
+	return c.Status(fiber.StatusOK).SendString("You have been unsubscribed")
+}
+
+func (handler *NewsletterHandlerImpl) SendAuthorExport(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	return handler.sendExport(c, userID.(string), "subscribers.csv")
+}
+
+func (handler *NewsletterHandlerImpl) SendSiteExport(c *fiber.Ctx) error {
+	// site-wide subscribers have no author
+	return handler.sendExport(c, "", "site-subscribers.csv")
+}
+
+func (handler *NewsletterHandlerImpl) sendExport(c *fiber.Ctx, authorID string, filename string) error {
+	result, err := handler.NewsletterService.ExportSubscribers(authorID)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	c.Type("csv")
+	c.Attachment(filename)
+	return c.Status(fiber.StatusOK).Send(result)
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"resqiar.com-server/constants"
+	"resqiar.com-server/inputs"
+	"resqiar.com-server/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+type NotificationHandler interface {
+	SendNotifications(c *fiber.Ctx) error
+	SendMarkRead(c *fiber.Ctx) error
+	SendMarkAllRead(c *fiber.Ctx) error
+	SendNotificationStream(c *fiber.Ctx) error
+}
+
+type NotificationHandlerImpl struct {
+	NotificationService services.NotificationService
+	UtilService         services.UtilService
+}
+
+func (handler *NotificationHandlerImpl) SendNotifications(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	unreadOnly := c.QueryBool("unread", false)
+	limit := c.QueryInt("limit", constants.DefaultNotificationLimit)
+
+	// if limit is out of range, set to default value
+	if limit <= 0 || limit > constants.MaxNotificationLimit {
+		limit = constants.DefaultNotificationLimit
+	}
+
+	result, unread, err := handler.NotificationService.GetNotifications(userID.(string), unreadOnly, limit)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"result": result,
+		"unread": unread,
+	})
+}
+
+func (handler *NotificationHandlerImpl) SendMarkRead(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	// define body payload
+	var payload inputs.NotificationIDInput
+
+	// bind the body parser into payload
+	if err := c.BodyParser(&payload); err != nil {
+		// send raw error (unprocessable entity)
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	// validate the payload using class-validator
+	if err := handler.UtilService.ValidateInput(payload); err != "" {
+		return c.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
+			"error": err,
+		})
+	}
+
+	if err := handler.NotificationService.MarkRead(payload.ID, userID.(string)); err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func (handler *NotificationHandlerImpl) SendMarkAllRead(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	if err := handler.NotificationService.MarkAllRead(userID.(string)); err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// SendNotificationStream keeps the connection open as a Server-Sent Events stream
+// and pushes every new notification of the current user as it happens.
+func (handler *NotificationHandlerImpl) SendNotificationStream(c *fiber.Ctx) error {
+	// fiber context must not be used inside the stream writer,
+	// so take everything needed from it beforehand.
+	userID := c.Locals("userID").(string)
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		stream := handler.NotificationService.Stream(ctx, userID)
+
+		keepAlive := time.NewTicker(constants.NotificationKeepAlive)
+		defer keepAlive.Stop()
+
+		// tell the client how long to wait before reconnecting
+		fmt.Fprint(w, "retry: 5000\n\n")
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		for {
+			select {
+			case notification, ok := <-stream:
+				if !ok {
+					return
+				}
+
+				payload, err := json.Marshal(notification)
+				if err != nil {
+					continue
+				}
+
+				fmt.Fprintf(w, "id: %s\nevent: notification\ndata: %s\n\n", notification.ID, payload)
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			}
+
+			// failing to flush means the client is gone
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}))
+
+	return nil
+}
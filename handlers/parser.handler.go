@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"resqiar.com-server/dto"
 	"resqiar.com-server/services"
 
 	"github.com/gofiber/fiber/v2"
@@ -8,6 +9,7 @@ import (
 
 type ParserHandler interface {
 	ParseMDtoHTML(c *fiber.Ctx) error
+	ParseMDtoDocument(c *fiber.Ctx) error
 }
 
 type ParserHandlerImpl struct {
@@ -15,12 +17,49 @@ type ParserHandlerImpl struct {
 }
 
 func (s *ParserHandlerImpl) ParseMDtoHTML(c *fiber.Ctx) error {
-	payload := c.Body()
-	parsed := s.ParserService.ParseMDByte(payload)
+	matter, content, err := s.ParserService.ParseFrontMatter(c.Body())
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	parsed := s.ParserService.ParseMDByte(content)
 	if parsed == nil {
 		return c.SendStatus(fiber.StatusBadRequest)
 	}
 
+	// the raw HTML stays the default, clients asking for JSON get the front matter along with it
+	if c.Accepts("application/octet-stream", fiber.MIMEApplicationJSON) == fiber.MIMEApplicationJSON {
+		return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+			"result": &dto.ParsedHTMLOutput{
+				HTML:        string(parsed),
+				FrontMatter: matter,
+			},
+		})
+	}
+
 	c.Type("application/octet-stream")
 	return c.Status(fiber.StatusOK).Send(parsed)
 }
+
+func (s *ParserHandlerImpl) ParseMDtoDocument(c *fiber.Ctx) error {
+	matter, content, err := s.ParserService.ParseFrontMatter(c.Body())
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	parsed := s.ParserService.ParseMDDocument(content)
+	if parsed == nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"result": &dto.ParsedDocumentOutput{
+			RenderedMarkdown: parsed,
+			FrontMatter:      matter,
+		},
+	})
+}
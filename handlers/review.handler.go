@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"errors"
+
+	"resqiar.com-server/inputs"
+	"resqiar.com-server/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ReviewHandler interface {
+	SendTransitionBlog(c *fiber.Ctx) error
+	SendTransitions(c *fiber.Ctx) error
+	SendReviewQueue(c *fiber.Ctx) error
+	SendReviewBlog(c *fiber.Ctx) error
+	SendCreateNote(c *fiber.Ctx) error
+	SendNotes(c *fiber.Ctx) error
+	SendResolveNote(c *fiber.Ctx) error
+}
+
+type ReviewHandlerImpl struct {
+	ReviewService services.ReviewService
+	BlogService   services.BlogService
+	UtilService   services.UtilService
+}
+
+func (handler *ReviewHandlerImpl) SendTransitionBlog(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	// define body payload
+	var payload inputs.TransitionBlogInput
+
+	// bind the body parser into payload
+	if err := c.BodyParser(&payload); err != nil {
+		// send raw error (unprocessable entity)
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	// validate the payload using class-validator
+	if err := handler.UtilService.ValidateInput(payload); err != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err,
+		})
+	}
+
+	if err := handler.BlogService.TransitionBlog(&payload, userID.(string)); err != nil {
+		return handler.sendError(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func (handler *ReviewHandlerImpl) SendTransitions(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	// define body payload
+	var payload inputs.BlogIDInput
+
+	// bind the body parser into payload
+	if err := c.BodyParser(&payload); err != nil {
+		// send raw error (unprocessable entity)
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	// validate the payload using class-validator
+	if err := handler.UtilService.ValidateInput(payload); err != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err,
+		})
+	}
+
+	result, err := handler.ReviewService.GetTransitions(payload.ID, userID.(string))
+	if err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"result": result,
+	})
+}
+
+func (handler *ReviewHandlerImpl) SendReviewQueue(c *fiber.Ctx) error {
+	result, err := handler.ReviewService.GetReviewQueue()
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"result": result,
+	})
+}
+
+func (handler *ReviewHandlerImpl) SendReviewBlog(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	// define body payload
+	var payload inputs.BlogIDInput
+
+	// bind the body parser into payload
+	if err := c.BodyParser(&payload); err != nil {
+		// send raw error (unprocessable entity)
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	// validate the payload using class-validator
+	if err := handler.UtilService.ValidateInput(payload); err != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err,
+		})
+	}
+
+	result, err := handler.ReviewService.GetReviewBlog(payload.ID, userID.(string))
+	if err != nil {
+		return handler.sendError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"result": result,
+	})
+}
+
+func (handler *ReviewHandlerImpl) SendCreateNote(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	// define body payload
+	var payload inputs.CreateReviewNoteInput
+
+	// bind the body parser into payload
+	if err := c.BodyParser(&payload); err != nil {
+		// send raw error (unprocessable entity)
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	// validate the payload using class-validator
+	if err := handler.UtilService.ValidateInput(payload); err != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err,
+		})
+	}
+
+	result, err := handler.ReviewService.CreateNote(&payload, userID.(string))
+	if err != nil {
+		return handler.sendError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"result": result,
+	})
+}
+
+func (handler *ReviewHandlerImpl) SendNotes(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	// define body payload
+	var payload inputs.BlogIDInput
+
+	// bind the body parser into payload
+	if err := c.BodyParser(&payload); err != nil {
+		// send raw error (unprocessable entity)
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	// validate the payload using class-validator
+	if err := handler.UtilService.ValidateInput(payload); err != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err,
+		})
+	}
+
+	result, err := handler.ReviewService.GetNotes(payload.ID, userID.(string))
+	if err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"result": result,
+	})
+}
+
+func (handler *ReviewHandlerImpl) SendResolveNote(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	// define body payload
+	var payload inputs.ResolveReviewNoteInput
+
+	// bind the body parser into payload
+	if err := c.BodyParser(&payload); err != nil {
+		// send raw error (unprocessable entity)
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	// validate the payload using class-validator
+	if err := handler.UtilService.ValidateInput(payload); err != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err,
+		})
+	}
+
+	if err := handler.ReviewService.ResolveNote(&payload, userID.(string)); err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// sendError tells workflow errors apart, anything else means the blog is out of the user's reach.
+func (handler *ReviewHandlerImpl) sendError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrTransitionForbidden):
+		return c.Status(fiber.StatusForbidden).JSON(&fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidTransition),
+		errors.Is(err, services.ErrApprovalOutdated),
+		errors.Is(err, services.ErrNotInReview):
+		return c.Status(fiber.StatusConflict).JSON(&fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNotFound)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"resqiar.com-server/constants"
 	"resqiar.com-server/inputs"
 	"resqiar.com-server/services"
 
@@ -13,11 +14,18 @@ type UserHandler interface {
 	SendUserProfile(c *fiber.Ctx) error
 	SendCheckUsername(c *fiber.Ctx) error
 	SendUserUpdateProfile(c *fiber.Ctx) error
+	SendFollowUser(c *fiber.Ctx) error
+	SendUnfollowUser(c *fiber.Ctx) error
+	SendFollowStatus(c *fiber.Ctx) error
+	SendPreferences(c *fiber.Ctx) error
+	SendUpdatePreferences(c *fiber.Ctx) error
 }
 
 type UserHandlerImpl struct {
-	UserService services.UserService
-	UtilService services.UtilService
+	UserService   services.UserService
+	UtilService   services.UtilService
+	FollowService services.FollowService
+	MailService   services.MailService
 }
 
 func (handler *UserHandlerImpl) SendUsernameList(c *fiber.Ctx) error {
@@ -53,6 +61,15 @@ func (handler *UserHandlerImpl) SendUserProfile(c *fiber.Ctx) error {
 		return c.SendStatus(fiber.StatusBadRequest)
 	}
 
+	validator, err := handler.UserService.GetProfileValidator(username)
+	if err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	if handler.UtilService.CheckNotModified(c, validator, constants.CacheControlPublic) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
 	safeUser, err := handler.UserService.FindUserByUsername(username)
 	if err != nil {
 		return c.SendStatus(fiber.StatusNotFound)
@@ -107,3 +124,79 @@ func (handler *UserHandlerImpl) SendUserUpdateProfile(c *fiber.Ctx) error {
 
 	return c.SendStatus(fiber.StatusOK)
 }
+
+func (handler *UserHandlerImpl) SendFollowUser(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	username := c.Params("username")
+	if username == "" {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	if err := handler.FollowService.FollowUser(userID.(string), username); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func (handler *UserHandlerImpl) SendUnfollowUser(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	username := c.Params("username")
+	if username == "" {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	if err := handler.FollowService.UnfollowUser(userID.(string), username); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func (handler *UserHandlerImpl) SendFollowStatus(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	username := c.Params("username")
+	if username == "" {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	following, err := handler.FollowService.IsFollowing(userID.(string), username)
+	if err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	return c.JSON(&fiber.Map{
+		"result": following,
+	})
+}
+
+func (handler *UserHandlerImpl) SendPreferences(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	preference, err := handler.MailService.GetPreference(userID.(string))
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(&fiber.Map{
+		"result": preference,
+	})
+}
+
+func (handler *UserHandlerImpl) SendUpdatePreferences(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+
+	var payload inputs.UpdatePreferenceInput
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	if err := handler.MailService.UpdatePreference(&payload, userID.(string)); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+
+	"resqiar.com-server/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type WebmentionHandler interface {
+	SendReceiveWebmention(c *fiber.Ctx) error
+	SendMentions(c *fiber.Ctx) error
+}
+
+type WebmentionHandlerImpl struct {
+	WebmentionService services.WebmentionService
+}
+
+// SendReceiveWebmention takes the "source" and "target" form values,
+// the mention is verified in the background so it is only accepted for now.
+func (handler *WebmentionHandlerImpl) SendReceiveWebmention(c *fiber.Ctx) error {
+	err := handler.WebmentionService.ReceiveWebmention(c.FormValue("source"), c.FormValue("target"))
+	if errors.Is(err, services.ErrInvalidSource) || errors.Is(err, services.ErrInvalidTarget) {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if errors.Is(err, services.ErrTooManyMentions) {
+		return c.Status(fiber.StatusTooManyRequests).JSON(&fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+func (handler *WebmentionHandlerImpl) SendMentions(c *fiber.Ctx) error {
+	mentions, err := handler.WebmentionService.GetMentions(c.Params("id"))
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"result": mentions,
+	})
+}
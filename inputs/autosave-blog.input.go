@@ -0,0 +1,16 @@
+package inputs
+
+type AutosaveBlogInput struct {
+	// blog IDs are generated as 12 alphanumeric characters, see entities.Blog
+	ID       string `validate:"required,alphanum,len=12"`
+	Title    string `validate:"omitempty,max=100"`
+	Summary  string `validate:"omitempty,max=300"`
+	Content  string `validate:"omitempty,max=50000"`
+	CoverURL string `validate:"omitempty,url"`
+
+	Prev string `validate:"omitempty,max=32"`
+	Next string `validate:"omitempty,max=32"`
+
+	// Version the editor started from
+	Version int `validate:"required,min=1"`
+}
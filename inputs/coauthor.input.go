@@ -0,0 +1,12 @@
+package inputs
+
+type InviteCoAuthorInput struct {
+	BlogID   string `validate:"required"`
+	Username string `validate:"required"`
+	Role     string `validate:"required,oneof=editor viewer"`
+}
+
+type RemoveCoAuthorInput struct {
+	BlogID string `validate:"required"`
+	UserID string `validate:"required"`
+}
@@ -1,11 +1,51 @@
 package inputs
 
+import (
+	"time"
+
+	"resqiar.com-server/types"
+)
+
 type CreateBlogInput struct {
-	Title    string `validate:"required,max=100"`
-	Summary  string `validate:"max=300"`
-	Content  string `validate:"max=50000"`
-	CoverURL string `validate:"omitempty,url"`
+	Title    string     `validate:"required,max=100"`
+	Summary  string     `validate:"max=300"`
+	Content  string     `validate:"max=50000"`
+	CoverURL string     `validate:"omitempty,url"`
+	Tags     types.Tags `validate:"omitempty,max=10,dive,required,max=30"`
+
+	// Slug is generated from the title on publish when it is empty
+	Slug        string `validate:"omitempty,max=100,slug"`
+	PublishedAt *time.Time
 
 	Prev string `validate:"omitempty,max=32"`
 	Next string `validate:"omitempty,max=32"`
 }
+
+// ApplyFrontMatter fills the fields left empty with the front matter of the content,
+// fields given explicitly always take precedence over it.
+func (input *CreateBlogInput) ApplyFrontMatter(matter *types.FrontMatter) {
+	if matter == nil {
+		return
+	}
+
+	input.Title = fallback(input.Title, matter.Title)
+	input.Summary = fallback(input.Summary, matter.Summary)
+	input.CoverURL = fallback(input.CoverURL, matter.CoverURL)
+	input.Slug = fallback(input.Slug, matter.Slug)
+
+	if input.Tags == nil {
+		input.Tags = matter.Tags
+	}
+
+	if input.PublishedAt == nil {
+		input.PublishedAt = matter.Date
+	}
+}
+
+func fallback(value string, other string) string {
+	if value != "" {
+		return value
+	}
+
+	return other
+}
@@ -0,0 +1,23 @@
+package inputs
+
+// AuthorizationInput is the authorization request of an IndieAuth client,
+// when approving it Scope holds the scopes the user granted.
+type AuthorizationInput struct {
+	ResponseType string `query:"response_type" form:"response_type" json:"response_type" validate:"required,eq=code"`
+	ClientID     string `query:"client_id" form:"client_id" json:"client_id" validate:"required,url,max=500"`
+	RedirectURI  string `query:"redirect_uri" form:"redirect_uri" json:"redirect_uri" validate:"required,url,max=500"`
+	State        string `query:"state" form:"state" json:"state" validate:"required,max=500"`
+	Scope        string `query:"scope" form:"scope" json:"scope" validate:"max=200"` // space separated
+
+	// PKCE, only S256 is accepted
+	CodeChallenge       string `query:"code_challenge" form:"code_challenge" json:"code_challenge" validate:"required,min=43,max=128"`
+	CodeChallengeMethod string `query:"code_challenge_method" form:"code_challenge_method" json:"code_challenge_method" validate:"required,eq=S256"`
+}
+
+type TokenInput struct {
+	GrantType    string `form:"grant_type" json:"grant_type" validate:"required,eq=authorization_code"`
+	Code         string `form:"code" json:"code" validate:"required,max=100"`
+	ClientID     string `form:"client_id" json:"client_id" validate:"required,url,max=500"`
+	RedirectURI  string `form:"redirect_uri" json:"redirect_uri" validate:"required,url,max=500"`
+	CodeVerifier string `form:"code_verifier" json:"code_verifier" validate:"required,min=43,max=128"`
+}
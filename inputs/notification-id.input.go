@@ -0,0 +1,5 @@
+package inputs
+
+type NotificationIDInput struct {
+	ID string `validate:"required,uuid"`
+}
@@ -0,0 +1,19 @@
+package inputs
+
+type TransitionBlogInput struct {
+	ID    string `validate:"required"`
+	State string `validate:"required,oneof=draft in_review changes_requested approved published archived"`
+	Note  string `validate:"max=1000"`
+}
+
+type CreateReviewNoteInput struct {
+	BlogID    string `validate:"required"`
+	StartLine int    `validate:"required,min=1"`
+	EndLine   int    `validate:"required,gtefield=StartLine"`
+	Body      string `validate:"required,max=2000"`
+}
+
+type ResolveReviewNoteInput struct {
+	ID     string `validate:"required"`
+	BlogID string `validate:"required"`
+}
@@ -0,0 +1,6 @@
+package inputs
+
+type SubscribeNewsletterInput struct {
+	Email  string `validate:"required,email,max=100"`
+	Author string `validate:"omitempty,max=100"` // author username, empty for every author
+}
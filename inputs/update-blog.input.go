@@ -1,16 +1,28 @@
 package inputs
 
-import "time"
+import (
+	"time"
+
+	"resqiar.com-server/types"
+)
 
 type UpdateBlogInput struct {
-	ID       string `validate:"required"`
-	Title    string `validate:"omitempty,max=100"`
-	Summary  string `validate:"omitempty,max=300"`
-	Content  string `validate:"omitempty,max=50000"`
-	CoverURL string `validate:"omitempty,url"`
+	ID       string     `validate:"required"`
+	Title    string     `validate:"omitempty,max=100"`
+	Summary  string     `validate:"omitempty,max=300"`
+	Content  string     `validate:"omitempty,max=50000"`
+	CoverURL string     `validate:"omitempty,url"`
+	Tags     types.Tags `validate:"omitempty,max=10,dive,required,max=30"`
+
+	// Slug replaces the current slug, keeping the previous one in its history
+	Slug        string `validate:"omitempty,max=100,slug"`
+	PublishedAt *time.Time
 
 	Prev string `validate:"omitempty,max=32"`
 	Next string `validate:"omitempty,max=32"`
+
+	// Version the edit is based on, it can also be given as If-Match
+	Version int `validate:"omitempty,min=1"`
 }
 
 type SafeUpdateBlogInput struct {
@@ -18,8 +30,40 @@ type SafeUpdateBlogInput struct {
 	Summary   string
 	Content   string
 	CoverURL  string
+	Tags      types.Tags
 	UpdatedAt time.Time
 
+	PublishedAt time.Time
+
+	ContentHTML     string
+	RendererVersion int
+	Version         int
+
+	TOC         types.TableOfContents
+	WordCount   int
+	ReadingTime int
+
 	Prev string `validate:"omitempty,max=32"`
 	Next string `validate:"omitempty,max=32"`
 }
+
+// ApplyFrontMatter fills the fields left empty with the front matter of the content,
+// fields given explicitly always take precedence over it.
+func (input *UpdateBlogInput) ApplyFrontMatter(matter *types.FrontMatter) {
+	if matter == nil {
+		return
+	}
+
+	input.Title = fallback(input.Title, matter.Title)
+	input.Summary = fallback(input.Summary, matter.Summary)
+	input.CoverURL = fallback(input.CoverURL, matter.CoverURL)
+	input.Slug = fallback(input.Slug, matter.Slug)
+
+	if input.Tags == nil {
+		input.Tags = matter.Tags
+	}
+
+	if input.PublishedAt == nil {
+		input.PublishedAt = matter.Date
+	}
+}
@@ -0,0 +1,6 @@
+package inputs
+
+type UpdatePreferenceInput struct {
+	EmailNewPost bool
+	EmailDigest  bool
+}
@@ -0,0 +1,6 @@
+package inputs
+
+type UpdateSlugInput struct {
+	ID   string `validate:"required"`
+	Slug string `validate:"required,max=100,slug"`
+}
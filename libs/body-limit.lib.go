@@ -0,0 +1,22 @@
+package libs
+
+import (
+	"strings"
+
+	"resqiar.com-server/constants"
+)
+
+// BodyLimit returns the largest body the URL accepts, only the routes receiving
+// uploads go past the default limit, zero keeps the default.
+func BodyLimit(URL string) int {
+	path, _, _ := strings.Cut(URL, "?")
+
+	switch strings.TrimSuffix(path, "/") {
+	case "/blog/import", "/blog/import/adm/wordpress":
+		return constants.ImportMaxArchiveSize
+	case "/micropub", "/micropub/media":
+		return constants.MicropubMaxBodySize
+	default:
+		return 0
+	}
+}
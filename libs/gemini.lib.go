@@ -0,0 +1,98 @@
+package libs
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"resqiar.com-server/config"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/handlers"
+	"resqiar.com-server/types"
+)
+
+// ListenGemini serves the capsule over TLS until the listener fails,
+// every connection carries a single request and is closed after the response.
+func ListenGemini(capsule *config.GeminiCapsule, handler handlers.GeminiHandler) error {
+	certificate, err := tls.LoadX509KeyPair(capsule.CertPath, capsule.KeyPath)
+	if err != nil {
+		return err
+	}
+
+	listener, err := tls.Listen("tcp", ":"+capsule.Port, &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	})
+	if err != nil {
+		return err
+	}
+
+	defer listener.Close()
+
+	log.Printf("Gemini capsule listening on %s", capsule.URL())
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+
+			return err
+		}
+
+		go serveGemini(conn, capsule, handler)
+	}
+}
+
+func serveGemini(conn net.Conn, capsule *config.GeminiCapsule, handler handlers.GeminiHandler) {
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(constants.GeminiTimeout))
+
+	response := geminiResponse(conn, capsule, handler)
+
+	writer := bufio.NewWriter(conn)
+	fmt.Fprintf(writer, "%d %s\r\n", response.Status, response.Meta)
+
+	if response.Status == constants.GeminiSuccess {
+		writer.Write(response.Body)
+	}
+
+	if err := writer.Flush(); err != nil {
+		log.Println("Error writing Gemini response:", err)
+	}
+}
+
+func geminiResponse(conn net.Conn, capsule *config.GeminiCapsule, handler handlers.GeminiHandler) *types.GeminiResponse {
+	// the reader holds exactly the longest valid request, a longer one never reaches its CRLF
+	reader := bufio.NewReaderSize(conn, constants.GeminiMaxRequestSize+2)
+
+	line, err := reader.ReadSlice('\n')
+	if err != nil || !strings.HasSuffix(string(line), "\r\n") {
+		return &types.GeminiResponse{Status: constants.GeminiBadRequest, Meta: "Bad request"}
+	}
+
+	request, err := url.Parse(strings.TrimSuffix(string(line), "\r\n"))
+	if err != nil || !request.IsAbs() || request.User != nil {
+		return &types.GeminiResponse{Status: constants.GeminiBadRequest, Meta: "Bad request"}
+	}
+
+	// the capsule serves itself only, it is no proxy
+	if request.Scheme != "gemini" || !strings.EqualFold(request.Hostname(), capsule.Host) {
+		return &types.GeminiResponse{Status: constants.GeminiProxyRefused, Meta: "Proxy request refused"}
+	}
+
+	if port := request.Port(); port != "" && port != capsule.Port {
+		return &types.GeminiResponse{Status: constants.GeminiProxyRefused, Meta: "Proxy request refused"}
+	}
+
+	return handler.ServeGemini(request.EscapedPath())
+}
@@ -0,0 +1,18 @@
+package libs
+
+import "strings"
+
+// IsStreamRoute reports whether the URL serves a stream, e.g. events or a zip archive,
+// such responses must be flushed as-is and never be buffered.
+func IsStreamRoute(URL string) bool {
+	switch {
+	case strings.HasPrefix(URL, "/notifications/stream"):
+		return true
+	case strings.HasPrefix(URL, "/blog/collab/"):
+		return true
+	case strings.HasPrefix(URL, "/blog/export"):
+		return true
+	default:
+		return false
+	}
+}
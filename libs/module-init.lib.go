@@ -1,6 +1,12 @@
 package libs
 
 import (
+	"context"
+	"log"
+	"os"
+
+	"resqiar.com-server/config"
+	"resqiar.com-server/db"
 	"resqiar.com-server/handlers"
 	"resqiar.com-server/repositories"
 	"resqiar.com-server/routes"
@@ -14,14 +20,144 @@ func ModuleInit(server *fiber.App, DB *gorm.DB) {
 	// Init repositories
 	userRepository := repositories.InitUserRepo(DB)
 	blogRepository := repositories.InitBlogRepo(DB)
+	relatedRepository := repositories.InitRelatedRepo(DB)
+	followRepository := repositories.InitFollowRepo(DB)
+	notificationRepository := repositories.InitNotificationRepo(DB)
+	preferenceRepository := repositories.InitPreferenceRepo(DB)
+	newsletterRepository := repositories.InitNewsletterRepo(DB)
+	autosaveRepository := repositories.InitAutosaveRepo(db.RedisStore.Conn(), DB)
+	collabRepository := repositories.InitCollabRepo(db.RedisStore.Conn())
+	coAuthorRepository := repositories.InitCoAuthorRepo(DB)
+	reviewRepository := repositories.InitReviewRepo(DB)
+	importRepository := repositories.InitImportRepo(DB)
+	activityPubRepository := repositories.InitActivityPubRepo(DB)
+	webmentionRepository := repositories.InitWebmentionRepo(DB)
+	indieAuthRepository := repositories.InitIndieAuthRepo(DB)
+	mediaRepository := repositories.InitMediaRepo(DB)
 
 	// Init services
 	utilService := services.InitUtilService()
+	eventService := services.InitEventService()
+	cacheService := services.InitCacheService(db.RedisStore.Conn())
 	userService := services.UserServiceImpl{
-		Repository:  userRepository,
-		UtilService: utilService,
+		Repository:   userRepository,
+		UtilService:  utilService,
+		CacheService: cacheService,
+	}
+	relatedService := services.RelatedServiceImpl{
+		Repository:     relatedRepository,
+		BlogRepository: blogRepository,
+	}
+	blogService := services.BlogServiceImpl{
+		UtilService:    utilService,
+		Repository:     blogRepository,
+		RelatedService: &relatedService,
+		EventService:   eventService,
+		CacheService:   cacheService,
+		UserRepository: userRepository,
+	}
+	autosaveService := services.AutosaveServiceImpl{
+		Repository:     autosaveRepository,
+		BlogRepository: blogRepository,
+	}
+	collabService := services.CollabServiceImpl{
+		UtilService:    utilService,
+		Repository:     collabRepository,
+		BlogRepository: blogRepository,
+		BlogService:    &blogService,
+		UserRepository: userRepository,
+		CacheService:   cacheService,
+		PubSub:         db.RedisStore.Conn(),
+	}
+	coAuthorService := services.CoAuthorServiceImpl{
+		Repository:     coAuthorRepository,
+		BlogRepository: blogRepository,
+		UserRepository: userRepository,
+		EventService:   eventService,
+		CacheService:   cacheService,
+	}
+	reviewService := services.ReviewServiceImpl{
+		Repository:     reviewRepository,
+		BlogRepository: blogRepository,
+		UserRepository: userRepository,
+	}
+	importService := services.ImportServiceImpl{
+		UtilService:    utilService,
+		Repository:     importRepository,
+		BlogRepository: blogRepository,
+		RelatedService: &relatedService,
+		CacheService:   cacheService,
+		UserRepository: userRepository,
+	}
+	exportService := services.ExportServiceImpl{
+		UtilService:    utilService,
+		BlogRepository: blogRepository,
+		UserRepository: userRepository,
 	}
-	blogService := services.BlogServiceImpl{UtilService: utilService, Repository: blogRepository}
+	epubService := services.EpubServiceImpl{
+		UtilService:    utilService,
+		BlogRepository: blogRepository,
+		CacheService:   cacheService,
+		Client:         services.InitEpubClient(),
+	}
+	activityPubService := services.ActivityPubServiceImpl{
+		UtilService:    utilService,
+		Repository:     activityPubRepository,
+		UserRepository: userRepository,
+		BlogRepository: blogRepository,
+		Client:         services.InitActivityPubClient(),
+		ServerURL:      os.Getenv("SERVER_URL"),
+	}
+	activityPubService.SubscribeEvents(eventService)
+	webmentionService := services.WebmentionServiceImpl{
+		UtilService:    utilService,
+		Repository:     webmentionRepository,
+		BlogRepository: blogRepository,
+		CacheService:   cacheService,
+		Client:         services.InitWebmentionClient(),
+	}
+	webmentionService.SubscribeEvents(eventService)
+	indieAuthService := services.IndieAuthServiceImpl{
+		UtilService:    utilService,
+		Repository:     indieAuthRepository,
+		UserRepository: userRepository,
+		ServerURL:      os.Getenv("SERVER_URL"),
+	}
+	micropubService := services.MicropubServiceImpl{
+		UtilService:    utilService,
+		BlogService:    &blogService,
+		BlogRepository: blogRepository,
+		Repository:     mediaRepository,
+		ServerURL:      os.Getenv("SERVER_URL"),
+	}
+	followService := services.FollowServiceImpl{
+		Repository:     followRepository,
+		UserRepository: userRepository,
+		EventService:   eventService,
+		CacheService:   cacheService,
+	}
+	notificationService := services.NotificationServiceImpl{
+		Repository: notificationRepository,
+		PubSub:     db.RedisStore.Conn(),
+	}
+	notificationService.SubscribeEvents(eventService)
+	mailer := services.InitMailer()
+	mailService := services.MailServiceImpl{
+		Mailer:         mailer,
+		UtilService:    utilService,
+		Repository:     preferenceRepository,
+		BlogRepository: blogRepository,
+		Locker:         db.RedisStore.Conn(),
+	}
+	mailService.SubscribeEvents(eventService)
+	newsletterService := services.NewsletterServiceImpl{
+		Mailer:         mailer,
+		UtilService:    utilService,
+		Repository:     newsletterRepository,
+		BlogRepository: blogRepository,
+		UserRepository: userRepository,
+	}
+	newsletterService.SubscribeEvents(eventService)
 	authService := services.AuthServiceImpl{}
 	parserService := services.ParserServiceImpl{}
 
@@ -32,12 +168,60 @@ func ModuleInit(server *fiber.App, DB *gorm.DB) {
 		UtilService: utilService,
 	}
 	userHandler := handlers.UserHandlerImpl{
-		UserService: &userService,
-		UtilService: utilService,
+		UserService:   &userService,
+		UtilService:   utilService,
+		FollowService: &followService,
+		MailService:   &mailService,
 	}
 	blogHandler := handlers.BlogHandlerImpl{
-		BlogService: &blogService,
-		UtilService: utilService,
+		BlogService:     &blogService,
+		UtilService:     utilService,
+		RelatedService:  &relatedService,
+		AutosaveService: &autosaveService,
+	}
+	collabHandler := handlers.CollabHandlerImpl{
+		CollabService: &collabService,
+	}
+	coAuthorHandler := handlers.CoAuthorHandlerImpl{
+		CoAuthorService: &coAuthorService,
+		UtilService:     utilService,
+	}
+	reviewHandler := handlers.ReviewHandlerImpl{
+		ReviewService: &reviewService,
+		BlogService:   &blogService,
+		UtilService:   utilService,
+	}
+	importHandler := handlers.ImportHandlerImpl{
+		ImportService: &importService,
+	}
+	exportHandler := handlers.ExportHandlerImpl{
+		ExportService: &exportService,
+	}
+	epubHandler := handlers.EpubHandlerImpl{
+		EpubService: &epubService,
+	}
+	activityPubHandler := handlers.ActivityPubHandlerImpl{
+		ActivityPubService: &activityPubService,
+	}
+	webmentionHandler := handlers.WebmentionHandlerImpl{
+		WebmentionService: &webmentionService,
+	}
+	indieAuthHandler := handlers.IndieAuthHandlerImpl{
+		IndieAuthService: &indieAuthService,
+	}
+	micropubHandler := handlers.MicropubHandlerImpl{
+		MicropubService: &micropubService,
+	}
+	notificationHandler := handlers.NotificationHandlerImpl{
+		NotificationService: &notificationService,
+		UtilService:         utilService,
+	}
+	mailHandler := handlers.MailHandlerImpl{
+		MailService: &mailService,
+	}
+	newsletterHandler := handlers.NewsletterHandlerImpl{
+		NewsletterService: &newsletterService,
+		UtilService:       utilService,
 	}
 	parserHandler := handlers.ParserHandlerImpl{
 		ParserService: &parserService,
@@ -47,5 +231,55 @@ func ModuleInit(server *fiber.App, DB *gorm.DB) {
 	routes.InitAuthRoute(server, &authHandler)
 	routes.InitUserRoute(server, &userHandler)
 	routes.InitBlogRoute(server, &blogHandler)
+	routes.InitCollabRoute(server, &collabHandler)
+	routes.InitCoAuthorRoute(server, &coAuthorHandler)
+	routes.InitReviewRoute(server, &reviewHandler)
+	routes.InitImportRoute(server, &importHandler)
+	routes.InitExportRoute(server, &exportHandler)
+	routes.InitEpubRoute(server, &epubHandler)
+	routes.InitActivityPubRoute(server, &activityPubHandler)
+	routes.InitWebmentionRoute(server, &webmentionHandler)
+	routes.InitIndieAuthRoute(server, &indieAuthHandler)
+	routes.InitMicropubRoute(server, &micropubHandler)
 	routes.InitParserRoute(server, &parserHandler)
+	routes.InitNotificationRoute(server, &notificationHandler)
+	routes.InitMailRoute(server, &mailHandler)
+	routes.InitNewsletterRoute(server, &newsletterHandler)
+
+	// The Gemini capsule is optional, it listens next to the server with its own certificate
+	if capsule := config.GeminiConfig(); capsule != nil {
+		geminiHandler := handlers.GeminiHandlerImpl{
+			GeminiService: &services.GeminiServiceImpl{
+				BlogService: &blogService,
+				BaseURL:     capsule.URL(),
+			},
+			BlogService: &blogService,
+		}
+
+		go func() {
+			if err := ListenGemini(capsule, &geminiHandler); err != nil {
+				log.Println("Error serving the Gemini capsule:", err)
+			}
+		}()
+	}
+
+	// Start background jobs
+	go mailService.RunDigestScheduler(context.Background())
+	go newsletterService.RunSendingJob(context.Background())
+	go autosaveService.RunFlushJob(context.Background())
+	go collabService.RunPersistJob(context.Background())
+	go importService.RunImportJob(context.Background())
+	go activityPubService.RunDeliveryJob(context.Background())
+	go webmentionService.RunVerifyJob(context.Background())
+	go func() {
+		// catch up with renderer changes since the last deploy
+		rendered, err := blogService.RenderStaleBlogs()
+		if err != nil {
+			log.Println("Error rendering stale blogs:", err)
+		}
+
+		if rendered > 0 {
+			log.Printf("Re-rendered %d stale blogs", rendered)
+		}
+	}()
 }
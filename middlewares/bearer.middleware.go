@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"strings"
+	"time"
+
+	"resqiar.com-server/db"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// check the IndieAuth access token given as a bearer token,
+// or as the access_token form value of Micropub requests.
+// if valid, save its user and scope, else throw 401
+func BearerRoute(c *fiber.Ctx) error {
+	token, found := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !found {
+		token = c.FormValue("access_token")
+	}
+
+	if token == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	var accessToken entities.AccessToken
+	result := db.DB.First(&accessToken, "token = ? AND expires_at > ?", services.HashToken(token), time.Now())
+
+	if result.Error != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	c.Locals("userID", accessToken.UserID)
+	c.Locals("scope", accessToken.Scope)
+
+	return c.Next()
+}
@@ -0,0 +1,28 @@
+package middlewares
+
+import (
+	"resqiar.com-server/db"
+	"resqiar.com-server/entities"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// check logged in user if he/she is a reviewer or an admin,
+// if so allow the route, else throw 401
+func ReviewerRoute(c *fiber.Ctx) error {
+	// user id from locals
+	userID := c.Locals("userID")
+	if userID == nil {
+		return c.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	var currentUser entities.User
+	result := db.DB.First(&currentUser, "ID = ? AND (is_reviewer = ? OR is_admin = ?)", userID, true, true)
+
+	// check if error OR if current user is NOT reviewer
+	if result.Error != nil || !(currentUser.IsReviewer || currentUser.IsAdmin) {
+		return c.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	return c.Next()
+}
@@ -0,0 +1,195 @@
+package repositories
+
+import (
+	"time"
+
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ActivityPubRepository interface {
+	// GetActorKey returns nil when the user has no key pair yet.
+	GetActorKey(userID string) (*entities.ActorKey, error)
+
+	// CreateActorKey stores the key pair unless the user got one meanwhile,
+	// it returns the key pair the user ends up with.
+	CreateActorKey(key *entities.ActorKey) (*entities.ActorKey, error)
+
+	SaveFollower(follower *entities.RemoteFollower) error
+	DeleteFollower(userID string, actorID string) error
+	GetFollowers(userID string) ([]entities.RemoteFollower, error)
+	CountFollowers(userID string) (int64, error)
+
+	CreateDeliveries(deliveries []entities.ActivityDelivery) error
+
+	// QueueUpdates replaces the activity of the Update deliveries of the same blog and inbox
+	// still waiting for their first attempt, the other deliveries are created.
+	QueueUpdates(deliveries []entities.ActivityDelivery) error
+
+	// ClaimPendingDelivery atomically takes the oldest delivery which is due,
+	// it returns nil when there is nothing left to deliver.
+	ClaimPendingDelivery(timeout time.Duration) (*entities.ActivityDelivery, error)
+
+	// FinishDelivery stores the outcome of the attempt and when to try again, if ever.
+	FinishDelivery(delivery *entities.ActivityDelivery) error
+}
+
+type ActivityPubRepoImpl struct {
+	db *gorm.DB
+}
+
+func InitActivityPubRepo(db *gorm.DB) ActivityPubRepository {
+	return &ActivityPubRepoImpl{
+		db: db,
+	}
+}
+
+func (repo *ActivityPubRepoImpl) GetActorKey(userID string) (*entities.ActorKey, error) {
+	var keys []entities.ActorKey
+
+	if err := repo.db.Limit(1).Find(&keys, "user_id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	return &keys[0], nil
+}
+
+func (repo *ActivityPubRepoImpl) CreateActorKey(key *entities.ActorKey) (*entities.ActorKey, error) {
+	if err := repo.db.Clauses(clause.OnConflict{DoNothing: true}).Create(key).Error; err != nil {
+		return nil, err
+	}
+
+	var stored entities.ActorKey
+
+	if err := repo.db.First(&stored, "user_id = ?", key.UserID).Error; err != nil {
+		return nil, err
+	}
+
+	return &stored, nil
+}
+
+// SaveFollower updates the inboxes of an actor following again.
+func (repo *ActivityPubRepoImpl) SaveFollower(follower *entities.RemoteFollower) error {
+	if err := repo.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "actor_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"inbox", "shared_inbox"}),
+	}).Create(follower).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *ActivityPubRepoImpl) DeleteFollower(userID string, actorID string) error {
+	if err := repo.db.Delete(&entities.RemoteFollower{}, "user_id = ? AND actor_id = ?", userID, actorID).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *ActivityPubRepoImpl) GetFollowers(userID string) ([]entities.RemoteFollower, error) {
+	var followers []entities.RemoteFollower
+
+	if err := repo.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&followers).Error; err != nil {
+		return nil, err
+	}
+
+	return followers, nil
+}
+
+func (repo *ActivityPubRepoImpl) CountFollowers(userID string) (int64, error) {
+	var count int64
+
+	if err := repo.db.Model(&entities.RemoteFollower{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (repo *ActivityPubRepoImpl) CreateDeliveries(deliveries []entities.ActivityDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	if err := repo.db.Create(&deliveries).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// QueueUpdates only merges in deliveries waiting for their delay,
+// a claimed delivery is pushed past it and may already be posting the older activity.
+func (repo *ActivityPubRepoImpl) QueueUpdates(deliveries []entities.ActivityDelivery) error {
+	now := time.Now()
+
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		for i := range deliveries {
+			delivery := &deliveries[i]
+
+			result := tx.Model(&entities.ActivityDelivery{}).
+				Where("blog_id = ? AND inbox = ? AND status = ? AND attempts = 0", delivery.BlogID, delivery.Inbox, constants.DeliveryPending).
+				Where("next_attempt_at > ? AND next_attempt_at <= ?", now, now.Add(constants.DeliveryUpdateDelay)).
+				Update("activity", delivery.Activity)
+			if result.Error != nil {
+				return result.Error
+			}
+
+			if result.RowsAffected > 0 {
+				continue
+			}
+
+			if err := tx.Create(delivery).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// ClaimPendingDelivery pushes the next attempt of the claimed delivery past the timeout,
+// should the instance crash while delivering another one retries it after the timeout.
+func (repo *ActivityPubRepoImpl) ClaimPendingDelivery(timeout time.Duration) (*entities.ActivityDelivery, error) {
+	var deliveries []entities.ActivityDelivery
+
+	PENDING_SQL := "SELECT id FROM activity_deliveries " +
+		"WHERE status = ? AND next_attempt_at <= ? " +
+		"ORDER BY next_attempt_at ASC LIMIT 1 FOR UPDATE SKIP LOCKED"
+
+	now := time.Now()
+
+	if err := repo.db.Model(&deliveries).
+		Clauses(clause.Returning{}).
+		Where("id = (?)", gorm.Expr(PENDING_SQL, constants.DeliveryPending, now)).
+		Update("next_attempt_at", now.Add(timeout)).
+		Error; err != nil {
+		return nil, err
+	}
+
+	if len(deliveries) == 0 {
+		return nil, nil
+	}
+
+	return &deliveries[0], nil
+}
+
+func (repo *ActivityPubRepoImpl) FinishDelivery(delivery *entities.ActivityDelivery) error {
+	if err := repo.db.Model(delivery).
+		Select("status", "next_attempt_at", "attempts", "error", "delivered_at").
+		Updates(delivery).
+		Error; err != nil {
+		return err
+	}
+
+	return nil
+}
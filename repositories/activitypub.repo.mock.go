@@ -0,0 +1,108 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"resqiar.com-server/entities"
+)
+
+type ActivityPubRepoMock struct {
+	Mock mock.Mock
+}
+
+func (repo *ActivityPubRepoMock) GetActorKey(userID string) (*entities.ActorKey, error) {
+	args := repo.Mock.Called(userID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*entities.ActorKey), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *ActivityPubRepoMock) CreateActorKey(key *entities.ActorKey) (*entities.ActorKey, error) {
+	args := repo.Mock.Called(key)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*entities.ActorKey), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *ActivityPubRepoMock) SaveFollower(follower *entities.RemoteFollower) error {
+	args := repo.Mock.Called(follower)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
+
+func (repo *ActivityPubRepoMock) DeleteFollower(userID string, actorID string) error {
+	args := repo.Mock.Called(userID, actorID)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
+
+func (repo *ActivityPubRepoMock) GetFollowers(userID string) ([]entities.RemoteFollower, error) {
+	args := repo.Mock.Called(userID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]entities.RemoteFollower), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *ActivityPubRepoMock) CountFollowers(userID string) (int64, error) {
+	args := repo.Mock.Called(userID)
+
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (repo *ActivityPubRepoMock) CreateDeliveries(deliveries []entities.ActivityDelivery) error {
+	args := repo.Mock.Called(deliveries)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
+
+func (repo *ActivityPubRepoMock) QueueUpdates(deliveries []entities.ActivityDelivery) error {
+	args := repo.Mock.Called(deliveries)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
+
+func (repo *ActivityPubRepoMock) ClaimPendingDelivery(timeout time.Duration) (*entities.ActivityDelivery, error) {
+	args := repo.Mock.Called(timeout)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*entities.ActivityDelivery), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *ActivityPubRepoMock) FinishDelivery(delivery *entities.ActivityDelivery) error {
+	args := repo.Mock.Called(delivery)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
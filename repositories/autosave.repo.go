@@ -0,0 +1,233 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/types"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// every scheduled flush lives in this sorted set, scored by when it is due
+const AUTOSAVE_PENDING_KEY = "autosave:pending"
+
+type AutosaveRepository interface {
+	// SaveAutosave stores the snapshot and schedules its flush,
+	// pushing back the flush already scheduled for it if any.
+	SaveAutosave(autosave *types.Autosave, flushAt time.Time) (*types.Autosave, error)
+
+	// GetAutosave returns the snapshot from Redis, or the flushed one once it expired there.
+	GetAutosave(blogID string, userID string) (*types.Autosave, error)
+	DeleteAutosave(blogID string, userID string) error
+
+	// ClaimDueAutosaves unschedules the flushes due by now and returns their snapshots,
+	// every flush is claimed by a single server instance.
+	ClaimDueAutosaves(now time.Time, limit int) ([]types.Autosave, error)
+
+	// FlushAutosave stores the snapshot in Postgres unless a newer one is there already.
+	FlushAutosave(autosave *types.Autosave) error
+}
+
+type AutosaveRepoImpl struct {
+	client redis.UniversalClient
+	db     *gorm.DB
+}
+
+func InitAutosaveRepo(client redis.UniversalClient, db *gorm.DB) AutosaveRepository {
+	return &AutosaveRepoImpl{
+		client: client,
+		db:     db,
+	}
+}
+
+func (repo *AutosaveRepoImpl) SaveAutosave(autosave *types.Autosave, flushAt time.Time) (*types.Autosave, error) {
+	ctx := context.Background()
+	key := autosaveKey(autosave.BlogID, autosave.UserID)
+
+	// read-modify-write, retried when another autosave of the key lands meanwhile
+	save := func(tx *redis.Tx) error {
+		data, err := json.Marshal(autosave)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, data, constants.AutosaveTTL)
+			pipe.ZAdd(ctx, AUTOSAVE_PENDING_KEY, redis.Z{
+				Score:  float64(flushAt.Unix()),
+				Member: autosaveMember(autosave.BlogID, autosave.UserID),
+			})
+
+			return nil
+		})
+
+		return err
+	}
+
+	if err := repo.watch(ctx, save, key); err != nil {
+		return nil, err
+	}
+
+	return autosave, nil
+}
+
+func (repo *AutosaveRepoImpl) GetAutosave(blogID string, userID string) (*types.Autosave, error) {
+	autosave, err := getAutosave(context.Background(), repo.client, autosaveKey(blogID, userID))
+	if err != nil {
+		return nil, err
+	}
+
+	if autosave != nil {
+		return autosave, nil
+	}
+
+	var flushed entities.BlogAutosave
+
+	if err := repo.db.First(&flushed, "blog_id = ? AND user_id = ?", blogID, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("Record not found")
+		}
+
+		return nil, err
+	}
+
+	return &types.Autosave{
+		BlogID:   flushed.BlogID,
+		UserID:   flushed.UserID,
+		Title:    flushed.Title,
+		Summary:  flushed.Summary,
+		Content:  flushed.Content,
+		CoverURL: flushed.CoverURL,
+		Prev:     flushed.Prev,
+		Next:     flushed.Next,
+		Base:     flushed.Base,
+		SavedAt:  flushed.SavedAt,
+	}, nil
+}
+
+func (repo *AutosaveRepoImpl) DeleteAutosave(blogID string, userID string) error {
+	ctx := context.Background()
+
+	_, err := repo.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, autosaveKey(blogID, userID))
+		pipe.ZRem(ctx, AUTOSAVE_PENDING_KEY, autosaveMember(blogID, userID))
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return repo.db.Delete(&entities.BlogAutosave{}, "blog_id = ? AND user_id = ?", blogID, userID).Error
+}
+
+func (repo *AutosaveRepoImpl) ClaimDueAutosaves(now time.Time, limit int) ([]types.Autosave, error) {
+	ctx := context.Background()
+
+	members, err := repo.client.ZRangeByScore(ctx, AUTOSAVE_PENDING_KEY, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.Unix(), 10),
+		Count: int64(limit),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var autosaves []types.Autosave
+
+	for _, member := range members {
+		// only the instance removing the member gets to flush it
+		removed, err := repo.client.ZRem(ctx, AUTOSAVE_PENDING_KEY, member).Result()
+		if err != nil {
+			return autosaves, err
+		}
+
+		if removed == 0 {
+			continue
+		}
+
+		blogID, userID, _ := strings.Cut(member, ":")
+
+		autosave, err := getAutosave(ctx, repo.client, autosaveKey(blogID, userID))
+		if err != nil {
+			return autosaves, err
+		}
+
+		// expired or discarded by an explicit save meanwhile
+		if autosave == nil {
+			continue
+		}
+
+		autosaves = append(autosaves, *autosave)
+	}
+
+	return autosaves, nil
+}
+
+// FlushAutosave keeps the newest snapshot, a flush claimed late must not overwrite a later one.
+func (repo *AutosaveRepoImpl) FlushAutosave(autosave *types.Autosave) error {
+	flushed := entities.BlogAutosave{
+		BlogID:   autosave.BlogID,
+		UserID:   autosave.UserID,
+		Title:    autosave.Title,
+		Summary:  autosave.Summary,
+		Content:  autosave.Content,
+		CoverURL: autosave.CoverURL,
+		Prev:     autosave.Prev,
+		Next:     autosave.Next,
+		Base:     autosave.Base,
+		SavedAt:  autosave.SavedAt,
+	}
+
+	return repo.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "blog_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"title", "summary", "content", "cover_url", "prev", "next", "base", "saved_at"}),
+		Where:     clause.Where{Exprs: []clause.Expression{gorm.Expr("blog_autosaves.saved_at < excluded.saved_at")}},
+	}).Create(&flushed).Error
+}
+
+// watch runs the transaction again as long as the watched keys change under it.
+func (repo *AutosaveRepoImpl) watch(ctx context.Context, fn func(tx *redis.Tx) error, keys ...string) error {
+	for {
+		err := repo.client.Watch(ctx, fn, keys...)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+}
+
+func getAutosave(ctx context.Context, client redis.Cmdable, key string) (*types.Autosave, error) {
+	data, err := client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var autosave types.Autosave
+	if err := json.Unmarshal(data, &autosave); err != nil {
+		return nil, err
+	}
+
+	return &autosave, nil
+}
+
+func autosaveKey(blogID string, userID string) string {
+	return fmt.Sprintf("autosave:%s:%s", blogID, userID)
+}
+
+func autosaveMember(blogID string, userID string) string {
+	return blogID + ":" + userID
+}
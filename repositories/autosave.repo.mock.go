@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"resqiar.com-server/types"
+)
+
+type AutosaveRepoMock struct {
+	Mock mock.Mock
+}
+
+func (repo *AutosaveRepoMock) SaveAutosave(autosave *types.Autosave, flushAt time.Time) (*types.Autosave, error) {
+	args := repo.Mock.Called(autosave, flushAt)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*types.Autosave), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *AutosaveRepoMock) GetAutosave(blogID string, userID string) (*types.Autosave, error) {
+	args := repo.Mock.Called(blogID, userID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*types.Autosave), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *AutosaveRepoMock) DeleteAutosave(blogID string, userID string) error {
+	args := repo.Mock.Called(blogID, userID)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
+
+func (repo *AutosaveRepoMock) ClaimDueAutosaves(now time.Time, limit int) ([]types.Autosave, error) {
+	args := repo.Mock.Called(now, limit)
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]types.Autosave), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *AutosaveRepoMock) FlushAutosave(autosave *types.Autosave) error {
+	args := repo.Mock.Called(autosave)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
@@ -1,10 +1,13 @@
 package repositories
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
 
+	"resqiar.com-server/constants"
+	"resqiar.com-server/dto"
 	"resqiar.com-server/entities"
 	"resqiar.com-server/inputs"
 	"resqiar.com-server/types"
@@ -17,14 +20,59 @@ type BlogRepository interface {
 	GetBlogs(onlyPublished bool, desc bool, username string) ([]entities.SafeBlogAuthor, error)
 	GetBlog(opts *types.GetBlogOpts) (*entities.SafeBlogAuthor, error)
 	CreateBlog(input *entities.Blog) (*entities.Blog, error)
-	UpdateBlog(blogID string, safe *inputs.SafeUpdateBlogInput) error
+	UpdateBlog(blogID string, version int, safe *inputs.SafeUpdateBlogInput) (bool, error)
+	GetByID(blogID string) (*entities.Blog, error)
 	GetByIDAndAuthor(blogID string, userID string) (*entities.Blog, error)
+	GetByIDAndOwner(blogID string, userID string) (*entities.Blog, error)
 	GetCurrentUserBlogs(userID string, desc bool) ([]entities.Blog, error)
 	GetCurrentUserSlugs(slug string, userID string) ([]entities.Blog, error)
 	GetCurrentUserBlog(blogID string, userID string) (*entities.Blog, error)
+
+	// GetAuthorBlogs returns every blog the user authored along with its content, newest first.
+	GetAuthorBlogs(userID string) ([]entities.Blog, error)
 	SaveBlog(blog *entities.Blog) error
+
+	// UpdateSlug changes the slug of the blog, keeping its previous slug in its history.
+	UpdateSlug(blog *entities.Blog, slug string) error
+
+	// GetSlugRedirect returns the current slug of the published blog
+	// the author used to publish under the given slug.
+	GetSlugRedirect(username string, slug string) (string, error)
+
+	// TransitionBlog saves the blog in its new state and records the transition,
+	// it returns false when the blog already left the state the transition starts from.
+	TransitionBlog(blog *entities.Blog, transition *entities.BlogTransition) (bool, error)
+	GetTransitions(blogID string) ([]dto.TransitionOutput, error)
+
+	GetFeed(userID string, cursor *types.FeedCursor, limit int) ([]entities.SafeBlogAuthor, error)
+
+	// GetPublishedCollection returns the published blogs of the author and/or with the tag
+	// along with their content, oldest first so they read in the order they were written.
+	GetPublishedCollection(username string, tag string, limit int) ([]entities.SafeBlogAuthor, error)
+	GetStaleRenderedBlogs(version int, limit int) ([]entities.Blog, error)
+	SaveRenderedContent(blogID string, rendered *types.RenderedMarkdown, version int) error
+
+	GetBlogValidator(opts *types.GetBlogOpts) (*types.BlogValidator, error)
+	GetBlogsValidator(username string) (*types.Validator, error)
+	GetFeedValidator(userID string) (*types.Validator, error)
 }
 
+// CO_AUTHOR_OF_SQL selects the blogs the user co-authors in any role,
+// EDITOR_OF_SQL only the ones they edit.
+const CO_AUTHOR_OF_SQL = "SELECT blog_id FROM co_authors WHERE user_id = ? AND accepted_at IS NOT NULL"
+const EDITOR_OF_SQL = CO_AUTHOR_OF_SQL + " AND role = '" + constants.CoAuthorEditor + "'"
+
+// Editing co-authors are listed on the blog, so they are part of its validator:
+// CO_AUTHORS_MODIFIED_SQL is when they last changed and CO_AUTHORS_COUNT_SQL how many there are.
+const CO_AUTHORS_WHERE_SQL = "WHERE co_authors.blog_id = blogs.id AND co_authors.accepted_at IS NOT NULL AND co_authors.role = '" + constants.CoAuthorEditor + "'"
+const CO_AUTHORS_MODIFIED_SQL = "(SELECT MAX(GREATEST(co_authors.updated_at, co_users.updated_at)) FROM co_authors JOIN users co_users ON co_authors.user_id = co_users.id " + CO_AUTHORS_WHERE_SQL + ")"
+const CO_AUTHORS_COUNT_SQL = "(SELECT COUNT(*) FROM co_authors " + CO_AUTHORS_WHERE_SQL + ")"
+
+// VALIDATOR_SELECT_SQL selects the validator of the joined blogs,
+// empty lists get the epoch instead of NULL as their last modification.
+const VALIDATOR_SELECT_SQL = "COUNT(*) + COALESCE(SUM(" + CO_AUTHORS_COUNT_SQL + "), 0) AS count, " +
+	"COALESCE(MAX(GREATEST(blogs.updated_at, users.updated_at, " + CO_AUTHORS_MODIFIED_SQL + ")), to_timestamp(0)) AS last_modified"
+
 type BlogRepoImpl struct {
 	db *gorm.DB
 }
@@ -41,7 +89,7 @@ func (repo *BlogRepoImpl) GetBlogs(onlyPublished bool, orderDesc bool, username
 	query := repo.db.Model(&entities.Blog{})
 
 	// Define SELECT and JOIN for database query operations
-	BLOG_SELECT_SQL := "blogs.id, blogs.slug, blogs.created_at, blogs.updated_at, blogs.published_at, blogs.title, blogs.summary, blogs.cover_url, blogs.author_id, blogs.prev, blogs.next, "
+	BLOG_SELECT_SQL := "blogs.id, blogs.slug, blogs.created_at, blogs.updated_at, blogs.published_at, blogs.title, blogs.summary, blogs.cover_url, blogs.tags, blogs.author_id, blogs.prev, blogs.next, blogs.version, "
 	AUTHOR_SELECT_SQL := "users.id AS author_id, users.username AS author_username, users.created_at AS author_created_at, users.bio AS author_bio, users.picture_url AS author_picture_url, users.is_tester AS author_is_tester"
 	JOIN_SQL := "JOIN users ON blogs.author_id = users.id"
 
@@ -105,6 +153,10 @@ func (repo *BlogRepoImpl) GetBlogs(onlyPublished bool, orderDesc bool, username
 		blogs = append(blogs, blog)
 	}
 
+	if err := repo.attachAuthors(blogs); err != nil {
+		return nil, err
+	}
+
 	return blogs, nil
 }
 
@@ -116,11 +168,11 @@ func (repo *BlogRepoImpl) GetBlog(opts *types.GetBlogOpts) (*entities.SafeBlogAu
 	var CONTENT_SELECT_SQL string
 
 	if opts.IncludeContent {
-		CONTENT_SELECT_SQL = "blogs.content, "
+		CONTENT_SELECT_SQL = "blogs.content, blogs.content_html, blogs.renderer_version, blogs.toc, blogs.word_count, blogs.reading_time, "
 	}
 
 	// Define SELECT and JOIN for database query operations
-	BLOG_SELECT_SQL := "blogs.id, blogs.slug, blogs.created_at, blogs.updated_at, blogs.published_at, blogs.title, blogs.summary, blogs.cover_url, blogs.author_id, blogs.prev, blogs.next, "
+	BLOG_SELECT_SQL := "blogs.id, blogs.slug, blogs.created_at, blogs.updated_at, blogs.published_at, blogs.title, blogs.summary, blogs.cover_url, blogs.tags, blogs.author_id, blogs.prev, blogs.next, blogs.version, "
 	AUTHOR_SELECT_SQL := "users.id AS author_id, users.username AS author_username, users.created_at AS author_created_at, users.bio AS author_bio, users.picture_url AS author_picture_url, users.is_tester AS author_is_tester"
 	JOIN_SQL := "JOIN users ON blogs.author_id = users.id"
 
@@ -188,7 +240,13 @@ func (repo *BlogRepoImpl) GetBlog(opts *types.GetBlogOpts) (*entities.SafeBlogAu
 		},
 	}
 
-	return &blog, nil
+	blogs := []entities.SafeBlogAuthor{blog}
+
+	if err := repo.attachAuthors(blogs); err != nil {
+		return nil, err
+	}
+
+	return &blogs[0], nil
 }
 
 func (repo *BlogRepoImpl) CreateBlog(input *entities.Blog) (*entities.Blog, error) {
@@ -202,9 +260,33 @@ func (repo *BlogRepoImpl) CreateBlog(input *entities.Blog) (*entities.Blog, erro
 	return newBlog, nil
 }
 
+func (repo *BlogRepoImpl) GetByID(blogID string) (*entities.Blog, error) {
+	var blog entities.Blog
+
+	if err := repo.db.First(&blog, "id = ?", blogID).Error; err != nil {
+		return nil, err
+	}
+
+	return &blog, nil
+}
+
+// GetByIDAndAuthor returns the blog when the user may edit it,
+// either as its author or as one of its editing co-authors.
 func (repo *BlogRepoImpl) GetByIDAndAuthor(blogID string, userID string) (*entities.Blog, error) {
 	var blog entities.Blog
 
+	err := repo.db.First(&blog, "id = ? AND (author_id = ? OR id IN ("+EDITOR_OF_SQL+"))", blogID, userID, userID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &blog, nil
+}
+
+// GetByIDAndOwner returns the blog only to its author, co-authors excluded.
+func (repo *BlogRepoImpl) GetByIDAndOwner(blogID string, userID string) (*entities.Blog, error) {
+	var blog entities.Blog
+
 	err := repo.db.First(&blog, "id = ? AND author_id = ?", blogID, userID).Error
 	if err != nil {
 		return nil, err
@@ -213,12 +295,15 @@ func (repo *BlogRepoImpl) GetByIDAndAuthor(blogID string, userID string) (*entit
 	return &blog, nil
 }
 
-func (repo *BlogRepoImpl) UpdateBlog(blogID string, safe *inputs.SafeUpdateBlogInput) error {
-	if err := repo.db.Model(&entities.Blog{}).Where("id = ?", blogID).Updates(&safe).Error; err != nil {
-		return err
+// UpdateBlog only updates the blog while it is still at the given version,
+// it returns false when someone else updated it first.
+func (repo *BlogRepoImpl) UpdateBlog(blogID string, version int, safe *inputs.SafeUpdateBlogInput) (bool, error) {
+	result := repo.db.Model(&entities.Blog{}).Where("id = ? AND version = ?", blogID, version).Updates(&safe)
+	if result.Error != nil {
+		return false, result.Error
 	}
 
-	return nil
+	return result.RowsAffected > 0, nil
 }
 
 func (repo *BlogRepoImpl) GetCurrentUserBlogs(userID string, desc bool) ([]entities.Blog, error) {
@@ -232,9 +317,9 @@ func (repo *BlogRepoImpl) GetCurrentUserBlogs(userID string, desc bool) ([]entit
 	}
 
 	if err := repo.db.
-		Omit("content").
+		Omit("content", "content_html").
 		Order(fmt.Sprintf("updated_at %s", queryOrder)).
-		Find(&blogs, "author_id = ?", userID).
+		Find(&blogs, "author_id = ? OR id IN ("+CO_AUTHOR_OF_SQL+")", userID, userID).
 		Error; err != nil {
 		return nil, err
 	}
@@ -255,13 +340,26 @@ func (repo *BlogRepoImpl) GetCurrentUserSlugs(slug string, userID string) ([]ent
 func (repo *BlogRepoImpl) GetCurrentUserBlog(blogID string, userID string) (*entities.Blog, error) {
 	var blog entities.Blog
 
-	if err := repo.db.First(&blog, "id = ? AND author_id = ?", blogID, userID).Error; err != nil {
+	if err := repo.db.First(&blog, "id = ? AND (author_id = ? OR id IN ("+CO_AUTHOR_OF_SQL+"))", blogID, userID, userID).Error; err != nil {
 		return nil, err
 	}
 
 	return &blog, nil
 }
 
+func (repo *BlogRepoImpl) GetAuthorBlogs(userID string) ([]entities.Blog, error) {
+	var blogs []entities.Blog
+
+	if err := repo.db.
+		Order("published_at DESC, created_at DESC").
+		Find(&blogs, "author_id = ?", userID).
+		Error; err != nil {
+		return nil, err
+	}
+
+	return blogs, nil
+}
+
 func (repo *BlogRepoImpl) SaveBlog(blog *entities.Blog) error {
 	if err := repo.db.Save(&blog).Error; err != nil {
 		return err
@@ -269,3 +367,375 @@ func (repo *BlogRepoImpl) SaveBlog(blog *entities.Blog) error {
 
 	return nil
 }
+
+func (repo *BlogRepoImpl) UpdateSlug(blog *entities.Blog, slug string) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if blog.Slug != "" {
+			// a slug reused by another blog of the author now leads there
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "author_id"}, {Name: "slug"}},
+				DoUpdates: clause.AssignmentColumns([]string{"blog_id", "created_at"}),
+			}).Create(&entities.BlogSlug{
+				AuthorID: blog.AuthorID,
+				Slug:     blog.Slug,
+				BlogID:   blog.ID,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&entities.Blog{}).
+			Where("id = ?", blog.ID).
+			Updates(map[string]interface{}{"slug": slug, "updated_at": time.Now()}).
+			Error
+	})
+}
+
+func (repo *BlogRepoImpl) GetSlugRedirect(username string, slug string) (string, error) {
+	var slugs []string
+
+	if err := repo.db.Model(&entities.BlogSlug{}).
+		Joins("JOIN blogs ON blog_slugs.blog_id = blogs.id AND blogs.deleted_at IS NULL").
+		Joins("JOIN users ON blog_slugs.author_id = users.id").
+		Where("users.username = ? AND blog_slugs.slug = ? AND blogs.published = ?", username, slug, true).
+		Limit(1).
+		Pluck("blogs.slug", &slugs).
+		Error; err != nil {
+		return "", err
+	}
+
+	if len(slugs) == 0 {
+		return "", gorm.ErrRecordNotFound
+	}
+
+	return slugs[0], nil
+}
+
+func (repo *BlogRepoImpl) TransitionBlog(blog *entities.Blog, transition *entities.BlogTransition) (bool, error) {
+	moved := false
+
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&entities.Blog{}).
+			Where("id = ? AND state = ?", blog.ID, transition.FromState).
+			Select("state", "published", "slug", "published_at", "updated_at", "content_html", "toc", "word_count", "reading_time", "renderer_version", "approved_version").
+			Updates(blog)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		moved = true
+
+		return tx.Create(transition).Error
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return moved, nil
+}
+
+// GetTransitions returns the history of the blog, oldest first.
+func (repo *BlogRepoImpl) GetTransitions(blogID string) ([]dto.TransitionOutput, error) {
+	var transitions []dto.TransitionOutput
+
+	if err := repo.db.Model(&entities.BlogTransition{}).
+		Select("blog_transitions.from_state, blog_transitions.to_state, blog_transitions.note, blog_transitions.created_at, users.username AS actor_username").
+		Joins("JOIN users ON blog_transitions.actor_id = users.id").
+		Where("blog_transitions.blog_id = ?", blogID).
+		Order("blog_transitions.created_at ASC").
+		Scan(&transitions).
+		Error; err != nil {
+		return nil, err
+	}
+
+	return transitions, nil
+}
+
+func (repo *BlogRepoImpl) GetFeed(userID string, cursor *types.FeedCursor, limit int) ([]entities.SafeBlogAuthor, error) {
+	var blogs []entities.SafeBlogAuthor
+
+	// Define SELECT and JOIN for database query operations
+	BLOG_SELECT_SQL := "blogs.id, blogs.slug, blogs.created_at, blogs.updated_at, blogs.published_at, blogs.title, blogs.summary, blogs.cover_url, blogs.tags, blogs.author_id, blogs.prev, blogs.next, blogs.version, "
+	AUTHOR_SELECT_SQL := "users.id AS author_id, users.username AS author_username, users.created_at AS author_created_at, users.bio AS author_bio, users.picture_url AS author_picture_url, users.is_tester AS author_is_tester"
+	JOIN_SQL := "JOIN users ON blogs.author_id = users.id"
+	FOLLOWING_SQL := "blogs.author_id IN (SELECT following_id FROM follows WHERE follower_id = ?)"
+
+	query := repo.db.Model(&entities.Blog{}).
+		Select(BLOG_SELECT_SQL+AUTHOR_SELECT_SQL).
+		Joins(JOIN_SQL).
+		Where("blogs.published = ?", true).
+		Where(FOLLOWING_SQL, userID)
+
+	// keyset pagination, only take blogs strictly older than the cursor
+	if cursor != nil {
+		query.Where("(blogs.published_at, blogs.id) < (?, ?)", cursor.PublishedAt, cursor.ID)
+	}
+
+	rows, err := query.
+		Order("blogs.published_at DESC, blogs.id DESC").
+		Limit(limit).
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var temp struct {
+			entities.SafeBlog
+			AuthorID         string    `gorm:"column:author_id"`
+			AuthorUsername   string    `gorm:"column:author_username"`
+			AuthorCreatedAt  time.Time `gorm:"column:author_created_at"`
+			AuthorBio        string    `gorm:"column:author_bio"`
+			AuthorPictureURL string    `gorm:"column:author_picture_url"`
+			AuthorIsTester   bool      `gorm:"column:author_is_tester"`
+		}
+
+		if err := repo.db.ScanRows(rows, &temp); err != nil {
+			return nil, err
+		}
+
+		blogs = append(blogs, entities.SafeBlogAuthor{
+			SafeBlog: temp.SafeBlog,
+			Author: entities.SafeUser{
+				ID:         temp.AuthorID,
+				Username:   temp.AuthorUsername,
+				CreatedAt:  temp.AuthorCreatedAt,
+				Bio:        temp.AuthorBio,
+				PictureURL: temp.AuthorPictureURL,
+				IsTester:   temp.AuthorIsTester,
+			},
+		})
+	}
+
+	if err := repo.attachAuthors(blogs); err != nil {
+		return nil, err
+	}
+
+	return blogs, nil
+}
+
+func (repo *BlogRepoImpl) GetPublishedCollection(username string, tag string, limit int) ([]entities.SafeBlogAuthor, error) {
+	var blogs []entities.SafeBlogAuthor
+
+	// Define SELECT and JOIN for database query operations
+	BLOG_SELECT_SQL := "blogs.id, blogs.slug, blogs.created_at, blogs.updated_at, blogs.published_at, blogs.title, blogs.summary, blogs.cover_url, blogs.tags, blogs.author_id, blogs.prev, blogs.next, blogs.version, "
+	CONTENT_SELECT_SQL := "blogs.content, blogs.content_html, blogs.renderer_version, blogs.toc, blogs.word_count, blogs.reading_time, "
+	AUTHOR_SELECT_SQL := "users.id AS author_id, users.username AS author_username, COALESCE(users.fullname, '') AS author_fullname, users.created_at AS author_created_at, users.bio AS author_bio, users.picture_url AS author_picture_url, users.is_tester AS author_is_tester"
+	JOIN_SQL := "JOIN users ON blogs.author_id = users.id"
+
+	query := repo.db.Model(&entities.Blog{}).
+		Select(BLOG_SELECT_SQL+CONTENT_SELECT_SQL+AUTHOR_SELECT_SQL).
+		Joins(JOIN_SQL).
+		Where("blogs.published = ?", true)
+
+	if username != "" {
+		query.Where("users.username = ?", username)
+	}
+
+	// tags are a JSON array, containment matches any of its elements
+	if tag != "" {
+		encoded, err := json.Marshal([]string{tag})
+		if err != nil {
+			return nil, err
+		}
+
+		query.Where("blogs.tags @> ?::jsonb", string(encoded))
+	}
+
+	rows, err := query.
+		Order("blogs.published_at ASC, blogs.id ASC").
+		Limit(limit).
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var temp struct {
+			entities.SafeBlog
+			AuthorID         string    `gorm:"column:author_id"`
+			AuthorUsername   string    `gorm:"column:author_username"`
+			AuthorFullname   string    `gorm:"column:author_fullname"`
+			AuthorCreatedAt  time.Time `gorm:"column:author_created_at"`
+			AuthorBio        string    `gorm:"column:author_bio"`
+			AuthorPictureURL string    `gorm:"column:author_picture_url"`
+			AuthorIsTester   bool      `gorm:"column:author_is_tester"`
+		}
+
+		if err := repo.db.ScanRows(rows, &temp); err != nil {
+			return nil, err
+		}
+
+		blogs = append(blogs, entities.SafeBlogAuthor{
+			SafeBlog: temp.SafeBlog,
+			Author: entities.SafeUser{
+				ID:         temp.AuthorID,
+				Username:   temp.AuthorUsername,
+				Fullname:   temp.AuthorFullname,
+				CreatedAt:  temp.AuthorCreatedAt,
+				Bio:        temp.AuthorBio,
+				PictureURL: temp.AuthorPictureURL,
+				IsTester:   temp.AuthorIsTester,
+			},
+		})
+	}
+
+	if err := repo.attachAuthors(blogs); err != nil {
+		return nil, err
+	}
+
+	return blogs, nil
+}
+
+// GetStaleRenderedBlogs returns blogs whose stored HTML was rendered by an older renderer version,
+// blogs rendered by a newer one are left to the instances running it during a rolling deploy.
+func (repo *BlogRepoImpl) GetStaleRenderedBlogs(version int, limit int) ([]entities.Blog, error) {
+	var blogs []entities.Blog
+
+	if err := repo.db.
+		Select("id", "content").
+		Where("renderer_version < ?", version).
+		Order("id ASC").
+		Limit(limit).
+		Find(&blogs).
+		Error; err != nil {
+		return nil, err
+	}
+
+	return blogs, nil
+}
+
+// SaveRenderedContent stores the rendered content unless the blog was already
+// rendered by the given version or a newer one meanwhile, e.g. by an edit of the author.
+func (repo *BlogRepoImpl) SaveRenderedContent(blogID string, rendered *types.RenderedMarkdown, version int) error {
+	if err := repo.db.Model(&entities.Blog{}).
+		Where("id = ? AND renderer_version < ?", blogID, version).
+		UpdateColumns(map[string]interface{}{
+			"content_html":     rendered.HTML,
+			"toc":              rendered.TOC,
+			"word_count":       rendered.WordCount,
+			"reading_time":     rendered.ReadingTime,
+			"renderer_version": version,
+		}).
+		Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetBlogValidator loads the validator of a single blog without its content,
+// the author is part of it since the blog embeds them.
+func (repo *BlogRepoImpl) GetBlogValidator(opts *types.GetBlogOpts) (*types.BlogValidator, error) {
+	var validator types.BlogValidator
+
+	// listed in the same order as attachAuthors does
+	CO_AUTHOR_IDS_SQL := "(SELECT string_agg(',' || co_authors.user_id, '' ORDER BY co_authors.accepted_at ASC) FROM co_authors " + CO_AUTHORS_WHERE_SQL + ")"
+
+	query := repo.db.Model(&entities.Blog{}).
+		Select("GREATEST(blogs.updated_at, users.updated_at, " + CO_AUTHORS_MODIFIED_SQL + ") AS last_modified, 1 + " + CO_AUTHORS_COUNT_SQL + " AS count, " +
+			"blogs.id AS blog_id, blogs.author_id || COALESCE(" + CO_AUTHOR_IDS_SQL + ", '') AS author_ids").
+		Joins("JOIN users ON blogs.author_id = users.id")
+
+	if opts.UseID != "" {
+		query.Where("blogs.id = ?", opts.UseID)
+	} else {
+		query.Where("blogs.slug = ? AND users.username = ?", opts.BlogSlug, opts.BlogAuthor)
+	}
+
+	if opts.Published {
+		query.Where("blogs.published = ?", true)
+	}
+
+	if err := query.Take(&validator).Error; err != nil {
+		return nil, err
+	}
+
+	return &validator, nil
+}
+
+// GetBlogsValidator loads the validator of the published blogs list,
+// restricted to a single author when the username is not empty.
+func (repo *BlogRepoImpl) GetBlogsValidator(username string) (*types.Validator, error) {
+	var validator types.Validator
+
+	query := repo.db.Model(&entities.Blog{}).
+		Select(VALIDATOR_SELECT_SQL).
+		Joins("JOIN users ON blogs.author_id = users.id").
+		Where("blogs.published = ?", true)
+
+	if username != "" {
+		query.Where("users.username = ?", username)
+	}
+
+	if err := query.Scan(&validator).Error; err != nil {
+		return nil, err
+	}
+
+	return &validator, nil
+}
+
+// GetFeedValidator loads the validator of the user feed,
+// following someone changes the feed even when their blogs are older.
+func (repo *BlogRepoImpl) GetFeedValidator(userID string) (*types.Validator, error) {
+	var validator types.Validator
+
+	LAST_FOLLOW_SQL := "(SELECT MAX(created_at) FROM follows WHERE follower_id = ?)"
+
+	if err := repo.db.Model(&entities.Blog{}).
+		Select("COUNT(*) + COALESCE(SUM("+CO_AUTHORS_COUNT_SQL+"), 0) AS count, "+
+			"GREATEST(MAX(GREATEST(blogs.updated_at, users.updated_at, "+CO_AUTHORS_MODIFIED_SQL+")), "+LAST_FOLLOW_SQL+", to_timestamp(0)) AS last_modified", userID).
+		Joins("JOIN users ON blogs.author_id = users.id").
+		Where("blogs.published = ?", true).
+		Where("blogs.author_id IN (SELECT following_id FROM follows WHERE follower_id = ?)", userID).
+		Scan(&validator).
+		Error; err != nil {
+		return nil, err
+	}
+
+	return &validator, nil
+}
+
+// attachAuthors lists the author of every blog followed by its editing co-authors into Authors.
+func (repo *BlogRepoImpl) attachAuthors(blogs []entities.SafeBlogAuthor) error {
+	if len(blogs) == 0 {
+		return nil
+	}
+
+	blogIDs := make([]string, len(blogs))
+	index := make(map[string]int, len(blogs))
+
+	for i := range blogs {
+		blogs[i].Authors = []entities.SafeUser{blogs[i].Author}
+		blogIDs[i] = blogs[i].ID
+		index[blogs[i].ID] = i
+	}
+
+	var coAuthors []struct {
+		BlogID string
+		entities.SafeUser
+	}
+
+	if err := repo.db.Model(&entities.CoAuthor{}).
+		Select("co_authors.blog_id, users.id, users.username, users.created_at, users.bio, users.picture_url, users.is_tester").
+		Joins("JOIN users ON co_authors.user_id = users.id").
+		Where("co_authors.blog_id IN ? AND co_authors.accepted_at IS NOT NULL AND co_authors.role = ?", blogIDs, constants.CoAuthorEditor).
+		Order("co_authors.accepted_at ASC").
+		Scan(&coAuthors).
+		Error; err != nil {
+		return err
+	}
+
+	for _, coAuthor := range coAuthors {
+		i := index[coAuthor.BlogID]
+		blogs[i].Authors = append(blogs[i].Authors, coAuthor.SafeUser)
+	}
+
+	return nil
+}
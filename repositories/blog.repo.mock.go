@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"github.com/stretchr/testify/mock"
+	"resqiar.com-server/dto"
 	"resqiar.com-server/entities"
 	"resqiar.com-server/inputs"
 	"resqiar.com-server/types"
@@ -41,14 +42,20 @@ func (repo *BlogRepoMock) CreateBlog(input *entities.Blog) (*entities.Blog, erro
 	return nil, args.Error(1)
 }
 
-func (repo *BlogRepoMock) UpdateBlog(blogID string, safe *inputs.SafeUpdateBlogInput) error {
-	args := repo.Mock.Called(blogID, safe)
+func (repo *BlogRepoMock) UpdateBlog(blogID string, version int, safe *inputs.SafeUpdateBlogInput) (bool, error) {
+	args := repo.Mock.Called(blogID, version, safe)
 
-	if args.Get(0) == nil {
-		return nil
+	return args.Bool(0), args.Error(1)
+}
+
+func (repo *BlogRepoMock) GetByID(blogID string) (*entities.Blog, error) {
+	args := repo.Mock.Called(blogID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*entities.Blog), args.Error(1)
 	}
 
-	return args.Error(0)
+	return nil, args.Error(1)
 }
 
 func (repo *BlogRepoMock) GetByIDAndAuthor(blogID string, userID string) (*entities.Blog, error) {
@@ -61,6 +68,16 @@ func (repo *BlogRepoMock) GetByIDAndAuthor(blogID string, userID string) (*entit
 	return nil, args.Error(1)
 }
 
+func (repo *BlogRepoMock) GetByIDAndOwner(blogID string, userID string) (*entities.Blog, error) {
+	args := repo.Mock.Called(blogID, userID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*entities.Blog), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
 func (repo *BlogRepoMock) GetCurrentUserBlogs(userID string, desc bool) ([]entities.Blog, error) {
 	args := repo.Mock.Called(userID, desc)
 
@@ -91,6 +108,16 @@ func (repo *BlogRepoMock) GetCurrentUserBlog(blogID string, userID string) (*ent
 	return nil, args.Error(1)
 }
 
+func (repo *BlogRepoMock) GetAuthorBlogs(userID string) ([]entities.Blog, error) {
+	args := repo.Mock.Called(userID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]entities.Blog), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
 func (repo *BlogRepoMock) SaveBlog(blog *entities.Blog) error {
 	args := repo.Mock.Called(blog)
 
@@ -100,3 +127,101 @@ func (repo *BlogRepoMock) SaveBlog(blog *entities.Blog) error {
 
 	return args.Error(0)
 }
+
+func (repo *BlogRepoMock) UpdateSlug(blog *entities.Blog, slug string) error {
+	args := repo.Mock.Called(blog, slug)
+
+	return args.Error(0)
+}
+
+func (repo *BlogRepoMock) GetSlugRedirect(username string, slug string) (string, error) {
+	args := repo.Mock.Called(username, slug)
+
+	return args.String(0), args.Error(1)
+}
+
+func (repo *BlogRepoMock) TransitionBlog(blog *entities.Blog, transition *entities.BlogTransition) (bool, error) {
+	args := repo.Mock.Called(blog, transition)
+
+	return args.Bool(0), args.Error(1)
+}
+
+func (repo *BlogRepoMock) GetTransitions(blogID string) ([]dto.TransitionOutput, error) {
+	args := repo.Mock.Called(blogID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]dto.TransitionOutput), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *BlogRepoMock) GetFeed(userID string, cursor *types.FeedCursor, limit int) ([]entities.SafeBlogAuthor, error) {
+	args := repo.Mock.Called(userID, cursor, limit)
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]entities.SafeBlogAuthor), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *BlogRepoMock) GetPublishedCollection(username string, tag string, limit int) ([]entities.SafeBlogAuthor, error) {
+	args := repo.Mock.Called(username, tag, limit)
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]entities.SafeBlogAuthor), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *BlogRepoMock) GetStaleRenderedBlogs(version int, limit int) ([]entities.Blog, error) {
+	args := repo.Mock.Called(version, limit)
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]entities.Blog), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *BlogRepoMock) SaveRenderedContent(blogID string, rendered *types.RenderedMarkdown, version int) error {
+	args := repo.Mock.Called(blogID, rendered, version)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
+
+func (repo *BlogRepoMock) GetBlogValidator(opts *types.GetBlogOpts) (*types.BlogValidator, error) {
+	args := repo.Mock.Called(opts)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*types.BlogValidator), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *BlogRepoMock) GetBlogsValidator(username string) (*types.Validator, error) {
+	args := repo.Mock.Called(username)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*types.Validator), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *BlogRepoMock) GetFeedValidator(userID string) (*types.Validator, error) {
+	args := repo.Mock.Called(userID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*types.Validator), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
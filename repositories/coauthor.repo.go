@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"time"
+
+	"resqiar.com-server/dto"
+	"resqiar.com-server/entities"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CoAuthorRepository interface {
+	// CreateInvitation reports whether a new invitation was created,
+	// inviting someone already invited or co-authoring is a no-op.
+	CreateInvitation(coAuthor *entities.CoAuthor) (bool, error)
+
+	// AcceptInvitation reports whether a pending invitation was accepted.
+	AcceptInvitation(blogID string, userID string) (bool, error)
+
+	// DeleteCoAuthor removes the co-author or their pending invitation,
+	// it returns the removed row, nil when there was none.
+	DeleteCoAuthor(blogID string, userID string) (*entities.CoAuthor, error)
+
+	GetCoAuthors(blogID string) ([]dto.CoAuthorOutput, error)
+	GetInvitations(userID string) ([]dto.InvitationOutput, error)
+}
+
+type CoAuthorRepoImpl struct {
+	db *gorm.DB
+}
+
+func InitCoAuthorRepo(db *gorm.DB) CoAuthorRepository {
+	return &CoAuthorRepoImpl{
+		db: db,
+	}
+}
+
+func (repo *CoAuthorRepoImpl) CreateInvitation(coAuthor *entities.CoAuthor) (bool, error) {
+	result := repo.db.Clauses(clause.OnConflict{DoNothing: true}).Create(coAuthor)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func (repo *CoAuthorRepoImpl) AcceptInvitation(blogID string, userID string) (bool, error) {
+	result := repo.db.Model(&entities.CoAuthor{}).
+		Where("blog_id = ? AND user_id = ? AND accepted_at IS NULL", blogID, userID).
+		Update("accepted_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func (repo *CoAuthorRepoImpl) DeleteCoAuthor(blogID string, userID string) (*entities.CoAuthor, error) {
+	var coAuthors []entities.CoAuthor
+
+	if err := repo.db.
+		Clauses(clause.Returning{}).
+		Where("blog_id = ? AND user_id = ?", blogID, userID).
+		Delete(&coAuthors).
+		Error; err != nil {
+		return nil, err
+	}
+
+	if len(coAuthors) == 0 {
+		return nil, nil
+	}
+
+	return &coAuthors[0], nil
+}
+
+// GetCoAuthors returns everyone the blog is shared with, pending invitations included.
+func (repo *CoAuthorRepoImpl) GetCoAuthors(blogID string) ([]dto.CoAuthorOutput, error) {
+	var coAuthors []dto.CoAuthorOutput
+
+	if err := repo.db.Model(&entities.CoAuthor{}).
+		Select("users.id AS user_id, users.username, users.picture_url, co_authors.role, co_authors.accepted_at").
+		Joins("JOIN users ON co_authors.user_id = users.id").
+		Where("co_authors.blog_id = ?", blogID).
+		Order("co_authors.created_at ASC").
+		Scan(&coAuthors).
+		Error; err != nil {
+		return nil, err
+	}
+
+	return coAuthors, nil
+}
+
+// GetInvitations returns the pending invitations of the user, newest first.
+func (repo *CoAuthorRepoImpl) GetInvitations(userID string) ([]dto.InvitationOutput, error) {
+	var invitations []dto.InvitationOutput
+
+	if err := repo.db.Model(&entities.CoAuthor{}).
+		Select("co_authors.blog_id, blogs.title AS blog_title, users.username AS invited_by, co_authors.role, co_authors.created_at").
+		Joins("JOIN blogs ON co_authors.blog_id = blogs.id").
+		Joins("JOIN users ON co_authors.invited_by = users.id").
+		Where("co_authors.user_id = ? AND co_authors.accepted_at IS NULL", userID).
+		Order("co_authors.created_at DESC").
+		Scan(&invitations).
+		Error; err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
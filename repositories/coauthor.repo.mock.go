@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"github.com/stretchr/testify/mock"
+	"resqiar.com-server/dto"
+	"resqiar.com-server/entities"
+)
+
+type CoAuthorRepoMock struct {
+	Mock mock.Mock
+}
+
+func (repo *CoAuthorRepoMock) CreateInvitation(coAuthor *entities.CoAuthor) (bool, error) {
+	args := repo.Mock.Called(coAuthor)
+
+	return args.Bool(0), args.Error(1)
+}
+
+func (repo *CoAuthorRepoMock) AcceptInvitation(blogID string, userID string) (bool, error) {
+	args := repo.Mock.Called(blogID, userID)
+
+	return args.Bool(0), args.Error(1)
+}
+
+func (repo *CoAuthorRepoMock) DeleteCoAuthor(blogID string, userID string) (*entities.CoAuthor, error) {
+	args := repo.Mock.Called(blogID, userID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*entities.CoAuthor), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *CoAuthorRepoMock) GetCoAuthors(blogID string) ([]dto.CoAuthorOutput, error) {
+	args := repo.Mock.Called(blogID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]dto.CoAuthorOutput), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *CoAuthorRepoMock) GetInvitations(userID string) ([]dto.InvitationOutput, error) {
+	args := repo.Mock.Called(userID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]dto.InvitationOutput), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
@@ -0,0 +1,347 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/types"
+)
+
+// every session with unsaved edits lives in this sorted set, scored by when it is due to be persisted
+const COLLAB_PENDING_KEY = "collab:pending"
+
+type CollabRepository interface {
+	// OpenCollabSession returns the session of the blog, starting one from the given content if there is none.
+	OpenCollabSession(blogID string, content string, version int) (*types.CollabSession, error)
+	GetCollabSession(blogID string) (*types.CollabSession, error)
+
+	// GetCollabOperations returns the operations applied after the given revision, oldest first.
+	GetCollabOperations(blogID string, revision int) ([]types.CollabOperation, error)
+
+	// AppendCollabOperation stores the operation and the document it produced only while
+	// the session is still at the revision before it, it returns false when another operation landed first.
+	// The session gets scheduled to be persisted at persistAt unless it already is.
+	AppendCollabOperation(blogID string, operation *types.CollabOperation, content string, persistAt time.Time) (bool, error)
+
+	// ClaimDueCollabSessions unschedules the sessions due by now and returns their blog IDs,
+	// every session is claimed by a single server instance.
+	ClaimDueCollabSessions(now time.Time, limit int) ([]string, error)
+	MarkCollabPersisted(blogID string, revision int, version int) error
+
+	SaveCollabPresence(blogID string, presence *types.CollabPresence) error
+	DeleteCollabPresence(blogID string, clientID string) error
+	GetCollabPresences(blogID string) ([]types.CollabPresence, error)
+}
+
+type CollabRepoImpl struct {
+	client redis.UniversalClient
+}
+
+func InitCollabRepo(client redis.UniversalClient) CollabRepository {
+	return &CollabRepoImpl{
+		client: client,
+	}
+}
+
+func (repo *CollabRepoImpl) OpenCollabSession(blogID string, content string, version int) (*types.CollabSession, error) {
+	ctx := context.Background()
+	key := collabKey(blogID)
+
+	var session *types.CollabSession
+
+	open := func(tx *redis.Tx) error {
+		existing, err := getCollabSession(ctx, tx, blogID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if existing == nil {
+				// operations of an expired session must not leak into the new one
+				pipe.Del(ctx, collabOperationsKey(blogID))
+				pipe.HSet(ctx, key,
+					"content", content,
+					"revision", 0,
+					"version", version,
+					"persisted", 0,
+				)
+			}
+
+			expireCollab(ctx, pipe, blogID)
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		session = existing
+		if session == nil {
+			session = &types.CollabSession{
+				BlogID:  blogID,
+				Content: content,
+				Version: version,
+			}
+		}
+
+		return nil
+	}
+
+	if err := repo.watch(ctx, open, key); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (repo *CollabRepoImpl) GetCollabSession(blogID string) (*types.CollabSession, error) {
+	session, err := getCollabSession(context.Background(), repo.client, blogID)
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil {
+		return nil, errors.New("Record not found")
+	}
+
+	return session, nil
+}
+
+func (repo *CollabRepoImpl) GetCollabOperations(blogID string, revision int) ([]types.CollabOperation, error) {
+	// the operation producing revision N is stored at index N-1
+	values, err := repo.client.LRange(context.Background(), collabOperationsKey(blogID), int64(revision), -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	operations := make([]types.CollabOperation, 0, len(values))
+
+	for _, value := range values {
+		var operation types.CollabOperation
+		if err := json.Unmarshal([]byte(value), &operation); err != nil {
+			return nil, err
+		}
+
+		operations = append(operations, operation)
+	}
+
+	return operations, nil
+}
+
+func (repo *CollabRepoImpl) AppendCollabOperation(blogID string, operation *types.CollabOperation, content string, persistAt time.Time) (bool, error) {
+	ctx := context.Background()
+	key := collabKey(blogID)
+
+	data, err := json.Marshal(operation)
+	if err != nil {
+		return false, err
+	}
+
+	var appended bool
+
+	appendOperation := func(tx *redis.Tx) error {
+		revision, err := tx.HGet(ctx, key, "revision").Int()
+		if err == redis.Nil {
+			return errors.New("Record not found")
+		}
+
+		if err != nil {
+			return err
+		}
+
+		// someone else got the revision first, the caller has to transform again
+		if revision != operation.Revision-1 {
+			appended = false
+			return nil
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key, "content", content, "revision", operation.Revision)
+			pipe.RPush(ctx, collabOperationsKey(blogID), data)
+			pipe.ZAddNX(ctx, COLLAB_PENDING_KEY, redis.Z{
+				Score:  float64(persistAt.Unix()),
+				Member: blogID,
+			})
+
+			expireCollab(ctx, pipe, blogID)
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		appended = true
+
+		return nil
+	}
+
+	if err := repo.watch(ctx, appendOperation, key); err != nil {
+		return false, err
+	}
+
+	return appended, nil
+}
+
+func (repo *CollabRepoImpl) ClaimDueCollabSessions(now time.Time, limit int) ([]string, error) {
+	ctx := context.Background()
+
+	members, err := repo.client.ZRangeByScore(ctx, COLLAB_PENDING_KEY, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.Unix(), 10),
+		Count: int64(limit),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var blogIDs []string
+
+	for _, member := range members {
+		// only the instance removing the member gets to persist it
+		removed, err := repo.client.ZRem(ctx, COLLAB_PENDING_KEY, member).Result()
+		if err != nil {
+			return blogIDs, err
+		}
+
+		if removed == 0 {
+			continue
+		}
+
+		blogIDs = append(blogIDs, member)
+	}
+
+	return blogIDs, nil
+}
+
+func (repo *CollabRepoImpl) MarkCollabPersisted(blogID string, revision int, version int) error {
+	ctx := context.Background()
+	key := collabKey(blogID)
+
+	mark := func(tx *redis.Tx) error {
+		exist, err := tx.Exists(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+
+		// expired meanwhile, do not leave a partial session behind
+		if exist == 0 {
+			return nil
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key, "persisted", revision, "version", version)
+			return nil
+		})
+
+		return err
+	}
+
+	return repo.watch(ctx, mark, key)
+}
+
+func (repo *CollabRepoImpl) SaveCollabPresence(blogID string, presence *types.CollabPresence) error {
+	ctx := context.Background()
+
+	data, err := json.Marshal(presence)
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, collabPresenceKey(blogID), presence.ClientID, data)
+		pipe.Expire(ctx, collabPresenceKey(blogID), constants.CollabSessionTTL)
+
+		return nil
+	})
+
+	return err
+}
+
+func (repo *CollabRepoImpl) DeleteCollabPresence(blogID string, clientID string) error {
+	return repo.client.HDel(context.Background(), collabPresenceKey(blogID), clientID).Err()
+}
+
+func (repo *CollabRepoImpl) GetCollabPresences(blogID string) ([]types.CollabPresence, error) {
+	values, err := repo.client.HVals(context.Background(), collabPresenceKey(blogID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	presences := make([]types.CollabPresence, 0, len(values))
+
+	for _, value := range values {
+		var presence types.CollabPresence
+		if err := json.Unmarshal([]byte(value), &presence); err != nil {
+			return nil, err
+		}
+
+		presences = append(presences, presence)
+	}
+
+	return presences, nil
+}
+
+// watch runs the transaction again as long as the watched keys change under it.
+func (repo *CollabRepoImpl) watch(ctx context.Context, fn func(tx *redis.Tx) error, keys ...string) error {
+	for {
+		err := repo.client.Watch(ctx, fn, keys...)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+}
+
+func getCollabSession(ctx context.Context, client redis.Cmdable, blogID string) (*types.CollabSession, error) {
+	values, err := client.HGetAll(ctx, collabKey(blogID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	session := types.CollabSession{
+		BlogID:  blogID,
+		Content: values["content"],
+	}
+
+	fields := map[string]*int{
+		"revision":  &session.Revision,
+		"version":   &session.Version,
+		"persisted": &session.Persisted,
+	}
+
+	for field, dest := range fields {
+		if *dest, err = strconv.Atoi(values[field]); err != nil {
+			return nil, err
+		}
+	}
+
+	return &session, nil
+}
+
+// expireCollab pushes back the expiry of every key of the session.
+func expireCollab(ctx context.Context, pipe redis.Pipeliner, blogID string) {
+	pipe.Expire(ctx, collabKey(blogID), constants.CollabSessionTTL)
+	pipe.Expire(ctx, collabOperationsKey(blogID), constants.CollabSessionTTL)
+	pipe.Expire(ctx, collabPresenceKey(blogID), constants.CollabSessionTTL)
+}
+
+func collabKey(blogID string) string {
+	return "collab:" + blogID
+}
+
+func collabOperationsKey(blogID string) string {
+	return "collab:" + blogID + ":operations"
+}
+
+func collabPresenceKey(blogID string) string {
+	return "collab:" + blogID + ":presence"
+}
@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"resqiar.com-server/types"
+)
+
+type CollabRepoMock struct {
+	Mock mock.Mock
+}
+
+func (repo *CollabRepoMock) OpenCollabSession(blogID string, content string, version int) (*types.CollabSession, error) {
+	args := repo.Mock.Called(blogID, content, version)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*types.CollabSession), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *CollabRepoMock) GetCollabSession(blogID string) (*types.CollabSession, error) {
+	args := repo.Mock.Called(blogID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*types.CollabSession), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *CollabRepoMock) GetCollabOperations(blogID string, revision int) ([]types.CollabOperation, error) {
+	args := repo.Mock.Called(blogID, revision)
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]types.CollabOperation), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *CollabRepoMock) AppendCollabOperation(blogID string, operation *types.CollabOperation, content string, persistAt time.Time) (bool, error) {
+	args := repo.Mock.Called(blogID, operation, content, persistAt)
+
+	return args.Bool(0), args.Error(1)
+}
+
+func (repo *CollabRepoMock) ClaimDueCollabSessions(now time.Time, limit int) ([]string, error) {
+	args := repo.Mock.Called(now, limit)
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]string), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *CollabRepoMock) MarkCollabPersisted(blogID string, revision int, version int) error {
+	args := repo.Mock.Called(blogID, revision, version)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
+
+func (repo *CollabRepoMock) SaveCollabPresence(blogID string, presence *types.CollabPresence) error {
+	args := repo.Mock.Called(blogID, presence)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
+
+func (repo *CollabRepoMock) DeleteCollabPresence(blogID string, clientID string) error {
+	args := repo.Mock.Called(blogID, clientID)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
+
+func (repo *CollabRepoMock) GetCollabPresences(blogID string) ([]types.CollabPresence, error) {
+	args := repo.Mock.Called(blogID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]types.CollabPresence), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
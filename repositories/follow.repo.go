@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"resqiar.com-server/entities"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FollowRepository interface {
+	CreateFollow(followerID string, followingID string) (bool, error)
+	DeleteFollow(followerID string, followingID string) error
+	IsFollowing(followerID string, followingID string) (bool, error)
+}
+
+type FollowRepoImpl struct {
+	db *gorm.DB
+}
+
+func InitFollowRepo(db *gorm.DB) FollowRepository {
+	return &FollowRepoImpl{
+		db: db,
+	}
+}
+
+// CreateFollow reports whether a new follow was created,
+// following the same user twice is a no-op.
+func (repo *FollowRepoImpl) CreateFollow(followerID string, followingID string) (bool, error) {
+	follow := entities.Follow{
+		FollowerID:  followerID,
+		FollowingID: followingID,
+	}
+
+	result := repo.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&follow)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func (repo *FollowRepoImpl) DeleteFollow(followerID string, followingID string) error {
+	if err := repo.db.
+		Where("follower_id = ? AND following_id = ?", followerID, followingID).
+		Delete(&entities.Follow{}).
+		Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *FollowRepoImpl) IsFollowing(followerID string, followingID string) (bool, error) {
+	var count int64
+
+	if err := repo.db.Model(&entities.Follow{}).
+		Where("follower_id = ? AND following_id = ?", followerID, followingID).
+		Count(&count).
+		Error; err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
@@ -0,0 +1,31 @@
+package repositories
+
+import (
+	"github.com/stretchr/testify/mock"
+)
+
+type FollowRepoMock struct {
+	Mock mock.Mock
+}
+
+func (repo *FollowRepoMock) CreateFollow(followerID string, followingID string) (bool, error) {
+	args := repo.Mock.Called(followerID, followingID)
+
+	return args.Bool(0), args.Error(1)
+}
+
+func (repo *FollowRepoMock) DeleteFollow(followerID string, followingID string) error {
+	args := repo.Mock.Called(followerID, followingID)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
+
+func (repo *FollowRepoMock) IsFollowing(followerID string, followingID string) (bool, error) {
+	args := repo.Mock.Called(followerID, followingID)
+
+	return args.Bool(0), args.Error(1)
+}
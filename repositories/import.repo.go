@@ -0,0 +1,180 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ImportRepository interface {
+	CreateJob(job *entities.ImportJob) error
+
+	// GetJob returns the job of the user without its archive.
+	GetJob(jobID string, userID string) (*entities.ImportJob, error)
+
+	// ClaimPendingJob atomically takes the oldest job nobody is running,
+	// it returns nil when there is nothing left to import.
+	ClaimPendingJob(timeout time.Duration) (*entities.ImportJob, error)
+
+	// SaveProgress stores the items processed so far along with their counts.
+	SaveProgress(job *entities.ImportJob) error
+
+	// FinishJob stores the final status of the job and drops its archive.
+	FinishJob(job *entities.ImportJob) error
+
+	// GetImportedBlog returns the ID of the blog the post was imported as for the author,
+	// it is empty when the post was never imported or its blog was deleted since.
+	GetImportedBlog(source string, authorID string, externalID string) (string, error)
+
+	// CreateImportedBlog creates the blog along with where it was imported from,
+	// it returns false when the post was imported meanwhile and creates nothing.
+	// A post whose blog was deleted is imported as the new blog.
+	CreateImportedBlog(blog *entities.Blog, imported *entities.ImportedPost) (bool, error)
+}
+
+type ImportRepoImpl struct {
+	db *gorm.DB
+}
+
+func InitImportRepo(db *gorm.DB) ImportRepository {
+	return &ImportRepoImpl{
+		db: db,
+	}
+}
+
+func (repo *ImportRepoImpl) CreateJob(job *entities.ImportJob) error {
+	if err := repo.db.Create(job).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *ImportRepoImpl) GetJob(jobID string, userID string) (*entities.ImportJob, error) {
+	var job entities.ImportJob
+
+	if err := repo.db.Omit("archive").First(&job, "id = ? AND user_id = ?", jobID, userID).Error; err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// ClaimPendingJob also takes running jobs started before the timeout,
+// they belong to a crashed instance and resume from the items they processed.
+func (repo *ImportRepoImpl) ClaimPendingJob(timeout time.Duration) (*entities.ImportJob, error) {
+	var jobs []entities.ImportJob
+
+	PENDING_SQL := "SELECT id FROM import_jobs " +
+		"WHERE status = ? OR (status = ? AND started_at < ?) " +
+		"ORDER BY created_at ASC LIMIT 1 FOR UPDATE SKIP LOCKED"
+
+	now := time.Now()
+
+	if err := repo.db.Model(&jobs).
+		Clauses(clause.Returning{}).
+		Where("id = (?)", gorm.Expr(PENDING_SQL, constants.ImportPending, constants.ImportRunning, now.Add(-timeout))).
+		Updates(map[string]interface{}{
+			"status":     constants.ImportRunning,
+			"started_at": now,
+		}).
+		Error; err != nil {
+		return nil, err
+	}
+
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+
+	return &jobs[0], nil
+}
+
+func (repo *ImportRepoImpl) SaveProgress(job *entities.ImportJob) error {
+	if err := repo.db.Model(job).
+		Select("processed", "created", "failed", "skipped", "items").
+		Updates(job).
+		Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *ImportRepoImpl) FinishJob(job *entities.ImportJob) error {
+	now := time.Now()
+
+	job.Archive = nil
+	job.FinishedAt = &now
+
+	if err := repo.db.Model(job).
+		Select("status", "processed", "created", "failed", "skipped", "items", "error", "archive", "finished_at").
+		Updates(job).
+		Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *ImportRepoImpl) GetImportedBlog(source string, authorID string, externalID string) (string, error) {
+	var imported []entities.ImportedPost
+
+	if err := repo.db.
+		Joins("JOIN blogs ON blogs.id = imported_posts.blog_id AND blogs.deleted_at IS NULL").
+		Where("imported_posts.source = ? AND imported_posts.author_id = ? AND imported_posts.external_id = ?", source, authorID, externalID).
+		Limit(1).
+		Find(&imported).
+		Error; err != nil {
+		return "", err
+	}
+
+	if len(imported) == 0 {
+		return "", nil
+	}
+
+	return imported[0].BlogID, nil
+}
+
+var errAlreadyImported = errors.New("Post was already imported")
+
+func (repo *ImportRepoImpl) CreateImportedBlog(blog *entities.Blog, imported *entities.ImportedPost) (bool, error) {
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(blog).Error; err != nil {
+			return err
+		}
+
+		imported.BlogID = blog.ID
+
+		// the blog of a deleted post is replaced, a live one means the post was imported meanwhile
+		result := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "source"}, {Name: "author_id"}, {Name: "external_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"blog_id", "created_at"}),
+			Where: clause.Where{Exprs: []clause.Expression{
+				clause.Expr{SQL: "NOT EXISTS (SELECT 1 FROM blogs WHERE blogs.id = imported_posts.blog_id AND blogs.deleted_at IS NULL)"},
+			}},
+		}).Create(imported)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		// another import got there first, drop the blog created above
+		if result.RowsAffected == 0 {
+			return errAlreadyImported
+		}
+
+		return nil
+	})
+	if errors.Is(err, errAlreadyImported) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"resqiar.com-server/entities"
+)
+
+type ImportRepoMock struct {
+	Mock mock.Mock
+}
+
+func (repo *ImportRepoMock) CreateJob(job *entities.ImportJob) error {
+	args := repo.Mock.Called(job)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
+
+func (repo *ImportRepoMock) GetJob(jobID string, userID string) (*entities.ImportJob, error) {
+	args := repo.Mock.Called(jobID, userID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*entities.ImportJob), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *ImportRepoMock) ClaimPendingJob(timeout time.Duration) (*entities.ImportJob, error) {
+	args := repo.Mock.Called(timeout)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*entities.ImportJob), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *ImportRepoMock) SaveProgress(job *entities.ImportJob) error {
+	args := repo.Mock.Called(job)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
+
+func (repo *ImportRepoMock) FinishJob(job *entities.ImportJob) error {
+	args := repo.Mock.Called(job)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
+
+func (repo *ImportRepoMock) GetImportedBlog(source string, authorID string, externalID string) (string, error) {
+	args := repo.Mock.Called(source, authorID, externalID)
+
+	return args.String(0), args.Error(1)
+}
+
+func (repo *ImportRepoMock) CreateImportedBlog(blog *entities.Blog, imported *entities.ImportedPost) (bool, error) {
+	args := repo.Mock.Called(blog, imported)
+
+	return args.Bool(0), args.Error(1)
+}
@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"resqiar.com-server/entities"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IndieAuthRepository interface {
+	CreateCode(code *entities.IndieAuthCode) error
+
+	// TakeCode deletes the unexpired code and returns it, a code can only be taken once.
+	// It returns nil when there is no such code.
+	TakeCode(code string) (*entities.IndieAuthCode, error)
+
+	CreateToken(token *entities.AccessToken) error
+
+	// GetToken returns the unexpired token, or nil when there is no such token.
+	GetToken(token string) (*entities.AccessToken, error)
+	DeleteToken(token string) error
+}
+
+type IndieAuthRepoImpl struct {
+	db *gorm.DB
+}
+
+func InitIndieAuthRepo(db *gorm.DB) IndieAuthRepository {
+	return &IndieAuthRepoImpl{
+		db: db,
+	}
+}
+
+func (repo *IndieAuthRepoImpl) CreateCode(code *entities.IndieAuthCode) error {
+	if err := repo.db.Create(code).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *IndieAuthRepoImpl) TakeCode(code string) (*entities.IndieAuthCode, error) {
+	var codes []entities.IndieAuthCode
+
+	// expired codes are swept along
+	if err := repo.db.
+		Clauses(clause.Returning{}).
+		Where("code = ? OR expires_at <= ?", code, time.Now()).
+		Delete(&codes).
+		Error; err != nil {
+		return nil, err
+	}
+
+	for _, taken := range codes {
+		if taken.Code == code && taken.ExpiresAt.After(time.Now()) {
+			return &taken, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (repo *IndieAuthRepoImpl) CreateToken(token *entities.AccessToken) error {
+	if err := repo.db.Create(token).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *IndieAuthRepoImpl) GetToken(token string) (*entities.AccessToken, error) {
+	var accessToken entities.AccessToken
+
+	err := repo.db.First(&accessToken, "token = ? AND expires_at > ?", token, time.Now()).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &accessToken, nil
+}
+
+func (repo *IndieAuthRepoImpl) DeleteToken(token string) error {
+	if err := repo.db.Delete(&entities.AccessToken{}, "token = ?", token).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
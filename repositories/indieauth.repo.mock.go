@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"github.com/stretchr/testify/mock"
+	"resqiar.com-server/entities"
+)
+
+type IndieAuthRepoMock struct {
+	Mock mock.Mock
+}
+
+func (repo *IndieAuthRepoMock) CreateCode(code *entities.IndieAuthCode) error {
+	args := repo.Mock.Called(code)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
+
+func (repo *IndieAuthRepoMock) TakeCode(code string) (*entities.IndieAuthCode, error) {
+	args := repo.Mock.Called(code)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*entities.IndieAuthCode), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *IndieAuthRepoMock) CreateToken(token *entities.AccessToken) error {
+	args := repo.Mock.Called(token)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
+
+func (repo *IndieAuthRepoMock) GetToken(token string) (*entities.AccessToken, error) {
+	args := repo.Mock.Called(token)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*entities.AccessToken), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *IndieAuthRepoMock) DeleteToken(token string) error {
+	args := repo.Mock.Called(token)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
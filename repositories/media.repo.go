@@ -0,0 +1,40 @@
+package repositories
+
+import (
+	"resqiar.com-server/entities"
+
+	"gorm.io/gorm"
+)
+
+type MediaRepository interface {
+	CreateMedia(media *entities.MediaFile) (*entities.MediaFile, error)
+	GetMedia(ID string) (*entities.MediaFile, error)
+}
+
+type MediaRepoImpl struct {
+	db *gorm.DB
+}
+
+func InitMediaRepo(db *gorm.DB) MediaRepository {
+	return &MediaRepoImpl{
+		db: db,
+	}
+}
+
+func (repo *MediaRepoImpl) CreateMedia(media *entities.MediaFile) (*entities.MediaFile, error) {
+	if err := repo.db.Create(media).Error; err != nil {
+		return nil, err
+	}
+
+	return media, nil
+}
+
+func (repo *MediaRepoImpl) GetMedia(ID string) (*entities.MediaFile, error) {
+	var media entities.MediaFile
+
+	if err := repo.db.First(&media, "id = ?", ID).Error; err != nil {
+		return nil, err
+	}
+
+	return &media, nil
+}
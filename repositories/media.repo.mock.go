@@ -0,0 +1,30 @@
+package repositories
+
+import (
+	"github.com/stretchr/testify/mock"
+	"resqiar.com-server/entities"
+)
+
+type MediaRepoMock struct {
+	Mock mock.Mock
+}
+
+func (repo *MediaRepoMock) CreateMedia(media *entities.MediaFile) (*entities.MediaFile, error) {
+	args := repo.Mock.Called(media)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*entities.MediaFile), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *MediaRepoMock) GetMedia(ID string) (*entities.MediaFile, error) {
+	args := repo.Mock.Called(ID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*entities.MediaFile), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
@@ -0,0 +1,165 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"resqiar.com-server/entities"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NewsletterRepository interface {
+	// FindSubscriber returns a "404" error when the email is not subscribed to the author.
+	FindSubscriber(email string, authorID string) (*entities.Subscriber, error)
+	FindSubscriberByToken(token string) (*entities.Subscriber, error)
+	FindSubscriberByConfirmToken(token string) (*entities.Subscriber, error)
+	SaveSubscriber(subscriber *entities.Subscriber) error
+	GetSubscribers(authorID string) ([]entities.Subscriber, error)
+	GetIssueRecipients(authorID string) ([]entities.Subscriber, error)
+
+	CreateIssue(blogID string) error
+	ClaimPendingIssue(timeout time.Duration) (*entities.NewsletterIssue, error)
+	MarkIssueSent(blogID string, recipients int) error
+}
+
+type NewsletterRepoImpl struct {
+	db *gorm.DB
+}
+
+func InitNewsletterRepo(db *gorm.DB) NewsletterRepository {
+	return &NewsletterRepoImpl{
+		db: db,
+	}
+}
+
+func (repo *NewsletterRepoImpl) FindSubscriber(email string, authorID string) (*entities.Subscriber, error) {
+	var subscriber entities.Subscriber
+
+	if err := repo.db.First(&subscriber, "email = ? AND author_id = ?", email, authorID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("404")
+		}
+
+		return nil, err
+	}
+
+	return &subscriber, nil
+}
+
+func (repo *NewsletterRepoImpl) FindSubscriberByToken(token string) (*entities.Subscriber, error) {
+	var subscriber entities.Subscriber
+
+	if err := repo.db.First(&subscriber, "token = ?", token).Error; err != nil {
+		return nil, err
+	}
+
+	return &subscriber, nil
+}
+
+func (repo *NewsletterRepoImpl) FindSubscriberByConfirmToken(token string) (*entities.Subscriber, error) {
+	var subscriber entities.Subscriber
+
+	// subscribers from before confirm tokens existed have an empty one
+	if err := repo.db.First(&subscriber, "confirm_token = ? AND confirm_token <> ''", token).Error; err != nil {
+		return nil, err
+	}
+
+	return &subscriber, nil
+}
+
+func (repo *NewsletterRepoImpl) SaveSubscriber(subscriber *entities.Subscriber) error {
+	if err := repo.db.Save(subscriber).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetSubscribers returns every confirmed and still subscribed reader of the author,
+// an empty authorID returns the site-wide subscribers.
+func (repo *NewsletterRepoImpl) GetSubscribers(authorID string) ([]entities.Subscriber, error) {
+	var subscribers []entities.Subscriber
+
+	if err := repo.db.
+		Where("author_id = ? AND confirmed_at IS NOT NULL AND unsubscribed_at IS NULL", authorID).
+		Order("created_at ASC").
+		Find(&subscribers).
+		Error; err != nil {
+		return nil, err
+	}
+
+	return subscribers, nil
+}
+
+// GetIssueRecipients returns the confirmed subscribers of the author along with the site-wide ones,
+// the same email may appear twice if the reader subscribed to both.
+func (repo *NewsletterRepoImpl) GetIssueRecipients(authorID string) ([]entities.Subscriber, error) {
+	var subscribers []entities.Subscriber
+
+	if err := repo.db.
+		Where("author_id IN (?, '') AND confirmed_at IS NOT NULL AND unsubscribed_at IS NULL", authorID).
+		Order("author_id DESC").
+		Find(&subscribers).
+		Error; err != nil {
+		return nil, err
+	}
+
+	return subscribers, nil
+}
+
+// CreateIssue queues the blog for sending, blogs already queued are ignored
+// so republishing a blog never sends it twice.
+func (repo *NewsletterRepoImpl) CreateIssue(blogID string) error {
+	issue := entities.NewsletterIssue{
+		BlogID: blogID,
+	}
+
+	if err := repo.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&issue).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ClaimPendingIssue atomically takes the oldest issue nobody is sending,
+// so multiple server instances never send the same issue at once.
+// It returns nil when there is nothing left to send.
+func (repo *NewsletterRepoImpl) ClaimPendingIssue(timeout time.Duration) (*entities.NewsletterIssue, error) {
+	var issues []entities.NewsletterIssue
+
+	PENDING_SQL := "SELECT blog_id FROM newsletter_issues " +
+		"WHERE sent_at IS NULL AND (started_at IS NULL OR started_at < ?) " +
+		"ORDER BY created_at ASC LIMIT 1 FOR UPDATE SKIP LOCKED"
+
+	now := time.Now()
+
+	if err := repo.db.Model(&issues).
+		Clauses(clause.Returning{}).
+		Where("blog_id = (?)", gorm.Expr(PENDING_SQL, now.Add(-timeout))).
+		Update("started_at", now).
+		Error; err != nil {
+		return nil, err
+	}
+
+	if len(issues) == 0 {
+		return nil, nil
+	}
+
+	return &issues[0], nil
+}
+
+func (repo *NewsletterRepoImpl) MarkIssueSent(blogID string, recipients int) error {
+	if err := repo.db.Model(&entities.NewsletterIssue{}).
+		Where("blog_id = ?", blogID).
+		Updates(map[string]interface{}{
+			"sent_at":    time.Now(),
+			"recipients": recipients,
+		}).
+		Error; err != nil {
+		return err
+	}
+
+	return nil
+}
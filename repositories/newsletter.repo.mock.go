@@ -0,0 +1,102 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"resqiar.com-server/entities"
+)
+
+type NewsletterRepoMock struct {
+	Mock mock.Mock
+}
+
+func (repo *NewsletterRepoMock) FindSubscriber(email string, authorID string) (*entities.Subscriber, error) {
+	args := repo.Mock.Called(email, authorID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*entities.Subscriber), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *NewsletterRepoMock) FindSubscriberByToken(token string) (*entities.Subscriber, error) {
+	args := repo.Mock.Called(token)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*entities.Subscriber), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *NewsletterRepoMock) FindSubscriberByConfirmToken(token string) (*entities.Subscriber, error) {
+	args := repo.Mock.Called(token)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*entities.Subscriber), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *NewsletterRepoMock) SaveSubscriber(subscriber *entities.Subscriber) error {
+	args := repo.Mock.Called(subscriber)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
+
+func (repo *NewsletterRepoMock) GetSubscribers(authorID string) ([]entities.Subscriber, error) {
+	args := repo.Mock.Called(authorID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]entities.Subscriber), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *NewsletterRepoMock) GetIssueRecipients(authorID string) ([]entities.Subscriber, error) {
+	args := repo.Mock.Called(authorID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]entities.Subscriber), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *NewsletterRepoMock) CreateIssue(blogID string) error {
+	args := repo.Mock.Called(blogID)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
+
+func (repo *NewsletterRepoMock) ClaimPendingIssue(timeout time.Duration) (*entities.NewsletterIssue, error) {
+	args := repo.Mock.Called(timeout)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*entities.NewsletterIssue), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *NewsletterRepoMock) MarkIssueSent(blogID string, recipients int) error {
+	args := repo.Mock.Called(blogID, recipients)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
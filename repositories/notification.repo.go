@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"errors"
+
+	"resqiar.com-server/entities"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationRepository interface {
+	CreateNotification(notification *entities.Notification) (*entities.Notification, error)
+	GetNotifications(userID string, unreadOnly bool, limit int) ([]entities.Notification, error)
+	CountUnread(userID string) (int64, error)
+	MarkRead(notificationID string, userID string) error
+	MarkAllRead(userID string) error
+}
+
+type NotificationRepoImpl struct {
+	db *gorm.DB
+}
+
+func InitNotificationRepo(db *gorm.DB) NotificationRepository {
+	return &NotificationRepoImpl{
+		db: db,
+	}
+}
+
+func (repo *NotificationRepoImpl) CreateNotification(notification *entities.Notification) (*entities.Notification, error) {
+	if err := repo.db.Clauses(clause.Returning{}).Create(notification).Error; err != nil {
+		return nil, err
+	}
+
+	return notification, nil
+}
+
+func (repo *NotificationRepoImpl) GetNotifications(userID string, unreadOnly bool, limit int) ([]entities.Notification, error) {
+	var notifications []entities.Notification
+
+	query := repo.db.Where("user_id = ?", userID)
+
+	if unreadOnly {
+		query.Where("read = ?", false)
+	}
+
+	if err := query.
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&notifications).
+		Error; err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+func (repo *NotificationRepoImpl) CountUnread(userID string) (int64, error) {
+	var count int64
+
+	if err := repo.db.Model(&entities.Notification{}).
+		Where("user_id = ? AND read = ?", userID, false).
+		Count(&count).
+		Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (repo *NotificationRepoImpl) MarkRead(notificationID string, userID string) error {
+	result := repo.db.Model(&entities.Notification{}).
+		Where("id = ? AND user_id = ?", notificationID, userID).
+		Update("read", true)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	// notification does not exist or belongs to another user
+	if result.RowsAffected == 0 {
+		return errors.New("Record not found")
+	}
+
+	return nil
+}
+
+func (repo *NotificationRepoImpl) MarkAllRead(userID string) error {
+	if err := repo.db.Model(&entities.Notification{}).
+		Where("user_id = ? AND read = ?", userID, false).
+		Update("read", true).
+		Error; err != nil {
+		return err
+	}
+
+	return nil
+}
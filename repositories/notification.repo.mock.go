@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"github.com/stretchr/testify/mock"
+	"resqiar.com-server/entities"
+)
+
+type NotificationRepoMock struct {
+	Mock mock.Mock
+}
+
+func (repo *NotificationRepoMock) CreateNotification(notification *entities.Notification) (*entities.Notification, error) {
+	args := repo.Mock.Called(notification)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*entities.Notification), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *NotificationRepoMock) GetNotifications(userID string, unreadOnly bool, limit int) ([]entities.Notification, error) {
+	args := repo.Mock.Called(userID, unreadOnly, limit)
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]entities.Notification), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *NotificationRepoMock) CountUnread(userID string) (int64, error) {
+	args := repo.Mock.Called(userID)
+
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (repo *NotificationRepoMock) MarkRead(notificationID string, userID string) error {
+	args := repo.Mock.Called(notificationID, userID)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
+
+func (repo *NotificationRepoMock) MarkAllRead(userID string) error {
+	args := repo.Mock.Called(userID)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"errors"
+
+	"resqiar.com-server/dto"
+	"resqiar.com-server/entities"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PreferenceRepository interface {
+	GetPreference(userID string) (*entities.NotificationPreference, error)
+	SavePreference(preference *entities.NotificationPreference) error
+	GetNewPostRecipients(authorID string) ([]dto.MailRecipient, error)
+	GetDigestRecipients() ([]dto.MailRecipient, error)
+}
+
+type PreferenceRepoImpl struct {
+	db *gorm.DB
+}
+
+func InitPreferenceRepo(db *gorm.DB) PreferenceRepository {
+	return &PreferenceRepoImpl{
+		db: db,
+	}
+}
+
+// GetPreference returns the stored preference of the user,
+// or the default preference if the user never changed it.
+func (repo *PreferenceRepoImpl) GetPreference(userID string) (*entities.NotificationPreference, error) {
+	var preference entities.NotificationPreference
+
+	err := repo.db.First(&preference, "user_id = ?", userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return entities.DefaultNotificationPreference(userID), nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &preference, nil
+}
+
+func (repo *PreferenceRepoImpl) SavePreference(preference *entities.NotificationPreference) error {
+	if err := repo.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(preference).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetNewPostRecipients returns every follower of the author who wants new blog emails
+func (repo *PreferenceRepoImpl) GetNewPostRecipients(authorID string) ([]dto.MailRecipient, error) {
+	var recipients []dto.MailRecipient
+
+	if err := repo.db.Table("follows").
+		Select("users.id AS user_id, users.email, users.username").
+		Joins("JOIN users ON follows.follower_id = users.id AND users.deleted_at IS NULL").
+		Joins("LEFT JOIN notification_preferences ON notification_preferences.user_id = users.id").
+		Where("follows.following_id = ?", authorID).
+		Where("COALESCE(notification_preferences.email_new_post, true) = ?", true).
+		Scan(&recipients).
+		Error; err != nil {
+		return nil, err
+	}
+
+	return recipients, nil
+}
+
+// GetDigestRecipients returns every user following at least one author who wants the weekly digest
+func (repo *PreferenceRepoImpl) GetDigestRecipients() ([]dto.MailRecipient, error) {
+	var recipients []dto.MailRecipient
+
+	if err := repo.db.Model(&entities.User{}).
+		Select("users.id AS user_id, users.email, users.username").
+		Joins("LEFT JOIN notification_preferences ON notification_preferences.user_id = users.id").
+		Where("EXISTS (SELECT 1 FROM follows WHERE follows.follower_id = users.id)").
+		Where("COALESCE(notification_preferences.email_digest, true) = ?", true).
+		Scan(&recipients).
+		Error; err != nil {
+		return nil, err
+	}
+
+	return recipients, nil
+}
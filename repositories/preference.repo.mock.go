@@ -0,0 +1,51 @@
+package repositories
+
+import (
+	"github.com/stretchr/testify/mock"
+	"resqiar.com-server/dto"
+	"resqiar.com-server/entities"
+)
+
+type PreferenceRepoMock struct {
+	Mock mock.Mock
+}
+
+func (repo *PreferenceRepoMock) GetPreference(userID string) (*entities.NotificationPreference, error) {
+	args := repo.Mock.Called(userID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*entities.NotificationPreference), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *PreferenceRepoMock) SavePreference(preference *entities.NotificationPreference) error {
+	args := repo.Mock.Called(preference)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
+
+func (repo *PreferenceRepoMock) GetNewPostRecipients(authorID string) ([]dto.MailRecipient, error) {
+	args := repo.Mock.Called(authorID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]dto.MailRecipient), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *PreferenceRepoMock) GetDigestRecipients() ([]dto.MailRecipient, error) {
+	args := repo.Mock.Called()
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]dto.MailRecipient), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
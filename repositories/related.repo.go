@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"time"
+
+	"resqiar.com-server/entities"
+
+	"gorm.io/gorm"
+)
+
+type RelatedRepository interface {
+	GetPublishedCorpus() ([]entities.Blog, error)
+	ReplaceRelated(related map[string][]entities.RelatedBlog) error
+	GetRelated(blogID string, limit int) ([]entities.SafeBlogAuthor, error)
+}
+
+type RelatedRepoImpl struct {
+	db *gorm.DB
+}
+
+func InitRelatedRepo(db *gorm.DB) RelatedRepository {
+	return &RelatedRepoImpl{
+		db: db,
+	}
+}
+
+func (repo *RelatedRepoImpl) GetPublishedCorpus() ([]entities.Blog, error) {
+	var blogs []entities.Blog
+
+	if err := repo.db.
+		Select("id", "title", "summary", "content", "author_id").
+		Find(&blogs, "published = ?", true).
+		Error; err != nil {
+		return nil, err
+	}
+
+	return blogs, nil
+}
+
+// ReplaceRelated swaps the stored neighbours of every given blog with the new ones
+// inside a single transaction, so readers never observe a half-written list.
+func (repo *RelatedRepoImpl) ReplaceRelated(related map[string][]entities.RelatedBlog) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		// neighbours of blogs which are no longer part of the corpus are stale
+		if err := tx.Where("1 = 1").Delete(&entities.RelatedBlog{}).Error; err != nil {
+			return err
+		}
+
+		for _, neighbours := range related {
+			if len(neighbours) == 0 {
+				continue
+			}
+
+			if err := tx.Create(&neighbours).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (repo *RelatedRepoImpl) GetRelated(blogID string, limit int) ([]entities.SafeBlogAuthor, error) {
+	var blogs []entities.SafeBlogAuthor
+
+	// Define SELECT and JOIN for database query operations
+	BLOG_SELECT_SQL := "blogs.id, blogs.slug, blogs.created_at, blogs.updated_at, blogs.published_at, blogs.title, blogs.summary, blogs.cover_url, blogs.tags, blogs.author_id, blogs.prev, blogs.next, "
+	AUTHOR_SELECT_SQL := "users.id AS author_id, users.username AS author_username, users.created_at AS author_created_at, users.bio AS author_bio, users.picture_url AS author_picture_url, users.is_tester AS author_is_tester"
+	JOIN_SQL := "JOIN blogs ON related_blogs.related_id = blogs.id JOIN users ON blogs.author_id = users.id"
+
+	rows, err := repo.db.Model(&entities.RelatedBlog{}).
+		Select(BLOG_SELECT_SQL+AUTHOR_SELECT_SQL).
+		Joins(JOIN_SQL).
+		Where("related_blogs.blog_id = ? AND blogs.published = ? AND blogs.deleted_at IS NULL", blogID, true).
+		Order("related_blogs.rank ASC").
+		Limit(limit).
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var temp struct {
+			entities.SafeBlog
+			AuthorID         string    `gorm:"column:author_id"`
+			AuthorUsername   string    `gorm:"column:author_username"`
+			AuthorCreatedAt  time.Time `gorm:"column:author_created_at"`
+			AuthorBio        string    `gorm:"column:author_bio"`
+			AuthorPictureURL string    `gorm:"column:author_picture_url"`
+			AuthorIsTester   bool      `gorm:"column:author_is_tester"`
+		}
+
+		if err := repo.db.ScanRows(rows, &temp); err != nil {
+			return nil, err
+		}
+
+		blogs = append(blogs, entities.SafeBlogAuthor{
+			SafeBlog: temp.SafeBlog,
+			Author: entities.SafeUser{
+				ID:         temp.AuthorID,
+				Username:   temp.AuthorUsername,
+				CreatedAt:  temp.AuthorCreatedAt,
+				Bio:        temp.AuthorBio,
+				PictureURL: temp.AuthorPictureURL,
+				IsTester:   temp.AuthorIsTester,
+			},
+		})
+	}
+
+	return blogs, nil
+}
@@ -0,0 +1,40 @@
+package repositories
+
+import (
+	"github.com/stretchr/testify/mock"
+	"resqiar.com-server/entities"
+)
+
+type RelatedRepoMock struct {
+	Mock mock.Mock
+}
+
+func (repo *RelatedRepoMock) GetPublishedCorpus() ([]entities.Blog, error) {
+	args := repo.Mock.Called()
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]entities.Blog), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *RelatedRepoMock) ReplaceRelated(related map[string][]entities.RelatedBlog) error {
+	args := repo.Mock.Called(related)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
+
+func (repo *RelatedRepoMock) GetRelated(blogID string, limit int) ([]entities.SafeBlogAuthor, error) {
+	args := repo.Mock.Called(blogID, limit)
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]entities.SafeBlogAuthor), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"time"
+
+	"resqiar.com-server/constants"
+	"resqiar.com-server/dto"
+	"resqiar.com-server/entities"
+
+	"gorm.io/gorm"
+)
+
+type ReviewRepository interface {
+	// GetReviewQueue returns the blogs waiting for a review, longest waiting first.
+	GetReviewQueue() ([]entities.Blog, error)
+
+	CreateNote(note *entities.ReviewNote) (*entities.ReviewNote, error)
+	GetNotes(blogID string) ([]dto.ReviewNoteOutput, error)
+
+	// ResolveNote reports whether an unresolved note of the blog was resolved.
+	ResolveNote(noteID string, blogID string) (bool, error)
+}
+
+type ReviewRepoImpl struct {
+	db *gorm.DB
+}
+
+func InitReviewRepo(db *gorm.DB) ReviewRepository {
+	return &ReviewRepoImpl{
+		db: db,
+	}
+}
+
+func (repo *ReviewRepoImpl) GetReviewQueue() ([]entities.Blog, error) {
+	var blogs []entities.Blog
+
+	if err := repo.db.
+		Omit("content", "content_html").
+		Order("updated_at ASC").
+		Find(&blogs, "state = ?", constants.BlogInReview).
+		Error; err != nil {
+		return nil, err
+	}
+
+	return blogs, nil
+}
+
+func (repo *ReviewRepoImpl) CreateNote(note *entities.ReviewNote) (*entities.ReviewNote, error) {
+	if err := repo.db.Create(note).Error; err != nil {
+		return nil, err
+	}
+
+	return note, nil
+}
+
+// GetNotes returns every note of the blog in reading order.
+func (repo *ReviewRepoImpl) GetNotes(blogID string) ([]dto.ReviewNoteOutput, error) {
+	var notes []dto.ReviewNoteOutput
+
+	if err := repo.db.Model(&entities.ReviewNote{}).
+		Select("review_notes.id, review_notes.version, review_notes.start_line, review_notes.end_line, review_notes.body, review_notes.created_at, review_notes.resolved_at, users.username AS reviewer_username").
+		Joins("JOIN users ON review_notes.reviewer_id = users.id").
+		Where("review_notes.blog_id = ?", blogID).
+		Order("review_notes.start_line ASC, review_notes.created_at ASC").
+		Scan(&notes).
+		Error; err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+func (repo *ReviewRepoImpl) ResolveNote(noteID string, blogID string) (bool, error) {
+	result := repo.db.Model(&entities.ReviewNote{}).
+		Where("id = ? AND blog_id = ? AND resolved_at IS NULL", noteID, blogID).
+		Update("resolved_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"github.com/stretchr/testify/mock"
+	"resqiar.com-server/dto"
+	"resqiar.com-server/entities"
+)
+
+type ReviewRepoMock struct {
+	Mock mock.Mock
+}
+
+func (repo *ReviewRepoMock) GetReviewQueue() ([]entities.Blog, error) {
+	args := repo.Mock.Called()
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]entities.Blog), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *ReviewRepoMock) CreateNote(note *entities.ReviewNote) (*entities.ReviewNote, error) {
+	args := repo.Mock.Called(note)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*entities.ReviewNote), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *ReviewRepoMock) GetNotes(blogID string) ([]dto.ReviewNoteOutput, error) {
+	args := repo.Mock.Called(blogID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]dto.ReviewNoteOutput), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *ReviewRepoMock) ResolveNote(noteID string, blogID string) (bool, error) {
+	args := repo.Mock.Called(noteID, blogID)
+
+	return args.Bool(0), args.Error(1)
+}
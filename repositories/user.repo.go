@@ -3,11 +3,18 @@ package repositories
 import (
 	"resqiar.com-server/entities"
 	"resqiar.com-server/inputs"
+	"resqiar.com-server/types"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
+// select every SafeUser column along with its follower and following counts
+const SAFE_USER_SELECT_SQL = "users.id, users.created_at, users.fullname, users.username, users.bio, users.picture_url, " +
+	"users.website_url, users.github_url, users.linkedin_url, users.instagram_url, users.twitter_url, users.youtube_url, users.is_tester, " +
+	"(SELECT COUNT(*) FROM follows WHERE follows.following_id = users.id) AS follower_count, " +
+	"(SELECT COUNT(*) FROM follows WHERE follows.follower_id = users.id) AS following_count"
+
 type UserRepoImpl struct {
 	db *gorm.DB
 }
@@ -25,6 +32,10 @@ type UserRepository interface {
 	FindByID(ID string) (*entities.SafeUser, error)
 	FindByUsername(username string) (*entities.SafeUser, error)
 	UpdateUser(ID string, payload *inputs.UpdateUserInput) error
+	GetProfileValidator(username string) (*types.Validator, error)
+
+	// IsReviewer reports whether the user may review blogs of other authors.
+	IsReviewer(ID string) (bool, error)
 }
 
 func (repo *UserRepoImpl) GetUsernameList() ([]string, error) {
@@ -60,7 +71,7 @@ func (repo *UserRepoImpl) FindByEmail(email string) (*entities.User, error) {
 func (repo *UserRepoImpl) FindByID(ID string) (*entities.SafeUser, error) {
 	var user entities.SafeUser
 
-	result := repo.db.Model(&entities.User{}).First(&user, "id = ?", ID)
+	result := repo.db.Model(&entities.User{}).Select(SAFE_USER_SELECT_SQL).First(&user, "id = ?", ID)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -71,7 +82,7 @@ func (repo *UserRepoImpl) FindByID(ID string) (*entities.SafeUser, error) {
 func (repo *UserRepoImpl) FindByUsername(username string) (*entities.SafeUser, error) {
 	var user entities.SafeUser
 
-	result := repo.db.Model(&entities.User{}).First(&user, "username = ?", username)
+	result := repo.db.Model(&entities.User{}).Select(SAFE_USER_SELECT_SQL).First(&user, "username = ?", username)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -86,3 +97,34 @@ func (repo *UserRepoImpl) UpdateUser(ID string, payload *inputs.UpdateUserInput)
 
 	return nil
 }
+
+// GetProfileValidator loads the validator of a public profile,
+// follows are part of it since the profile shows the follow counts.
+func (repo *UserRepoImpl) GetProfileValidator(username string) (*types.Validator, error) {
+	var validator types.Validator
+
+	FOLLOWS_SQL := "FROM follows WHERE follows.following_id = users.id OR follows.follower_id = users.id"
+
+	if err := repo.db.Model(&entities.User{}).
+		Select("(SELECT COUNT(*) "+FOLLOWS_SQL+") AS count, GREATEST(users.updated_at, (SELECT MAX(follows.created_at) "+FOLLOWS_SQL+")) AS last_modified").
+		Where("users.username = ?", username).
+		Take(&validator).
+		Error; err != nil {
+		return nil, err
+	}
+
+	return &validator, nil
+}
+
+func (repo *UserRepoImpl) IsReviewer(ID string) (bool, error) {
+	var count int64
+
+	err := repo.db.Model(&entities.User{}).
+		Where("id = ? AND (is_reviewer = ? OR is_admin = ?)", ID, true, true).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
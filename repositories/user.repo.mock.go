@@ -6,6 +6,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"resqiar.com-server/entities"
 	"resqiar.com-server/inputs"
+	"resqiar.com-server/types"
 )
 
 type UserRepoMock struct {
@@ -51,6 +52,10 @@ func (repo *UserRepoMock) FindByEmail(email string) (*entities.User, error) {
 func (repo *UserRepoMock) FindByID(ID string) (*entities.SafeUser, error) {
 	args := repo.Mock.Called(ID)
 
+	if user, ok := args.Get(0).(*entities.SafeUser); ok {
+		return user, nil
+	}
+
 	validID := "example-of-valid-id"
 
 	if args.Get(0) == validID {
@@ -67,6 +72,10 @@ func (repo *UserRepoMock) FindByID(ID string) (*entities.SafeUser, error) {
 func (repo *UserRepoMock) FindByUsername(username string) (*entities.SafeUser, error) {
 	args := repo.Mock.Called(username)
 
+	if user, ok := args.Get(0).(*entities.SafeUser); ok {
+		return user, nil
+	}
+
 	existUsername := "example-of-valid-username"
 
 	if args.Get(0) == existUsername {
@@ -89,3 +98,19 @@ func (repo *UserRepoMock) UpdateUser(ID string, payload *inputs.UpdateUserInput)
 
 	return args.Error(0)
 }
+
+func (repo *UserRepoMock) GetProfileValidator(username string) (*types.Validator, error) {
+	args := repo.Mock.Called(username)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*types.Validator), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *UserRepoMock) IsReviewer(ID string) (bool, error) {
+	args := repo.Mock.Called(ID)
+
+	return args.Bool(0), args.Error(1)
+}
@@ -0,0 +1,138 @@
+package repositories
+
+import (
+	"time"
+
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WebmentionRepository interface {
+	// SaveMention queues the webmention for verification,
+	// a webmention sent again is verified again.
+	SaveMention(mention *entities.Webmention) error
+
+	// CountRecentMentions returns how many webmentions the host sent since the given time.
+	CountRecentMentions(sourceHost string, since time.Time) (int64, error)
+
+	// ClaimPendingMention atomically takes the oldest webmention which is due for verification,
+	// it returns nil when there is nothing left to verify.
+	ClaimPendingMention(timeout time.Duration) (*entities.Webmention, error)
+
+	// FinishMention stores the outcome of the verification and when to try again, if ever.
+	FinishMention(mention *entities.Webmention) error
+
+	// GetMentions returns the verified webmentions of the blog, oldest first.
+	GetMentions(blogID string) ([]entities.Webmention, error)
+
+	GetSentWebmentions(blogID string) ([]entities.SentWebmention, error)
+	SaveSentWebmention(sent *entities.SentWebmention) error
+}
+
+type WebmentionRepoImpl struct {
+	db *gorm.DB
+}
+
+func InitWebmentionRepo(db *gorm.DB) WebmentionRepository {
+	return &WebmentionRepoImpl{
+		db: db,
+	}
+}
+
+func (repo *WebmentionRepoImpl) SaveMention(mention *entities.Webmention) error {
+	if err := repo.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "source"}, {Name: "target"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"updated_at", "source_host", "blog_id", "status", "next_attempt_at", "attempts", "error",
+		}),
+	}).Create(mention).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *WebmentionRepoImpl) CountRecentMentions(sourceHost string, since time.Time) (int64, error) {
+	var count int64
+
+	if err := repo.db.Model(&entities.Webmention{}).
+		Where("source_host = ? AND updated_at >= ?", sourceHost, since).
+		Count(&count).
+		Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// ClaimPendingMention pushes the next attempt of the claimed webmention past the timeout,
+// should the instance crash while verifying another one verifies it after the timeout.
+func (repo *WebmentionRepoImpl) ClaimPendingMention(timeout time.Duration) (*entities.Webmention, error) {
+	var mentions []entities.Webmention
+
+	PENDING_SQL := "SELECT id FROM webmentions " +
+		"WHERE status = ? AND next_attempt_at <= ? " +
+		"ORDER BY next_attempt_at ASC LIMIT 1 FOR UPDATE SKIP LOCKED"
+
+	now := time.Now()
+
+	if err := repo.db.Model(&mentions).
+		Clauses(clause.Returning{}).
+		Where("id = (?)", gorm.Expr(PENDING_SQL, constants.WebmentionPending, now)).
+		Update("next_attempt_at", now.Add(timeout)).
+		Error; err != nil {
+		return nil, err
+	}
+
+	if len(mentions) == 0 {
+		return nil, nil
+	}
+
+	return &mentions[0], nil
+}
+
+func (repo *WebmentionRepoImpl) FinishMention(mention *entities.Webmention) error {
+	if err := repo.db.Model(mention).
+		Select("title", "status", "next_attempt_at", "attempts", "error", "verified_at").
+		Updates(mention).
+		Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *WebmentionRepoImpl) GetMentions(blogID string) ([]entities.Webmention, error) {
+	var mentions []entities.Webmention
+
+	if err := repo.db.
+		Where("blog_id = ? AND status = ?", blogID, constants.WebmentionVerified).
+		Order("verified_at ASC").
+		Find(&mentions).
+		Error; err != nil {
+		return nil, err
+	}
+
+	return mentions, nil
+}
+
+func (repo *WebmentionRepoImpl) GetSentWebmentions(blogID string) ([]entities.SentWebmention, error) {
+	var sent []entities.SentWebmention
+
+	if err := repo.db.Where("blog_id = ?", blogID).Find(&sent).Error; err != nil {
+		return nil, err
+	}
+
+	return sent, nil
+}
+
+func (repo *WebmentionRepoImpl) SaveSentWebmention(sent *entities.SentWebmention) error {
+	if err := repo.db.Save(sent).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
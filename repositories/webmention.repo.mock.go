@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"resqiar.com-server/entities"
+)
+
+type WebmentionRepoMock struct {
+	Mock mock.Mock
+}
+
+func (repo *WebmentionRepoMock) SaveMention(mention *entities.Webmention) error {
+	args := repo.Mock.Called(mention)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
+
+func (repo *WebmentionRepoMock) CountRecentMentions(sourceHost string, since time.Time) (int64, error) {
+	args := repo.Mock.Called(sourceHost, since)
+
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (repo *WebmentionRepoMock) ClaimPendingMention(timeout time.Duration) (*entities.Webmention, error) {
+	args := repo.Mock.Called(timeout)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*entities.Webmention), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *WebmentionRepoMock) FinishMention(mention *entities.Webmention) error {
+	args := repo.Mock.Called(mention)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
+
+func (repo *WebmentionRepoMock) GetMentions(blogID string) ([]entities.Webmention, error) {
+	args := repo.Mock.Called(blogID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]entities.Webmention), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *WebmentionRepoMock) GetSentWebmentions(blogID string) ([]entities.SentWebmention, error) {
+	args := repo.Mock.Called(blogID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]entities.SentWebmention), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (repo *WebmentionRepoMock) SaveSentWebmention(sent *entities.SentWebmention) error {
+	args := repo.Mock.Called(sent)
+
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	return args.Error(0)
+}
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"resqiar.com-server/handlers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitActivityPubRoute(server *fiber.App, handler handlers.ActivityPubHandler) {
+	// remote servers look actors up by their username first
+	server.Get("/.well-known/webfinger", handler.SendWebFinger)
+
+	// activities are authenticated by their HTTP signature, not by a session
+	activityPub := server.Group("/ap")
+
+	activityPub.Get("/users/:id", handler.SendActor)
+	activityPub.Get("/users/:id/outbox", handler.SendOutbox)
+	activityPub.Get("/users/:id/followers", handler.SendFollowers)
+	activityPub.Post("/users/:id/inbox", handler.SendInbox)
+	activityPub.Get("/blogs/:id", handler.SendArticle)
+}
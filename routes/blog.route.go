@@ -17,16 +17,22 @@ func InitBlogRoute(server *fiber.App, handler handlers.BlogHandler) {
 	blog.Get("/list/slug", handler.SendPublishedSlugs)
 	blog.Get("/get/published/:id", handler.SendPublishedBlogByID)
 	blog.Get("/get/:author/:slug", handler.SendPublishedBlog)
+	blog.Get("/get/:author/:slug/related", handler.SendRelatedBlogs)
 	blog.Get("/get/:author", handler.SendAuthorPublishedBlogs)
 
 	blog.Post("/list/current", middlewares.ProtectedRoute, handler.SendCurrentUserBlogs)
 	blog.Post("/get/preview", middlewares.ProtectedRoute, handler.SendCurrentUserBlog)
 	blog.Post("/get/my", middlewares.ProtectedRoute, handler.SendMyBlog)
+	blog.Get("/feed", middlewares.ProtectedRoute, handler.SendFeed)
+	blog.Post("/feed", middlewares.ProtectedRoute, handler.SendFeed)
 
 	blog.Post("/create", middlewares.ProtectedRoute, handler.SendBlogCreate)
 	blog.Post("/publish", middlewares.ProtectedRoute, handler.SendPublishBlog)
 	blog.Post("/unpublish", middlewares.ProtectedRoute, handler.SendUnpublishBlog)
 	blog.Post("/update", middlewares.ProtectedRoute, handler.SendUpdateBlog)
+	blog.Post("/slug", middlewares.ProtectedRoute, handler.SendUpdateSlug)
+	blog.Post("/autosave", middlewares.ProtectedRoute, handler.SendAutosave)
+	blog.Post("/autosave/get", middlewares.ProtectedRoute, handler.SendUnsavedAutosave)
 
 	// =========== SPECIAL ROUTES FOR ADM ONLY ===========
 	blogADM := server.Group("/blog/adm", middlewares.ProtectedRoute, middlewares.AdminRoute)
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"resqiar.com-server/handlers"
+	"resqiar.com-server/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitCoAuthorRoute(server *fiber.App, handler handlers.CoAuthorHandler) {
+	coAuthor := server.Group("/blog/coauthor", middlewares.ProtectedRoute)
+
+	// only the author of the blog can share it or take it back
+	coAuthor.Post("/invite", handler.SendInvite)
+	coAuthor.Post("/remove", handler.SendRemoveCoAuthor)
+
+	// invitations of the current user
+	coAuthor.Get("/invitations", handler.SendInvitations)
+	coAuthor.Post("/accept", handler.SendAcceptInvitation)
+	coAuthor.Post("/decline", handler.SendDeclineInvitation)
+
+	coAuthor.Post("/list", handler.SendCoAuthors)
+}
@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"os"
+	"strings"
+
+	"resqiar.com-server/handlers"
+	"resqiar.com-server/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+func InitCollabRoute(server *fiber.App, handler handlers.CollabHandler) {
+	collab := server.Group("/blog/collab", middlewares.ProtectedRoute)
+
+	// the session cookie is sent along by any page opening the socket,
+	// only the client may open one, as CORS only allows it to make requests
+	origins := strings.Split(os.Getenv("CORS_CLIENT_URL"), ",")
+	for i := range origins {
+		origins[i] = strings.TrimSpace(origins[i])
+	}
+
+	// WebSocket handshakes are GET requests carrying the session cookie
+	collab.Get("/:id", handler.SendCollabUpgrade, websocket.New(handler.SendCollabSession, websocket.Config{
+		Origins: origins,
+	}))
+}
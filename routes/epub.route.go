@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"resqiar.com-server/handlers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitEpubRoute(server *fiber.App, handler handlers.EpubHandler) {
+	// books are made of published blogs only, they are public as well
+	epub := server.Group("/blog/epub")
+
+	epub.Get("/author/:author", handler.SendAuthorEpub)
+	epub.Get("/tag/:tag", handler.SendTagEpub)
+	epub.Get("/series/:id", handler.SendSeriesEpub)
+}
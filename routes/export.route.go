@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"resqiar.com-server/handlers"
+	"resqiar.com-server/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitExportRoute(server *fiber.App, handler handlers.ExportHandler) {
+	server.Get("/blog/export", middlewares.ProtectedRoute, handler.SendExport)
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"resqiar.com-server/handlers"
+	"resqiar.com-server/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitImportRoute(server *fiber.App, handler handlers.ImportHandler) {
+	blogImport := server.Group("/blog/import", middlewares.ProtectedRoute)
+
+	blogImport.Post("/", handler.SendCreateImport)
+	blogImport.Get("/:id", handler.SendImport)
+
+	// imports for other authors, e.g. moving a whole WordPress blog over
+	blogImportADM := server.Group("/blog/import/adm", middlewares.ProtectedRoute, middlewares.AdminRoute)
+
+	blogImportADM.Post("/wordpress", handler.SendCreateWordPressImport)
+}
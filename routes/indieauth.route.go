@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"resqiar.com-server/handlers"
+	"resqiar.com-server/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitIndieAuthRoute(server *fiber.App, handler handlers.IndieAuthHandler) {
+	server.Get("/.well-known/oauth-authorization-server", handler.SendMetadata)
+
+	// the profile URL users sign in to clients with
+	server.Get("/indieauth/users/:username", handler.SendProfile)
+
+	indieAuth := server.Group("/auth/indieauth")
+
+	// the consent page of the client asks these on behalf of the signed in user
+	indieAuth.Get("/authorize", middlewares.ProtectedRoute, handler.SendAuthorization)
+	indieAuth.Post("/authorize", middlewares.ProtectedRoute, handler.SendApproveAuthorization)
+
+	// clients authenticate with the authorization code and their PKCE verifier
+	indieAuth.Post("/token", handler.SendToken)
+	indieAuth.Get("/token", handler.SendVerifyToken)
+	indieAuth.Post("/revoke", handler.SendRevokeToken)
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"resqiar.com-server/handlers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitMailRoute(server *fiber.App, handler handlers.MailHandler) {
+	mail := server.Group("/mail")
+
+	mail.Get("/unsubscribe", handler.SendUnsubscribePage)
+	mail.Post("/unsubscribe", handler.SendUnsubscribe)
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"resqiar.com-server/handlers"
+	"resqiar.com-server/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitMicropubRoute(server *fiber.App, handler handlers.MicropubHandler) {
+	micropub := server.Group("/micropub")
+
+	// clients are authenticated by their IndieAuth access token, not by a session
+	micropub.Get("/", middlewares.BearerRoute, handler.SendQuery)
+	micropub.Post("/", middlewares.BearerRoute, handler.SendMicropub)
+	micropub.Post("/media", middlewares.BearerRoute, handler.SendUploadMedia)
+
+	micropub.Get("/media/:id", handler.SendMedia)
+	micropub.Get("/posts/:id", handler.SendPost)
+}
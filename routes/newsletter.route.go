@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"resqiar.com-server/handlers"
+	"resqiar.com-server/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitNewsletterRoute(server *fiber.App, handler handlers.NewsletterHandler) {
+	newsletter := server.Group("/newsletter")
+
+	// anonymous readers, no account required
+	newsletter.Post("/subscribe", handler.SendSubscribe)
+	newsletter.Get("/confirm", handler.SendConfirm)
+	newsletter.Get("/unsubscribe", handler.SendUnsubscribePage)
+	newsletter.Post("/unsubscribe", handler.SendUnsubscribe)
+
+	// authors can only export their own subscribers
+	newsletter.Get("/export", middlewares.ProtectedRoute, handler.SendAuthorExport)
+
+	// =========== SPECIAL ROUTES FOR ADM ONLY ===========
+	newsletterADM := server.Group("/newsletter/adm", middlewares.ProtectedRoute, middlewares.AdminRoute)
+	newsletterADM.Get("/export", handler.SendSiteExport)
+}
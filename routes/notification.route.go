@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"resqiar.com-server/handlers"
+	"resqiar.com-server/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitNotificationRoute(server *fiber.App, handler handlers.NotificationHandler) {
+	notification := server.Group("/notifications", middlewares.ProtectedRoute)
+
+	notification.Post("/list", handler.SendNotifications)
+	notification.Post("/read", handler.SendMarkRead)
+	notification.Post("/read/all", handler.SendMarkAllRead)
+
+	// EventSource can only issue GET requests
+	notification.Get("/stream", handler.SendNotificationStream)
+}
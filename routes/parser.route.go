@@ -9,4 +9,5 @@ import (
 func InitParserRoute(server *fiber.App, handler handlers.ParserHandler) {
 	parser := server.Group("/parser")
 	parser.Post("/html", handler.ParseMDtoHTML)
+	parser.Post("/document", handler.ParseMDtoDocument)
 }
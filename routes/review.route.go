@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"resqiar.com-server/handlers"
+	"resqiar.com-server/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitReviewRoute(server *fiber.App, handler handlers.ReviewHandler) {
+	review := server.Group("/blog/review", middlewares.ProtectedRoute)
+
+	// authors and reviewers, each transition checks the role it needs
+	review.Post("/transition", handler.SendTransitionBlog)
+	review.Post("/history", handler.SendTransitions)
+	review.Post("/note/list", handler.SendNotes)
+	review.Post("/note/resolve", handler.SendResolveNote)
+
+	// reviewers only
+	review.Get("/queue", middlewares.ReviewerRoute, handler.SendReviewQueue)
+	review.Post("/get", middlewares.ReviewerRoute, handler.SendReviewBlog)
+	review.Post("/note/create", middlewares.ReviewerRoute, handler.SendCreateNote)
+}
@@ -17,4 +17,13 @@ func InitUserRoute(server *fiber.App, handler handlers.UserHandler) {
 	user.Get("/check/:username", middlewares.ProtectedRoute, handler.SendCheckUsername)
 
 	user.Post("/profile/update", middlewares.ProtectedRoute, handler.SendUserUpdateProfile)
+
+	// email notification preferences
+	user.Get("/preferences", middlewares.ProtectedRoute, handler.SendPreferences)
+	user.Post("/preferences/update", middlewares.ProtectedRoute, handler.SendUpdatePreferences)
+
+	// follow graph between users
+	user.Get("/follow/:username", middlewares.ProtectedRoute, handler.SendFollowStatus)
+	user.Post("/follow/:username", middlewares.ProtectedRoute, handler.SendFollowUser)
+	user.Post("/unfollow/:username", middlewares.ProtectedRoute, handler.SendUnfollowUser)
 }
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"resqiar.com-server/handlers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitWebmentionRoute(server *fiber.App, handler handlers.WebmentionHandler) {
+	// blog pages advertise this endpoint with <link rel="webmention">, anyone may send to it
+	server.Post("/webmention", handler.SendReceiveWebmention)
+
+	// verified mentions are shown under the blog
+	server.Get("/blog/webmentions/:id", handler.SendMentions)
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/limiter"
 	"github.com/joho/godotenv"
+	"github.com/valyala/fasthttp"
 )
 
 func main() {
@@ -21,10 +22,18 @@ func main() {
 
 	server := fiber.New()
 
+	// the default body limit applies everywhere but to the routes receiving uploads
+	server.Server().HeaderReceived = func(header *fasthttp.RequestHeader) fasthttp.RequestConfig {
+		return fasthttp.RequestConfig{
+			MaxRequestBodySize: libs.BodyLimit(string(header.RequestURI())),
+		}
+	}
+
 	// Setup CORS
 	server.Use(cors.New(cors.Config{
 		AllowOrigins:     os.Getenv("CORS_CLIENT_URL"),
-		AllowHeaders:     "Origin, Content-Type, Accept",
+		AllowHeaders:     "Origin, Content-Type, Accept, If-None-Match, If-Modified-Since, If-Match",
+		ExposeHeaders:    "ETag, Last-Modified",
 		AllowCredentials: true,
 	}))
 
@@ -33,19 +42,12 @@ func main() {
 
 	// Setup compression
 	server.Use(compress.New(compress.Config{
+		Next: func(c *fiber.Ctx) bool {
+			return libs.IsStreamRoute(c.Path())
+		},
 		Level: 2, // best compression
 	}))
 
-	// Setup caching
-	// server.Use(cache.New(cache.Config{
-	// 	Next: func(c *fiber.Ctx) bool {
-	// 		URL := string(c.Request().URI().Path())
-	// 		return libs.ShouldNotCached(URL)
-	// 	},
-	// 	Expiration:   30 * time.Minute,
-	// 	CacheControl: true,
-	// }))
-
 	DB := db.InitDB() // init Postgres db
 	db.InitRedis()    // init Redis db
 
@@ -0,0 +1,692 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+var (
+	ErrActorNotFound   = errors.New("Actor does not exist")
+	ErrInvalidActivity = errors.New("Activity is invalid")
+)
+
+type ActivityPubService interface {
+	// GetWebFinger resolves acct:<username>@<domain> to the actor of the user.
+	GetWebFinger(resource string) (*types.WebFinger, error)
+
+	GetActor(userID string) (*types.Actor, error)
+	GetOutbox(userID string) (*types.OrderedCollection, error)
+	GetFollowers(userID string) (*types.OrderedCollection, error)
+	GetArticle(blogID string) (*types.Article, error)
+
+	// ReceiveActivity handles an activity posted to the inbox of the user,
+	// it is only trusted once signed by the actor it claims to come from.
+	ReceiveActivity(userID string, request *types.SignedRequest) error
+
+	// QueueBlogActivity queues the activity about the blog for every remote follower of its author,
+	// Create and Update send the blog while Delete only sends its ID.
+	QueueBlogActivity(activityType string, blogID string) error
+
+	// DeliverPending delivers every activity which is due, it is safe to run on multiple instances.
+	DeliverPending() error
+
+	// RunDeliveryJob periodically delivers pending activities until the context is done.
+	RunDeliveryJob(ctx context.Context)
+}
+
+type ActivityPubServiceImpl struct {
+	UtilService    UtilService
+	Repository     repositories.ActivityPubRepository
+	UserRepository repositories.UserRepository
+	BlogRepository repositories.BlogRepository
+	Client         *http.Client
+
+	// ServerURL is where this server is reached, actors and blogs are identified by URLs under it
+	ServerURL string
+}
+
+// InitActivityPubClient returns a client reaching remote servers on public addresses only,
+// actor and inbox URLs come from other servers and must not reach into the network of the server.
+func InitActivityPubClient() *http.Client {
+	return publicClient(constants.ActivityPubRequestTimeout)
+}
+
+// SubscribeEvents registers the service to the events of blogs its followers hear about.
+func (service *ActivityPubServiceImpl) SubscribeEvents(events EventService) {
+	for eventType, activityType := range map[string]string{
+		constants.EventBlogPublished:   "Create",
+		constants.EventBlogUpdated:     "Update",
+		constants.EventBlogUnpublished: "Delete",
+	} {
+		activityType := activityType
+
+		events.Subscribe(eventType, func(event types.Event) {
+			if err := service.QueueBlogActivity(activityType, event.BlogID); err != nil {
+				log.Println("Error queueing activity:", err)
+			}
+		})
+	}
+}
+
+func (service *ActivityPubServiceImpl) GetWebFinger(resource string) (*types.WebFinger, error) {
+	account, found := strings.CutPrefix(resource, "acct:")
+	if !found {
+		return nil, ErrActorNotFound
+	}
+
+	username, domain, found := strings.Cut(account, "@")
+	if !found || !strings.EqualFold(domain, service.domain()) {
+		return nil, ErrActorNotFound
+	}
+
+	user, err := service.UserRepository.FindByUsername(username)
+	if err != nil {
+		return nil, ErrActorNotFound
+	}
+
+	return &types.WebFinger{
+		Subject: "acct:" + user.Username + "@" + service.domain(),
+		Aliases: []string{service.actorID(user.ID)},
+		Links: []types.WebFingerLink{
+			{
+				Rel:  "self",
+				Type: constants.ActivityPubContentType,
+				Href: service.actorID(user.ID),
+			},
+		},
+	}, nil
+}
+
+func (service *ActivityPubServiceImpl) GetActor(userID string) (*types.Actor, error) {
+	user, err := service.UserRepository.FindByID(userID)
+	if err != nil {
+		return nil, ErrActorNotFound
+	}
+
+	key, err := service.actorKey(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	actorID := service.actorID(user.ID)
+
+	actor := &types.Actor{
+		Context:           []string{constants.ActivityStreamsContext, constants.SecurityContext},
+		ID:                actorID,
+		Type:              "Person",
+		PreferredUsername: user.Username,
+		Name:              user.Fullname,
+		Summary:           user.Bio,
+		Inbox:             actorID + "/inbox",
+		Outbox:            actorID + "/outbox",
+		Followers:         actorID + "/followers",
+		PublicKey: types.ActorPublicKey{
+			ID:           actorID + "#main-key",
+			Owner:        actorID,
+			PublicKeyPem: key.PublicKey,
+		},
+	}
+
+	if user.PictureURL != "" {
+		actor.Icon = &types.ActorIcon{Type: "Image", URL: user.PictureURL}
+	}
+
+	return actor, nil
+}
+
+func (service *ActivityPubServiceImpl) GetOutbox(userID string) (*types.OrderedCollection, error) {
+	user, err := service.UserRepository.FindByID(userID)
+	if err != nil {
+		return nil, ErrActorNotFound
+	}
+
+	blogs, err := service.BlogRepository.GetBlogs(true, true, user.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	outbox := &types.OrderedCollection{
+		Context:    constants.ActivityStreamsContext,
+		ID:         service.actorID(user.ID) + "/outbox",
+		Type:       "OrderedCollection",
+		TotalItems: int64(len(blogs)),
+	}
+
+	if len(blogs) > constants.ActivityPubOutboxLimit {
+		blogs = blogs[:constants.ActivityPubOutboxLimit]
+	}
+
+	for i := range blogs {
+		activity, err := service.blogActivity("Create", &blogs[i], blogs[i].PublishedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		activity.Context = ""
+		outbox.OrderedItems = append(outbox.OrderedItems, *activity)
+	}
+
+	return outbox, nil
+}
+
+// GetFollowers only tells how many followers the user has, not who they are.
+func (service *ActivityPubServiceImpl) GetFollowers(userID string) (*types.OrderedCollection, error) {
+	user, err := service.UserRepository.FindByID(userID)
+	if err != nil {
+		return nil, ErrActorNotFound
+	}
+
+	count, err := service.Repository.CountFollowers(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.OrderedCollection{
+		Context:    constants.ActivityStreamsContext,
+		ID:         service.actorID(user.ID) + "/followers",
+		Type:       "OrderedCollection",
+		TotalItems: count,
+	}, nil
+}
+
+func (service *ActivityPubServiceImpl) GetArticle(blogID string) (*types.Article, error) {
+	blog, err := service.BlogRepository.GetBlog(&types.GetBlogOpts{
+		UseID:          blogID,
+		IncludeContent: true,
+		Published:      true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	article := service.article(blog)
+	article.Context = constants.ActivityStreamsContext
+
+	return article, nil
+}
+
+func (service *ActivityPubServiceImpl) ReceiveActivity(userID string, request *types.SignedRequest) error {
+	if len(request.Body) > constants.ActivityPubMaxBodySize {
+		return ErrInvalidActivity
+	}
+
+	user, err := service.UserRepository.FindByID(userID)
+	if err != nil {
+		return ErrActorNotFound
+	}
+
+	var activity types.Activity
+
+	if err := json.Unmarshal(request.Body, &activity); err != nil || activity.Actor == "" || activity.Type == "" {
+		return ErrInvalidActivity
+	}
+
+	remote, err := service.verifyActivity(request, &activity)
+	if err != nil {
+		return err
+	}
+
+	switch activity.Type {
+	case "Follow":
+		var object string
+
+		if err := json.Unmarshal(activity.Object, &object); err != nil || object != service.actorID(user.ID) {
+			return ErrInvalidActivity
+		}
+
+		if err := service.Repository.SaveFollower(&entities.RemoteFollower{
+			UserID:      user.ID,
+			ActorID:     remote.ID,
+			Inbox:       remote.Inbox,
+			SharedInbox: remote.sharedInbox(),
+		}); err != nil {
+			return err
+		}
+
+		// the follow is accepted right away, authors cannot review their followers
+		accept := &types.Activity{
+			Context: constants.ActivityStreamsContext,
+			ID:      fmt.Sprintf("%s#accepts/%s", service.actorID(user.ID), service.UtilService.GenerateRandomID(16)),
+			Type:    "Accept",
+			Actor:   service.actorID(user.ID),
+			To:      []string{remote.ID},
+			Object:  json.RawMessage(request.Body),
+		}
+
+		return service.queue(user.ID, accept, []string{remote.Inbox})
+
+	case "Undo":
+		var undone types.Activity
+
+		// only the follows are undone, the ID alone does not tell what was undone
+		if err := json.Unmarshal(activity.Object, &undone); err != nil || undone.Type != "Follow" {
+			return nil
+		}
+
+		if undone.Actor != remote.ID {
+			return ErrInvalidActivity
+		}
+
+		return service.Repository.DeleteFollower(user.ID, remote.ID)
+	}
+
+	// everything else, e.g. likes and replies, is not supported yet
+	return nil
+}
+
+func (service *ActivityPubServiceImpl) QueueBlogActivity(activityType string, blogID string) error {
+	deleted := activityType == "Delete"
+
+	// unpublished blogs are still fetched to know their author
+	blog, err := service.BlogRepository.GetBlog(&types.GetBlogOpts{
+		UseID:          blogID,
+		IncludeContent: !deleted,
+		Published:      !deleted,
+	})
+	if err != nil {
+		return err
+	}
+
+	followers, err := service.Repository.GetFollowers(blog.Author.ID)
+	if err != nil {
+		return err
+	}
+
+	if len(followers) == 0 {
+		return nil
+	}
+
+	var activity *types.Activity
+
+	if deleted {
+		activity, err = service.deleteActivity(blog)
+	} else {
+		activity, err = service.blogActivity(activityType, blog, time.Now())
+	}
+	if err != nil {
+		return err
+	}
+
+	// followers of the same server share an inbox, one delivery reaches all of them
+	var inboxes []string
+	seen := make(map[string]bool)
+
+	for _, follower := range followers {
+		inbox := follower.Inbox
+		if follower.SharedInbox != "" {
+			inbox = follower.SharedInbox
+		}
+
+		if !seen[inbox] {
+			seen[inbox] = true
+			inboxes = append(inboxes, inbox)
+		}
+	}
+
+	if activityType == "Update" {
+		return service.queueUpdate(blog.ID, blog.Author.ID, activity, inboxes)
+	}
+
+	return service.queue(blog.Author.ID, activity, inboxes)
+}
+
+func (service *ActivityPubServiceImpl) DeliverPending() error {
+	for {
+		delivery, err := service.Repository.ClaimPendingDelivery(constants.DeliveryJobTimeout)
+		if err != nil {
+			return err
+		}
+
+		if delivery == nil {
+			return nil
+		}
+
+		now := time.Now()
+		delivery.Attempts++
+
+		retry, err := service.deliver(delivery)
+
+		switch {
+		case err == nil:
+			delivery.Status = constants.DeliveryDelivered
+			delivery.DeliveredAt = &now
+			delivery.Error = ""
+		case !retry || delivery.Attempts >= constants.DeliveryMaxAttempts:
+			delivery.Status = constants.DeliveryFailed
+			delivery.Error = err.Error()
+		default:
+			delivery.NextAttemptAt = now.Add(constants.DeliveryRetryDelay << (delivery.Attempts - 1))
+			delivery.Error = err.Error()
+		}
+
+		if err := service.Repository.FinishDelivery(delivery); err != nil {
+			return err
+		}
+	}
+}
+
+func (service *ActivityPubServiceImpl) RunDeliveryJob(ctx context.Context) {
+	ticker := time.NewTicker(constants.DeliveryJobInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := service.DeliverPending(); err != nil {
+				log.Println("Error delivering activities:", err)
+			}
+		}
+	}
+}
+
+// deliver posts the activity signed by its actor,
+// it reports whether a failed delivery is worth retrying.
+func (service *ActivityPubServiceImpl) deliver(delivery *entities.ActivityDelivery) (bool, error) {
+	key, err := service.actorKey(delivery.UserID)
+	if err != nil {
+		return true, err
+	}
+
+	privateKey, err := parsePrivateKey(key.PrivateKey)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, delivery.Inbox, bytes.NewReader(delivery.Activity))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", constants.ActivityPubContentType)
+
+	if err := signRequest(req, service.actorID(delivery.UserID)+"#main-key", privateKey, delivery.Activity); err != nil {
+		return false, err
+	}
+
+	res, err := service.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer res.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(res.Body, constants.ActivityPubMaxBodySize))
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return true, nil
+	}
+
+	// other client errors are refusals, sending the same activity again would not help
+	retry := res.StatusCode >= 500 || res.StatusCode == http.StatusRequestTimeout || res.StatusCode == http.StatusTooManyRequests
+
+	return retry, fmt.Errorf("Inbox answered %s", res.Status)
+}
+
+// verifyActivity returns the actor of the activity once its signature is verified.
+func (service *ActivityPubServiceImpl) verifyActivity(request *types.SignedRequest, activity *types.Activity) (*remoteActor, error) {
+	signature, err := parseSignature(request.Header.Get("Signature"))
+	if err != nil {
+		return nil, err
+	}
+
+	// the key belongs to the actor document it is published in
+	actorURL, _, _ := strings.Cut(signature.KeyID, "#")
+
+	remote, err := service.fetchActor(actorURL)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	if remote.ID != activity.Actor || remote.PublicKey.ID != signature.KeyID || remote.PublicKey.Owner != remote.ID {
+		return nil, ErrInvalidSignature
+	}
+
+	publicKey, err := parsePublicKey(remote.PublicKey.PublicKeyPem)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	if err := verifySignature(request, signature, publicKey); err != nil {
+		return nil, err
+	}
+
+	if remote.Inbox == "" {
+		return nil, ErrInvalidActivity
+	}
+
+	return remote, nil
+}
+
+type remoteActor struct {
+	types.Actor
+}
+
+func (actor *remoteActor) sharedInbox() string {
+	if actor.Endpoints == nil {
+		return ""
+	}
+
+	return actor.Endpoints.SharedInbox
+}
+
+func (service *ActivityPubServiceImpl) fetchActor(actorURL string) (*remoteActor, error) {
+	parsed, err := url.Parse(actorURL)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") {
+		return nil, ErrInvalidActivity
+	}
+
+	req, err := http.NewRequest(http.MethodGet, actorURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", constants.ActivityPubContentType+", "+constants.ActivityPubLDContentType)
+
+	res, err := service.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Actor answered %s", res.Status)
+	}
+
+	var actor remoteActor
+
+	if err := json.NewDecoder(io.LimitReader(res.Body, constants.ActivityPubMaxBodySize)).Decode(&actor); err != nil {
+		return nil, err
+	}
+
+	// the document must be the one asked for, not another actor it redirected to
+	if actor.ID != actorURL {
+		return nil, ErrInvalidActivity
+	}
+
+	return &actor, nil
+}
+
+// actorKey returns the key pair of the user, generating it the first time.
+func (service *ActivityPubServiceImpl) actorKey(userID string) (*entities.ActorKey, error) {
+	key, err := service.Repository.GetActorKey(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if key != nil {
+		return key, nil
+	}
+
+	publicKey, privateKey, err := generateKeyPair()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.Repository.CreateActorKey(&entities.ActorKey{
+		UserID:     userID,
+		PublicKey:  publicKey,
+		PrivateKey: privateKey,
+	})
+}
+
+func (service *ActivityPubServiceImpl) queue(userID string, activity *types.Activity, inboxes []string) error {
+	data, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	deliveries := make([]entities.ActivityDelivery, len(inboxes))
+
+	for i, inbox := range inboxes {
+		deliveries[i] = entities.ActivityDelivery{
+			UserID:        userID,
+			Inbox:         inbox,
+			Activity:      data,
+			Status:        constants.DeliveryPending,
+			NextAttemptAt: now,
+		}
+	}
+
+	return service.Repository.CreateDeliveries(deliveries)
+}
+
+// queueUpdate delays the deliveries so that the updates of a blog edited in a row are sent once.
+func (service *ActivityPubServiceImpl) queueUpdate(blogID string, userID string, activity *types.Activity, inboxes []string) error {
+	data, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+
+	at := time.Now().Add(constants.DeliveryUpdateDelay)
+
+	deliveries := make([]entities.ActivityDelivery, len(inboxes))
+
+	for i, inbox := range inboxes {
+		deliveries[i] = entities.ActivityDelivery{
+			UserID:        userID,
+			Inbox:         inbox,
+			Activity:      data,
+			BlogID:        blogID,
+			Status:        constants.DeliveryPending,
+			NextAttemptAt: at,
+		}
+	}
+
+	return service.Repository.QueueUpdates(deliveries)
+}
+
+func (service *ActivityPubServiceImpl) blogActivity(activityType string, blog *entities.SafeBlogAuthor, at time.Time) (*types.Activity, error) {
+	article := service.article(blog)
+
+	object, err := json.Marshal(article)
+	if err != nil {
+		return nil, err
+	}
+
+	// every update is a new activity about the same article
+	activityID := article.ID + "#create"
+	if activityType != "Create" {
+		activityID = fmt.Sprintf("%s#%s/%d", article.ID, strings.ToLower(activityType), at.Unix())
+	}
+
+	return &types.Activity{
+		Context:   constants.ActivityStreamsContext,
+		ID:        activityID,
+		Type:      activityType,
+		Actor:     article.AttributedTo,
+		Published: at.UTC().Format(time.RFC3339),
+		To:        article.To,
+		Cc:        article.Cc,
+		Object:    object,
+	}, nil
+}
+
+func (service *ActivityPubServiceImpl) deleteActivity(blog *entities.SafeBlogAuthor) (*types.Activity, error) {
+	articleID := service.articleID(blog.ID)
+	actorID := service.actorID(blog.Author.ID)
+
+	object, err := json.Marshal(&types.Article{
+		ID:   articleID,
+		Type: "Tombstone",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.Activity{
+		Context: constants.ActivityStreamsContext,
+		ID:      fmt.Sprintf("%s#delete/%d", articleID, time.Now().Unix()),
+		Type:    "Delete",
+		Actor:   actorID,
+		To:      []string{constants.ActivityPubPublic},
+		Cc:      []string{actorID + "/followers"},
+		Object:  object,
+	}, nil
+}
+
+func (service *ActivityPubServiceImpl) article(blog *entities.SafeBlogAuthor) *types.Article {
+	actorID := service.actorID(blog.Author.ID)
+
+	article := &types.Article{
+		ID:           service.articleID(blog.ID),
+		Type:         "Article",
+		AttributedTo: actorID,
+		Name:         blog.Title,
+		Summary:      blog.Summary,
+		Content:      blog.ContentHTML,
+		URL:          service.UtilService.BlogURL(blog.Author.Username, blog.Slug),
+		Published:    blog.PublishedAt.UTC().Format(time.RFC3339),
+		To:           []string{constants.ActivityPubPublic},
+		Cc:           []string{actorID + "/followers"},
+	}
+
+	// blogs rendered before rendering on write have no HTML stored
+	if article.Content == "" && blog.Content != "" {
+		article.Content = service.UtilService.RenderMD(blog.Content).HTML
+	}
+
+	if blog.UpdatedAt.After(blog.PublishedAt) {
+		article.Updated = blog.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+
+	for _, tag := range blog.Tags {
+		article.Tag = append(article.Tag, types.ArticleTag{Type: "Hashtag", Name: "#" + tag})
+	}
+
+	return article
+}
+
+// actors are identified by the ID of the user, usernames may change
+func (service *ActivityPubServiceImpl) actorID(userID string) string {
+	return service.ServerURL + "/ap/users/" + userID
+}
+
+func (service *ActivityPubServiceImpl) articleID(blogID string) string {
+	return service.ServerURL + "/ap/blogs/" + blogID
+}
+
+func (service *ActivityPubServiceImpl) domain() string {
+	parsed, err := url.Parse(service.ServerURL)
+	if err != nil {
+		return ""
+	}
+
+	return parsed.Host
+}
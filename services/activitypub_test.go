@@ -0,0 +1,505 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+var activityPubRepoTest = repositories.ActivityPubRepoMock{}
+var activityPubUserRepoTest = repositories.UserRepoMock{}
+var activityPubBlogRepoTest = repositories.BlogRepoMock{}
+var activityPubServiceTest = ActivityPubServiceImpl{
+	UtilService:    InitUtilService(),
+	Repository:     &activityPubRepoTest,
+	UserRepository: &activityPubUserRepoTest,
+	BlogRepository: &activityPubBlogRepoTest,
+	Client:         http.DefaultClient,
+	ServerURL:      "https://blog.example.com",
+}
+
+var activityPubUser = &entities.SafeUser{ID: "example-of-user-id", Username: "author", Fullname: "The Author"}
+var activityPubActorID = "https://blog.example.com/ap/users/example-of-user-id"
+
+// keys are slow to generate, every test shares the same ones
+var activityPubKeys = struct {
+	sync.Once
+	local  *entities.ActorKey
+	remote *entities.ActorKey
+}{}
+
+func testActorKeys(t *testing.T) (*entities.ActorKey, *entities.ActorKey) {
+	activityPubKeys.Do(func() {
+		for _, key := range []**entities.ActorKey{&activityPubKeys.local, &activityPubKeys.remote} {
+			public, private, err := generateKeyPair()
+			require.Nil(t, err)
+
+			*key = &entities.ActorKey{UserID: activityPubUser.ID, PublicKey: public, PrivateKey: private}
+		}
+	})
+
+	return activityPubKeys.local, activityPubKeys.remote
+}
+
+// fakeRemote is another ActivityPub server, it publishes its actor and records what its inbox receives.
+type fakeRemote struct {
+	*httptest.Server
+	mu       sync.Mutex
+	received []*types.SignedRequest
+	status   int
+}
+
+func newFakeRemote(t *testing.T, remoteKey *entities.ActorKey) *fakeRemote {
+	remote := &fakeRemote{status: http.StatusAccepted}
+
+	remote.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/users/remote":
+			w.Header().Set("Content-Type", constants.ActivityPubContentType)
+
+			json.NewEncoder(w).Encode(&types.Actor{
+				ID:                remote.actorID(),
+				Type:              "Person",
+				PreferredUsername: "remote",
+				Inbox:             remote.URL + "/users/remote/inbox",
+				PublicKey: types.ActorPublicKey{
+					ID:           remote.actorID() + "#main-key",
+					Owner:        remote.actorID(),
+					PublicKeyPem: remoteKey.PublicKey,
+				},
+				Endpoints: &types.ActorEndpoints{SharedInbox: remote.URL + "/inbox"},
+			})
+		case r.Method == http.MethodPost:
+			body, _ := io.ReadAll(r.Body)
+
+			header := r.Header.Clone()
+			header.Set("Host", r.Host)
+
+			remote.mu.Lock()
+			remote.received = append(remote.received, &types.SignedRequest{Method: r.Method, Target: r.URL.RequestURI(), Header: header, Body: body})
+			status := remote.status
+			remote.mu.Unlock()
+
+			w.WriteHeader(status)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	t.Cleanup(remote.Close)
+
+	return remote
+}
+
+func (remote *fakeRemote) setStatus(status int) {
+	remote.mu.Lock()
+	defer remote.mu.Unlock()
+
+	remote.status = status
+}
+
+func (remote *fakeRemote) actorID() string {
+	return remote.URL + "/users/remote"
+}
+
+// signedActivity is the activity as posted by the remote actor to the inbox of the user.
+func (remote *fakeRemote) signedActivity(t *testing.T, remoteKey *entities.ActorKey, activity interface{}) *types.SignedRequest {
+	body, err := json.Marshal(activity)
+	require.Nil(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, activityPubActorID+"/inbox", bytes.NewReader(body))
+	require.Nil(t, err)
+
+	privateKey, err := parsePrivateKey(remoteKey.PrivateKey)
+	require.Nil(t, err)
+
+	require.Nil(t, signRequest(req, remote.actorID()+"#main-key", privateKey, body))
+
+	return &types.SignedRequest{Method: req.Method, Target: req.URL.RequestURI(), Header: req.Header, Body: body}
+}
+
+func resetActivityPubMocks() {
+	activityPubRepoTest.Mock.ExpectedCalls = nil
+	activityPubRepoTest.Mock.Calls = nil
+	activityPubUserRepoTest.Mock.ExpectedCalls = nil
+	activityPubBlogRepoTest.Mock.ExpectedCalls = nil
+}
+
+func TestGetWebFinger(t *testing.T) {
+	t.Cleanup(resetActivityPubMocks)
+
+	t.Run("Should resolve the account to the actor of the user", func(t *testing.T) {
+		activityPubUserRepoTest.Mock.On("FindByUsername", "author").Return(activityPubUser).Once()
+
+		finger, err := activityPubServiceTest.GetWebFinger("acct:author@blog.example.com")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "acct:author@blog.example.com", finger.Subject)
+		assert.Equal(t, activityPubActorID, finger.Links[0].Href)
+		assert.Equal(t, constants.ActivityPubContentType, finger.Links[0].Type)
+	})
+
+	t.Run("Should not resolve accounts of another domain", func(t *testing.T) {
+		_, err := activityPubServiceTest.GetWebFinger("acct:author@elsewhere.com")
+
+		assert.ErrorIs(t, err, ErrActorNotFound)
+	})
+
+	t.Run("Should not resolve unknown users", func(t *testing.T) {
+		activityPubUserRepoTest.Mock.On("FindByUsername", "nobody").Return(nil).Once()
+
+		_, err := activityPubServiceTest.GetWebFinger("acct:nobody@blog.example.com")
+
+		assert.ErrorIs(t, err, ErrActorNotFound)
+	})
+}
+
+func TestGetActor(t *testing.T) {
+	t.Cleanup(resetActivityPubMocks)
+
+	localKey, _ := testActorKeys(t)
+
+	t.Run("Should return the actor along with its public key", func(t *testing.T) {
+		activityPubUserRepoTest.Mock.On("FindByID", activityPubUser.ID).Return(activityPubUser).Once()
+		activityPubRepoTest.Mock.On("GetActorKey", activityPubUser.ID).Return(localKey, nil).Once()
+
+		actor, err := activityPubServiceTest.GetActor(activityPubUser.ID)
+
+		assert.Nil(t, err)
+		assert.Equal(t, activityPubActorID, actor.ID)
+		assert.Equal(t, "author", actor.PreferredUsername)
+		assert.Equal(t, activityPubActorID+"/inbox", actor.Inbox)
+		assert.Equal(t, activityPubActorID+"#main-key", actor.PublicKey.ID)
+		assert.Equal(t, localKey.PublicKey, actor.PublicKey.PublicKeyPem)
+	})
+
+	t.Run("Should generate the key pair the first time", func(t *testing.T) {
+		activityPubUserRepoTest.Mock.On("FindByID", activityPubUser.ID).Return(activityPubUser).Once()
+		activityPubRepoTest.Mock.On("GetActorKey", activityPubUser.ID).Return(nil, nil).Once()
+		activityPubRepoTest.Mock.On("CreateActorKey", mock.Anything).Return(localKey, nil).Once()
+
+		actor, err := activityPubServiceTest.GetActor(activityPubUser.ID)
+
+		assert.Nil(t, err)
+		assert.Equal(t, localKey.PublicKey, actor.PublicKey.PublicKeyPem)
+
+		created := activityPubRepoTest.Mock.Calls[len(activityPubRepoTest.Mock.Calls)-1].Arguments.Get(0).(*entities.ActorKey)
+		assert.Contains(t, created.PublicKey, "BEGIN PUBLIC KEY")
+		assert.Contains(t, created.PrivateKey, "BEGIN RSA PRIVATE KEY")
+	})
+}
+
+func TestReceiveActivity(t *testing.T) {
+	t.Cleanup(resetActivityPubMocks)
+
+	_, remoteKey := testActorKeys(t)
+	remote := newFakeRemote(t, remoteKey)
+
+	follow := map[string]interface{}{
+		"@context": constants.ActivityStreamsContext,
+		"id":       remote.URL + "/follows/1",
+		"type":     "Follow",
+		"actor":    remote.actorID(),
+		"object":   activityPubActorID,
+	}
+
+	t.Run("Should store the follower and queue the accept", func(t *testing.T) {
+		activityPubUserRepoTest.Mock.On("FindByID", activityPubUser.ID).Return(activityPubUser).Once()
+		activityPubRepoTest.Mock.On("SaveFollower", &entities.RemoteFollower{
+			UserID:      activityPubUser.ID,
+			ActorID:     remote.actorID(),
+			Inbox:       remote.URL + "/users/remote/inbox",
+			SharedInbox: remote.URL + "/inbox",
+		}).Return(nil).Once()
+		activityPubRepoTest.Mock.On("CreateDeliveries", mock.Anything).Return(nil).Once()
+
+		err := activityPubServiceTest.ReceiveActivity(activityPubUser.ID, remote.signedActivity(t, remoteKey, follow))
+
+		require.Nil(t, err)
+		activityPubRepoTest.Mock.AssertExpectations(t)
+
+		deliveries := activityPubRepoTest.Mock.Calls[len(activityPubRepoTest.Mock.Calls)-1].Arguments.Get(0).([]entities.ActivityDelivery)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, remote.URL+"/users/remote/inbox", deliveries[0].Inbox)
+
+		var accept types.Activity
+		require.Nil(t, json.Unmarshal(deliveries[0].Activity, &accept))
+		assert.Equal(t, "Accept", accept.Type)
+		assert.Equal(t, activityPubActorID, accept.Actor)
+		assert.Contains(t, string(accept.Object), remote.URL+"/follows/1")
+	})
+
+	t.Run("Should refuse a tampered activity", func(t *testing.T) {
+		activityPubRepoTest.Mock.Calls = nil
+		activityPubUserRepoTest.Mock.On("FindByID", activityPubUser.ID).Return(activityPubUser).Once()
+
+		request := remote.signedActivity(t, remoteKey, follow)
+		request.Body = bytes.Replace(request.Body, []byte("follows/1"), []byte("follows/2"), 1)
+
+		err := activityPubServiceTest.ReceiveActivity(activityPubUser.ID, request)
+
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+		activityPubRepoTest.Mock.AssertNotCalled(t, "SaveFollower", mock.Anything)
+	})
+
+	t.Run("Should refuse an activity signed by another actor", func(t *testing.T) {
+		activityPubUserRepoTest.Mock.On("FindByID", activityPubUser.ID).Return(activityPubUser).Once()
+
+		impersonated := map[string]interface{}{
+			"id":     "https://elsewhere.com/follows/1",
+			"type":   "Follow",
+			"actor":  "https://elsewhere.com/users/victim",
+			"object": activityPubActorID,
+		}
+
+		err := activityPubServiceTest.ReceiveActivity(activityPubUser.ID, remote.signedActivity(t, remoteKey, impersonated))
+
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+
+	t.Run("Should refuse an expired signature", func(t *testing.T) {
+		activityPubUserRepoTest.Mock.On("FindByID", activityPubUser.ID).Return(activityPubUser).Once()
+
+		request := remote.signedActivity(t, remoteKey, follow)
+		request.Header.Set("Date", time.Now().Add(-2*constants.ActivityPubSignatureMaxAge).UTC().Format(http.TimeFormat))
+
+		err := activityPubServiceTest.ReceiveActivity(activityPubUser.ID, request)
+
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+
+	t.Run("Should remove the follower when the follow is undone", func(t *testing.T) {
+		activityPubUserRepoTest.Mock.On("FindByID", activityPubUser.ID).Return(activityPubUser).Once()
+		activityPubRepoTest.Mock.On("DeleteFollower", activityPubUser.ID, remote.actorID()).Return(nil).Once()
+
+		undo := map[string]interface{}{
+			"id":     remote.URL + "/follows/1/undo",
+			"type":   "Undo",
+			"actor":  remote.actorID(),
+			"object": follow,
+		}
+
+		err := activityPubServiceTest.ReceiveActivity(activityPubUser.ID, remote.signedActivity(t, remoteKey, undo))
+
+		assert.Nil(t, err)
+		activityPubRepoTest.Mock.AssertExpectations(t)
+	})
+
+	t.Run("Should return not found for unknown users", func(t *testing.T) {
+		activityPubUserRepoTest.Mock.On("FindByID", "unknown").Return(nil).Once()
+
+		err := activityPubServiceTest.ReceiveActivity("unknown", remote.signedActivity(t, remoteKey, follow))
+
+		assert.ErrorIs(t, err, ErrActorNotFound)
+	})
+}
+
+func TestQueueBlogActivity(t *testing.T) {
+	t.Cleanup(resetActivityPubMocks)
+
+	blog := &entities.SafeBlogAuthor{
+		SafeBlog: entities.SafeBlog{
+			ID:          "example-of-blog-id",
+			Title:       "Hello fediverse",
+			Slug:        "hello-fediverse",
+			ContentHTML: "<p>Hello</p>",
+			Tags:        types.Tags{"go"},
+			PublishedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		},
+		Author: *activityPubUser,
+	}
+
+	followers := []entities.RemoteFollower{
+		{ActorID: "https://one.example/users/a", Inbox: "https://one.example/users/a/inbox", SharedInbox: "https://one.example/inbox"},
+		{ActorID: "https://one.example/users/b", Inbox: "https://one.example/users/b/inbox", SharedInbox: "https://one.example/inbox"},
+		{ActorID: "https://two.example/users/c", Inbox: "https://two.example/users/c/inbox"},
+	}
+
+	queued := func(t *testing.T) ([]entities.ActivityDelivery, types.Activity) {
+		deliveries := activityPubRepoTest.Mock.Calls[len(activityPubRepoTest.Mock.Calls)-1].Arguments.Get(0).([]entities.ActivityDelivery)
+
+		var activity types.Activity
+		require.Nil(t, json.Unmarshal(deliveries[0].Activity, &activity))
+
+		return deliveries, activity
+	}
+
+	t.Run("Should deliver the blog once to every inbox of the followers", func(t *testing.T) {
+		activityPubBlogRepoTest.Mock.On("GetBlog", &types.GetBlogOpts{UseID: blog.ID, IncludeContent: true, Published: true}).Return(blog, nil).Once()
+		activityPubRepoTest.Mock.On("GetFollowers", activityPubUser.ID).Return(followers, nil).Once()
+		activityPubRepoTest.Mock.On("CreateDeliveries", mock.Anything).Return(nil).Once()
+
+		err := activityPubServiceTest.QueueBlogActivity("Create", blog.ID)
+		require.Nil(t, err)
+
+		deliveries, activity := queued(t)
+
+		require.Len(t, deliveries, 2)
+		assert.Equal(t, "https://one.example/inbox", deliveries[0].Inbox)
+		assert.Equal(t, "https://two.example/users/c/inbox", deliveries[1].Inbox)
+		assert.Equal(t, constants.DeliveryPending, deliveries[0].Status)
+
+		var article types.Article
+		require.Nil(t, json.Unmarshal(activity.Object, &article))
+
+		assert.Equal(t, "Create", activity.Type)
+		assert.Equal(t, activityPubActorID, activity.Actor)
+		assert.Equal(t, "https://blog.example.com/ap/blogs/example-of-blog-id", article.ID)
+		assert.Equal(t, "Article", article.Type)
+		assert.Equal(t, "Hello fediverse", article.Name)
+		assert.Equal(t, "<p>Hello</p>", article.Content)
+		assert.Equal(t, []string{constants.ActivityPubPublic}, article.To)
+		assert.Equal(t, "#go", article.Tag[0].Name)
+	})
+
+	t.Run("Should send a tombstone when the blog is unpublished", func(t *testing.T) {
+		activityPubBlogRepoTest.Mock.On("GetBlog", &types.GetBlogOpts{UseID: blog.ID}).Return(blog, nil).Once()
+		activityPubRepoTest.Mock.On("GetFollowers", activityPubUser.ID).Return(followers, nil).Once()
+		activityPubRepoTest.Mock.On("CreateDeliveries", mock.Anything).Return(nil).Once()
+
+		err := activityPubServiceTest.QueueBlogActivity("Delete", blog.ID)
+		require.Nil(t, err)
+
+		_, activity := queued(t)
+
+		assert.Equal(t, "Delete", activity.Type)
+		assert.JSONEq(t, `{"id":"https://blog.example.com/ap/blogs/example-of-blog-id","type":"Tombstone"}`, string(activity.Object))
+	})
+
+	t.Run("Should delay the updates of the blog to merge the ones made in a row", func(t *testing.T) {
+		activityPubRepoTest.Mock.Calls = nil
+		activityPubBlogRepoTest.Mock.On("GetBlog", &types.GetBlogOpts{UseID: blog.ID, IncludeContent: true, Published: true}).Return(blog, nil).Once()
+		activityPubRepoTest.Mock.On("GetFollowers", activityPubUser.ID).Return(followers, nil).Once()
+		activityPubRepoTest.Mock.On("QueueUpdates", mock.Anything).Return(nil).Once()
+
+		err := activityPubServiceTest.QueueBlogActivity("Update", blog.ID)
+		require.Nil(t, err)
+
+		deliveries, activity := queued(t)
+
+		require.Len(t, deliveries, 2)
+		assert.Equal(t, "Update", activity.Type)
+		assert.Equal(t, blog.ID, deliveries[0].BlogID)
+		assert.True(t, deliveries[0].NextAttemptAt.After(time.Now()))
+		activityPubRepoTest.Mock.AssertNotCalled(t, "CreateDeliveries", mock.Anything)
+	})
+
+	t.Run("Should queue nothing without followers", func(t *testing.T) {
+		activityPubRepoTest.Mock.Calls = nil
+		activityPubBlogRepoTest.Mock.On("GetBlog", mock.Anything).Return(blog, nil).Once()
+		activityPubRepoTest.Mock.On("GetFollowers", activityPubUser.ID).Return([]entities.RemoteFollower{}, nil).Once()
+
+		err := activityPubServiceTest.QueueBlogActivity("Update", blog.ID)
+
+		assert.Nil(t, err)
+		activityPubRepoTest.Mock.AssertNotCalled(t, "CreateDeliveries", mock.Anything)
+	})
+}
+
+func TestDeliverPending(t *testing.T) {
+	t.Cleanup(resetActivityPubMocks)
+
+	localKey, remoteKey := testActorKeys(t)
+	remote := newFakeRemote(t, remoteKey)
+
+	newDelivery := func() *entities.ActivityDelivery {
+		return &entities.ActivityDelivery{
+			ID:       "example-of-delivery-id",
+			UserID:   activityPubUser.ID,
+			Inbox:    remote.URL + "/inbox",
+			Activity: []byte(`{"type":"Create"}`),
+			Status:   constants.DeliveryPending,
+			Attempts: 1,
+		}
+	}
+
+	deliver := func(t *testing.T, delivery *entities.ActivityDelivery) {
+		activityPubRepoTest.Mock.ExpectedCalls = nil
+		activityPubRepoTest.Mock.On("ClaimPendingDelivery", constants.DeliveryJobTimeout).Return(delivery, nil).Once()
+		activityPubRepoTest.Mock.On("ClaimPendingDelivery", constants.DeliveryJobTimeout).Return(nil, nil).Once()
+		activityPubRepoTest.Mock.On("GetActorKey", activityPubUser.ID).Return(localKey, nil)
+		activityPubRepoTest.Mock.On("FinishDelivery", delivery).Return(nil).Once()
+
+		require.Nil(t, activityPubServiceTest.DeliverPending())
+		activityPubRepoTest.Mock.AssertExpectations(t)
+	}
+
+	t.Run("Should post the activity signed by its actor", func(t *testing.T) {
+		delivery := newDelivery()
+
+		deliver(t, delivery)
+
+		assert.Equal(t, constants.DeliveryDelivered, delivery.Status)
+		assert.NotNil(t, delivery.DeliveredAt)
+
+		remote.mu.Lock()
+		request := remote.received[len(remote.received)-1]
+		remote.mu.Unlock()
+		signature, err := parseSignature(request.Header.Get("Signature"))
+		require.Nil(t, err)
+
+		publicKey, err := parsePublicKey(localKey.PublicKey)
+		require.Nil(t, err)
+
+		assert.Equal(t, activityPubActorID+"#main-key", signature.KeyID)
+		assert.Nil(t, verifySignature(request, signature, publicKey))
+		assert.Equal(t, `{"type":"Create"}`, string(request.Body))
+		assert.Equal(t, constants.ActivityPubContentType, request.Header.Get("Content-Type"))
+	})
+
+	t.Run("Should retry later when the server fails", func(t *testing.T) {
+		remote.setStatus(http.StatusServiceUnavailable)
+		delivery := newDelivery()
+
+		deliver(t, delivery)
+
+		assert.Equal(t, constants.DeliveryPending, delivery.Status)
+		assert.Equal(t, 2, delivery.Attempts)
+		assert.WithinDuration(t, time.Now().Add(2*constants.DeliveryRetryDelay), delivery.NextAttemptAt, time.Minute)
+		assert.True(t, strings.Contains(delivery.Error, "503"))
+	})
+
+	t.Run("Should give up after the last attempt", func(t *testing.T) {
+		remote.setStatus(http.StatusServiceUnavailable)
+		delivery := newDelivery()
+		delivery.Attempts = constants.DeliveryMaxAttempts - 1
+
+		deliver(t, delivery)
+
+		assert.Equal(t, constants.DeliveryFailed, delivery.Status)
+	})
+
+	t.Run("Should not retry a refused activity", func(t *testing.T) {
+		remote.setStatus(http.StatusGone)
+		delivery := newDelivery()
+
+		deliver(t, delivery)
+
+		assert.Equal(t, constants.DeliveryFailed, delivery.Status)
+		assert.Equal(t, 2, delivery.Attempts)
+	})
+
+	t.Run("Should return the error of claiming", func(t *testing.T) {
+		activityPubRepoTest.Mock.ExpectedCalls = nil
+		activityPubRepoTest.Mock.On("ClaimPendingDelivery", constants.DeliveryJobTimeout).Return(nil, errors.New("Something went wrong")).Once()
+
+		err := activityPubServiceTest.DeliverPending()
+
+		assert.EqualError(t, err, "Something went wrong")
+	})
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"resqiar.com-server/constants"
+	"resqiar.com-server/inputs"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+type AutosaveService interface {
+	// Autosave stores a draft snapshot without touching Postgres,
+	// it gets flushed next to the blog once the author stops autosaving for a while.
+	// The blog itself only changes on an explicit save, based on the Base of the snapshot.
+	Autosave(payload *inputs.AutosaveBlogInput, userID string) (*types.Autosave, error)
+
+	// GetUnsavedAutosave returns the autosave of the blog only when it is newer than the saved blog.
+	GetUnsavedAutosave(blogID string, userID string) (*types.Autosave, error)
+
+	// DiscardAutosave drops the autosave once the author saved explicitly.
+	DiscardAutosave(blogID string, userID string) error
+
+	// FlushDueAutosaves persists every autosave whose debounce elapsed,
+	// published blogs keep their content until the author saves explicitly.
+	FlushDueAutosaves() error
+
+	// RunFlushJob periodically flushes due autosaves until the context is done.
+	RunFlushJob(ctx context.Context)
+}
+
+type AutosaveServiceImpl struct {
+	Repository     repositories.AutosaveRepository
+	BlogRepository repositories.BlogRepository
+}
+
+func (service *AutosaveServiceImpl) Autosave(payload *inputs.AutosaveBlogInput, userID string) (*types.Autosave, error) {
+	// only blogs the user can edit get an autosave, anything else would pile up in Redis
+	if _, err := service.BlogRepository.GetByIDAndAuthor(payload.ID, userID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	autosave := &types.Autosave{
+		BlogID:   payload.ID,
+		UserID:   userID,
+		Title:    payload.Title,
+		Summary:  payload.Summary,
+		Content:  payload.Content,
+		CoverURL: payload.CoverURL,
+		Prev:     payload.Prev,
+		Next:     payload.Next,
+		Base:     payload.Version,
+		SavedAt:  now,
+	}
+
+	result, err := service.Repository.SaveAutosave(autosave, now.Add(constants.AutosaveDebounce))
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (service *AutosaveServiceImpl) GetUnsavedAutosave(blogID string, userID string) (*types.Autosave, error) {
+	autosave, err := service.Repository.GetAutosave(blogID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	blog, err := service.BlogRepository.GetByIDAndAuthor(blogID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// already flushed or saved explicitly afterwards
+	if !autosave.SavedAt.After(blog.UpdatedAt) {
+		return nil, errors.New("Record not found")
+	}
+
+	return autosave, nil
+}
+
+func (service *AutosaveServiceImpl) DiscardAutosave(blogID string, userID string) error {
+	if err := service.Repository.DeleteAutosave(blogID, userID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (service *AutosaveServiceImpl) FlushDueAutosaves() error {
+	for {
+		autosaves, err := service.Repository.ClaimDueAutosaves(time.Now(), constants.AutosaveFlushBatch)
+		if err != nil {
+			return err
+		}
+
+		if len(autosaves) == 0 {
+			return nil
+		}
+
+		for i := range autosaves {
+			if err := service.Repository.FlushAutosave(&autosaves[i]); err != nil {
+				log.Println("Error flushing autosave:", err)
+			}
+		}
+	}
+}
+
+func (service *AutosaveServiceImpl) RunFlushJob(ctx context.Context) {
+	ticker := time.NewTicker(constants.AutosaveFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := service.FlushDueAutosaves(); err != nil {
+				log.Println("Error flushing autosaves:", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/inputs"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+var autosaveRepoTest = repositories.AutosaveRepoMock{}
+var autosaveBlogRepoTest = repositories.BlogRepoMock{}
+var autosaveServiceTest = AutosaveServiceImpl{
+	Repository:     &autosaveRepoTest,
+	BlogRepository: &autosaveBlogRepoTest,
+}
+
+func TestAutosave(t *testing.T) {
+	t.Run("Should store the snapshot and debounce its flush", func(t *testing.T) {
+		payload := &inputs.AutosaveBlogInput{
+			ID:      "example-of-blog-id",
+			Content: "# Hello World",
+			Version: 3,
+		}
+
+		before := time.Now()
+
+		firstMock := autosaveRepoTest.Mock.On("SaveAutosave", mock.Anything, mock.Anything).Return(&types.Autosave{
+			BlogID: payload.ID,
+			Base:   3,
+		}, nil)
+		secondMock := autosaveBlogRepoTest.Mock.On("GetByIDAndAuthor", payload.ID, "example-of-user-id").Return(&entities.Blog{
+			ID:      payload.ID,
+			Version: 3,
+		}, nil)
+
+		result, err := autosaveServiceTest.Autosave(payload, "example-of-user-id")
+
+		require.Nil(t, err)
+		assert.Equal(t, 3, result.Base)
+
+		call := autosaveRepoTest.Mock.Calls[len(autosaveRepoTest.Mock.Calls)-1]
+		saved := call.Arguments.Get(0).(*types.Autosave)
+		flushAt := call.Arguments.Get(1).(time.Time)
+
+		assert.Equal(t, "example-of-user-id", saved.UserID)
+		assert.Equal(t, "# Hello World", saved.Content)
+		assert.Equal(t, 3, saved.Base)
+		assert.Equal(t, saved.SavedAt.Add(constants.AutosaveDebounce), flushAt)
+		assert.False(t, saved.SavedAt.Before(before))
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			autosaveRepoTest.Mock.Calls = nil
+		})
+	})
+
+	t.Run("Should not store snapshots of blogs the user cannot edit", func(t *testing.T) {
+		payload := &inputs.AutosaveBlogInput{
+			ID:      "example-of-blog-id",
+			Content: "# Hello World",
+			Version: 3,
+		}
+
+		firstMock := autosaveBlogRepoTest.Mock.On("GetByIDAndAuthor", payload.ID, "example-of-other-id").Return(nil, errors.New("Record not found"))
+
+		result, err := autosaveServiceTest.Autosave(payload, "example-of-other-id")
+
+		assert.Nil(t, result)
+		assert.Error(t, err)
+		autosaveRepoTest.Mock.AssertNotCalled(t, "SaveAutosave", mock.Anything, mock.Anything)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+		})
+	})
+}
+
+func TestGetUnsavedAutosave(t *testing.T) {
+	savedAt := time.Date(2024, time.January, 1, 10, 0, 0, 0, time.UTC)
+
+	autosave := &types.Autosave{
+		BlogID:  "example-of-blog-id",
+		UserID:  "example-of-user-id",
+		SavedAt: savedAt,
+	}
+
+	t.Run("Should return the autosave newer than the saved blog", func(t *testing.T) {
+		firstMock := autosaveRepoTest.Mock.On("GetAutosave", autosave.BlogID, autosave.UserID).Return(autosave, nil)
+		secondMock := autosaveBlogRepoTest.Mock.On("GetByIDAndAuthor", autosave.BlogID, autosave.UserID).Return(&entities.Blog{
+			UpdatedAt: savedAt.Add(-time.Minute),
+		}, nil)
+
+		result, err := autosaveServiceTest.GetUnsavedAutosave(autosave.BlogID, autosave.UserID)
+
+		assert.Nil(t, err)
+		assert.Equal(t, autosave, result)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+
+	t.Run("Should not return the autosave once the blog was saved afterwards", func(t *testing.T) {
+		firstMock := autosaveRepoTest.Mock.On("GetAutosave", autosave.BlogID, autosave.UserID).Return(autosave, nil)
+		secondMock := autosaveBlogRepoTest.Mock.On("GetByIDAndAuthor", autosave.BlogID, autosave.UserID).Return(&entities.Blog{
+			UpdatedAt: savedAt.Add(time.Minute),
+		}, nil)
+
+		result, err := autosaveServiceTest.GetUnsavedAutosave(autosave.BlogID, autosave.UserID)
+
+		assert.Nil(t, result)
+		assert.EqualError(t, err, "Record not found")
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+}
+
+func TestFlushDueAutosaves(t *testing.T) {
+	autosave := types.Autosave{
+		BlogID: "example-of-blog-id",
+		UserID: "example-of-user-id",
+		Title:  "Example of Title",
+		Base:   1,
+	}
+
+	t.Run("Should persist the autosave next to the blog without editing it", func(t *testing.T) {
+		// the first claim returns the due autosave, the following one finds nothing left
+		autosaveRepoTest.Mock.On("ClaimDueAutosaves", mock.Anything, constants.AutosaveFlushBatch).Return([]types.Autosave{autosave}, nil).Once()
+		firstMock := autosaveRepoTest.Mock.On("ClaimDueAutosaves", mock.Anything, constants.AutosaveFlushBatch).Return([]types.Autosave{}, nil)
+		secondMock := autosaveRepoTest.Mock.On("FlushAutosave", &autosave).Return(nil)
+
+		err := autosaveServiceTest.FlushDueAutosaves()
+
+		assert.Nil(t, err)
+		autosaveRepoTest.Mock.AssertCalled(t, "FlushAutosave", &autosave)
+		autosaveBlogRepoTest.Mock.AssertNotCalled(t, "UpdateBlog", mock.Anything, mock.Anything, mock.Anything)
+
+		t.Cleanup(func() {
+			// Cleanup mocking, this also removes the first claim since they share arguments
+			firstMock.Unset()
+			secondMock.Unset()
+			autosaveRepoTest.Mock.Calls = nil
+		})
+	})
+}
@@ -1,7 +1,14 @@
 package services
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"resqiar.com-server/constants"
@@ -12,6 +19,12 @@ import (
 	"resqiar.com-server/types"
 )
 
+// ErrVersionConflict is returned when an edit is based on an outdated version of the blog.
+var ErrVersionConflict = errors.New("Blog was modified since the given version")
+
+// ErrSlugTaken is returned when another blog of the author already uses the slug.
+var ErrSlugTaken = errors.New("Slug is already taken")
+
 type BlogService interface {
 	GetAllBlogs(onlyPublished bool, order constants.Order) ([]entities.SafeBlogAuthor, error)
 	GetAllUserBlogs(username string, order constants.Order) ([]entities.SafeBlogAuthor, error)
@@ -19,14 +32,46 @@ type BlogService interface {
 	GetBlogDetail(opt *types.BlogDetailOpts) (*entities.SafeBlogAuthor, error)
 	CreateBlog(payload *inputs.CreateBlogInput, userID string) (*entities.Blog, error)
 	EditBlog(payload *inputs.UpdateBlogInput, userID string) error
+
+	// SaveContent saves the content of a blog edited by several people at once,
+	// it returns the new version of the blog.
+	SaveContent(blogID string, version int, content string) (int, error)
 	GetCurrentUserBlogs(userID string, order constants.Order) ([]entities.Blog, error)
 	GetCurrentUserBlog(blogID string, userID string) (*entities.Blog, error)
+
+	// CanEditBlog reports whether the user is the author or an editing co-author of the blog.
+	CanEditBlog(blogID string, userID string) bool
 	ChangeBlogPublish(payload *inputs.BlogIDInput, userID string, publishState bool) error
+	TransitionBlog(payload *inputs.TransitionBlogInput, userID string) error
+
+	// UpdateSlug sets a custom slug, slugs are unique among the blogs of an author.
+	UpdateSlug(payload *inputs.UpdateSlugInput, userID string) error
+
+	// GetSlugRedirect returns the current slug of a published blog
+	// known by the given slug before, for old links to keep working.
+	GetSlugRedirect(username string, slug string) (string, error)
+	GetFeed(userID string, cursor string, limit int) (*dto.FeedOutput, error)
+
+	// RenderStaleBlogs re-renders every blog rendered by an older renderer version,
+	// it returns how many blogs were re-rendered.
+	RenderStaleBlogs() (int, error)
+
+	// Validators are cached next to what the matching getters return
+	// and identify its current version, for conditional requests.
+	GetBlogValidator(opts *types.GetBlogOpts) (*types.Validator, error)
+	GetBlogsValidator(username string) (*types.Validator, error)
+	GetFeedValidator(userID string, cursor string, limit int) (*types.Validator, error)
 }
 
 type BlogServiceImpl struct {
-	UtilService UtilService
-	Repository  repositories.BlogRepository
+	UtilService    UtilService
+	Repository     repositories.BlogRepository
+	RelatedService RelatedService
+	EventService   EventService
+	CacheService   CacheService
+
+	// UserRepository tells reviewers apart, without it nobody is a reviewer.
+	UserRepository repositories.UserRepository
 }
 
 // GetAllBlogs retrieves a list of SafeBlogAuthor entities from the database.
@@ -40,8 +85,24 @@ func (service *BlogServiceImpl) GetAllBlogs(onlyPublished bool, dataOrder consta
 		order = false
 	}
 
-	// Get all only-published blogs with the desc order true/false (default to desc / true)
-	blogs, err := service.Repository.GetBlogs(onlyPublished, order, "")
+	var blogs []entities.SafeBlogAuthor
+
+	key := fmt.Sprintf("blogs:%t:%t", onlyPublished, order)
+
+	err := remember(service.CacheService, key, &blogs, func() (interface{}, []string, error) {
+		// Get all only-published blogs with the desc order true/false (default to desc / true)
+		blogs, err := service.Repository.GetBlogs(onlyPublished, order, "")
+		if err != nil {
+			return nil, nil, err
+		}
+
+		tag := constants.CacheTagPublished
+		if !onlyPublished {
+			tag = constants.CacheTagDrafts
+		}
+
+		return blogs, append(blogsCacheTags(blogs), tag), nil
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -57,8 +118,26 @@ func (service *BlogServiceImpl) GetAllUserBlogs(username string, dataOrder const
 		order = false
 	}
 
-	// Get all only-published blogs with the desc order true/false (default to desc / true)
-	blogs, err := service.Repository.GetBlogs(true, order, username)
+	var blogs []entities.SafeBlogAuthor
+
+	key := fmt.Sprintf("blogs:user:%s:%t", username, order)
+
+	err := remember(service.CacheService, key, &blogs, func() (interface{}, []string, error) {
+		// Get all only-published blogs with the desc order true/false (default to desc / true)
+		blogs, err := service.Repository.GetBlogs(true, order, username)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		tags := append(blogsCacheTags(blogs), UsernameCacheTag(username))
+
+		// the author is unknown without any blog, the first one published anywhere may belong to them
+		if len(blogs) == 0 {
+			tags = append(tags, constants.CacheTagPublished)
+		}
+
+		return blogs, tags, nil
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -67,24 +146,33 @@ func (service *BlogServiceImpl) GetAllUserBlogs(username string, dataOrder const
 }
 
 func (service *BlogServiceImpl) GetAllSlugs() ([]dto.SitemapOutput, error) {
-	// get all published blogs ID
-	// always set to DESC
-	blogs, err := service.GetAllBlogs(true, constants.DESC)
-	if err != nil {
-		return nil, err
-	}
-
 	var result []dto.SitemapOutput
 
-	// only get the id, and append it to array if of string
-	for _, blog := range blogs {
-		temp := dto.SitemapOutput{
-			Slug:           blog.Slug,
-			AuthorUsername: blog.Author.Username,
-			UpdatedAt:      blog.UpdatedAt,
+	err := remember(service.CacheService, "slugs", &result, func() (interface{}, []string, error) {
+		// get all published blogs ID
+		// always set to DESC
+		blogs, err := service.GetAllBlogs(true, constants.DESC)
+		if err != nil {
+			return nil, nil, err
 		}
 
-		result = append(result, temp)
+		var result []dto.SitemapOutput
+
+		// only get the id, and append it to array if of string
+		for _, blog := range blogs {
+			temp := dto.SitemapOutput{
+				Slug:           blog.Slug,
+				AuthorUsername: blog.Author.Username,
+				UpdatedAt:      blog.UpdatedAt,
+			}
+
+			result = append(result, temp)
+		}
+
+		return result, append(blogsCacheTags(blogs), constants.CacheTagPublished), nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return result, nil
@@ -95,26 +183,63 @@ func (service *BlogServiceImpl) GetAllSlugs() ([]dto.SitemapOutput, error) {
 // It returns the retrieved blog and any error encountered during the process.
 // If no blog is found or an error occurs, it returns an appropriate error.
 func (service *BlogServiceImpl) GetBlogDetail(opt *types.BlogDetailOpts) (*entities.SafeBlogAuthor, error) {
-	blog, err := service.Repository.GetBlog(&types.GetBlogOpts{
-		UseID:          opt.UseID,
-		BlogAuthor:     opt.BlogAuthor,
-		BlogSlug:       opt.BlogSlug,
-		IncludeContent: opt.IncludeContent,
-		Published:      opt.Published,
+	var blog *entities.SafeBlogAuthor
+
+	key := fmt.Sprintf("blog:%s:%s:%s:%t:%t:%t", opt.UseID, opt.BlogAuthor, opt.BlogSlug, opt.IncludeContent, opt.Published, opt.ReturnHTML)
+
+	err := remember(service.CacheService, key, &blog, func() (interface{}, []string, error) {
+		blog, err := service.Repository.GetBlog(&types.GetBlogOpts{
+			UseID:          opt.UseID,
+			BlogAuthor:     opt.BlogAuthor,
+			BlogSlug:       opt.BlogSlug,
+			IncludeContent: opt.IncludeContent,
+			Published:      opt.Published,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if opt.IncludeContent {
+			rendered := service.renderedContent(blog.ID, blog.Content, &types.RenderedMarkdown{
+				HTML:        blog.ContentHTML,
+				TOC:         blog.TOC,
+				WordCount:   blog.WordCount,
+				ReadingTime: blog.ReadingTime,
+			}, blog.RendererVersion)
+
+			blog.TOC = rendered.TOC
+			blog.WordCount = rendered.WordCount
+			blog.ReadingTime = rendered.ReadingTime
+
+			if opt.ReturnHTML {
+				// replace the content of the blog with the rendered HTML instead of pure markdown
+				blog.Content = rendered.HTML
+			}
+		}
+
+		return blog, blogsCacheTags([]entities.SafeBlogAuthor{*blog}), nil
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	if opt.IncludeContent && opt.ReturnHTML {
-		// replace the content of the blog with the parsed HTML instead of pure markdown
-		blog.Content = service.UtilService.ParseMD(blog.Content)
-	}
-
 	return blog, nil
 }
 
 func (service *BlogServiceImpl) CreateBlog(payload *inputs.CreateBlogInput, userID string) (*entities.Blog, error) {
+	if payload.Slug != "" {
+		slugExist, err := service.GetCurrentUserSlugs(payload.Slug, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(slugExist) > 0 {
+			return nil, ErrSlugTaken
+		}
+	}
+
+	rendered := service.UtilService.RenderMD(payload.Content)
+
 	newBlog := entities.Blog{
 		Title:   payload.Title,
 		Summary: payload.Summary,
@@ -128,8 +253,22 @@ func (service *BlogServiceImpl) CreateBlog(payload *inputs.CreateBlogInput, user
 		// we still want to ensure the published value here-
 		// is NOT coming from the payload, but rather hardcoded.
 		Published: false,
+		State:     constants.BlogDraft,
 
 		CoverURL: payload.CoverURL, AuthorID: userID,
+		Tags: payload.Tags,
+		Slug: payload.Slug,
+
+		ContentHTML:     rendered.HTML,
+		RendererVersion: constants.RendererVersion,
+		TOC:             rendered.TOC,
+		WordCount:       rendered.WordCount,
+		ReadingTime:     rendered.ReadingTime,
+	}
+
+	// a publish date given upfront is kept when publishing
+	if payload.PublishedAt != nil {
+		newBlog.PublishedAt = *payload.PublishedAt
 	}
 
 	result, err := service.Repository.CreateBlog(&newBlog)
@@ -137,6 +276,8 @@ func (service *BlogServiceImpl) CreateBlog(payload *inputs.CreateBlogInput, user
 		return nil, err
 	}
 
+	invalidate(service.CacheService, constants.CacheTagDrafts)
+
 	return result, nil
 }
 
@@ -146,22 +287,124 @@ func (service *BlogServiceImpl) EditBlog(payload *inputs.UpdateBlogInput, userID
 		return err
 	}
 
+	if blog.Version != payload.Version {
+		return ErrVersionConflict
+	}
+
 	safe := &inputs.SafeUpdateBlogInput{
 		Title:    payload.Title,
 		Summary:  payload.Summary,
 		Content:  payload.Content,
 		CoverURL: payload.CoverURL,
+		Tags:     payload.Tags,
 		Prev:     payload.Prev,
 		Next:     payload.Next,
+		Version:  blog.Version + 1,
+	}
+
+	if payload.PublishedAt != nil {
+		safe.PublishedAt = *payload.PublishedAt
+	}
+
+	changeSlug := payload.Slug != "" && payload.Slug != blog.Slug
+
+	// co-authors share the slugs of the author
+	if changeSlug {
+		slugExist, err := service.GetCurrentUserSlugs(payload.Slug, blog.AuthorID)
+		if err != nil {
+			return err
+		}
+
+		if len(slugExist) > 0 {
+			return ErrSlugTaken
+		}
 	}
 
-	if err := service.Repository.UpdateBlog(blog.ID, safe); err != nil {
+	// empty fields are left untouched, only render when the content changes
+	if payload.Content != "" {
+		rendered := service.UtilService.RenderMD(payload.Content)
+
+		safe.ContentHTML = rendered.HTML
+		safe.RendererVersion = constants.RendererVersion
+		safe.TOC = rendered.TOC
+		safe.WordCount = rendered.WordCount
+		safe.ReadingTime = rendered.ReadingTime
+	}
+
+	// the version is checked again while updating, another edit may have landed meanwhile
+	updated, err := service.Repository.UpdateBlog(blog.ID, blog.Version, safe)
+	if err != nil {
 		return err
 	}
 
+	if !updated {
+		return ErrVersionConflict
+	}
+
+	if changeSlug {
+		if err := service.Repository.UpdateSlug(blog, payload.Slug); err != nil {
+			return err
+		}
+
+		invalidate(service.CacheService, BlogCacheTag(blog.ID), AuthorCacheTag(blog.AuthorID), constants.CacheTagPublished)
+	} else {
+		invalidate(service.CacheService, BlogCacheTag(blog.ID))
+	}
+
+	// drafts are not part of the related posts corpus
+	if blog.Published {
+		service.refreshRelated()
+		service.publishUpdated(blog.ID, userID)
+	}
+
 	return nil
 }
 
+func (service *BlogServiceImpl) SaveContent(blogID string, version int, content string) (int, error) {
+	blog, err := service.Repository.GetByID(blogID)
+	if err != nil {
+		return 0, err
+	}
+
+	if blog.Version != version {
+		return 0, ErrVersionConflict
+	}
+
+	rendered := service.UtilService.RenderMD(content)
+
+	// an empty content is left untouched like any other empty field of an update
+	safe := &inputs.SafeUpdateBlogInput{
+		Content:         content,
+		ContentHTML:     rendered.HTML,
+		RendererVersion: constants.RendererVersion,
+		Version:         blog.Version + 1,
+		TOC:             rendered.TOC,
+		WordCount:       rendered.WordCount,
+		ReadingTime:     rendered.ReadingTime,
+	}
+
+	updated, err := service.Repository.UpdateBlog(blog.ID, blog.Version, safe)
+	if err != nil {
+		return 0, err
+	}
+
+	if !updated {
+		return 0, ErrVersionConflict
+	}
+
+	// the word count and reading time show up in the listings
+	if blog.Published {
+		invalidate(service.CacheService, BlogCacheTag(blog.ID), AuthorCacheTag(blog.AuthorID), constants.CacheTagPublished)
+
+		service.refreshRelated()
+		service.publishUpdated(blog.ID, "")
+	} else {
+		invalidate(service.CacheService, BlogCacheTag(blog.ID))
+	}
+
+	return safe.Version, nil
+}
+
 func (service *BlogServiceImpl) GetCurrentUserBlogs(userID string, dataOrder constants.Order) ([]entities.Blog, error) {
 	// default data-order to true (DESC)
 	order := true
@@ -184,12 +427,28 @@ func (service *BlogServiceImpl) GetCurrentUserBlog(blogID string, userID string)
 		return nil, err
 	}
 
-	// replace the content of the blog with the parsed HTML instead of pure markdown
-	blog.Content = service.UtilService.ParseMD(blog.Content)
+	rendered := service.renderedContent(blog.ID, blog.Content, &types.RenderedMarkdown{
+		HTML:        blog.ContentHTML,
+		TOC:         blog.TOC,
+		WordCount:   blog.WordCount,
+		ReadingTime: blog.ReadingTime,
+	}, blog.RendererVersion)
+
+	// replace the content of the blog with the rendered HTML instead of pure markdown
+	blog.Content = rendered.HTML
+	blog.TOC = rendered.TOC
+	blog.WordCount = rendered.WordCount
+	blog.ReadingTime = rendered.ReadingTime
 
 	return blog, nil
 }
 
+func (service *BlogServiceImpl) CanEditBlog(blogID string, userID string) bool {
+	_, err := service.Repository.GetByIDAndAuthor(blogID, userID)
+
+	return err == nil
+}
+
 func (service *BlogServiceImpl) GetCurrentUserSlugs(slug string, userID string) ([]entities.Blog, error) {
 	exist, err := service.Repository.GetCurrentUserSlugs(slug, userID)
 	if err != nil {
@@ -199,48 +458,438 @@ func (service *BlogServiceImpl) GetCurrentUserSlugs(slug string, userID string)
 	return exist, nil
 }
 
+// ChangeBlogPublish is the transition of the blog to published, or back to draft when unpublishing.
 func (service *BlogServiceImpl) ChangeBlogPublish(payload *inputs.BlogIDInput, userID string, publishState bool) error {
-	blog, err := service.Repository.GetByIDAndAuthor(payload.ID, userID)
+	state := constants.BlogDraft
+
+	if publishState {
+		state = constants.BlogPublished
+	}
+
+	return service.TransitionBlog(&inputs.TransitionBlogInput{
+		ID:    payload.ID,
+		State: state,
+	}, userID)
+}
+
+// TransitionBlog moves the blog to the given state when the user holds a role allowed to,
+// the transition is recorded along with its optional note.
+func (service *BlogServiceImpl) TransitionBlog(payload *inputs.TransitionBlogInput, userID string) error {
+	blog, roles, err := blogRoles(service.Repository, service.UserRepository, payload.ID, userID)
 	if err != nil {
 		return err
 	}
 
+	from := blog.State
+
+	if err := canTransition(roles, from, payload.State); err != nil {
+		return err
+	}
+
 	currentTime := time.Now()
 
-	// update published state based on given param
-	blog.Published = publishState
+	blog.State = payload.State
+	blog.Published = payload.State == constants.BlogPublished
 
-	if publishState {
-		generatedSlug := service.UtilService.FormatToURL(blog.Title)
+	switch {
+	case payload.State == constants.BlogApproved:
+		blog.ApprovedVersion = blog.Version
 
-		slugExist, err := service.GetCurrentUserSlugs(generatedSlug, userID)
-		if err != nil {
-			return err
+	case payload.State == constants.BlogPublished:
+		// the approval only covers the version the reviewer read
+		if from == constants.BlogApproved && blog.ApprovedVersion != blog.Version {
+			return ErrApprovalOutdated
 		}
 
-		if len(slugExist) == 0 {
-			blog.Slug = generatedSlug
-		} else {
-			blog.Slug = fmt.Sprintf("%s-%d", generatedSlug, currentTime.Unix())
+		// the slug is kept once set, republishing must not change the URL
+		if blog.Slug == "" {
+			generatedSlug := service.UtilService.FormatToURL(blog.Title)
+
+			// slugs are unique per author, co-authors publish under the author's name
+			slugExist, err := service.GetCurrentUserSlugs(generatedSlug, blog.AuthorID)
+			if err != nil {
+				return err
+			}
+
+			if len(slugExist) == 0 {
+				blog.Slug = generatedSlug
+			} else {
+				blog.Slug = fmt.Sprintf("%s-%d", generatedSlug, currentTime.Unix())
+			}
 		}
 
-		// we need to update the PublishedAt field
-		blog.PublishedAt = currentTime
-	} else {
-		// reset slug
-		blog.Slug = ""
+		// we need to update the PublishedAt field, unless a publish date was given upfront
+		if blog.PublishedAt.IsZero() {
+			blog.PublishedAt = currentTime
+		}
+
+		// blogs written before rendering on write was introduced have no HTML yet
+		if blog.RendererVersion < constants.RendererVersion {
+			rendered := service.UtilService.RenderMD(blog.Content)
 
+			blog.ContentHTML = rendered.HTML
+			blog.RendererVersion = constants.RendererVersion
+			blog.TOC = rendered.TOC
+			blog.WordCount = rendered.WordCount
+			blog.ReadingTime = rendered.ReadingTime
+		}
+
+	case from == constants.BlogPublished:
 		// reset the PublishedAt field to "January 1, year 1, 00:00:00 UTC" (invalid date)
 		blog.PublishedAt = time.Time{}
 	}
 
 	// change the updated at to newest date
-	blog.UpdatedAt = time.Now()
+	blog.UpdatedAt = currentTime
+
+	moved, err := service.Repository.TransitionBlog(blog, &entities.BlogTransition{
+		BlogID:    blog.ID,
+		ActorID:   userID,
+		FromState: from,
+		ToState:   payload.State,
+		Note:      payload.Note,
+	})
+	if err != nil {
+		return err
+	}
+
+	// someone else moved the blog first
+	if !moved {
+		return ErrInvalidTransition
+	}
+
+	if from != constants.BlogPublished && !blog.Published {
+		invalidate(service.CacheService, BlogCacheTag(blog.ID))
+		return nil
+	}
+
+	invalidate(service.CacheService, BlogCacheTag(blog.ID), AuthorCacheTag(blog.AuthorID), constants.CacheTagPublished)
+
+	// both publishing and unpublishing change the related posts corpus
+	service.refreshRelated()
+
+	if service.EventService != nil {
+		eventType := constants.EventBlogPublished
+		if !blog.Published {
+			eventType = constants.EventBlogUnpublished
+		}
+
+		service.EventService.Publish(types.Event{
+			Type:    eventType,
+			ActorID: userID,
+			BlogID:  blog.ID,
+		})
+	}
 
-	// save back to the database
-	if err := service.Repository.SaveBlog(blog); err != nil {
+	return nil
+}
+
+func (service *BlogServiceImpl) UpdateSlug(payload *inputs.UpdateSlugInput, userID string) error {
+	blog, err := service.Repository.GetByIDAndAuthor(payload.ID, userID)
+	if err != nil {
 		return err
 	}
 
+	if blog.Slug == payload.Slug {
+		return nil
+	}
+
+	// co-authors share the slugs of the author
+	slugExist, err := service.GetCurrentUserSlugs(payload.Slug, blog.AuthorID)
+	if err != nil {
+		return err
+	}
+
+	if len(slugExist) > 0 {
+		return ErrSlugTaken
+	}
+
+	if err := service.Repository.UpdateSlug(blog, payload.Slug); err != nil {
+		return err
+	}
+
+	invalidate(service.CacheService, BlogCacheTag(blog.ID), AuthorCacheTag(blog.AuthorID), constants.CacheTagPublished)
+
+	// the URL of the blog changed
+	if blog.Published {
+		service.publishUpdated(blog.ID, userID)
+	}
+
 	return nil
 }
+
+func (service *BlogServiceImpl) publishUpdated(blogID string, userID string) {
+	if service.EventService == nil {
+		return
+	}
+
+	service.EventService.Publish(types.Event{
+		Type:    constants.EventBlogUpdated,
+		ActorID: userID,
+		BlogID:  blogID,
+	})
+}
+
+func (service *BlogServiceImpl) GetSlugRedirect(username string, slug string) (string, error) {
+	return service.Repository.GetSlugRedirect(username, slug)
+}
+
+// GetFeed retrieves published blogs of every author the user follows, newest first.
+// The cursor is the opaque NextCursor of the previous page, empty for the first page.
+// NextCursor is empty when there are no more blogs to fetch.
+func (service *BlogServiceImpl) GetFeed(userID string, cursor string, limit int) (*dto.FeedOutput, error) {
+	var result *dto.FeedOutput
+
+	key := fmt.Sprintf("feed:%s:%s:%d", userID, cursor, limit)
+
+	err := remember(service.CacheService, key, &result, func() (interface{}, []string, error) {
+		var feedCursor *types.FeedCursor
+
+		if cursor != "" {
+			decoded, err := DecodeFeedCursor(cursor)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			feedCursor = decoded
+		}
+
+		// fetch one extra blog to know whether another page exists
+		blogs, err := service.Repository.GetFeed(userID, feedCursor, limit+1)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		result := &dto.FeedOutput{
+			Blogs: blogs,
+		}
+
+		if len(blogs) > limit {
+			result.Blogs = blogs[:limit]
+
+			last := result.Blogs[limit-1]
+			result.NextCursor = EncodeFeedCursor(&types.FeedCursor{
+				PublishedAt: last.PublishedAt,
+				ID:          last.ID,
+			})
+		}
+
+		return result, feedCacheTags(userID, result.Blogs), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// feedCacheTags returns the tags of a feed page, blogs published
+// by the followed authors may show up at any page.
+func feedCacheTags(userID string, blogs []entities.SafeBlogAuthor) []string {
+	return append(blogsCacheTags(blogs), FollowerCacheTag(userID), constants.CacheTagPublished)
+}
+
+// EncodeFeedCursor turns a cursor into an opaque URL-safe string.
+func EncodeFeedCursor(cursor *types.FeedCursor) string {
+	raw := fmt.Sprintf("%d_%s", cursor.PublishedAt.UnixNano(), cursor.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeFeedCursor reverses EncodeFeedCursor, it errors on tampered cursors.
+func DecodeFeedCursor(cursor string) (*types.FeedCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New("Invalid cursor")
+	}
+
+	timestamp, ID, found := strings.Cut(string(raw), "_")
+	if !found || ID == "" {
+		return nil, errors.New("Invalid cursor")
+	}
+
+	nano, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, errors.New("Invalid cursor")
+	}
+
+	return &types.FeedCursor{
+		PublishedAt: time.Unix(0, nano),
+		ID:          ID,
+	}, nil
+}
+
+// refreshRelated schedules recomputing related posts in the background, edits made in a row
+// share a single recompute, the caller should never wait for nor fail because of it.
+func (service *BlogServiceImpl) refreshRelated() {
+	if service.RelatedService == nil {
+		return
+	}
+
+	service.RelatedService.ScheduleRecompute()
+}
+
+func (service *BlogServiceImpl) RenderStaleBlogs() (int, error) {
+	var rendered int
+
+	for {
+		blogs, err := service.Repository.GetStaleRenderedBlogs(constants.RendererVersion, constants.RenderBatchSize)
+		if err != nil {
+			return rendered, err
+		}
+
+		if len(blogs) == 0 {
+			return rendered, nil
+		}
+
+		for _, blog := range blogs {
+			result := service.UtilService.RenderMD(blog.Content)
+
+			if err := service.Repository.SaveRenderedContent(blog.ID, result, constants.RendererVersion); err != nil {
+				return rendered, err
+			}
+
+			invalidate(service.CacheService, BlogCacheTag(blog.ID))
+			rendered++
+		}
+	}
+}
+
+// renderedContent returns the stored rendering of the blog unless it was rendered by an
+// older renderer version, in which case the content is re-rendered and stored again.
+func (service *BlogServiceImpl) renderedContent(blogID string, content string, stored *types.RenderedMarkdown, version int) *types.RenderedMarkdown {
+	if version >= constants.RendererVersion {
+		return stored
+	}
+
+	rendered := service.UtilService.RenderMD(content)
+
+	if err := service.Repository.SaveRenderedContent(blogID, rendered, constants.RendererVersion); err != nil {
+		log.Println("Error saving rendered blog:", err)
+	}
+
+	return rendered
+}
+
+// GetBlogValidator is stored under the tags of the blog it validates,
+// it is loaded on its own, the blog gets cached by the request serving it.
+func (service *BlogServiceImpl) GetBlogValidator(opts *types.GetBlogOpts) (*types.Validator, error) {
+	var validator *types.Validator
+
+	key := fmt.Sprintf("validator:blog:%s:%s:%s:%t", opts.UseID, opts.BlogAuthor, opts.BlogSlug, opts.Published)
+
+	err := remember(service.CacheService, key, &validator, func() (interface{}, []string, error) {
+		result, err := service.Repository.GetBlogValidator(opts)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		authorIDs := strings.Split(result.AuthorIDs, ",")
+
+		// only the IDs matter to the digest and the tags, Authors lists the author first as well
+		blog := entities.SafeBlogAuthor{
+			SafeBlog: entities.SafeBlog{ID: result.BlogID},
+			Author:   entities.SafeUser{ID: authorIDs[0]},
+		}
+
+		for _, authorID := range authorIDs {
+			blog.Authors = append(blog.Authors, entities.SafeUser{ID: authorID})
+		}
+
+		blogs := []entities.SafeBlogAuthor{blog}
+		result.Digest = blogsDigest(blogs)
+
+		return &result.Validator, blogsCacheTags(blogs), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return validator, nil
+}
+
+// GetBlogsValidator validates the published blogs, of a single author when the username is not empty,
+// in every order since they only differ by it.
+func (service *BlogServiceImpl) GetBlogsValidator(username string) (*types.Validator, error) {
+	var validator *types.Validator
+
+	err := remember(service.CacheService, "validator:blogs:"+username, &validator, func() (interface{}, []string, error) {
+		var blogs []entities.SafeBlogAuthor
+		var err error
+
+		if username == "" {
+			blogs, err = service.GetAllBlogs(true, constants.DESC)
+		} else {
+			blogs, err = service.GetAllUserBlogs(username, constants.DESC)
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		validator, err := service.Repository.GetBlogsValidator(username)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		validator.Digest = blogsDigest(blogs)
+
+		// the same tags as the lists
+		tags := append(blogsCacheTags(blogs), constants.CacheTagPublished)
+
+		if username != "" {
+			tags = append(tags, UsernameCacheTag(username))
+		}
+
+		return validator, tags, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return validator, nil
+}
+
+func (service *BlogServiceImpl) GetFeedValidator(userID string, cursor string, limit int) (*types.Validator, error) {
+	var validator *types.Validator
+
+	key := fmt.Sprintf("validator:feed:%s:%s:%d", userID, cursor, limit)
+
+	err := remember(service.CacheService, key, &validator, func() (interface{}, []string, error) {
+		feed, err := service.GetFeed(userID, cursor, limit)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		validator, err := service.Repository.GetFeedValidator(userID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		validator.Digest = blogsDigest(feed.Blogs)
+
+		return validator, feedCacheTags(userID, feed.Blogs), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return validator, nil
+}
+
+// blogsDigest hashes the IDs of the blogs and of their authors in order.
+func blogsDigest(blogs []entities.SafeBlogAuthor) string {
+	hash := sha256.New()
+
+	for _, blog := range blogs {
+		hash.Write([]byte(blog.ID))
+
+		for _, author := range append([]entities.SafeUser{blog.Author}, blog.Authors...) {
+			hash.Write([]byte{0})
+			hash.Write([]byte(author.ID))
+		}
+
+		hash.Write([]byte{'\n'})
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
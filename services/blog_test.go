@@ -2,10 +2,12 @@ package services
 
 import (
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"resqiar.com-server/constants"
 	"resqiar.com-server/dto"
 	"resqiar.com-server/entities"
@@ -295,8 +297,9 @@ func TestGetBlogDetail(t *testing.T) {
 
 		expectedBlog := &entities.SafeBlogAuthor{
 			SafeBlog: entities.SafeBlog{
-				Slug:        slug,
-				PublishedAt: time.Now(),
+				Slug:            slug,
+				PublishedAt:     time.Now(),
+				RendererVersion: constants.RendererVersion,
 			},
 		}
 
@@ -333,8 +336,9 @@ func TestGetBlogDetail(t *testing.T) {
 
 		expectedBlog := &entities.SafeBlogAuthor{
 			SafeBlog: entities.SafeBlog{
-				ID:          blogID,
-				PublishedAt: time.Time{},
+				ID:              blogID,
+				PublishedAt:     time.Time{},
+				RendererVersion: constants.RendererVersion,
 			},
 		}
 
@@ -364,6 +368,85 @@ func TestGetBlogDetail(t *testing.T) {
 		})
 	})
 
+	t.Run("Should serve the stored HTML rendered by the current renderer", func(t *testing.T) {
+		blogID := "example-of-id"
+
+		expectedBlog := &entities.SafeBlogAuthor{
+			SafeBlog: entities.SafeBlog{
+				ID:              blogID,
+				Content:         "# Hello World",
+				ContentHTML:     "<p>stored</p>",
+				RendererVersion: constants.RendererVersion,
+			},
+		}
+
+		getBlogOpts := &types.GetBlogOpts{
+			UseID:          blogID,
+			IncludeContent: true,
+			Published:      true,
+		}
+
+		blogDetailOpts := &types.BlogDetailOpts{
+			GetBlogOpts: getBlogOpts,
+			ReturnHTML:  true,
+		}
+
+		mock := blogRepoTest.Mock.On("GetBlog", getBlogOpts).Return(expectedBlog, nil)
+		result, err := blogServiceTest.GetBlogDetail(blogDetailOpts)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "<p>stored</p>", result.Content)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			mock.Unset()
+		})
+	})
+
+	t.Run("Should re-render and store the HTML rendered by an older renderer", func(t *testing.T) {
+		blogID := "example-of-id"
+
+		expectedBlog := &entities.SafeBlogAuthor{
+			SafeBlog: entities.SafeBlog{
+				ID:              blogID,
+				Content:         "# Hello World",
+				ContentHTML:     "<p>outdated</p>",
+				RendererVersion: constants.RendererVersion - 1,
+			},
+		}
+
+		getBlogOpts := &types.GetBlogOpts{
+			UseID:          blogID,
+			IncludeContent: true,
+			Published:      true,
+		}
+
+		blogDetailOpts := &types.BlogDetailOpts{
+			GetBlogOpts: getBlogOpts,
+			ReturnHTML:  true,
+		}
+
+		rendered := utilService.RenderMD("# Hello World")
+
+		firstMock := blogRepoTest.Mock.On("GetBlog", getBlogOpts).Return(expectedBlog, nil)
+		secondMock := blogRepoTest.Mock.On("SaveRenderedContent", blogID, rendered, constants.RendererVersion).Return(nil)
+
+		result, err := blogServiceTest.GetBlogDetail(blogDetailOpts)
+
+		assert.Nil(t, err)
+		assert.Equal(t, rendered.HTML, result.Content)
+		assert.Equal(t, types.TableOfContents{{ID: "hello-world", Text: "Hello World", Level: 1}}, result.TOC)
+		assert.Equal(t, 2, result.WordCount)
+		assert.Equal(t, 1, result.ReadingTime)
+		blogRepoTest.Mock.AssertCalled(t, "SaveRenderedContent", blogID, rendered, constants.RendererVersion)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+
 	t.Run("Should error if author username wrong", func(t *testing.T) {
 		authorUsername := "Wrong_Author"
 		slug := "example-of-slug"
@@ -433,8 +516,11 @@ func TestCreateBlog(t *testing.T) {
 			Title: "Example Title",
 		}
 		input := entities.Blog{
-			Title:    payload.Title,
-			AuthorID: userID,
+			Title:           payload.Title,
+			AuthorID:        userID,
+			RendererVersion: constants.RendererVersion,
+			State:           constants.BlogDraft,
+			TOC:             types.TableOfContents{},
 		}
 
 		mock := blogRepoTest.Mock.On("CreateBlog", &input).Return(&input, nil)
@@ -458,8 +544,11 @@ func TestCreateBlog(t *testing.T) {
 			Title: "Example Title",
 		}
 		input := entities.Blog{
-			Title:    payload.Title,
-			AuthorID: userID,
+			Title:           payload.Title,
+			AuthorID:        userID,
+			RendererVersion: constants.RendererVersion,
+			State:           constants.BlogDraft,
+			TOC:             types.TableOfContents{},
 		}
 
 		mock := blogRepoTest.Mock.On("CreateBlog", &input).Return(nil, errors.New("Something went wrong"))
@@ -483,17 +572,21 @@ func TestEditBlog(t *testing.T) {
 		userID := "example-of-id"
 
 		payload := &inputs.UpdateBlogInput{
-			ID: blogID,
+			ID:      blogID,
+			Version: 1,
 		}
 
 		expectedBlog := &entities.Blog{
-			ID: payload.ID,
+			ID:      payload.ID,
+			Version: 1,
 		}
 
-		expected := inputs.SafeUpdateBlogInput{}
+		expected := inputs.SafeUpdateBlogInput{
+			Version: 2,
+		}
 
 		firstMock := blogRepoTest.Mock.On("GetByIDAndAuthor", payload.ID, userID).Return(expectedBlog, nil)
-		secondMock := blogRepoTest.Mock.On("UpdateBlog", payload.ID, &expected).Return(nil)
+		secondMock := blogRepoTest.Mock.On("UpdateBlog", payload.ID, 1, &expected).Return(true, nil)
 
 		err := blogServiceTest.EditBlog(payload, userID)
 
@@ -511,13 +604,16 @@ func TestEditBlog(t *testing.T) {
 		userID := "example-of-id"
 
 		payload := &inputs.UpdateBlogInput{
-			ID: blogID,
+			ID:      blogID,
+			Version: 1,
 		}
 
-		expected := inputs.SafeUpdateBlogInput{}
+		expected := inputs.SafeUpdateBlogInput{
+			Version: 2,
+		}
 
 		firstMock := blogRepoTest.Mock.On("GetByIDAndAuthor", payload.ID, userID).Return(nil, errors.New("Record not found"))
-		secondMock := blogRepoTest.Mock.On("UpdateBlog", payload.ID, &expected).Return(nil)
+		secondMock := blogRepoTest.Mock.On("UpdateBlog", payload.ID, 1, &expected).Return(true, nil)
 
 		err := blogServiceTest.EditBlog(payload, userID)
 
@@ -537,17 +633,21 @@ func TestEditBlog(t *testing.T) {
 		userID := "example-of-id"
 
 		payload := &inputs.UpdateBlogInput{
-			ID: blogID,
+			ID:      blogID,
+			Version: 1,
 		}
 
 		expectedBlog := &entities.Blog{
-			ID: payload.ID,
+			ID:      payload.ID,
+			Version: 1,
 		}
 
-		expected := inputs.SafeUpdateBlogInput{}
+		expected := inputs.SafeUpdateBlogInput{
+			Version: 2,
+		}
 
 		firstMock := blogRepoTest.Mock.On("GetByIDAndAuthor", payload.ID, userID).Return(expectedBlog, nil)
-		secondMock := blogRepoTest.Mock.On("UpdateBlog", payload.ID, &expected).Return(errors.New("Error updating blog"))
+		secondMock := blogRepoTest.Mock.On("UpdateBlog", payload.ID, 1, &expected).Return(false, errors.New("Error updating blog"))
 
 		err := blogServiceTest.EditBlog(payload, userID)
 
@@ -560,6 +660,65 @@ func TestEditBlog(t *testing.T) {
 			secondMock.Unset()
 		})
 	})
+
+	t.Run("Should return conflict when the edit is based on an outdated version", func(t *testing.T) {
+		blogID := "example-of-id"
+		userID := "example-of-id"
+
+		payload := &inputs.UpdateBlogInput{
+			ID:      blogID,
+			Version: 1,
+		}
+
+		expectedBlog := &entities.Blog{
+			ID:      payload.ID,
+			Version: 2,
+		}
+
+		firstMock := blogRepoTest.Mock.On("GetByIDAndAuthor", payload.ID, userID).Return(expectedBlog, nil)
+
+		err := blogServiceTest.EditBlog(payload, userID)
+
+		assert.ErrorIs(t, err, ErrVersionConflict)
+		blogRepoTest.Mock.AssertNotCalled(t, "UpdateBlog", payload.ID, 2, &inputs.SafeUpdateBlogInput{Version: 3})
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+		})
+	})
+
+	t.Run("Should return conflict when another edit lands while updating", func(t *testing.T) {
+		blogID := "example-of-id"
+		userID := "example-of-id"
+
+		payload := &inputs.UpdateBlogInput{
+			ID:      blogID,
+			Version: 1,
+		}
+
+		expectedBlog := &entities.Blog{
+			ID:      payload.ID,
+			Version: 1,
+		}
+
+		expected := inputs.SafeUpdateBlogInput{
+			Version: 2,
+		}
+
+		firstMock := blogRepoTest.Mock.On("GetByIDAndAuthor", payload.ID, userID).Return(expectedBlog, nil)
+		secondMock := blogRepoTest.Mock.On("UpdateBlog", payload.ID, 1, &expected).Return(false, nil)
+
+		err := blogServiceTest.EditBlog(payload, userID)
+
+		assert.ErrorIs(t, err, ErrVersionConflict)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
 }
 
 func TestGetCurrentUserBlogs(t *testing.T) {
@@ -682,7 +841,8 @@ func TestGetCurrentUserBlog(t *testing.T) {
 		blogID := "example-of-blog-id"
 
 		expected := &entities.Blog{
-			AuthorID: userID,
+			AuthorID:        userID,
+			RendererVersion: constants.RendererVersion,
 		}
 
 		mock := blogRepoTest.Mock.On("GetCurrentUserBlog", blogID, userID).Return(expected, nil)
@@ -730,15 +890,19 @@ func TestChangeBlogPublish(t *testing.T) {
 			ID:        payload.ID,
 			Title:     "Example of Title",
 			Published: false,
+			AuthorID:  userID,
+			State:     constants.BlogDraft,
 		}
 
 		firstMock := blogRepoTest.Mock.On("GetByIDAndAuthor", payload.ID, userID).Return(unpublishedBlog, nil)
-		secondMock := blogRepoTest.Mock.On("SaveBlog", unpublishedBlog).Return(nil)
+		secondMock := blogRepoTest.Mock.On("TransitionBlog", unpublishedBlog, mock.Anything).Return(true, nil)
 		thirdMock := blogRepoTest.Mock.On("GetCurrentUserSlugs", slug, userID).Return([]entities.Blog{}, nil)
 
 		err := blogServiceTest.ChangeBlogPublish(&payload, userID, true)
 
 		assert.Nil(t, err)
+		assert.True(t, unpublishedBlog.Published)
+		assert.Equal(t, constants.BlogPublished, unpublishedBlog.State)
 
 		t.Cleanup(func() {
 			// Cleanup mocking
@@ -748,26 +912,39 @@ func TestChangeBlogPublish(t *testing.T) {
 		})
 	})
 
-	t.Run("Should change a blog publication status from TRUE to FALSE", func(t *testing.T) {
+	t.Run("Should publish an approved blog", func(t *testing.T) {
 		slug := "example-of-title"
 		userID := "example-of-user-id"
 		payload := inputs.BlogIDInput{
 			ID: "example-of-id",
 		}
 
-		unpublishedBlog := &entities.Blog{
-			ID:        payload.ID,
-			Title:     "Example of Title",
-			Published: true,
+		approvedBlog := &entities.Blog{
+			ID:              payload.ID,
+			Title:           "Example of Title",
+			Published:       false,
+			AuthorID:        userID,
+			State:           constants.BlogApproved,
+			Version:         2,
+			ApprovedVersion: 2,
 		}
 
-		firstMock := blogRepoTest.Mock.On("GetByIDAndAuthor", payload.ID, userID).Return(unpublishedBlog, nil)
-		secondMock := blogRepoTest.Mock.On("SaveBlog", unpublishedBlog).Return(nil)
+		firstMock := blogRepoTest.Mock.On("GetByIDAndAuthor", payload.ID, userID).Return(approvedBlog, nil)
+		secondMock := blogRepoTest.Mock.On("TransitionBlog", approvedBlog, mock.Anything).Return(true, nil)
 		thirdMock := blogRepoTest.Mock.On("GetCurrentUserSlugs", slug, userID).Return([]entities.Blog{}, nil)
 
-		err := blogServiceTest.ChangeBlogPublish(&payload, userID, false)
+		err := blogServiceTest.ChangeBlogPublish(&payload, userID, true)
 
 		assert.Nil(t, err)
+		assert.True(t, approvedBlog.Published)
+		assert.Equal(t, constants.BlogPublished, approvedBlog.State)
+		assert.Equal(t, slug, approvedBlog.Slug)
+		blogRepoTest.Mock.AssertCalled(t, "TransitionBlog", approvedBlog, &entities.BlogTransition{
+			BlogID:    payload.ID,
+			ActorID:   userID,
+			FromState: constants.BlogApproved,
+			ToState:   constants.BlogPublished,
+		})
 
 		t.Cleanup(func() {
 			// Cleanup mocking
@@ -777,22 +954,138 @@ func TestChangeBlogPublish(t *testing.T) {
 		})
 	})
 
-	t.Run("Should return error if blog not found", func(t *testing.T) {
+	t.Run("Should check the slug among the author's blogs when a co-author publishes", func(t *testing.T) {
 		slug := "example-of-title"
-		userID := "example-of-wrong-id"
+		userID := "example-of-editor-id"
 		payload := inputs.BlogIDInput{
 			ID: "example-of-id",
 		}
 
-		unpublishedBlog := &entities.Blog{
+		approvedBlog := &entities.Blog{
 			ID:        payload.ID,
 			Title:     "Example of Title",
+			Published: false,
+			AuthorID:  "example-of-user-id",
+			State:     constants.BlogApproved,
+		}
+
+		firstMock := blogRepoTest.Mock.On("GetByIDAndAuthor", payload.ID, userID).Return(approvedBlog, nil)
+		secondMock := blogRepoTest.Mock.On("TransitionBlog", approvedBlog, mock.Anything).Return(true, nil)
+		thirdMock := blogRepoTest.Mock.On("GetCurrentUserSlugs", slug, "example-of-user-id").Return([]entities.Blog{{ID: "example-of-other-id"}}, nil)
+
+		err := blogServiceTest.ChangeBlogPublish(&payload, userID, true)
+
+		assert.Nil(t, err)
+		assert.NotEqual(t, slug, approvedBlog.Slug)
+		assert.True(t, strings.HasPrefix(approvedBlog.Slug, slug+"-"))
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+		})
+	})
+
+	t.Run("Should keep the slug of a blog published before", func(t *testing.T) {
+		userID := "example-of-user-id"
+		payload := inputs.BlogIDInput{
+			ID: "example-of-id",
+		}
+
+		approvedBlog := &entities.Blog{
+			ID:       payload.ID,
+			Slug:     "example-of-custom-slug",
+			Title:    "Example of Title",
+			AuthorID: userID,
+			State:    constants.BlogApproved,
+		}
+
+		firstMock := blogRepoTest.Mock.On("GetByIDAndAuthor", payload.ID, userID).Return(approvedBlog, nil)
+		secondMock := blogRepoTest.Mock.On("TransitionBlog", approvedBlog, mock.Anything).Return(true, nil)
+
+		// forget the slug lookups of previous tests
+		blogRepoTest.Mock.Calls = nil
+
+		err := blogServiceTest.ChangeBlogPublish(&payload, userID, true)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "example-of-custom-slug", approvedBlog.Slug)
+		blogRepoTest.Mock.AssertNotCalled(t, "GetCurrentUserSlugs", "example-of-title", userID)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+
+	t.Run("Should move a published blog back to draft when unpublishing", func(t *testing.T) {
+		userID := "example-of-user-id"
+		payload := inputs.BlogIDInput{
+			ID: "example-of-id",
+		}
+
+		publishedBlog := &entities.Blog{
+			ID:        payload.ID,
+			Slug:      "example-of-title",
+			Title:     "Example of Title",
 			Published: true,
+			AuthorID:  userID,
+			State:     constants.BlogPublished,
+		}
+
+		firstMock := blogRepoTest.Mock.On("GetByIDAndAuthor", payload.ID, userID).Return(publishedBlog, nil)
+		secondMock := blogRepoTest.Mock.On("TransitionBlog", publishedBlog, mock.Anything).Return(true, nil)
+
+		err := blogServiceTest.ChangeBlogPublish(&payload, userID, false)
+
+		assert.Nil(t, err)
+		assert.False(t, publishedBlog.Published)
+		assert.Equal(t, constants.BlogDraft, publishedBlog.State)
+		assert.Equal(t, "example-of-title", publishedBlog.Slug)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+
+	t.Run("Should not publish a blog edited after its approval", func(t *testing.T) {
+		userID := "example-of-user-id"
+		payload := inputs.BlogIDInput{
+			ID: "example-of-id",
+		}
+
+		approvedBlog := &entities.Blog{
+			ID:              payload.ID,
+			Title:           "Example of Title",
+			AuthorID:        userID,
+			State:           constants.BlogApproved,
+			Version:         3,
+			ApprovedVersion: 2,
+		}
+
+		firstMock := blogRepoTest.Mock.On("GetByIDAndAuthor", payload.ID, userID).Return(approvedBlog, nil)
+
+		err := blogServiceTest.ChangeBlogPublish(&payload, userID, true)
+
+		assert.ErrorIs(t, err, ErrApprovalOutdated)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+		})
+	})
+
+	t.Run("Should return error if blog not found", func(t *testing.T) {
+		userID := "example-of-wrong-id"
+		payload := inputs.BlogIDInput{
+			ID: "example-of-id",
 		}
 
 		firstMock := blogRepoTest.Mock.On("GetByIDAndAuthor", payload.ID, userID).Return(nil, errors.New("Record not found"))
-		secondMock := blogRepoTest.Mock.On("SaveBlog", unpublishedBlog).Return(nil)
-		thirdMock := blogRepoTest.Mock.On("GetCurrentUserSlugs", slug, userID).Return([]entities.Blog{}, nil)
 
 		err := blogServiceTest.ChangeBlogPublish(&payload, userID, true)
 
@@ -802,29 +1095,27 @@ func TestChangeBlogPublish(t *testing.T) {
 		t.Cleanup(func() {
 			// Cleanup mocking
 			firstMock.Unset()
-			secondMock.Unset()
-			thirdMock.Unset()
 		})
 	})
 
 	t.Run("Should return error if failed to be saved", func(t *testing.T) {
-		slug := "example-of-title"
 		userID := "example-of-id"
 		payload := inputs.BlogIDInput{
 			ID: "example-of-id",
 		}
 
-		unpublishedBlog := &entities.Blog{
+		publishedBlog := &entities.Blog{
 			ID:        payload.ID,
 			Title:     "Example of Title",
 			Published: true,
+			AuthorID:  userID,
+			State:     constants.BlogPublished,
 		}
 
-		firstMock := blogRepoTest.Mock.On("GetByIDAndAuthor", payload.ID, userID).Return(unpublishedBlog, nil)
-		secondMock := blogRepoTest.Mock.On("SaveBlog", unpublishedBlog).Return(errors.New("Error saving blog"))
-		thirdMock := blogRepoTest.Mock.On("GetCurrentUserSlugs", slug, userID).Return([]entities.Blog{}, nil)
+		firstMock := blogRepoTest.Mock.On("GetByIDAndAuthor", payload.ID, userID).Return(publishedBlog, nil)
+		secondMock := blogRepoTest.Mock.On("TransitionBlog", publishedBlog, mock.Anything).Return(false, errors.New("Error saving blog"))
 
-		err := blogServiceTest.ChangeBlogPublish(&payload, userID, true)
+		err := blogServiceTest.ChangeBlogPublish(&payload, userID, false)
 
 		assert.NotNil(t, err)
 		assert.Error(t, err)
@@ -833,7 +1124,383 @@ func TestChangeBlogPublish(t *testing.T) {
 			// Cleanup mocking
 			firstMock.Unset()
 			secondMock.Unset()
+		})
+	})
+
+	t.Run("Should return error if the blog was moved by someone else first", func(t *testing.T) {
+		userID := "example-of-id"
+		payload := inputs.BlogIDInput{
+			ID: "example-of-id",
+		}
+
+		publishedBlog := &entities.Blog{
+			ID:        payload.ID,
+			Title:     "Example of Title",
+			Published: true,
+			AuthorID:  userID,
+			State:     constants.BlogPublished,
+		}
+
+		firstMock := blogRepoTest.Mock.On("GetByIDAndAuthor", payload.ID, userID).Return(publishedBlog, nil)
+		secondMock := blogRepoTest.Mock.On("TransitionBlog", publishedBlog, mock.Anything).Return(false, nil)
+
+		err := blogServiceTest.ChangeBlogPublish(&payload, userID, false)
+
+		assert.ErrorIs(t, err, ErrInvalidTransition)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+}
+
+func TestUpdateSlug(t *testing.T) {
+	userID := "example-of-editor-id"
+	authorID := "example-of-user-id"
+
+	payload := &inputs.UpdateSlugInput{
+		ID:   "example-of-id",
+		Slug: "example-of-new-slug",
+	}
+
+	t.Run("Should change the slug when the author has no blog using it", func(t *testing.T) {
+		blog := &entities.Blog{
+			ID:       payload.ID,
+			Slug:     "example-of-old-slug",
+			AuthorID: authorID,
+		}
+
+		firstMock := blogRepoTest.Mock.On("GetByIDAndAuthor", payload.ID, userID).Return(blog, nil)
+		secondMock := blogRepoTest.Mock.On("GetCurrentUserSlugs", payload.Slug, authorID).Return([]entities.Blog{}, nil)
+		thirdMock := blogRepoTest.Mock.On("UpdateSlug", blog, payload.Slug).Return(nil)
+
+		err := blogServiceTest.UpdateSlug(payload, userID)
+
+		assert.Nil(t, err)
+		blogRepoTest.Mock.AssertCalled(t, "UpdateSlug", blog, payload.Slug)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+		})
+	})
+
+	t.Run("Should return error when another blog of the author uses the slug", func(t *testing.T) {
+		blog := &entities.Blog{
+			ID:       payload.ID,
+			Slug:     "example-of-old-slug",
+			AuthorID: authorID,
+		}
+
+		firstMock := blogRepoTest.Mock.On("GetByIDAndAuthor", payload.ID, userID).Return(blog, nil)
+		secondMock := blogRepoTest.Mock.On("GetCurrentUserSlugs", payload.Slug, authorID).Return([]entities.Blog{{ID: "example-of-other-id"}}, nil)
+
+		err := blogServiceTest.UpdateSlug(payload, userID)
+
+		assert.ErrorIs(t, err, ErrSlugTaken)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+
+	t.Run("Should do nothing when the slug does not change", func(t *testing.T) {
+		blog := &entities.Blog{
+			ID:       payload.ID,
+			Slug:     payload.Slug,
+			AuthorID: authorID,
+		}
+
+		firstMock := blogRepoTest.Mock.On("GetByIDAndAuthor", payload.ID, userID).Return(blog, nil)
+
+		err := blogServiceTest.UpdateSlug(payload, userID)
+
+		assert.Nil(t, err)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+		})
+	})
+}
+
+func TestFrontMatterMetadata(t *testing.T) {
+	userID := "example-of-id"
+	publishedAt := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Should create the blog with the slug, tags and publish date", func(t *testing.T) {
+		payload := inputs.CreateBlogInput{
+			Title:       "Example Title",
+			Tags:        types.Tags{"go", "markdown"},
+			Slug:        "example-of-slug",
+			PublishedAt: &publishedAt,
+		}
+		input := entities.Blog{
+			Title:           payload.Title,
+			AuthorID:        userID,
+			Tags:            payload.Tags,
+			Slug:            payload.Slug,
+			PublishedAt:     publishedAt,
+			RendererVersion: constants.RendererVersion,
+			State:           constants.BlogDraft,
+			TOC:             types.TableOfContents{},
+		}
+
+		firstMock := blogRepoTest.Mock.On("GetCurrentUserSlugs", payload.Slug, userID).Return([]entities.Blog{}, nil)
+		secondMock := blogRepoTest.Mock.On("CreateBlog", &input).Return(&input, nil)
+
+		result, err := blogServiceTest.CreateBlog(&payload, userID)
+
+		assert.Nil(t, err)
+		assert.Equal(t, &input, result)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+
+	t.Run("Should not create the blog when the slug is taken", func(t *testing.T) {
+		payload := inputs.CreateBlogInput{
+			Title: "Example Title",
+			Slug:  "example-of-slug",
+		}
+
+		firstMock := blogRepoTest.Mock.On("GetCurrentUserSlugs", payload.Slug, userID).Return([]entities.Blog{{ID: "example-of-other-id"}}, nil)
+
+		result, err := blogServiceTest.CreateBlog(&payload, userID)
+
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, ErrSlugTaken)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+		})
+	})
+
+	t.Run("Should change the slug along with the edit", func(t *testing.T) {
+		payload := &inputs.UpdateBlogInput{
+			ID:          "example-of-blog-id",
+			Slug:        "example-of-new-slug",
+			PublishedAt: &publishedAt,
+			Version:     1,
+		}
+
+		blog := &entities.Blog{
+			ID:       payload.ID,
+			Slug:     "example-of-old-slug",
+			AuthorID: userID,
+			Version:  1,
+		}
+
+		firstMock := blogRepoTest.Mock.On("GetByIDAndAuthor", payload.ID, userID).Return(blog, nil)
+		secondMock := blogRepoTest.Mock.On("GetCurrentUserSlugs", payload.Slug, userID).Return([]entities.Blog{}, nil)
+		thirdMock := blogRepoTest.Mock.On("UpdateBlog", payload.ID, 1, &inputs.SafeUpdateBlogInput{
+			PublishedAt: publishedAt,
+			Version:     2,
+		}).Return(true, nil)
+		fourthMock := blogRepoTest.Mock.On("UpdateSlug", blog, payload.Slug).Return(nil)
+
+		err := blogServiceTest.EditBlog(payload, userID)
+
+		assert.Nil(t, err)
+		blogRepoTest.Mock.AssertCalled(t, "UpdateSlug", blog, payload.Slug)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+			fourthMock.Unset()
+		})
+	})
+
+	t.Run("Should keep the publish date given upfront when publishing", func(t *testing.T) {
+		blog := &entities.Blog{
+			ID:          "example-of-blog-id",
+			Slug:        "example-of-slug",
+			AuthorID:    userID,
+			State:       constants.BlogApproved,
+			PublishedAt: publishedAt,
+		}
+
+		firstMock := blogRepoTest.Mock.On("GetByIDAndAuthor", blog.ID, userID).Return(blog, nil)
+		secondMock := blogRepoTest.Mock.On("TransitionBlog", blog, mock.Anything).Return(true, nil)
+
+		err := blogServiceTest.ChangeBlogPublish(&inputs.BlogIDInput{ID: blog.ID}, userID, true)
+
+		assert.Nil(t, err)
+		assert.Equal(t, publishedAt, blog.PublishedAt)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+}
+
+func TestGetFeed(t *testing.T) {
+	userID := "example-of-user-id"
+
+	feedBlogs := []entities.SafeBlogAuthor{
+		{SafeBlog: entities.SafeBlog{ID: "first", PublishedAt: time.Unix(300, 0)}},
+		{SafeBlog: entities.SafeBlog{ID: "second", PublishedAt: time.Unix(200, 0)}},
+		{SafeBlog: entities.SafeBlog{ID: "third", PublishedAt: time.Unix(100, 0)}},
+	}
+
+	t.Run("Should return the first page with a next cursor", func(t *testing.T) {
+		var noCursor *types.FeedCursor
+
+		mock := blogRepoTest.Mock.On("GetFeed", userID, noCursor, 3).Return(feedBlogs, nil)
+
+		result, err := blogServiceTest.GetFeed(userID, "", 2)
+
+		assert.Nil(t, err)
+		assert.Len(t, result.Blogs, 2)
+		assert.NotEmpty(t, result.NextCursor)
+
+		cursor, err := DecodeFeedCursor(result.NextCursor)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "second", cursor.ID)
+		assert.True(t, cursor.PublishedAt.Equal(time.Unix(200, 0)))
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			mock.Unset()
+		})
+	})
+
+	t.Run("Should return the last page without a next cursor", func(t *testing.T) {
+		cursor := &types.FeedCursor{PublishedAt: time.Unix(200, 0), ID: "second"}
+
+		mock := blogRepoTest.Mock.On("GetFeed", userID, cursor, 3).Return(feedBlogs[2:], nil)
+
+		result, err := blogServiceTest.GetFeed(userID, EncodeFeedCursor(cursor), 2)
+
+		assert.Nil(t, err)
+		assert.Len(t, result.Blogs, 1)
+		assert.Empty(t, result.NextCursor)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			mock.Unset()
+		})
+	})
+
+	t.Run("Should return error if the cursor is invalid", func(t *testing.T) {
+		result, err := blogServiceTest.GetFeed(userID, "!!not-a-cursor!!", 2)
+
+		assert.Nil(t, result)
+		assert.EqualError(t, err, "Invalid cursor")
+	})
+
+	t.Run("Should return error if query fails", func(t *testing.T) {
+		var noCursor *types.FeedCursor
+
+		mock := blogRepoTest.Mock.On("GetFeed", userID, noCursor, 11).Return(nil, errors.New("Something went wrong"))
+
+		result, err := blogServiceTest.GetFeed(userID, "", 10)
+
+		assert.Nil(t, result)
+		assert.Error(t, err)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			mock.Unset()
+		})
+	})
+}
+
+func TestGetBlogsValidator(t *testing.T) {
+	lastModified := time.Unix(300, 0)
+
+	t.Run("Should tell apart lists trading a blog for another", func(t *testing.T) {
+		// earlier tests leave failing GetBlogs calls behind
+		blogRepoTest.Mock.ExpectedCalls = nil
+
+		firstMock := blogRepoTest.Mock.On("GetBlogs", true, true, "").Return([]entities.SafeBlogAuthor{
+			{SafeBlog: entities.SafeBlog{ID: "first"}},
+			{SafeBlog: entities.SafeBlog{ID: "second"}},
+		}, nil).Once()
+		secondMock := blogRepoTest.Mock.On("GetBlogsValidator", "").Return(&types.Validator{LastModified: lastModified, Count: 2}, nil).Once()
+
+		before, err := blogServiceTest.GetBlogsValidator("")
+		assert.Nil(t, err)
+
+		// the second blog was unpublished while an older one was published again
+		blogRepoTest.Mock.On("GetBlogs", true, true, "").Return([]entities.SafeBlogAuthor{
+			{SafeBlog: entities.SafeBlog{ID: "first"}},
+			{SafeBlog: entities.SafeBlog{ID: "third"}},
+		}, nil).Once()
+		blogRepoTest.Mock.On("GetBlogsValidator", "").Return(&types.Validator{LastModified: lastModified, Count: 2}, nil).Once()
+
+		after, err := blogServiceTest.GetBlogsValidator("")
+		assert.Nil(t, err)
+
+		assert.Equal(t, before.LastModified, after.LastModified)
+		assert.Equal(t, before.Count, after.Count)
+		assert.NotEqual(t, before.Digest, after.Digest)
+
+		t.Cleanup(func() {
+			// Cleanup mocking, this also removes the second calls since they share arguments
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+}
+
+func TestRenderStaleBlogs(t *testing.T) {
+	t.Run("Should re-render every stale blog batch by batch", func(t *testing.T) {
+		blogs := []entities.Blog{
+			{ID: "example-of-first-id", Content: "# First"},
+			{ID: "example-of-second-id", Content: "# Second"},
+		}
+
+		// the first query returns a batch, the following one finds nothing left
+		blogRepoTest.Mock.On("GetStaleRenderedBlogs", constants.RendererVersion, constants.RenderBatchSize).Return(blogs, nil).Once()
+		blogRepoTest.Mock.On("GetStaleRenderedBlogs", constants.RendererVersion, constants.RenderBatchSize).Return([]entities.Blog{}, nil).Once()
+		thirdMock := blogRepoTest.Mock.On("SaveRenderedContent", "example-of-first-id", utilService.RenderMD("# First"), constants.RendererVersion).Return(nil)
+		fourthMock := blogRepoTest.Mock.On("SaveRenderedContent", "example-of-second-id", utilService.RenderMD("# Second"), constants.RendererVersion).Return(nil)
+
+		rendered, err := blogServiceTest.RenderStaleBlogs()
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, rendered)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
 			thirdMock.Unset()
+			fourthMock.Unset()
+		})
+	})
+
+	t.Run("Should stop when saving fails", func(t *testing.T) {
+		blogs := []entities.Blog{
+			{ID: "example-of-first-id", Content: "# First"},
+		}
+
+		firstMock := blogRepoTest.Mock.On("GetStaleRenderedBlogs", constants.RendererVersion, constants.RenderBatchSize).Return(blogs, nil)
+		secondMock := blogRepoTest.Mock.On("SaveRenderedContent", "example-of-first-id", utilService.RenderMD("# First"), constants.RendererVersion).Return(errors.New("Something went wrong"))
+
+		rendered, err := blogServiceTest.RenderStaleBlogs()
+
+		assert.Error(t, err)
+		assert.Equal(t, 0, rendered)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
 		})
 	})
 }
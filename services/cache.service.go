@@ -0,0 +1,299 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+)
+
+// CacheCompute loads the value to be cached along with the tags
+// invalidating it, the tags usually depend on the loaded value.
+type CacheCompute func() (interface{}, []string, error)
+
+type CacheService interface {
+	// Remember decodes the entry of the key into dest,
+	// on a miss the entry is computed once and stored under its tags.
+	Remember(key string, dest interface{}, compute CacheCompute) error
+
+	// Invalidate removes every entry stored under at least one of the tags,
+	// including the ones being computed meanwhile.
+	Invalidate(tags ...string) error
+}
+
+type CacheServiceImpl struct {
+	Client redis.UniversalClient
+	TTL    time.Duration
+
+	group singleflight.Group
+}
+
+func InitCacheService(client redis.UniversalClient) CacheService {
+	return &CacheServiceImpl{
+		Client: client,
+		TTL:    constants.CacheTTL,
+	}
+}
+
+func (service *CacheServiceImpl) Remember(key string, dest interface{}, compute CacheCompute) error {
+	ctx := context.Background()
+
+	cached, err := service.Client.Get(ctx, entryKey(key)).Bytes()
+	if err == nil {
+		return json.Unmarshal(cached, dest)
+	}
+
+	// an unreachable cache should never take the endpoints down with it
+	if err != redis.Nil {
+		log.Println("Error reading cache:", err)
+	}
+
+	// concurrent misses on this instance share a single load
+	data, err, _ := service.group.Do(key, func() (interface{}, error) {
+		return service.load(ctx, key, compute)
+	})
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data.([]byte), dest)
+}
+
+// load computes and stores the entry, unless another instance is already
+// computing it in which case its result is awaited instead.
+func (service *CacheServiceImpl) load(ctx context.Context, key string, compute CacheCompute) ([]byte, error) {
+	locked, err := service.Client.SetNX(ctx, lockKey(key), 1, constants.CacheLockTimeout).Result()
+	if err != nil {
+		log.Println("Error locking cache entry:", err)
+	}
+
+	if err == nil && !locked {
+		if cached := service.await(ctx, key); cached != nil {
+			return cached, nil
+		}
+	}
+
+	if locked {
+		defer service.Client.Del(ctx, lockKey(key))
+	}
+
+	// invalidations from now on make the computed value stale
+	since, err := service.Client.Get(ctx, INVALIDATIONS_KEY).Int64()
+	if err != nil && err != redis.Nil {
+		log.Println("Error reading cache invalidations:", err)
+	}
+
+	value, tags, err := compute()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := service.store(ctx, key, data, tags, since); err != nil {
+		log.Println("Error writing cache:", err)
+	}
+
+	return data, nil
+}
+
+// await polls the entry until the instance holding the lock stores it,
+// it returns nil once the lock is released or expired without an entry.
+func (service *CacheServiceImpl) await(ctx context.Context, key string) []byte {
+	deadline := time.Now().Add(constants.CacheLockTimeout)
+
+	for time.Now().Before(deadline) {
+		time.Sleep(constants.CachePollInterval)
+
+		cached, err := service.Client.Get(ctx, entryKey(key)).Bytes()
+		if err == nil {
+			return cached
+		}
+
+		if exist, err := service.Client.Exists(ctx, lockKey(key)).Result(); err != nil || exist == 0 {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// store skips the entry when one of its tags was invalidated since the given invalidation,
+// the value was computed before the change and would stay stale until it expires.
+func (service *CacheServiceImpl) store(ctx context.Context, key string, data []byte, tags []string, since int64) error {
+	generationKeys := make([]string, len(tags))
+	for i, tag := range tags {
+		generationKeys[i] = generationKey(tag)
+	}
+
+	save := func(tx *redis.Tx) error {
+		if len(generationKeys) > 0 {
+			generations, err := tx.MGet(ctx, generationKeys...).Result()
+			if err != nil {
+				return err
+			}
+
+			for _, generation := range generations {
+				if value, ok := generation.(string); ok {
+					if invalidation, _ := strconv.ParseInt(value, 10, 64); invalidation > since {
+						return nil
+					}
+				}
+			}
+		}
+
+		_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, entryKey(key), data, service.TTL)
+
+			// tag sets live as long as their newest entry
+			for _, tag := range tags {
+				pipe.SAdd(ctx, tagKey(tag), entryKey(key))
+				pipe.Expire(ctx, tagKey(tag), service.TTL)
+			}
+
+			return nil
+		})
+
+		return err
+	}
+
+	// a tag invalidated while storing fails the transaction, the next miss computes it again
+	err := service.Client.Watch(ctx, save, generationKeys...)
+	if err == redis.TxFailedErr {
+		return nil
+	}
+
+	return err
+}
+
+// Invalidate records the invalidation under every tag before removing their entries,
+// so that values being computed meanwhile are not stored afterwards.
+func (service *CacheServiceImpl) Invalidate(tags ...string) error {
+	ctx := context.Background()
+
+	invalidation, err := service.Client.Incr(ctx, INVALIDATIONS_KEY).Result()
+	if err != nil {
+		return err
+	}
+
+	// computing an entry never takes longer than it lives
+	_, err = service.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, tag := range tags {
+			pipe.Set(ctx, generationKey(tag), invalidation, service.TTL)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		keys, err := service.Client.SMembers(ctx, tagKey(tag)).Result()
+		if err != nil {
+			return err
+		}
+
+		if err := service.Client.Del(ctx, append(keys, tagKey(tag))...).Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// every invalidation is numbered by this counter, the tags remember their last one
+const INVALIDATIONS_KEY = "cache:invalidations"
+
+func entryKey(key string) string {
+	return "cache:entry:" + key
+}
+
+func lockKey(key string) string {
+	return "cache:lock:" + key
+}
+
+func tagKey(tag string) string {
+	return "cache:tag:" + tag
+}
+
+func generationKey(tag string) string {
+	return "cache:generation:" + tag
+}
+
+func BlogCacheTag(blogID string) string {
+	return "blog:" + blogID
+}
+
+func AuthorCacheTag(authorID string) string {
+	return "author:" + authorID
+}
+
+func UsernameCacheTag(username string) string {
+	return "username:" + username
+}
+
+// FollowerCacheTag tags the feed of the user, following or unfollowing someone changes it.
+func FollowerCacheTag(userID string) string {
+	return "follower:" + userID
+}
+
+// blogsCacheTags returns the tags of every blog and author within the list,
+// editing any of them invalidates the list.
+func blogsCacheTags(blogs []entities.SafeBlogAuthor) []string {
+	var tags []string
+
+	authors := make(map[string]struct{})
+
+	for _, blog := range blogs {
+		tags = append(tags, BlogCacheTag(blog.ID))
+
+		// co-authors are embedded into the blog as well
+		for _, author := range append([]entities.SafeUser{blog.Author}, blog.Authors...) {
+			if _, exist := authors[author.ID]; !exist {
+				authors[author.ID] = struct{}{}
+				tags = append(tags, AuthorCacheTag(author.ID))
+			}
+		}
+	}
+
+	return tags
+}
+
+// remember goes through the cache when there is one,
+// services without a cache simply compute the value into dest.
+func remember(cache CacheService, key string, dest interface{}, compute CacheCompute) error {
+	if cache != nil {
+		return cache.Remember(key, dest, compute)
+	}
+
+	value, _, err := compute()
+	if err != nil {
+		return err
+	}
+
+	reflect.ValueOf(dest).Elem().Set(reflect.ValueOf(value))
+
+	return nil
+}
+
+// invalidate never fails the caller, the entries expire on their own anyway.
+func invalidate(cache CacheService, tags ...string) {
+	if cache == nil {
+		return
+	}
+
+	if err := cache.Invalidate(tags...); err != nil {
+		log.Println("Error invalidating cache:", err)
+	}
+}
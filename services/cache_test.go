@@ -0,0 +1,335 @@
+package services
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/inputs"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+// memoryCache is an in-memory CacheService keeping track of invalidated tags.
+type memoryCache struct {
+	mu          sync.Mutex
+	entries     map[string][]byte
+	tags        map[string][]string
+	invalidated []string
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{
+		entries: make(map[string][]byte),
+		tags:    make(map[string][]string),
+	}
+}
+
+func (cache *memoryCache) Remember(key string, dest interface{}, compute CacheCompute) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if data, exist := cache.entries[key]; exist {
+		return json.Unmarshal(data, dest)
+	}
+
+	value, tags, err := compute()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	cache.entries[key] = data
+	for _, tag := range tags {
+		cache.tags[tag] = append(cache.tags[tag], key)
+	}
+
+	return json.Unmarshal(data, dest)
+}
+
+func (cache *memoryCache) Invalidate(tags ...string) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	for _, tag := range tags {
+		for _, key := range cache.tags[tag] {
+			delete(cache.entries, key)
+		}
+
+		delete(cache.tags, tag)
+		cache.invalidated = append(cache.invalidated, tag)
+	}
+
+	return nil
+}
+
+var cachedBlogRepoTest = repositories.BlogRepoMock{}
+var cachedUserRepoTest = repositories.UserRepoMock{}
+
+func TestCachedBlogs(t *testing.T) {
+	blogs := []entities.SafeBlogAuthor{
+		{
+			SafeBlog: entities.SafeBlog{
+				ID:    "example-of-blog-id",
+				Title: "Example of Title",
+			},
+			Author: entities.SafeUser{
+				ID:       "example-of-author-id",
+				Username: "author123",
+			},
+		},
+	}
+
+	t.Run("Should only hit the repository on a miss", func(t *testing.T) {
+		cache := newMemoryCache()
+		service := BlogServiceImpl{
+			UtilService:  &utilService,
+			Repository:   &cachedBlogRepoTest,
+			CacheService: cache,
+		}
+
+		mock := cachedBlogRepoTest.Mock.On("GetBlogs", true, true, "").Return(blogs, nil)
+
+		first, err := service.GetAllBlogs(true, constants.DESC)
+		require.Nil(t, err)
+
+		second, err := service.GetAllBlogs(true, constants.DESC)
+		require.Nil(t, err)
+
+		assert.Equal(t, first, second)
+		assert.Equal(t, "Example of Title", second[0].Title)
+		cachedBlogRepoTest.Mock.AssertNumberOfCalls(t, "GetBlogs", 1)
+
+		assert.ElementsMatch(t, cache.tags[BlogCacheTag("example-of-blog-id")], []string{"blogs:true:true"})
+		assert.ElementsMatch(t, cache.tags[AuthorCacheTag("example-of-author-id")], []string{"blogs:true:true"})
+		assert.ElementsMatch(t, cache.tags[constants.CacheTagPublished], []string{"blogs:true:true"})
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			mock.Unset()
+			cachedBlogRepoTest.Mock.Calls = nil
+		})
+	})
+
+	t.Run("Should tag empty author lists to be invalidated by any publish", func(t *testing.T) {
+		cache := newMemoryCache()
+		service := BlogServiceImpl{
+			UtilService:  &utilService,
+			Repository:   &cachedBlogRepoTest,
+			CacheService: cache,
+		}
+
+		mock := cachedBlogRepoTest.Mock.On("GetBlogs", true, true, "author123").Return([]entities.SafeBlogAuthor{}, nil)
+
+		_, err := service.GetAllUserBlogs("author123", constants.DESC)
+		require.Nil(t, err)
+
+		assert.Contains(t, cache.tags[constants.CacheTagPublished], "blogs:user:author123:true")
+		assert.Contains(t, cache.tags[UsernameCacheTag("author123")], "blogs:user:author123:true")
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			mock.Unset()
+		})
+	})
+
+	t.Run("Should invalidate the drafts when creating a blog", func(t *testing.T) {
+		cache := newMemoryCache()
+		service := BlogServiceImpl{
+			UtilService:  &utilService,
+			Repository:   &cachedBlogRepoTest,
+			CacheService: cache,
+		}
+
+		blog := &entities.Blog{
+			Title:           "Example of Title",
+			Published:       false,
+			AuthorID:        "example-of-author-id",
+			RendererVersion: constants.RendererVersion,
+			State:           constants.BlogDraft,
+			TOC:             types.TableOfContents{},
+		}
+
+		mock := cachedBlogRepoTest.Mock.On("CreateBlog", blog).Return(blog, nil)
+
+		_, err := service.CreateBlog(&inputs.CreateBlogInput{
+			Title: "Example of Title",
+		}, "example-of-author-id")
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{constants.CacheTagDrafts}, cache.invalidated)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			mock.Unset()
+		})
+	})
+
+	t.Run("Should invalidate the blog when editing it", func(t *testing.T) {
+		cache := newMemoryCache()
+		service := BlogServiceImpl{
+			UtilService:  &utilService,
+			Repository:   &cachedBlogRepoTest,
+			CacheService: cache,
+		}
+
+		payload := &inputs.UpdateBlogInput{
+			ID:      "example-of-blog-id",
+			Title:   "Example of Title",
+			Version: 1,
+		}
+
+		firstMock := cachedBlogRepoTest.Mock.On("GetByIDAndAuthor", payload.ID, "example-of-author-id").Return(&entities.Blog{
+			ID:       payload.ID,
+			AuthorID: "example-of-author-id",
+			Version:  1,
+		}, nil)
+		secondMock := cachedBlogRepoTest.Mock.On("UpdateBlog", payload.ID, 1, &inputs.SafeUpdateBlogInput{
+			Title:   payload.Title,
+			Version: 2,
+		}).Return(true, nil)
+
+		err := service.EditBlog(payload, "example-of-author-id")
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{BlogCacheTag(payload.ID)}, cache.invalidated)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+
+	t.Run("Should invalidate the blog, its author and published lists when publishing", func(t *testing.T) {
+		cache := newMemoryCache()
+		service := BlogServiceImpl{
+			UtilService:  &utilService,
+			Repository:   &cachedBlogRepoTest,
+			CacheService: cache,
+		}
+
+		blog := &entities.Blog{
+			ID:       "example-of-blog-id",
+			Title:    "Example of Title",
+			AuthorID: "example-of-author-id",
+			State:    constants.BlogApproved,
+		}
+
+		firstMock := cachedBlogRepoTest.Mock.On("GetByIDAndAuthor", blog.ID, blog.AuthorID).Return(blog, nil)
+		secondMock := cachedBlogRepoTest.Mock.On("GetCurrentUserSlugs", "example-of-title", blog.AuthorID).Return([]entities.Blog{}, nil)
+		thirdMock := cachedBlogRepoTest.Mock.On("TransitionBlog", blog, mock.Anything).Return(true, nil)
+
+		err := service.ChangeBlogPublish(&inputs.BlogIDInput{ID: blog.ID}, blog.AuthorID, true)
+
+		assert.Nil(t, err)
+		assert.ElementsMatch(t, []string{
+			BlogCacheTag(blog.ID),
+			AuthorCacheTag(blog.AuthorID),
+			constants.CacheTagPublished,
+		}, cache.invalidated)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+		})
+	})
+}
+
+func TestCachedUser(t *testing.T) {
+	t.Run("Should invalidate the author and the new username when updating the user", func(t *testing.T) {
+		cache := newMemoryCache()
+		service := UserServiceImpl{
+			UtilService:  &utilService,
+			Repository:   &cachedUserRepoTest,
+			CacheService: cache,
+		}
+
+		payload := &inputs.UpdateUserInput{
+			Username: "new-username",
+		}
+
+		firstMock := cachedUserRepoTest.Mock.On("FindByID", "example-of-valid-id").Return("example-of-valid-id", nil)
+		secondMock := cachedUserRepoTest.Mock.On("UpdateUser", "example-of-valid-id", payload).Return(nil)
+
+		err := service.UpdateUser(payload, "example-of-valid-id")
+
+		assert.Nil(t, err)
+		assert.Contains(t, cache.invalidated, AuthorCacheTag("example-of-valid-id"))
+		assert.Contains(t, cache.invalidated, UsernameCacheTag("new-username"))
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+}
+
+func TestCacheRemember(t *testing.T) {
+	// nothing listens on this address, every Redis command fails right away
+	service := &CacheServiceImpl{
+		Client: redis.NewClient(&redis.Options{
+			Addr:        "127.0.0.1:1",
+			MaxRetries:  -1,
+			DialTimeout: 100 * time.Millisecond,
+		}),
+		TTL: time.Minute,
+	}
+
+	t.Run("Should compute concurrent misses only once", func(t *testing.T) {
+		var computed int32
+		var wg sync.WaitGroup
+
+		results := make([]string, 10)
+
+		for i := range results {
+			wg.Add(1)
+
+			go func(i int) {
+				defer wg.Done()
+
+				err := service.Remember("example-of-key", &results[i], func() (interface{}, []string, error) {
+					atomic.AddInt32(&computed, 1)
+					time.Sleep(200 * time.Millisecond)
+
+					return "example-of-value", nil, nil
+				})
+
+				assert.Nil(t, err)
+			}(i)
+		}
+
+		wg.Wait()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&computed))
+		for _, result := range results {
+			assert.Equal(t, "example-of-value", result)
+		}
+	})
+
+	t.Run("Should fall back to computing when the cache is unreachable", func(t *testing.T) {
+		var result []string
+
+		err := service.Remember("example-of-other-key", &result, func() (interface{}, []string, error) {
+			return []string{"example-of-value"}, nil, nil
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"example-of-value"}, result)
+	})
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"errors"
+
+	"resqiar.com-server/constants"
+	"resqiar.com-server/dto"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/inputs"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+type CoAuthorService interface {
+	// Invite shares the blog with another user, only the author of the blog may invite.
+	Invite(payload *inputs.InviteCoAuthorInput, userID string) error
+	AcceptInvitation(blogID string, userID string) error
+
+	// DeclineInvitation declines a pending invitation, or stops co-authoring an accepted one.
+	DeclineInvitation(blogID string, userID string) error
+
+	// RemoveCoAuthor takes the blog back from a co-author, only the author of the blog may remove.
+	RemoveCoAuthor(payload *inputs.RemoveCoAuthorInput, userID string) error
+
+	// GetCoAuthors lists everyone the blog is shared with to its author and co-authors.
+	GetCoAuthors(blogID string, userID string) ([]dto.CoAuthorOutput, error)
+	GetInvitations(userID string) ([]dto.InvitationOutput, error)
+}
+
+type CoAuthorServiceImpl struct {
+	Repository     repositories.CoAuthorRepository
+	BlogRepository repositories.BlogRepository
+	UserRepository repositories.UserRepository
+	EventService   EventService
+	CacheService   CacheService
+}
+
+func (service *CoAuthorServiceImpl) Invite(payload *inputs.InviteCoAuthorInput, userID string) error {
+	blog, err := service.BlogRepository.GetByIDAndOwner(payload.BlogID, userID)
+	if err != nil {
+		return err
+	}
+
+	invitee, err := service.UserRepository.FindByUsername(payload.Username)
+	if err != nil {
+		return err
+	}
+
+	if invitee.ID == blog.AuthorID {
+		return errors.New("Cannot invite yourself")
+	}
+
+	created, err := service.Repository.CreateInvitation(&entities.CoAuthor{
+		BlogID:    blog.ID,
+		UserID:    invitee.ID,
+		InvitedBy: userID,
+		Role:      payload.Role,
+	})
+	if err != nil {
+		return err
+	}
+
+	if !created {
+		return errors.New("User was already invited")
+	}
+
+	if service.EventService != nil {
+		service.EventService.Publish(types.Event{
+			Type:    constants.EventCoAuthorInvite,
+			ActorID: userID,
+			UserID:  invitee.ID,
+			BlogID:  blog.ID,
+		})
+	}
+
+	return nil
+}
+
+func (service *CoAuthorServiceImpl) AcceptInvitation(blogID string, userID string) error {
+	accepted, err := service.Repository.AcceptInvitation(blogID, userID)
+	if err != nil {
+		return err
+	}
+
+	if !accepted {
+		return errors.New("Record not found")
+	}
+
+	// editors are listed as authors of the blog from now on
+	invalidate(service.CacheService, BlogCacheTag(blogID))
+
+	return nil
+}
+
+func (service *CoAuthorServiceImpl) DeclineInvitation(blogID string, userID string) error {
+	return service.remove(blogID, userID)
+}
+
+func (service *CoAuthorServiceImpl) RemoveCoAuthor(payload *inputs.RemoveCoAuthorInput, userID string) error {
+	if _, err := service.BlogRepository.GetByIDAndOwner(payload.BlogID, userID); err != nil {
+		return err
+	}
+
+	return service.remove(payload.BlogID, payload.UserID)
+}
+
+func (service *CoAuthorServiceImpl) remove(blogID string, userID string) error {
+	removed, err := service.Repository.DeleteCoAuthor(blogID, userID)
+	if err != nil {
+		return err
+	}
+
+	if removed == nil {
+		return errors.New("Record not found")
+	}
+
+	// pending invitations were never listed on the blog
+	if removed.AcceptedAt != nil {
+		invalidate(service.CacheService, BlogCacheTag(blogID))
+	}
+
+	return nil
+}
+
+func (service *CoAuthorServiceImpl) GetCoAuthors(blogID string, userID string) ([]dto.CoAuthorOutput, error) {
+	if _, err := service.BlogRepository.GetCurrentUserBlog(blogID, userID); err != nil {
+		return nil, err
+	}
+
+	coAuthors, err := service.Repository.GetCoAuthors(blogID)
+	if err != nil {
+		return nil, err
+	}
+
+	return coAuthors, nil
+}
+
+func (service *CoAuthorServiceImpl) GetInvitations(userID string) ([]dto.InvitationOutput, error) {
+	invitations, err := service.Repository.GetInvitations(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
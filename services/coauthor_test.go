@@ -0,0 +1,188 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/inputs"
+	"resqiar.com-server/repositories"
+)
+
+var coAuthorRepoTest = repositories.CoAuthorRepoMock{}
+var coAuthorBlogRepoTest = repositories.BlogRepoMock{}
+var coAuthorUserRepoTest = repositories.UserRepoMock{}
+var coAuthorServiceTest = CoAuthorServiceImpl{
+	Repository:     &coAuthorRepoTest,
+	BlogRepository: &coAuthorBlogRepoTest,
+	UserRepository: &coAuthorUserRepoTest,
+}
+
+func TestInviteCoAuthor(t *testing.T) {
+	userID := "example-of-user-id"
+
+	payload := &inputs.InviteCoAuthorInput{
+		BlogID:   "example-of-blog-id",
+		Username: "example-of-valid-username",
+		Role:     constants.CoAuthorEditor,
+	}
+
+	blog := &entities.Blog{
+		ID:       payload.BlogID,
+		AuthorID: userID,
+	}
+
+	t.Run("Should create a pending invitation", func(t *testing.T) {
+		firstMock := coAuthorBlogRepoTest.Mock.On("GetByIDAndOwner", payload.BlogID, userID).Return(blog, nil)
+		secondMock := coAuthorUserRepoTest.Mock.On("FindByUsername", payload.Username).Return(payload.Username)
+		thirdMock := coAuthorRepoTest.Mock.On("CreateInvitation", &entities.CoAuthor{
+			BlogID:    payload.BlogID,
+			InvitedBy: userID,
+			Role:      constants.CoAuthorEditor,
+		}).Return(true, nil)
+
+		err := coAuthorServiceTest.Invite(payload, userID)
+
+		assert.Nil(t, err)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+		})
+	})
+
+	t.Run("Should return error if the user was already invited", func(t *testing.T) {
+		firstMock := coAuthorBlogRepoTest.Mock.On("GetByIDAndOwner", payload.BlogID, userID).Return(blog, nil)
+		secondMock := coAuthorUserRepoTest.Mock.On("FindByUsername", payload.Username).Return(payload.Username)
+		thirdMock := coAuthorRepoTest.Mock.On("CreateInvitation", &entities.CoAuthor{
+			BlogID:    payload.BlogID,
+			InvitedBy: userID,
+			Role:      constants.CoAuthorEditor,
+		}).Return(false, nil)
+
+		err := coAuthorServiceTest.Invite(payload, userID)
+
+		assert.EqualError(t, err, "User was already invited")
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+		})
+	})
+
+	t.Run("Should not let co-authors invite anyone", func(t *testing.T) {
+		firstMock := coAuthorBlogRepoTest.Mock.On("GetByIDAndOwner", payload.BlogID, "example-of-editor-id").Return(nil, errors.New("Record not found"))
+
+		err := coAuthorServiceTest.Invite(payload, "example-of-editor-id")
+
+		assert.NotNil(t, err)
+		coAuthorRepoTest.Mock.AssertNotCalled(t, "CreateInvitation", &entities.CoAuthor{
+			BlogID:    payload.BlogID,
+			InvitedBy: "example-of-editor-id",
+			Role:      constants.CoAuthorEditor,
+		})
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+		})
+	})
+}
+
+func TestAcceptInvitation(t *testing.T) {
+	blogID := "example-of-blog-id"
+	userID := "example-of-user-id"
+
+	t.Run("Should accept a pending invitation", func(t *testing.T) {
+		firstMock := coAuthorRepoTest.Mock.On("AcceptInvitation", blogID, userID).Return(true, nil)
+
+		err := coAuthorServiceTest.AcceptInvitation(blogID, userID)
+
+		assert.Nil(t, err)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+		})
+	})
+
+	t.Run("Should return error without a pending invitation", func(t *testing.T) {
+		firstMock := coAuthorRepoTest.Mock.On("AcceptInvitation", blogID, userID).Return(false, nil)
+
+		err := coAuthorServiceTest.AcceptInvitation(blogID, userID)
+
+		assert.EqualError(t, err, "Record not found")
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+		})
+	})
+}
+
+func TestRemoveCoAuthor(t *testing.T) {
+	payload := &inputs.RemoveCoAuthorInput{
+		BlogID: "example-of-blog-id",
+		UserID: "example-of-editor-id",
+	}
+
+	acceptedAt := time.Now()
+
+	t.Run("Should let the author remove a co-author", func(t *testing.T) {
+		firstMock := coAuthorBlogRepoTest.Mock.On("GetByIDAndOwner", payload.BlogID, "example-of-user-id").Return(&entities.Blog{
+			ID: payload.BlogID,
+		}, nil)
+		secondMock := coAuthorRepoTest.Mock.On("DeleteCoAuthor", payload.BlogID, payload.UserID).Return(&entities.CoAuthor{
+			BlogID:     payload.BlogID,
+			UserID:     payload.UserID,
+			AcceptedAt: &acceptedAt,
+		}, nil)
+
+		err := coAuthorServiceTest.RemoveCoAuthor(payload, "example-of-user-id")
+
+		assert.Nil(t, err)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+
+	t.Run("Should let a co-author leave the blog", func(t *testing.T) {
+		firstMock := coAuthorRepoTest.Mock.On("DeleteCoAuthor", payload.BlogID, payload.UserID).Return(&entities.CoAuthor{
+			BlogID:     payload.BlogID,
+			UserID:     payload.UserID,
+			AcceptedAt: &acceptedAt,
+		}, nil)
+
+		err := coAuthorServiceTest.DeclineInvitation(payload.BlogID, payload.UserID)
+
+		assert.Nil(t, err)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+		})
+	})
+
+	t.Run("Should return error if there was nothing to remove", func(t *testing.T) {
+		firstMock := coAuthorRepoTest.Mock.On("DeleteCoAuthor", payload.BlogID, payload.UserID).Return(nil, nil)
+
+		err := coAuthorServiceTest.DeclineInvitation(payload.BlogID, payload.UserID)
+
+		assert.EqualError(t, err, "Record not found")
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+		})
+	})
+}
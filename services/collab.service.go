@@ -0,0 +1,710 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/redis/go-redis/v9"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+type CollabService interface {
+	// Join connects a new client of the user to the editing session of the blog,
+	// the session starts from the saved blog when nobody is editing it.
+	// The first message the client receives is the snapshot to start editing from.
+	Join(blogID string, userID string) (*CollabClient, error)
+
+	// PersistDueSessions saves every session whose persist delay elapsed into its blog.
+	PersistDueSessions() error
+
+	// RunPersistJob periodically persists due sessions until the context is done.
+	RunPersistJob(ctx context.Context)
+}
+
+// CollabServiceImpl keeps the sessions in Redis, so every server instance edits the same document,
+// and only tracks the clients connected to the instance itself. Instances tell each other
+// what happened to a session through Redis pub/sub.
+type CollabServiceImpl struct {
+	UtilService    UtilService
+	Repository     repositories.CollabRepository
+	BlogRepository repositories.BlogRepository
+	BlogService    BlogService
+	UserRepository repositories.UserRepository
+	CacheService   CacheService
+	PubSub         redis.UniversalClient
+
+	mu    sync.Mutex
+	rooms map[string]*collabRoom
+}
+
+// collabRoom holds the clients of a session connected to this instance.
+type collabRoom struct {
+	blogID string
+	cancel context.CancelFunc
+
+	// how many clients joined or are joining, guarded by the service
+	members int
+
+	mu       sync.Mutex
+	clients  map[string]*CollabClient
+	revision int // last revision delivered to the clients
+}
+
+// CollabClient is a connection to a session. Messages has to be drained until it gets closed,
+// either by Leave or because the client could not keep up, and Leave has to be called once the connection is gone.
+type CollabClient struct {
+	ID       string
+	Messages <-chan types.CollabMessage
+
+	service  *CollabServiceImpl
+	room     *collabRoom
+	presence types.CollabPresence
+
+	// guarded by the room
+	messages chan types.CollabMessage
+	revision int // last revision sent to the client
+	closed   bool
+}
+
+func (service *CollabServiceImpl) Join(blogID string, userID string) (*CollabClient, error) {
+	blog, err := service.BlogRepository.GetByIDAndAuthor(blogID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := service.UserRepository.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := service.Repository.OpenCollabSession(blog.ID, blog.Content, blog.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	// the blog was saved outside of the session since the session was last persisted,
+	// bring the session up to date the same way a collaborator would
+	if session.Version != blog.Version && session.Persisted == session.Revision {
+		operation, err := service.commit(blog.ID, "", session.Revision, replaceOperation(session.Content, blog.Content))
+		if err != nil {
+			return nil, err
+		}
+
+		if err := service.Repository.MarkCollabPersisted(blog.ID, operation.Revision, blog.Version); err != nil {
+			return nil, err
+		}
+	}
+
+	room, err := service.joinRoom(blog.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make(chan types.CollabMessage, constants.CollabClientBuffer)
+
+	client := &CollabClient{
+		ID:       service.UtilService.GenerateRandomID(16),
+		Messages: messages,
+		messages: messages,
+		service:  service,
+		room:     room,
+	}
+
+	client.presence = types.CollabPresence{
+		ClientID:   client.ID,
+		UserID:     user.ID,
+		Username:   user.Username,
+		PictureURL: user.PictureURL,
+	}
+
+	if err := service.connect(client); err != nil {
+		service.leaveRoom(room)
+		return nil, err
+	}
+
+	if err := service.Repository.SaveCollabPresence(blog.ID, &client.presence); err != nil {
+		log.Println("Error saving collaborator presence:", err)
+	}
+
+	service.publish(blog.ID, &types.CollabMessage{
+		Type:     constants.CollabMessageJoin,
+		ClientID: client.ID,
+		Presence: []types.CollabPresence{client.presence},
+	})
+
+	return client, nil
+}
+
+// connect sends the snapshot to the client and starts delivering operations to it.
+func (service *CollabServiceImpl) connect(client *CollabClient) error {
+	room := client.room
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	session, err := service.Repository.GetCollabSession(room.blogID)
+	if err != nil {
+		return err
+	}
+
+	content := session.Content
+	client.revision = session.Revision
+
+	// the room is ahead of the snapshot, the client would never receive what it missed
+	if client.revision < room.revision {
+		missed, err := service.Repository.GetCollabOperations(room.blogID, client.revision)
+		if err != nil {
+			return err
+		}
+
+		for _, operation := range missed {
+			if operation.Revision > room.revision {
+				break
+			}
+
+			if content, err = applyOperation(content, operation.Operation); err != nil {
+				return err
+			}
+
+			client.revision = operation.Revision
+		}
+	}
+
+	presences, err := service.Repository.GetCollabPresences(room.blogID)
+	if err != nil {
+		return err
+	}
+
+	if err := service.transformCursors(room.blogID, presences, client.revision); err != nil {
+		return err
+	}
+
+	room.clients[client.ID] = client
+
+	client.send(types.CollabMessage{
+		Type:     constants.CollabMessageSnapshot,
+		ClientID: client.ID,
+		Revision: client.revision,
+		Content:  content,
+		Presence: presences,
+	})
+
+	return nil
+}
+
+// Handle processes a message the client sent, failures are reported back to the client.
+func (client *CollabClient) Handle(message *types.CollabMessage) {
+	service := client.service
+	blogID := client.room.blogID
+
+	switch message.Type {
+	case constants.CollabMessageOperation:
+		if _, err := service.commit(blogID, client.ID, message.Revision, message.Operation); err != nil {
+			client.SendError(err)
+		}
+	case constants.CollabMessageCursor:
+		if message.Cursor == nil {
+			client.SendError(errors.New("Cursor is required"))
+			return
+		}
+
+		cursor := *message.Cursor
+		client.presence.Cursor = &cursor
+
+		if err := service.Repository.SaveCollabPresence(blogID, &client.presence); err != nil {
+			log.Println("Error saving collaborator presence:", err)
+		}
+
+		service.publish(blogID, &types.CollabMessage{
+			Type:     constants.CollabMessageCursor,
+			ClientID: client.ID,
+			Cursor:   &cursor,
+		})
+	default:
+		client.SendError(errors.New("Unknown message type"))
+	}
+}
+
+// SendError reports the error to the client, the connection stays open.
+func (client *CollabClient) SendError(err error) {
+	client.room.mu.Lock()
+	defer client.room.mu.Unlock()
+
+	client.send(types.CollabMessage{
+		Type:  constants.CollabMessageError,
+		Error: err.Error(),
+	})
+}
+
+// Leave disconnects the client from the session and closes its messages.
+func (client *CollabClient) Leave() {
+	service := client.service
+	room := client.room
+
+	room.mu.Lock()
+	delete(room.clients, client.ID)
+	client.close()
+	room.mu.Unlock()
+
+	service.leaveRoom(room)
+
+	if err := service.Repository.DeleteCollabPresence(room.blogID, client.ID); err != nil {
+		log.Println("Error deleting collaborator presence:", err)
+	}
+
+	service.publish(room.blogID, &types.CollabMessage{
+		Type:     constants.CollabMessageLeave,
+		ClientID: client.ID,
+	})
+}
+
+// send queues the message without blocking, a client too slow to keep up gets disconnected
+// since it could not apply the next operations anyway. The room must be locked.
+func (client *CollabClient) send(message types.CollabMessage) {
+	if client.closed {
+		return
+	}
+
+	select {
+	case client.messages <- message:
+	default:
+		client.close()
+	}
+}
+
+// close must be called with the room locked.
+func (client *CollabClient) close() {
+	if client.closed {
+		return
+	}
+
+	client.closed = true
+	close(client.messages)
+}
+
+// commit transforms the operation based on the given revision against every operation applied since,
+// then applies it on top of the session. It retries as long as other operations land meanwhile.
+func (service *CollabServiceImpl) commit(blogID string, clientID string, revision int, op types.TextOperation) (*types.CollabOperation, error) {
+	for {
+		session, err := service.Repository.GetCollabSession(blogID)
+		if err != nil {
+			return nil, err
+		}
+
+		if revision < 0 || revision > session.Revision {
+			return nil, errors.New("Operation is based on an unknown revision")
+		}
+
+		concurrent, err := service.Repository.GetCollabOperations(blogID, revision)
+		if err != nil {
+			return nil, err
+		}
+
+		// operations appended after the session was read are handled by the next attempt
+		if len(concurrent) < session.Revision-revision {
+			continue
+		}
+
+		transformed := op
+
+		for _, other := range concurrent[:session.Revision-revision] {
+			if transformed, _, err = transformOperations(transformed, other.Operation); err != nil {
+				return nil, err
+			}
+		}
+
+		content, err := applyOperation(session.Content, transformed)
+		if err != nil {
+			return nil, err
+		}
+
+		if utf8.RuneCountInString(content) > constants.CollabMaxContentLength {
+			return nil, errors.New("Content is too long")
+		}
+
+		operation := &types.CollabOperation{
+			Revision:  session.Revision + 1,
+			ClientID:  clientID,
+			Operation: transformed,
+		}
+
+		appended, err := service.Repository.AppendCollabOperation(blogID, operation, content, time.Now().Add(constants.CollabPersistDelay))
+		if err != nil {
+			return nil, err
+		}
+
+		if !appended {
+			continue
+		}
+
+		service.publish(blogID, &types.CollabMessage{
+			Type:      constants.CollabMessageOperation,
+			ClientID:  operation.ClientID,
+			Revision:  operation.Revision,
+			Operation: operation.Operation,
+		})
+
+		return operation, nil
+	}
+}
+
+// joinRoom returns the room of the session, listening to the session when it is the first one.
+func (service *CollabServiceImpl) joinRoom(blogID string) (*collabRoom, error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	if service.rooms == nil {
+		service.rooms = make(map[string]*collabRoom)
+	}
+
+	if room, exist := service.rooms[blogID]; exist {
+		room.members++
+		return room, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	room := &collabRoom{
+		blogID:  blogID,
+		cancel:  cancel,
+		members: 1,
+		clients: make(map[string]*CollabClient),
+	}
+
+	if service.PubSub != nil {
+		subscription := service.PubSub.Subscribe(ctx, collabChannel(blogID))
+
+		// make sure nothing published from now on is missed
+		if _, err := subscription.Receive(ctx); err != nil {
+			subscription.Close()
+			cancel()
+
+			return nil, err
+		}
+
+		go service.listen(ctx, room, subscription)
+	}
+
+	session, err := service.Repository.GetCollabSession(blogID)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	room.revision = session.Revision
+	service.rooms[blogID] = room
+
+	return room, nil
+}
+
+// leaveRoom stops listening to the session once its last client is gone.
+func (service *CollabServiceImpl) leaveRoom(room *collabRoom) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	room.members--
+
+	if room.members > 0 {
+		return
+	}
+
+	delete(service.rooms, room.blogID)
+	room.cancel()
+}
+
+func (service *CollabServiceImpl) listen(ctx context.Context, room *collabRoom, subscription *redis.PubSub) {
+	defer subscription.Close()
+
+	messages := subscription.Channel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case payload, ok := <-messages:
+			if !ok {
+				return
+			}
+
+			var message types.CollabMessage
+			if err := json.Unmarshal([]byte(payload.Payload), &message); err != nil {
+				log.Println("Error decoding collaboration message:", err)
+				continue
+			}
+
+			service.dispatch(room, &message)
+		}
+	}
+}
+
+// publish tells every instance, this one included, what happened to the session.
+func (service *CollabServiceImpl) publish(blogID string, message *types.CollabMessage) {
+	// without Redis there is no other instance to tell
+	if service.PubSub == nil {
+		service.mu.Lock()
+		room := service.rooms[blogID]
+		service.mu.Unlock()
+
+		if room != nil {
+			service.dispatch(room, message)
+		}
+
+		return
+	}
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		log.Println("Error encoding collaboration message:", err)
+		return
+	}
+
+	// operations are stored already, instances missing them catch up on the next one
+	if err := service.PubSub.Publish(context.Background(), collabChannel(blogID), payload).Err(); err != nil {
+		log.Println("Error publishing collaboration message:", err)
+	}
+}
+
+// dispatch delivers a message published about the session to the clients of the room.
+func (service *CollabServiceImpl) dispatch(room *collabRoom, message *types.CollabMessage) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	switch message.Type {
+	case constants.CollabMessageOperation:
+		// already delivered while catching up
+		if message.Revision <= room.revision {
+			return
+		}
+
+		// messages from different instances may arrive out of order, the stored operations never do
+		if message.Revision > room.revision+1 {
+			if err := service.catchUp(room); err != nil {
+				log.Println("Error catching up collaboration session:", err)
+			}
+
+			return
+		}
+
+		service.deliver(room, types.CollabOperation{
+			Revision:  message.Revision,
+			ClientID:  message.ClientID,
+			Operation: message.Operation,
+		})
+	case constants.CollabMessageCursor:
+		if message.Cursor == nil {
+			return
+		}
+
+		cursor := *message.Cursor
+
+		// clients only know the revisions delivered to them, move the cursor to the current one
+		if cursor.Revision > room.revision {
+			if err := service.catchUp(room); err != nil {
+				log.Println("Error catching up collaboration session:", err)
+			}
+		}
+
+		if cursor.Revision < room.revision {
+			presences := []types.CollabPresence{{Cursor: &cursor}}
+
+			if err := service.transformCursors(room.blogID, presences, room.revision); err != nil {
+				log.Println("Error transforming collaborator cursor:", err)
+				return
+			}
+		}
+
+		service.broadcast(room, types.CollabMessage{
+			Type:     constants.CollabMessageCursor,
+			ClientID: message.ClientID,
+			Cursor:   &cursor,
+		})
+	default:
+		service.broadcast(room, *message)
+	}
+}
+
+// catchUp delivers every stored operation the room did not deliver yet. The room must be locked.
+func (service *CollabServiceImpl) catchUp(room *collabRoom) error {
+	missed, err := service.Repository.GetCollabOperations(room.blogID, room.revision)
+	if err != nil {
+		return err
+	}
+
+	for _, operation := range missed {
+		service.deliver(room, operation)
+	}
+
+	return nil
+}
+
+// deliver sends the operation to the clients that do not have it yet,
+// its own client only gets an acknowledgement. The room must be locked.
+func (service *CollabServiceImpl) deliver(room *collabRoom, operation types.CollabOperation) {
+	for _, client := range room.clients {
+		if operation.Revision <= client.revision {
+			continue
+		}
+
+		message := types.CollabMessage{
+			Type:     constants.CollabMessageAck,
+			Revision: operation.Revision,
+		}
+
+		if operation.ClientID != client.ID {
+			message.Type = constants.CollabMessageOperation
+			message.ClientID = operation.ClientID
+			message.Operation = operation.Operation
+		}
+
+		client.send(message)
+		client.revision = operation.Revision
+	}
+
+	room.revision = operation.Revision
+}
+
+// broadcast sends the message to every client of the room but the one it comes from. The room must be locked.
+func (service *CollabServiceImpl) broadcast(room *collabRoom, message types.CollabMessage) {
+	for _, client := range room.clients {
+		if client.ID == message.ClientID {
+			continue
+		}
+
+		client.send(message)
+	}
+}
+
+// transformCursors moves the cursors of the presences along with the operations up to the given revision.
+func (service *CollabServiceImpl) transformCursors(blogID string, presences []types.CollabPresence, revision int) error {
+	oldest := revision
+
+	for _, presence := range presences {
+		if presence.Cursor != nil && presence.Cursor.Revision < oldest {
+			oldest = presence.Cursor.Revision
+		}
+	}
+
+	if oldest == revision {
+		return nil
+	}
+
+	operations, err := service.Repository.GetCollabOperations(blogID, oldest)
+	if err != nil {
+		return err
+	}
+
+	for _, presence := range presences {
+		cursor := presence.Cursor
+		if cursor == nil {
+			continue
+		}
+
+		for _, operation := range operations {
+			if operation.Revision <= cursor.Revision {
+				continue
+			}
+
+			if operation.Revision > revision {
+				break
+			}
+
+			cursor.Position = transformIndex(cursor.Position, operation.Operation)
+			cursor.SelectionEnd = transformIndex(cursor.SelectionEnd, operation.Operation)
+			cursor.Revision = operation.Revision
+		}
+	}
+
+	return nil
+}
+
+func (service *CollabServiceImpl) PersistDueSessions() error {
+	for {
+		blogIDs, err := service.Repository.ClaimDueCollabSessions(time.Now(), constants.CollabPersistBatch)
+		if err != nil {
+			return err
+		}
+
+		if len(blogIDs) == 0 {
+			return nil
+		}
+
+		for _, blogID := range blogIDs {
+			if err := service.persist(blogID); err != nil {
+				log.Println("Error persisting collaboration session:", err)
+			}
+		}
+	}
+}
+
+func (service *CollabServiceImpl) persist(blogID string) error {
+	session, err := service.Repository.GetCollabSession(blogID)
+	if err != nil {
+		return err
+	}
+
+	// nothing new since the last time
+	if session.Persisted == session.Revision {
+		return nil
+	}
+
+	version, err := service.BlogService.SaveContent(blogID, session.Version, session.Content)
+
+	// the blog was saved outside of the session while people were editing it,
+	// they are brought up to date with what was saved rather than overwriting it
+	if errors.Is(err, ErrVersionConflict) {
+		if err := service.resync(blogID, session); err != nil {
+			return err
+		}
+
+		return ErrVersionConflict
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return service.Repository.MarkCollabPersisted(blogID, session.Revision, version)
+}
+
+// resync replaces the document of the session with the stored content of the blog,
+// the replacement goes to every participant like any other operation.
+func (service *CollabServiceImpl) resync(blogID string, session *types.CollabSession) error {
+	blog, err := service.BlogRepository.GetByID(blogID)
+	if err != nil {
+		return err
+	}
+
+	operation, err := service.commit(blogID, "", session.Revision, replaceOperation(session.Content, blog.Content))
+	if err != nil {
+		return err
+	}
+
+	return service.Repository.MarkCollabPersisted(blogID, operation.Revision, blog.Version)
+}
+
+func (service *CollabServiceImpl) RunPersistJob(ctx context.Context) {
+	ticker := time.NewTicker(constants.CollabPersistInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := service.PersistDueSessions(); err != nil {
+				log.Println("Error persisting collaboration sessions:", err)
+			}
+		}
+	}
+}
+
+func collabChannel(blogID string) string {
+	return "collab:" + blogID + ":events"
+}
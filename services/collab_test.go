@@ -0,0 +1,345 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/inputs"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+var collabRepoTest = repositories.CollabRepoMock{}
+var collabBlogRepoTest = repositories.BlogRepoMock{}
+var collabUserRepoTest = repositories.UserRepoMock{}
+
+func newCollabServiceTest() *CollabServiceImpl {
+	return &CollabServiceImpl{
+		UtilService:    &utilService,
+		Repository:     &collabRepoTest,
+		BlogRepository: &collabBlogRepoTest,
+		BlogService: &BlogServiceImpl{
+			UtilService: &utilService,
+			Repository:  &collabBlogRepoTest,
+		},
+		UserRepository: &collabUserRepoTest,
+	}
+}
+
+func TestTransformOperations(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		a        types.TextOperation
+		b        types.TextOperation
+		expected string
+	}{
+		{
+			name:     "Should keep an insert before a deleted range",
+			document: "hello world",
+			a:        types.TextOperation{{Insert: "X"}, {Retain: 11}},
+			b:        types.TextOperation{{Delete: 6}, {Retain: 5}},
+			expected: "Xworld",
+		},
+		{
+			name:     "Should put the inserts of the first operation first on a tie",
+			document: "hello world",
+			a:        types.TextOperation{{Retain: 5}, {Insert: "A"}, {Retain: 6}},
+			b:        types.TextOperation{{Retain: 5}, {Insert: "B"}, {Retain: 6}},
+			expected: "helloAB world",
+		},
+		{
+			name:     "Should delete overlapping ranges only once",
+			document: "hello world",
+			a:        types.TextOperation{{Retain: 3}, {Delete: 4}, {Retain: 4}},
+			b:        types.TextOperation{{Retain: 4}, {Delete: 5}, {Retain: 2}},
+			expected: "helld",
+		},
+		{
+			name:     "Should count characters rather than bytes",
+			document: "héllo",
+			a:        types.TextOperation{{Retain: 1}, {Insert: "ü"}, {Retain: 4}},
+			b:        types.TextOperation{{Retain: 2}, {Delete: 1}, {Retain: 2}},
+			expected: "hüélo",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			primeA, primeB, err := transformOperations(test.a, test.b)
+			require.Nil(t, err)
+
+			afterA, err := applyOperation(test.document, test.a)
+			require.Nil(t, err)
+
+			afterB, err := applyOperation(test.document, test.b)
+			require.Nil(t, err)
+
+			resultA, err := applyOperation(afterA, primeB)
+			require.Nil(t, err)
+
+			resultB, err := applyOperation(afterB, primeA)
+			require.Nil(t, err)
+
+			assert.Equal(t, test.expected, resultA)
+			assert.Equal(t, test.expected, resultB)
+		})
+	}
+
+	t.Run("Should reject operations on documents of different lengths", func(t *testing.T) {
+		_, _, err := transformOperations(
+			types.TextOperation{{Retain: 3}},
+			types.TextOperation{{Retain: 4}},
+		)
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestApplyOperation(t *testing.T) {
+	t.Run("Should reject an operation not covering the whole document", func(t *testing.T) {
+		_, err := applyOperation("hello", types.TextOperation{{Retain: 3}, {Insert: "!"}})
+
+		assert.Equal(t, errOperationLength, err)
+	})
+}
+
+func TestTransformIndex(t *testing.T) {
+	op := types.TextOperation{{Insert: "ab"}, {Retain: 2}, {Delete: 3}, {Retain: 5}}
+
+	assert.Equal(t, 2, transformIndex(0, op))
+	assert.Equal(t, 4, transformIndex(2, op))
+	assert.Equal(t, 4, transformIndex(4, op), "positions inside a deleted range move to its start")
+	assert.Equal(t, 5, transformIndex(6, op))
+}
+
+func TestReplaceOperation(t *testing.T) {
+	op := replaceOperation("hello world", "hello brave world")
+
+	assert.Equal(t, types.TextOperation{{Retain: 6}, {Insert: "brave "}, {Retain: 5}}, op)
+
+	result, err := applyOperation("hello world", op)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "hello brave world", result)
+}
+
+func TestCollabSession(t *testing.T) {
+	blogID := "example-of-blog-id"
+	userID := "example-of-valid-id"
+
+	session := &types.CollabSession{
+		BlogID:   blogID,
+		Content:  "hello world!",
+		Revision: 1,
+		Version:  2,
+	}
+
+	t.Run("Should transform an operation against the ones it missed and acknowledge it", func(t *testing.T) {
+		service := newCollabServiceTest()
+
+		firstMock := collabBlogRepoTest.Mock.On("GetByIDAndAuthor", blogID, userID).Return(&entities.Blog{
+			ID:      blogID,
+			Content: "hello world",
+			Version: 2,
+		}, nil)
+		secondMock := collabUserRepoTest.Mock.On("FindByID", userID).Return("example-of-valid-id")
+		thirdMock := collabRepoTest.Mock.On("OpenCollabSession", blogID, "hello world", 2).Return(session, nil)
+		fourthMock := collabRepoTest.Mock.On("GetCollabSession", blogID).Return(session, nil)
+		fifthMock := collabRepoTest.Mock.On("GetCollabPresences", blogID).Return([]types.CollabPresence{}, nil)
+		sixthMock := collabRepoTest.Mock.On("SaveCollabPresence", blogID, mock.Anything).Return(nil)
+		seventhMock := collabRepoTest.Mock.On("GetCollabOperations", blogID, 0).Return([]types.CollabOperation{
+			{
+				Revision:  1,
+				ClientID:  "example-of-other-client",
+				Operation: types.TextOperation{{Retain: 11}, {Insert: "!"}},
+			},
+		}, nil)
+		eighthMock := collabRepoTest.Mock.On("AppendCollabOperation", blogID, mock.Anything, "Xhello world!", mock.Anything).Return(true, nil)
+		ninthMock := collabRepoTest.Mock.On("DeleteCollabPresence", blogID, mock.Anything).Return(nil)
+
+		client, err := service.Join(blogID, userID)
+		require.Nil(t, err)
+
+		snapshot := <-client.Messages
+
+		assert.Equal(t, constants.CollabMessageSnapshot, snapshot.Type)
+		assert.Equal(t, client.ID, snapshot.ClientID)
+		assert.Equal(t, 1, snapshot.Revision)
+		assert.Equal(t, "hello world!", snapshot.Content)
+
+		// based on the document before the other client's operation
+		client.Handle(&types.CollabMessage{
+			Type:      constants.CollabMessageOperation,
+			Revision:  0,
+			Operation: types.TextOperation{{Insert: "X"}, {Retain: 11}},
+		})
+
+		ack := <-client.Messages
+
+		assert.Equal(t, constants.CollabMessageAck, ack.Type)
+		assert.Equal(t, 2, ack.Revision)
+
+		collabRepoTest.Mock.AssertCalled(t, "AppendCollabOperation", blogID, &types.CollabOperation{
+			Revision:  2,
+			ClientID:  client.ID,
+			Operation: types.TextOperation{{Insert: "X"}, {Retain: 12}},
+		}, "Xhello world!", mock.Anything)
+
+		client.Leave()
+
+		_, open := <-client.Messages
+		assert.False(t, open)
+		assert.Empty(t, service.rooms)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+			fourthMock.Unset()
+			fifthMock.Unset()
+			sixthMock.Unset()
+			seventhMock.Unset()
+			eighthMock.Unset()
+			ninthMock.Unset()
+			collabRepoTest.Mock.Calls = nil
+		})
+	})
+
+	t.Run("Should not let anyone but the author join", func(t *testing.T) {
+		service := newCollabServiceTest()
+
+		firstMock := collabBlogRepoTest.Mock.On("GetByIDAndAuthor", blogID, "example-of-other-id").Return(nil, assert.AnError)
+
+		client, err := service.Join(blogID, "example-of-other-id")
+
+		assert.Nil(t, client)
+		assert.Equal(t, assert.AnError, err)
+		collabRepoTest.Mock.AssertNotCalled(t, "OpenCollabSession", blogID, mock.Anything, mock.Anything)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+		})
+	})
+}
+
+func TestPersistDueSessions(t *testing.T) {
+	blogID := "example-of-blog-id"
+
+	session := &types.CollabSession{
+		BlogID:    blogID,
+		Content:   "# Hello World",
+		Revision:  3,
+		Version:   2,
+		Persisted: 1,
+	}
+
+	rendered := utilService.RenderMD(session.Content)
+
+	safe := func(version int) *inputs.SafeUpdateBlogInput {
+		return &inputs.SafeUpdateBlogInput{
+			Content:         session.Content,
+			ContentHTML:     rendered.HTML,
+			RendererVersion: constants.RendererVersion,
+			Version:         version,
+			TOC:             rendered.TOC,
+			WordCount:       rendered.WordCount,
+			ReadingTime:     rendered.ReadingTime,
+		}
+	}
+
+	t.Run("Should persist the session through the blog service", func(t *testing.T) {
+		service := newCollabServiceTest()
+
+		// the first claim returns the due session, the following one finds nothing left
+		collabRepoTest.Mock.On("ClaimDueCollabSessions", mock.Anything, constants.CollabPersistBatch).Return([]string{blogID}, nil).Once()
+		firstMock := collabRepoTest.Mock.On("ClaimDueCollabSessions", mock.Anything, constants.CollabPersistBatch).Return([]string{}, nil)
+		secondMock := collabRepoTest.Mock.On("GetCollabSession", blogID).Return(session, nil)
+		thirdMock := collabBlogRepoTest.Mock.On("GetByID", blogID).Return(&entities.Blog{
+			ID:      blogID,
+			Content: "# Hello",
+			Version: 2,
+		}, nil)
+		fourthMock := collabBlogRepoTest.Mock.On("UpdateBlog", blogID, 2, safe(3)).Return(true, nil)
+		fifthMock := collabRepoTest.Mock.On("MarkCollabPersisted", blogID, 3, 3).Return(nil)
+
+		err := service.PersistDueSessions()
+
+		assert.Nil(t, err)
+		collabRepoTest.Mock.AssertCalled(t, "MarkCollabPersisted", blogID, 3, 3)
+
+		t.Cleanup(func() {
+			// Cleanup mocking, this also removes the first claim since they share arguments
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+			fourthMock.Unset()
+			fifthMock.Unset()
+			collabRepoTest.Mock.Calls = nil
+			collabBlogRepoTest.Mock.Calls = nil
+		})
+	})
+
+	t.Run("Should resync the session with a blog saved outside of it", func(t *testing.T) {
+		service := newCollabServiceTest()
+
+		collabRepoTest.Mock.On("ClaimDueCollabSessions", mock.Anything, constants.CollabPersistBatch).Return([]string{blogID}, nil).Once()
+		firstMock := collabRepoTest.Mock.On("ClaimDueCollabSessions", mock.Anything, constants.CollabPersistBatch).Return([]string{}, nil)
+		secondMock := collabRepoTest.Mock.On("GetCollabSession", blogID).Return(session, nil)
+		thirdMock := collabBlogRepoTest.Mock.On("GetByID", blogID).Return(&entities.Blog{
+			ID:      blogID,
+			Content: "# Hello Saved",
+			Version: 4,
+		}, nil)
+		fourthMock := collabRepoTest.Mock.On("GetCollabOperations", blogID, 3).Return([]types.CollabOperation{}, nil)
+		fifthMock := collabRepoTest.Mock.On("AppendCollabOperation", blogID, mock.Anything, "# Hello Saved", mock.Anything).Return(true, nil)
+		sixthMock := collabRepoTest.Mock.On("MarkCollabPersisted", blogID, 4, 4).Return(nil)
+
+		err := service.PersistDueSessions()
+
+		assert.Nil(t, err)
+		collabBlogRepoTest.Mock.AssertNotCalled(t, "UpdateBlog", blogID, mock.Anything, mock.Anything)
+		collabRepoTest.Mock.AssertCalled(t, "MarkCollabPersisted", blogID, 4, 4)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+			fourthMock.Unset()
+			fifthMock.Unset()
+			sixthMock.Unset()
+			collabRepoTest.Mock.Calls = nil
+			collabBlogRepoTest.Mock.Calls = nil
+		})
+	})
+
+	t.Run("Should skip sessions without unsaved edits", func(t *testing.T) {
+		service := newCollabServiceTest()
+
+		collabRepoTest.Mock.On("ClaimDueCollabSessions", mock.Anything, constants.CollabPersistBatch).Return([]string{blogID}, nil).Once()
+		firstMock := collabRepoTest.Mock.On("ClaimDueCollabSessions", mock.Anything, constants.CollabPersistBatch).Return([]string{}, nil)
+		secondMock := collabRepoTest.Mock.On("GetCollabSession", blogID).Return(&types.CollabSession{
+			BlogID:    blogID,
+			Revision:  3,
+			Persisted: 3,
+		}, nil)
+
+		err := service.PersistDueSessions()
+
+		assert.Nil(t, err)
+		collabBlogRepoTest.Mock.AssertNotCalled(t, "UpdateBlog", blogID, mock.Anything, mock.Anything)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+}
@@ -0,0 +1,561 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/dto"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+var (
+	ErrEmptyCollection = errors.New("Collection has no published blogs")
+
+	errPrivateAddress = errors.New("Address is not public")
+
+	// media types a cover may have, along with the extension of its file
+	epubCoverTypes = map[string]string{
+		"image/jpeg":    ".jpg",
+		"image/png":     ".png",
+		"image/gif":     ".gif",
+		"image/webp":    ".webp",
+		"image/svg+xml": ".svg",
+	}
+
+	epubContainer = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles>
+<rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+</rootfiles>
+</container>
+`
+
+	epubStyle = `body { font-family: serif; line-height: 1.5; }
+img { max-width: 100%; }
+pre { white-space: pre-wrap; font-size: 0.85em; }
+.cover { text-align: center; }
+`
+
+	epubPackageTemplate = texttemplate.Must(texttemplate.New("package").Parse(
+		`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="en">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="book-id">{{.Identifier}}</dc:identifier>
+<dc:title>{{html .Title}}</dc:title>
+<dc:language>en</dc:language>
+{{range .Creators}}<dc:creator>{{html .}}</dc:creator>
+{{end}}<meta property="dcterms:modified">{{.Modified}}</meta>
+{{if .Cover}}<meta name="cover" content="cover-image"/>
+{{end}}</metadata>
+<manifest>
+<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+<item id="style" href="style.css" media-type="text/css"/>
+{{if .Cover}}<item id="cover-image" href="{{.Cover.File}}" media-type="{{.Cover.MediaType}}" properties="cover-image"/>
+<item id="cover" href="cover.xhtml" media-type="application/xhtml+xml"/>
+{{end}}{{range .Chapters}}<item id="{{.ID}}" href="{{.File}}" media-type="application/xhtml+xml"/>
+{{end}}</manifest>
+<spine>
+{{if .Cover}}<itemref idref="cover"/>
+{{end}}{{range .Chapters}}<itemref idref="{{.ID}}"/>
+{{end}}</spine>
+</package>
+`))
+
+	epubNavTemplate = texttemplate.Must(texttemplate.New("nav").Parse(
+		`{{define "items"}}<ol>
+{{range .}}<li><a href="{{html .Href}}">{{html .Title}}</a>{{if .Children}}
+{{template "items" .Children}}{{end}}</li>
+{{end}}</ol>{{end}}<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="en" lang="en">
+<head>
+<title>{{html .Title}}</title>
+<link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+<nav epub:type="toc" id="toc">
+<h1>Contents</h1>
+{{template "items" .Nav}}
+</nav>
+</body>
+</html>
+`))
+
+	epubChapterTemplate = texttemplate.Must(texttemplate.New("chapter").Parse(
+		`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="en" lang="en">
+<head>
+<title>{{html .Title}}</title>
+<link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+{{if .Cover}}<div class="cover"><img src="{{.Cover.File}}" alt="{{html .Title}}"/></div>
+{{else}}<h1>{{html .Title}}</h1>
+{{if .Author}}<p>{{html .Author}}</p>
+{{end}}{{.Content}}
+{{end}}</body>
+</html>
+`))
+)
+
+type EpubService interface {
+	// GetAuthorEpub, GetTagEpub and GetSeriesEpub return an EPUB 3 book of the published blogs
+	// of the collection, it is built once and cached until one of the blogs changes.
+	GetAuthorEpub(username string) (*dto.EpubOutput, error)
+	GetTagEpub(tag string) (*dto.EpubOutput, error)
+
+	// GetSeriesEpub follows the Prev and Next links of the blog, the series starts
+	// at the first blog without a published Prev and ends at the last one without a published Next.
+	GetSeriesEpub(blogID string) (*dto.EpubOutput, error)
+}
+
+type EpubServiceImpl struct {
+	UtilService    UtilService
+	BlogRepository repositories.BlogRepository
+	CacheService   CacheService
+
+	// Client downloads the cover of the book, without it books have no cover.
+	Client *http.Client
+}
+
+// InitEpubClient returns a client downloading covers from public addresses only,
+// covers are user input and must not reach into the network of the server.
+func InitEpubClient() *http.Client {
+	return publicClient(constants.EpubCoverTimeout)
+}
+
+// publicClient only connects to public addresses, whatever the requested host resolves to.
+func publicClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+				return errPrivateAddress
+			}
+
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:       http.ProxyFromEnvironment,
+			DialContext: dialer.DialContext,
+		},
+	}
+}
+
+func (service *EpubServiceImpl) GetAuthorEpub(username string) (*dto.EpubOutput, error) {
+	var book *dto.EpubOutput
+
+	key := "epub:author:" + username
+
+	err := remember(service.CacheService, key, &book, func() (interface{}, []string, error) {
+		blogs, err := service.BlogRepository.GetPublishedCollection(username, "", constants.EpubMaxBlogs)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(blogs) == 0 {
+			return nil, nil, ErrEmptyCollection
+		}
+
+		book, err := service.buildEpub(key, exportTitle(&blogs[0].Author), blogs)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return book, append(blogsCacheTags(blogs), UsernameCacheTag(username)), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return book, nil
+}
+
+func (service *EpubServiceImpl) GetTagEpub(tag string) (*dto.EpubOutput, error) {
+	var book *dto.EpubOutput
+
+	key := "epub:tag:" + tag
+
+	err := remember(service.CacheService, key, &book, func() (interface{}, []string, error) {
+		blogs, err := service.BlogRepository.GetPublishedCollection("", tag, constants.EpubMaxBlogs)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(blogs) == 0 {
+			return nil, nil, ErrEmptyCollection
+		}
+
+		book, err := service.buildEpub(key, tag, blogs)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// any author may publish a blog with the tag
+		return book, append(blogsCacheTags(blogs), constants.CacheTagPublished), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return book, nil
+}
+
+func (service *EpubServiceImpl) GetSeriesEpub(blogID string) (*dto.EpubOutput, error) {
+	var book *dto.EpubOutput
+
+	key := "epub:series:" + blogID
+
+	err := remember(service.CacheService, key, &book, func() (interface{}, []string, error) {
+		blogs, err := service.seriesBlogs(blogID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		book, err := service.buildEpub(key, blogs[0].Title, blogs)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// linking another blog into the series edits one of its blogs
+		return book, blogsCacheTags(blogs), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return book, nil
+}
+
+// seriesBlogs walks back to the first blog of the series, then forward to its last one.
+func (service *EpubServiceImpl) seriesBlogs(blogID string) ([]entities.SafeBlogAuthor, error) {
+	get := func(ID string) (*entities.SafeBlogAuthor, error) {
+		return service.BlogRepository.GetBlog(&types.GetBlogOpts{
+			UseID:          ID,
+			IncludeContent: true,
+			Published:      true,
+		})
+	}
+
+	blog, err := get(blogID)
+	if err != nil {
+		return nil, err
+	}
+
+	series := []entities.SafeBlogAuthor{*blog}
+
+	// links may loop, every blog is only part of the series once
+	seen := map[string]bool{blog.ID: true}
+
+	// an unpublished or deleted neighbour ends the series
+	for first := blog; first.Prev != "" && !seen[first.Prev] && len(series) < constants.EpubMaxBlogs; {
+		prev, err := get(first.Prev)
+		if err != nil {
+			break
+		}
+
+		seen[prev.ID] = true
+		series = append([]entities.SafeBlogAuthor{*prev}, series...)
+		first = prev
+	}
+
+	for last := blog; last.Next != "" && !seen[last.Next] && len(series) < constants.EpubMaxBlogs; {
+		next, err := get(last.Next)
+		if err != nil {
+			break
+		}
+
+		seen[next.ID] = true
+		series = append(series, *next)
+		last = next
+	}
+
+	return series, nil
+}
+
+type epubChapter struct {
+	ID      string
+	File    string
+	Title   string
+	Author  string
+	Content string
+	Cover   *epubCover
+}
+
+type epubCover struct {
+	File      string
+	MediaType string
+	Data      []byte
+}
+
+// epubNavItem is an entry of the navigation document, chapters list their headings.
+type epubNavItem struct {
+	Title    string
+	Href     string
+	Children []epubNavItem
+}
+
+// buildEpub writes the blogs as the chapters of an EPUB 3 book, in the order given.
+func (service *EpubServiceImpl) buildEpub(key string, title string, blogs []entities.SafeBlogAuthor) (*dto.EpubOutput, error) {
+	var chapters []epubChapter
+	var nav []epubNavItem
+	var creators []string
+	var cover *epubCover
+
+	var modified time.Time
+	seen := make(map[string]bool)
+
+	for i, blog := range blogs {
+		rendered := &types.RenderedMarkdown{
+			HTML: blog.ContentHTML,
+			TOC:  blog.TOC,
+		}
+
+		// blogs saved before the current renderer are rendered again
+		if rendered.HTML == "" || blog.RendererVersion < constants.RendererVersion {
+			rendered = service.UtilService.RenderMD(blog.Content)
+		}
+
+		content, err := epubXHTML(rendered.HTML)
+		if err != nil {
+			return nil, err
+		}
+
+		author := exportTitle(&blog.Author)
+
+		chapter := epubChapter{
+			ID:      fmt.Sprintf("chapter-%d", i+1),
+			File:    fmt.Sprintf("chapter-%d.xhtml", i+1),
+			Title:   blog.Title,
+			Content: content,
+		}
+
+		if !seen[author] {
+			seen[author] = true
+			creators = append(creators, author)
+		}
+
+		if blog.UpdatedAt.After(modified) {
+			modified = blog.UpdatedAt
+		}
+
+		// the first cover which downloads is the cover of the book
+		if cover == nil && blog.CoverURL != "" {
+			cover = service.downloadCover(blog.CoverURL)
+		}
+
+		chapters = append(chapters, chapter)
+		nav = append(nav, epubNavItem{
+			Title:    blog.Title,
+			Href:     chapter.File,
+			Children: epubNavHeadings(chapter.File, rendered.TOC),
+		})
+	}
+
+	// collections of several authors credit each of them in their chapters
+	if len(creators) > 1 {
+		for i := range chapters {
+			chapters[i].Author = exportTitle(&blogs[i].Author)
+		}
+	}
+
+	var buf bytes.Buffer
+
+	if err := writeEpub(&buf, epubBook{
+		Identifier: uuid.NewSHA1(uuid.NameSpaceURL, []byte(key)).URN(),
+		Title:      title,
+		Creators:   creators,
+		Modified:   modified.UTC().Format("2006-01-02T15:04:05Z"),
+		Cover:      cover,
+		Chapters:   chapters,
+		Nav:        nav,
+	}); err != nil {
+		return nil, err
+	}
+
+	name := service.UtilService.FormatToURL(title)
+	if name == "" {
+		name = "book"
+	}
+
+	return &dto.EpubOutput{
+		FileName:  name + ".epub",
+		Data:      buf.Bytes(),
+		UpdatedAt: modified,
+	}, nil
+}
+
+type epubBook struct {
+	Identifier string
+	Title      string
+	Creators   []string
+	Modified   string
+	Cover      *epubCover
+	Chapters   []epubChapter
+	Nav        []epubNavItem
+}
+
+// epubFile is either rendered from its template or written as it is.
+type epubFile struct {
+	Name     string
+	Template *texttemplate.Template
+	Data     any
+	Content  []byte
+}
+
+func writeEpub(w io.Writer, book epubBook) error {
+	archive := zip.NewWriter(w)
+
+	// readers recognize the book by its uncompressed mimetype coming first
+	mimetype, err := archive.CreateHeader(&zip.FileHeader{
+		Name:   "mimetype",
+		Method: zip.Store,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(mimetype, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	files := []epubFile{
+		{Name: "META-INF/container.xml", Content: []byte(epubContainer)},
+		{Name: "OEBPS/content.opf", Template: epubPackageTemplate, Data: book},
+		{Name: "OEBPS/nav.xhtml", Template: epubNavTemplate, Data: book},
+		{Name: "OEBPS/style.css", Content: []byte(epubStyle)},
+	}
+
+	if book.Cover != nil {
+		files = append(files,
+			epubFile{Name: "OEBPS/cover.xhtml", Template: epubChapterTemplate, Data: epubChapter{Title: book.Title, Cover: book.Cover}},
+			epubFile{Name: "OEBPS/" + book.Cover.File, Content: book.Cover.Data},
+		)
+	}
+
+	for _, chapter := range book.Chapters {
+		files = append(files, epubFile{Name: "OEBPS/" + chapter.File, Template: epubChapterTemplate, Data: chapter})
+	}
+
+	for _, file := range files {
+		writer, err := archive.Create(file.Name)
+		if err != nil {
+			return err
+		}
+
+		if file.Template != nil {
+			err = file.Template.Execute(writer, file.Data)
+		} else {
+			_, err = writer.Write(file.Content)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// epubXHTML serializes the rendered HTML as XHTML, which is what EPUB chapters are written in.
+func epubXHTML(content string) (string, error) {
+	nodes, err := html.ParseFragment(strings.NewReader(content), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+
+	// void elements are closed and attributes quoted, as XML wants them
+	for _, node := range nodes {
+		if err := html.Render(&buf, node); err != nil {
+			return "", err
+		}
+	}
+
+	return buf.String(), nil
+}
+
+func epubNavHeadings(file string, headings types.TableOfContents) []epubNavItem {
+	var items []epubNavItem
+
+	for _, heading := range headings {
+		items = append(items, epubNavItem{
+			Title:    heading.Text,
+			Href:     file + "#" + heading.ID,
+			Children: epubNavHeadings(file, heading.Children),
+		})
+	}
+
+	return items
+}
+
+// downloadCover returns nil when the cover cannot be downloaded,
+// the book is still worth reading without it.
+func (service *EpubServiceImpl) downloadCover(coverURL string) *epubCover {
+	if service.Client == nil || !(strings.HasPrefix(coverURL, "https://") || strings.HasPrefix(coverURL, "http://")) {
+		return nil
+	}
+
+	res, err := service.Client.Get(coverURL)
+	if err != nil {
+		return nil
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if err != nil {
+		return nil
+	}
+
+	ext, exist := epubCoverTypes[mediaType]
+	if !exist {
+		return nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, constants.EpubCoverMaxSize+1))
+	if err != nil || len(data) > constants.EpubCoverMaxSize {
+		return nil
+	}
+
+	return &epubCover{
+		File:      "cover" + ext,
+		MediaType: mediaType,
+		Data:      data,
+	}
+}
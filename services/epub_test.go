@@ -0,0 +1,202 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+var epubBlogRepoTest = repositories.BlogRepoMock{}
+var epubServiceTest = EpubServiceImpl{
+	UtilService:    InitUtilService(),
+	BlogRepository: &epubBlogRepoTest,
+}
+
+func newSeriesBlog(ID string, prev string, next string) *entities.SafeBlogAuthor {
+	rendered := InitUtilService().RenderMD("## Setup\n\nHello<br>world & more\n\n![Photo](https://example.com/photo.png)")
+
+	return &entities.SafeBlogAuthor{
+		SafeBlog: entities.SafeBlog{
+			ID:              ID,
+			Title:           "Part " + ID,
+			Prev:            prev,
+			Next:            next,
+			ContentHTML:     rendered.HTML,
+			TOC:             rendered.TOC,
+			RendererVersion: constants.RendererVersion,
+		},
+		Author: entities.SafeUser{ID: "example-of-user-id", Username: "author"},
+	}
+}
+
+func seriesOpts(ID string) interface{} {
+	return mock.MatchedBy(func(opts *types.GetBlogOpts) bool {
+		return opts.UseID == ID && opts.Published && opts.IncludeContent
+	})
+}
+
+func readEpub(t *testing.T, data []byte) (*zip.Reader, map[string]string) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.Nil(t, err)
+
+	files := make(map[string]string)
+
+	for _, file := range reader.File {
+		content, err := file.Open()
+		require.Nil(t, err)
+
+		data, err := io.ReadAll(content)
+		require.Nil(t, err)
+
+		files[file.Name] = string(data)
+	}
+
+	return reader, files
+}
+
+// assertXML fails unless the document is well-formed XML.
+func assertXML(t *testing.T, name string, document string) {
+	decoder := xml.NewDecoder(strings.NewReader(document))
+
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			return
+		}
+
+		if !assert.Nil(t, err, name) {
+			return
+		}
+	}
+}
+
+func TestGetSeriesEpub(t *testing.T) {
+	t.Run("Should build the whole series in order from any of its blogs", func(t *testing.T) {
+		epubBlogRepoTest.Mock.On("GetBlog", seriesOpts("1")).Return(newSeriesBlog("1", "", "2"), nil)
+		epubBlogRepoTest.Mock.On("GetBlog", seriesOpts("2")).Return(newSeriesBlog("2", "1", "3"), nil)
+		epubBlogRepoTest.Mock.On("GetBlog", seriesOpts("3")).Return(newSeriesBlog("3", "2", "4"), nil)
+		epubBlogRepoTest.Mock.On("GetBlog", seriesOpts("4")).Return(nil, errors.New("404"))
+
+		book, err := epubServiceTest.GetSeriesEpub("2")
+		require.Nil(t, err)
+
+		assert.Equal(t, "part-1.epub", book.FileName)
+
+		reader, files := readEpub(t, book.Data)
+
+		// readers recognize the book by its first file
+		assert.Equal(t, "mimetype", reader.File[0].Name)
+		assert.Equal(t, zip.Store, reader.File[0].Method)
+		assert.Equal(t, "application/epub+zip", files["mimetype"])
+
+		for name, content := range files {
+			if strings.HasSuffix(name, ".xml") || strings.HasSuffix(name, ".opf") || strings.HasSuffix(name, ".xhtml") {
+				assertXML(t, name, content)
+			}
+		}
+
+		assert.Contains(t, files["OEBPS/chapter-1.xhtml"], "<title>Part 1</title>")
+		assert.Contains(t, files["OEBPS/chapter-3.xhtml"], "<title>Part 3</title>")
+		assert.NotContains(t, files, "OEBPS/chapter-4.xhtml")
+
+		assert.Contains(t, files["OEBPS/nav.xhtml"], `<a href="chapter-2.xhtml">Part 2</a>`)
+		assert.Contains(t, files["OEBPS/nav.xhtml"], `<a href="chapter-2.xhtml#setup">Setup</a>`)
+
+		t.Cleanup(func() {
+			// matchers never equal each other, so the calls cannot be unset
+			epubBlogRepoTest.Mock.ExpectedCalls = nil
+		})
+	})
+
+	t.Run("Should stop at blogs linking back into the series", func(t *testing.T) {
+		epubBlogRepoTest.Mock.On("GetBlog", seriesOpts("1")).Return(newSeriesBlog("1", "2", "2"), nil)
+		epubBlogRepoTest.Mock.On("GetBlog", seriesOpts("2")).Return(newSeriesBlog("2", "1", "1"), nil)
+
+		book, err := epubServiceTest.GetSeriesEpub("1")
+		require.Nil(t, err)
+
+		_, files := readEpub(t, book.Data)
+
+		assert.Contains(t, files, "OEBPS/chapter-2.xhtml")
+		assert.NotContains(t, files, "OEBPS/chapter-3.xhtml")
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			epubBlogRepoTest.Mock.ExpectedCalls = nil
+		})
+	})
+
+	t.Run("Should return error when the blog is not published", func(t *testing.T) {
+		epubBlogRepoTest.Mock.On("GetBlog", seriesOpts("example-of-draft-id")).Return(nil, errors.New("404"))
+
+		book, err := epubServiceTest.GetSeriesEpub("example-of-draft-id")
+
+		assert.Nil(t, book)
+		assert.NotNil(t, err)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			epubBlogRepoTest.Mock.ExpectedCalls = nil
+		})
+	})
+}
+
+func TestGetTagEpub(t *testing.T) {
+	t.Run("Should download the cover of the book", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("cover"))
+		}))
+		defer server.Close()
+
+		blog := newSeriesBlog("1", "", "")
+		blog.CoverURL = server.URL + "/cover.png"
+
+		mock := epubBlogRepoTest.Mock.On("GetPublishedCollection", "", "go", constants.EpubMaxBlogs).Return([]entities.SafeBlogAuthor{*blog}, nil)
+
+		service := epubServiceTest
+		service.Client = server.Client()
+
+		book, err := service.GetTagEpub("go")
+		require.Nil(t, err)
+
+		_, files := readEpub(t, book.Data)
+
+		assert.Equal(t, "cover", files["OEBPS/cover.png"])
+		assert.Contains(t, files["OEBPS/content.opf"], `properties="cover-image"`)
+		assert.Contains(t, files["OEBPS/cover.xhtml"], `<img src="cover.png" alt="go"/>`)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			mock.Unset()
+		})
+	})
+
+	t.Run("Should return error when no blog has the tag", func(t *testing.T) {
+		mock := epubBlogRepoTest.Mock.On("GetPublishedCollection", "", "nothing", constants.EpubMaxBlogs).Return([]entities.SafeBlogAuthor{}, nil)
+
+		book, err := epubServiceTest.GetTagEpub("nothing")
+
+		assert.Nil(t, book)
+		assert.ErrorIs(t, err, ErrEmptyCollection)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			mock.Unset()
+		})
+	})
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"sync"
+
+	"resqiar.com-server/types"
+)
+
+type EventHandler func(event types.Event)
+
+// EventService is an in-process publisher, services publish what happened
+// and every interested service subscribes to the event types it cares about.
+type EventService interface {
+	Publish(event types.Event)
+	Subscribe(eventType string, handler EventHandler)
+}
+
+type EventServiceImpl struct {
+	mu       sync.RWMutex
+	handlers map[string][]EventHandler
+}
+
+func InitEventService() EventService {
+	return &EventServiceImpl{
+		handlers: make(map[string][]EventHandler),
+	}
+}
+
+// Publish dispatches the event to every subscribed handler in the background,
+// publishers should never wait for nor fail because of their subscribers.
+func (service *EventServiceImpl) Publish(event types.Event) {
+	service.mu.RLock()
+	defer service.mu.RUnlock()
+
+	for _, handler := range service.handlers[event.Type] {
+		go handler(event)
+	}
+}
+
+func (service *EventServiceImpl) Subscribe(eventType string, handler EventHandler) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	service.handlers[eventType] = append(service.handlers[eventType], handler)
+}
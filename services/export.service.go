@@ -0,0 +1,486 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	htmltemplate "html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"gopkg.in/yaml.v3"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/dto"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+var (
+	siteIndexTemplate = htmltemplate.Must(htmltemplate.New("index").Parse(
+		`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="style.css">
+<link rel="alternate" type="application/rss+xml" title="{{.Title}}" href="feed.xml">
+<link rel="alternate" type="application/atom+xml" title="{{.Title}}" href="atom.xml">
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+{{if .Author.Bio}}<p>{{.Author.Bio}}</p>{{end}}
+</header>
+<main>
+{{range .Posts}}<article>
+<h2><a href="{{.Path}}/">{{.Blog.Title}}</a></h2>
+<p><time datetime="{{.Blog.PublishedAt.Format "2006-01-02"}}">{{.Blog.PublishedAt.Format "January 2, 2006"}}</time>{{if .Blog.ReadingTime}} · {{.Blog.ReadingTime}} min read{{end}}</p>
+{{if .Blog.Summary}}<p>{{.Blog.Summary}}</p>{{end}}
+</article>
+{{end}}</main>
+<footer><p><a href="feed.xml">RSS</a> · <a href="atom.xml">Atom</a></p></footer>
+</body>
+</html>
+`))
+
+	sitePostTemplate = htmltemplate.Must(htmltemplate.New("post").Parse(
+		`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Blog.Title}} · {{.Title}}</title>
+{{if .Blog.Summary}}<meta name="description" content="{{.Blog.Summary}}">
+{{end}}<link rel="stylesheet" href="../../style.css">
+</head>
+<body>
+<header><p><a href="../../">{{.Title}}</a></p></header>
+<main>
+<article>
+<h1>{{.Blog.Title}}</h1>
+<p><time datetime="{{.Blog.PublishedAt.Format "2006-01-02"}}">{{.Blog.PublishedAt.Format "January 2, 2006"}}</time>{{if .Blog.ReadingTime}} · {{.Blog.ReadingTime}} min read{{end}}</p>
+{{if .Blog.Tags}}<ul class="tags">{{range .Blog.Tags}}<li>{{.}}</li>{{end}}</ul>
+{{end}}{{if .Blog.CoverURL}}<img class="cover" src="{{.Blog.CoverURL}}" alt="">
+{{end}}{{.Content}}
+</article>
+</main>
+</body>
+</html>
+`))
+
+	siteStyle = `body { max-width: 42rem; margin: 0 auto; padding: 2rem 1rem; font: 18px/1.6 system-ui, sans-serif; color: #222; }
+a { color: #0b5cad; }
+img { max-width: 100%; height: auto; }
+pre { overflow-x: auto; padding: 1rem; background: #f5f5f5; }
+time, .tags { color: #666; font-size: 0.9em; }
+.tags { list-style: none; padding: 0; }
+.tags li { display: inline; margin-right: 0.5em; }
+.cover { display: block; margin: 1rem 0; }
+`
+)
+
+type ExportService interface {
+	// GetExport collects the blogs the user authored,
+	// they are written out once the response starts streaming.
+	GetExport(userID string) (*dto.BlogExport, error)
+
+	// WriteMarkdown writes every blog as a Markdown file with front matter into a zip archive,
+	// the archive can be imported back as it is.
+	WriteMarkdown(w io.Writer, export *dto.BlogExport) error
+
+	// WriteSite writes the published blogs as a static HTML site into a zip archive,
+	// feeds link to baseURL when given, otherwise to the blogs here.
+	WriteSite(w io.Writer, export *dto.BlogExport, baseURL string) error
+}
+
+type ExportServiceImpl struct {
+	UtilService    UtilService
+	BlogRepository repositories.BlogRepository
+	UserRepository repositories.UserRepository
+}
+
+func (service *ExportServiceImpl) GetExport(userID string) (*dto.BlogExport, error) {
+	author, err := service.UserRepository.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	blogs, err := service.BlogRepository.GetAuthorBlogs(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.BlogExport{
+		Author:     author,
+		Blogs:      blogs,
+		ExportedAt: time.Now(),
+	}, nil
+}
+
+func (service *ExportServiceImpl) WriteMarkdown(w io.Writer, export *dto.BlogExport) error {
+	archive := zip.NewWriter(w)
+	paths := exportPaths(export.Blogs)
+
+	for i := range export.Blogs {
+		blog := &export.Blogs[i]
+
+		matter := types.FrontMatter{
+			Title:    blog.Title,
+			Summary:  blog.Summary,
+			CoverURL: blog.CoverURL,
+			Tags:     blog.Tags,
+			Slug:     blog.Slug,
+			Draft:    blog.State != constants.BlogPublished,
+		}
+
+		if !blog.PublishedAt.IsZero() {
+			matter.Date = &blog.PublishedAt
+		}
+
+		header, err := yaml.Marshal(&matter)
+		if err != nil {
+			return err
+		}
+
+		file, err := createExportFile(archive, "posts/"+paths[i]+".md", blog.UpdatedAt)
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(file, "---\n"+string(header)+"---\n\n"+blog.Content); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// exportPost is a published blog as the static site shows it.
+type exportPost struct {
+	Blog    *entities.Blog
+	Path    string
+	URL     string
+	Content htmltemplate.HTML
+}
+
+// exportAsset is an image the site shows, it is still hosted where it was uploaded to.
+type exportAsset struct {
+	URL   string
+	Posts []string
+}
+
+func (service *ExportServiceImpl) WriteSite(w io.Writer, export *dto.BlogExport, baseURL string) error {
+	archive := zip.NewWriter(w)
+	paths := exportPaths(export.Blogs)
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	var posts []exportPost
+	assets := make(map[string]*exportAsset)
+
+	for i := range export.Blogs {
+		blog := &export.Blogs[i]
+
+		// the site is public, only what is published here belongs on it
+		if blog.State != constants.BlogPublished {
+			continue
+		}
+
+		// blogs saved before the current renderer are rendered again
+		content := blog.ContentHTML
+		if content == "" || blog.RendererVersion < constants.RendererVersion {
+			content = service.UtilService.RenderMD(blog.Content).HTML
+		}
+
+		post := exportPost{
+			Blog:    blog,
+			Path:    "posts/" + paths[i],
+			URL:     service.UtilService.BlogURL(export.Author.Username, blog.Slug),
+			Content: htmltemplate.HTML(content),
+		}
+
+		if baseURL != "" {
+			post.URL = baseURL + "/" + post.Path + "/"
+		}
+
+		for _, image := range exportImages(blog.CoverURL, content) {
+			if assets[image] == nil {
+				assets[image] = &exportAsset{URL: image}
+			}
+
+			assets[image].Posts = append(assets[image].Posts, post.Path)
+		}
+
+		posts = append(posts, post)
+	}
+
+	for _, post := range posts {
+		if err := writeExportTemplate(archive, post.Path+"/index.html", post.Blog.UpdatedAt, sitePostTemplate, struct {
+			Title string
+			exportPost
+		}{exportTitle(export.Author), post}); err != nil {
+			return err
+		}
+	}
+
+	if err := writeExportTemplate(archive, "index.html", export.ExportedAt, siteIndexTemplate, struct {
+		Title  string
+		Author *entities.SafeUser
+		Posts  []exportPost
+	}{exportTitle(export.Author), export.Author, posts}); err != nil {
+		return err
+	}
+
+	// the profile of the author stands in for the site until it is hosted somewhere
+	siteURL := baseURL + "/"
+	if baseURL == "" {
+		siteURL = strings.TrimSuffix(service.UtilService.BlogURL(export.Author.Username, ""), "/")
+	}
+
+	rss, err := exportRSS(export, posts, siteURL)
+	if err != nil {
+		return err
+	}
+
+	atom, err := exportAtom(export, posts, siteURL)
+	if err != nil {
+		return err
+	}
+
+	// images are referenced where they were uploaded, this tells what to copy over
+	assetList := make([]*exportAsset, 0, len(assets))
+	for _, asset := range assets {
+		assetList = append(assetList, asset)
+	}
+
+	sort.Slice(assetList, func(i, j int) bool {
+		return assetList[i].URL < assetList[j].URL
+	})
+
+	assetsJSON, err := json.MarshalIndent(assetList, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	files := []struct {
+		Name    string
+		Content []byte
+	}{
+		{"feed.xml", rss},
+		{"atom.xml", atom},
+		{"assets.json", assetsJSON},
+		{"style.css", []byte(siteStyle)},
+	}
+
+	for _, file := range files {
+		writer, err := createExportFile(archive, file.Name, export.ExportedAt)
+		if err != nil {
+			return err
+		}
+
+		if _, err := writer.Write(file.Content); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// exportPaths names the file of every blog after its slug,
+// blogs without a slug of their own or sharing one are told apart by their ID.
+func exportPaths(blogs []entities.Blog) []string {
+	paths := make([]string, len(blogs))
+	taken := make(map[string]bool)
+
+	for i, blog := range blogs {
+		name := blog.Slug
+
+		if name == "" || taken[name] {
+			name = strings.TrimPrefix(blog.Slug+"-"+blog.ID, "-")
+		}
+
+		taken[name] = true
+		paths[i] = name
+	}
+
+	return paths
+}
+
+// exportImages returns the cover of the blog along with the images its content shows.
+func exportImages(cover string, content string) []string {
+	var images []string
+
+	if cover != "" {
+		images = append(images, cover)
+	}
+
+	document, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return images
+	}
+
+	document.Find("img[src]").Each(func(_ int, image *goquery.Selection) {
+		src, _ := image.Attr("src")
+
+		for _, image := range images {
+			if image == src {
+				return
+			}
+		}
+
+		images = append(images, src)
+	})
+
+	return images
+}
+
+func createExportFile(archive *zip.Writer, name string, modified time.Time) (io.Writer, error) {
+	return archive.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+}
+
+func writeExportTemplate(archive *zip.Writer, name string, modified time.Time, tmpl *htmltemplate.Template, data any) error {
+	file, err := createExportFile(archive, name, modified)
+	if err != nil {
+		return err
+	}
+
+	return tmpl.Execute(file, data)
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        string   `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Description string   `xml:"description"`
+	Categories  []string `xml:"category"`
+}
+
+func exportRSS(export *dto.BlogExport, posts []exportPost, siteURL string) ([]byte, error) {
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         exportTitle(export.Author),
+			Link:          siteURL,
+			Description:   export.Author.Bio,
+			LastBuildDate: export.ExportedAt.Format(time.RFC1123Z),
+		},
+	}
+
+	for _, post := range posts {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       post.Blog.Title,
+			Link:        post.URL,
+			GUID:        post.URL,
+			PubDate:     post.Blog.PublishedAt.Format(time.RFC1123Z),
+			Description: string(post.Content),
+			Categories:  post.Blog.Tags,
+		})
+	}
+
+	return marshalFeed(feed)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Link    atomLink    `xml:"link"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title     string       `xml:"title"`
+	ID        string       `xml:"id"`
+	Link      atomLink     `xml:"link"`
+	Published string       `xml:"published"`
+	Updated   string       `xml:"updated"`
+	Summary   string       `xml:"summary,omitempty"`
+	Content   *atomContent `xml:"content,omitempty"`
+}
+
+func exportAtom(export *dto.BlogExport, posts []exportPost, siteURL string) ([]byte, error) {
+	feed := atomFeed{
+		Title:   exportTitle(export.Author),
+		ID:      siteURL,
+		Link:    atomLink{Href: siteURL},
+		Updated: export.ExportedAt.Format(time.RFC3339),
+		Author:  atomAuthor{Name: exportTitle(export.Author)},
+	}
+
+	for _, post := range posts {
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:     post.Blog.Title,
+			ID:        post.URL,
+			Link:      atomLink{Href: post.URL},
+			Published: post.Blog.PublishedAt.Format(time.RFC3339),
+			Updated:   post.Blog.UpdatedAt.Format(time.RFC3339),
+			Summary:   post.Blog.Summary,
+			Content:   &atomContent{Type: "html", Value: string(post.Content)},
+		})
+	}
+
+	return marshalFeed(feed)
+}
+
+// exportTitle names the site after the author.
+func exportTitle(author *entities.SafeUser) string {
+	if author.Fullname != "" {
+		return author.Fullname
+	}
+
+	return author.Username
+}
+
+func marshalFeed(feed any) ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteString(xml.Header)
+
+	encoder := xml.NewEncoder(&buf)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(feed); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
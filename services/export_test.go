@@ -0,0 +1,152 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/dto"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+var exportBlogRepoTest = repositories.BlogRepoMock{}
+var exportUserRepoTest = repositories.UserRepoMock{}
+var exportServiceTest = ExportServiceImpl{
+	UtilService:    InitUtilService(),
+	BlogRepository: &exportBlogRepoTest,
+	UserRepository: &exportUserRepoTest,
+}
+
+var exportPublishedAt = time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+func newExport() *dto.BlogExport {
+	return &dto.BlogExport{
+		Author: &entities.SafeUser{
+			ID:       "example-of-user-id",
+			Fullname: "Example Author",
+			Username: "author",
+		},
+		Blogs: []entities.Blog{
+			{
+				ID:          "example-of-blog-id",
+				Title:       "Hello World",
+				Summary:     "A short welcome",
+				Content:     "# Hello\n\n![Photo](https://example.com/photo.png)",
+				CoverURL:    "https://example.com/cover.jpg",
+				Tags:        types.Tags{"go"},
+				Slug:        "hello-world",
+				State:       constants.BlogPublished,
+				Published:   true,
+				PublishedAt: exportPublishedAt,
+			},
+			{
+				ID:      "example-of-draft-id",
+				Title:   "Unfinished",
+				Content: "Soon",
+				State:   constants.BlogDraft,
+			},
+		},
+		ExportedAt: exportPublishedAt,
+	}
+}
+
+func readArchive(t *testing.T, archive []byte) map[string]string {
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	require.Nil(t, err)
+
+	files := make(map[string]string)
+
+	for _, file := range reader.File {
+		content, err := file.Open()
+		require.Nil(t, err)
+
+		data, err := io.ReadAll(content)
+		require.Nil(t, err)
+
+		files[file.Name] = string(data)
+	}
+
+	return files
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	t.Run("Should write every blog with its front matter", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		err := exportServiceTest.WriteMarkdown(&buf, newExport())
+		require.Nil(t, err)
+
+		files := readArchive(t, buf.Bytes())
+		require.Len(t, files, 2)
+
+		matter, content, err := splitFrontMatter([]byte(files["posts/hello-world.md"]))
+		require.Nil(t, err)
+
+		assert.Equal(t, "Hello World", matter.Title)
+		assert.Equal(t, "A short welcome", matter.Summary)
+		assert.Equal(t, "https://example.com/cover.jpg", matter.CoverURL)
+		assert.Equal(t, types.Tags{"go"}, matter.Tags)
+		assert.Equal(t, "hello-world", matter.Slug)
+		assert.True(t, exportPublishedAt.Equal(*matter.Date))
+		assert.False(t, matter.Draft)
+		assert.Contains(t, string(content), "# Hello")
+
+		// blogs without a slug are named after their ID
+		matter, _, err = splitFrontMatter([]byte(files["posts/example-of-draft-id.md"]))
+		require.Nil(t, err)
+
+		assert.True(t, matter.Draft)
+		assert.Nil(t, matter.Date)
+	})
+
+	t.Run("Should be importable back", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		err := exportServiceTest.WriteMarkdown(&buf, newExport())
+		require.Nil(t, err)
+
+		reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.Nil(t, err)
+
+		assert.Len(t, importFiles(reader), 2)
+	})
+}
+
+func TestWriteSite(t *testing.T) {
+	t.Run("Should write the published blogs as a static site", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		err := exportServiceTest.WriteSite(&buf, newExport(), "https://blog.example.com/")
+		require.Nil(t, err)
+
+		files := readArchive(t, buf.Bytes())
+
+		assert.Contains(t, files, "index.html")
+		assert.Contains(t, files, "style.css")
+		assert.Contains(t, files["index.html"], `<a href="posts/hello-world/">Hello World</a>`)
+
+		// drafts are not part of the site
+		assert.NotContains(t, files, "posts/example-of-draft-id/index.html")
+		assert.NotContains(t, files["index.html"], "Unfinished")
+
+		post := files["posts/hello-world/index.html"]
+
+		assert.Contains(t, post, "<title>Hello World · Example Author</title>")
+		assert.Contains(t, post, `<img src="https://example.com/photo.png" alt="Photo">`)
+
+		assert.Contains(t, files["feed.xml"], "<link>https://blog.example.com/posts/hello-world/</link>")
+		assert.Contains(t, files["atom.xml"], `<link href="https://blog.example.com/posts/hello-world/"></link>`)
+
+		assert.JSONEq(t, `[
+			{"URL": "https://example.com/cover.jpg", "Posts": ["posts/hello-world"]},
+			{"URL": "https://example.com/photo.png", "Posts": ["posts/hello-world"]}
+		]`, files["assets.json"])
+	})
+}
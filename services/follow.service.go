@@ -0,0 +1,76 @@
+package services
+
+import (
+	"errors"
+
+	"resqiar.com-server/constants"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+type FollowService interface {
+	FollowUser(followerID string, username string) error
+	UnfollowUser(followerID string, username string) error
+	IsFollowing(followerID string, username string) (bool, error)
+}
+
+type FollowServiceImpl struct {
+	Repository     repositories.FollowRepository
+	UserRepository repositories.UserRepository
+	EventService   EventService
+	CacheService   CacheService
+}
+
+func (service *FollowServiceImpl) FollowUser(followerID string, username string) error {
+	target, err := service.UserRepository.FindByUsername(username)
+	if err != nil {
+		return err
+	}
+
+	// following yourself makes no sense for the feed
+	if target.ID == followerID {
+		return errors.New("Cannot follow yourself")
+	}
+
+	created, err := service.Repository.CreateFollow(followerID, target.ID)
+	if err != nil {
+		return err
+	}
+
+	invalidate(service.CacheService, FollowerCacheTag(followerID))
+
+	// only notify on the first follow, not on repeated requests
+	if created && service.EventService != nil {
+		service.EventService.Publish(types.Event{
+			Type:    constants.EventFollow,
+			ActorID: followerID,
+			UserID:  target.ID,
+		})
+	}
+
+	return nil
+}
+
+func (service *FollowServiceImpl) UnfollowUser(followerID string, username string) error {
+	target, err := service.UserRepository.FindByUsername(username)
+	if err != nil {
+		return err
+	}
+
+	if err := service.Repository.DeleteFollow(followerID, target.ID); err != nil {
+		return err
+	}
+
+	invalidate(service.CacheService, FollowerCacheTag(followerID))
+
+	return nil
+}
+
+func (service *FollowServiceImpl) IsFollowing(followerID string, username string) (bool, error) {
+	target, err := service.UserRepository.FindByUsername(username)
+	if err != nil {
+		return false, err
+	}
+
+	return service.Repository.IsFollowing(followerID, target.ID)
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"resqiar.com-server/repositories"
+)
+
+var followRepoTest = repositories.FollowRepoMock{}
+var followUserRepoTest = repositories.UserRepoMock{}
+var followServiceTest = FollowServiceImpl{
+	Repository:     &followRepoTest,
+	UserRepository: &followUserRepoTest,
+}
+
+func TestFollowUser(t *testing.T) {
+	t.Run("Should follow an existing user", func(t *testing.T) {
+		followerID := "example-of-follower-id"
+		username := "example-of-valid-username"
+
+		firstMock := followUserRepoTest.Mock.On("FindByUsername", username).Return(username)
+		secondMock := followRepoTest.Mock.On("CreateFollow", followerID, "").Return(true, nil)
+
+		err := followServiceTest.FollowUser(followerID, username)
+
+		assert.Nil(t, err)
+		followRepoTest.Mock.AssertCalled(t, "CreateFollow", followerID, "")
+
+		t.Cleanup(func() {
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+
+	t.Run("Should return error if the user is not found", func(t *testing.T) {
+		followerID := "example-of-follower-id"
+		username := "example-of-invalid-username"
+
+		firstMock := followUserRepoTest.Mock.On("FindByUsername", username).Return(username)
+
+		err := followServiceTest.FollowUser(followerID, username)
+
+		assert.NotNil(t, err)
+		assert.EqualError(t, err, "Record not found")
+
+		t.Cleanup(func() {
+			firstMock.Unset()
+		})
+	})
+
+	t.Run("Should return error when following yourself", func(t *testing.T) {
+		// the mocked user always has an empty ID
+		followerID := ""
+		username := "example-of-valid-username"
+
+		firstMock := followUserRepoTest.Mock.On("FindByUsername", username).Return(username)
+
+		err := followServiceTest.FollowUser(followerID, username)
+
+		assert.NotNil(t, err)
+		assert.EqualError(t, err, "Cannot follow yourself")
+
+		t.Cleanup(func() {
+			firstMock.Unset()
+		})
+	})
+
+	t.Run("Should return error if the query fails", func(t *testing.T) {
+		followerID := "example-of-other-follower-id"
+		username := "example-of-valid-username"
+
+		firstMock := followUserRepoTest.Mock.On("FindByUsername", username).Return(username)
+		secondMock := followRepoTest.Mock.On("CreateFollow", followerID, "").Return(false, errors.New("Something went wrong"))
+
+		err := followServiceTest.FollowUser(followerID, username)
+
+		assert.NotNil(t, err)
+		assert.Error(t, err)
+
+		t.Cleanup(func() {
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+}
+
+func TestUnfollowUser(t *testing.T) {
+	t.Run("Should unfollow an existing user", func(t *testing.T) {
+		followerID := "example-of-follower-id"
+		username := "example-of-valid-username"
+
+		firstMock := followUserRepoTest.Mock.On("FindByUsername", username).Return(username)
+		secondMock := followRepoTest.Mock.On("DeleteFollow", followerID, "").Return(nil)
+
+		err := followServiceTest.UnfollowUser(followerID, username)
+
+		assert.Nil(t, err)
+		followRepoTest.Mock.AssertCalled(t, "DeleteFollow", followerID, "")
+
+		t.Cleanup(func() {
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+
+	t.Run("Should return error if the user is not found", func(t *testing.T) {
+		followerID := "example-of-follower-id"
+		username := "example-of-invalid-username"
+
+		firstMock := followUserRepoTest.Mock.On("FindByUsername", username).Return(username)
+
+		err := followServiceTest.UnfollowUser(followerID, username)
+
+		assert.NotNil(t, err)
+		assert.EqualError(t, err, "Record not found")
+
+		t.Cleanup(func() {
+			firstMock.Unset()
+		})
+	})
+}
+
+func TestIsFollowing(t *testing.T) {
+	t.Run("Should return the following state", func(t *testing.T) {
+		followerID := "example-of-follower-id"
+		username := "example-of-valid-username"
+
+		firstMock := followUserRepoTest.Mock.On("FindByUsername", username).Return(username)
+		secondMock := followRepoTest.Mock.On("IsFollowing", followerID, "").Return(true, nil)
+
+		following, err := followServiceTest.IsFollowing(followerID, username)
+
+		assert.Nil(t, err)
+		assert.True(t, following)
+
+		t.Cleanup(func() {
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+}
@@ -0,0 +1,442 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/types"
+)
+
+// markdownParser only parses, walking the syntax tree is left to its users, e.g. gemtext is written out by hand
+var markdownParser = goldmark.New(goldmark.WithExtensions(extension.GFM)).Parser()
+
+type GeminiService interface {
+	// RenderGemtext converts the Markdown into gemtext by walking its syntax tree,
+	// gemtext has no inline links so they are pulled out into link lines after their block.
+	RenderGemtext(markdown string) string
+
+	GetIndexPage() (string, error)
+	GetAuthorPage(username string) (string, error)
+	GetBlogPage(username string, slug string) (string, error)
+
+	// GetFeed returns the Atom feed of the author, or of every author when none is given.
+	GetFeed(username string) ([]byte, error)
+}
+
+type GeminiServiceImpl struct {
+	BlogService BlogService
+
+	// BaseURL is where the capsule is reached, e.g. gemini://example.com
+	BaseURL string
+}
+
+func (service *GeminiServiceImpl) RenderGemtext(markdown string) string {
+	source := []byte(markdown)
+
+	writer := &gemtextWriter{source: source}
+	writer.blocks(markdownParser.Parse(text.NewReader(source)), false)
+
+	return strings.TrimRight(writer.buf.String(), "\n") + "\n"
+}
+
+func (service *GeminiServiceImpl) GetIndexPage() (string, error) {
+	blogs, err := service.BlogService.GetAllBlogs(true, constants.DESC)
+	if err != nil {
+		return "", err
+	}
+
+	var page strings.Builder
+
+	fmt.Fprintf(&page, "# %s\n\n", strings.TrimPrefix(service.BaseURL, "gemini://"))
+	fmt.Fprintf(&page, "=> %s Atom feed\n\n", service.pageURL("atom.xml"))
+	page.WriteString("## Latest blogs\n\n")
+
+	for i, blog := range blogs {
+		if i == constants.GeminiIndexLimit {
+			break
+		}
+
+		fmt.Fprintf(&page, "=> %s %s %s by %s\n", service.blogURL(&blog), blog.PublishedAt.Format("2006-01-02"), blog.Title, blog.Author.Username)
+	}
+
+	return page.String(), nil
+}
+
+func (service *GeminiServiceImpl) GetAuthorPage(username string) (string, error) {
+	blogs, err := service.BlogService.GetAllUserBlogs(username, constants.DESC)
+	if err != nil {
+		return "", err
+	}
+
+	if len(blogs) == 0 {
+		return "", ErrAuthorNotFound
+	}
+
+	author := blogs[0].Author
+
+	var page strings.Builder
+
+	fmt.Fprintf(&page, "# %s\n\n", author.Username)
+
+	if author.Bio != "" {
+		fmt.Fprintf(&page, "%s\n\n", gemtextLine(author.Bio))
+	}
+
+	fmt.Fprintf(&page, "=> %s Atom feed\n\n", service.pageURL("blog", author.Username, "atom.xml"))
+	page.WriteString("## Blogs\n\n")
+
+	for _, blog := range blogs {
+		fmt.Fprintf(&page, "=> %s %s %s\n", service.blogURL(&blog), blog.PublishedAt.Format("2006-01-02"), blog.Title)
+	}
+
+	return page.String(), nil
+}
+
+func (service *GeminiServiceImpl) GetBlogPage(username string, slug string) (string, error) {
+	blog, err := service.BlogService.GetBlogDetail(&types.BlogDetailOpts{
+		GetBlogOpts: &types.GetBlogOpts{
+			BlogAuthor:     username,
+			BlogSlug:       slug,
+			IncludeContent: true,
+			Published:      true,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var page strings.Builder
+
+	fmt.Fprintf(&page, "# %s\n\n", gemtextLine(blog.Title))
+
+	if blog.Summary != "" {
+		fmt.Fprintf(&page, "%s\n\n", gemtextLine(blog.Summary))
+	}
+
+	fmt.Fprintf(&page, "=> %s %s, %s\n\n", service.pageURL("blog", blog.Author.Username), blog.Author.Username, blog.PublishedAt.Format("January 2, 2006"))
+
+	page.WriteString(service.RenderGemtext(blog.Content))
+
+	// series are linked through Prev and Next, the capsule resolves them by ID
+	for _, link := range []struct{ ID, Text string }{{blog.Prev, "Previous"}, {blog.Next, "Next"}} {
+		if link.ID == "" {
+			continue
+		}
+
+		neighbour, err := service.BlogService.GetBlogDetail(&types.BlogDetailOpts{
+			GetBlogOpts: &types.GetBlogOpts{
+				UseID:     link.ID,
+				Published: true,
+			},
+		})
+		if err == nil {
+			fmt.Fprintf(&page, "\n=> %s %s: %s", service.blogURL(neighbour), link.Text, neighbour.Title)
+		}
+	}
+
+	return strings.TrimSpace(page.String()) + "\n", nil
+}
+
+func (service *GeminiServiceImpl) GetFeed(username string) ([]byte, error) {
+	var blogs []entities.SafeBlogAuthor
+	var err error
+
+	feedURL := service.pageURL("atom.xml")
+	title := strings.TrimPrefix(service.BaseURL, "gemini://")
+
+	if username == "" {
+		blogs, err = service.BlogService.GetAllBlogs(true, constants.DESC)
+	} else {
+		blogs, err = service.BlogService.GetAllUserBlogs(username, constants.DESC)
+		feedURL = service.pageURL("blog", username, "atom.xml")
+		title = username
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if username != "" && len(blogs) == 0 {
+		return nil, ErrAuthorNotFound
+	}
+
+	if len(blogs) > constants.GeminiIndexLimit {
+		blogs = blogs[:constants.GeminiIndexLimit]
+	}
+
+	feed := atomFeed{
+		Title:   title,
+		ID:      feedURL,
+		Link:    atomLink{Href: feedURL},
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: title},
+	}
+
+	var updated time.Time
+
+	for _, blog := range blogs {
+		if blog.UpdatedAt.After(updated) {
+			updated = blog.UpdatedAt
+			feed.Updated = updated.UTC().Format(time.RFC3339)
+		}
+
+		blogURL := service.blogURL(&blog)
+
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:     blog.Title,
+			ID:        blogURL,
+			Link:      atomLink{Href: blogURL},
+			Published: blog.PublishedAt.UTC().Format(time.RFC3339),
+			Updated:   blog.UpdatedAt.UTC().Format(time.RFC3339),
+			Summary:   blog.Summary,
+		})
+	}
+
+	return marshalFeed(feed)
+}
+
+func (service *GeminiServiceImpl) blogURL(blog *entities.SafeBlogAuthor) string {
+	return service.pageURL("blog", blog.Author.Username, blog.Slug)
+}
+
+func (service *GeminiServiceImpl) pageURL(segments ...string) string {
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return service.BaseURL + "/" + strings.Join(segments, "/")
+}
+
+// gemtextWriter writes the blocks of the syntax tree as gemtext lines,
+// links met along the way wait in links until their block is written.
+type gemtextWriter struct {
+	source []byte
+	buf    strings.Builder
+	links  []gemtextLink
+}
+
+type gemtextLink struct {
+	URL  string
+	Text string
+}
+
+func (writer *gemtextWriter) blocks(parent ast.Node, quote bool) {
+	for node := parent.FirstChild(); node != nil; node = node.NextSibling() {
+		writer.block(node, quote)
+
+		// quotes are a single block, their links follow the whole quote
+		if !quote {
+			writer.flushLinks()
+		}
+	}
+}
+
+func (writer *gemtextWriter) block(node ast.Node, quote bool) {
+	switch node := node.(type) {
+	case *ast.Heading:
+		// gemtext only has three levels of headings
+		level := node.Level
+		if level > 3 {
+			level = 3
+		}
+
+		writer.line(strings.Repeat("#", level) + " " + strings.ReplaceAll(writer.inline(node), "\n", " "))
+		writer.buf.WriteString("\n")
+
+	case *ast.Paragraph, *ast.TextBlock:
+		for _, line := range strings.Split(writer.inline(node), "\n") {
+			if quote {
+				writer.line("> " + line)
+			} else if line != "" {
+				writer.line(gemtextLine(line))
+			}
+		}
+
+		if !quote {
+			writer.buf.WriteString("\n")
+		}
+
+	case *ast.Blockquote:
+		writer.blocks(node, true)
+		writer.buf.WriteString("\n")
+
+	case *ast.List:
+		writer.list(node, quote)
+		writer.buf.WriteString("\n")
+
+	case *ast.FencedCodeBlock:
+		writer.preformatted(string(node.Language(writer.source)), node.Lines())
+
+	case *ast.CodeBlock:
+		writer.preformatted("", node.Lines())
+
+	case *east.Table:
+		var rows []string
+
+		for row := node.FirstChild(); row != nil; row = row.NextSibling() {
+			var cells []string
+
+			for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+				cells = append(cells, writer.inline(cell))
+			}
+
+			rows = append(rows, strings.Join(cells, " | "))
+		}
+
+		writer.preformatted("", nil, rows...)
+	}
+
+	// thematic breaks and raw HTML have no gemtext counterpart
+}
+
+// list flattens nested lists, gemtext lists cannot be nested.
+func (writer *gemtextWriter) list(list *ast.List, quote bool) {
+	number := list.Start
+
+	for item := list.FirstChild(); item != nil; item = item.NextSibling() {
+		for child := item.FirstChild(); child != nil; child = child.NextSibling() {
+			switch child := child.(type) {
+			case *ast.Paragraph, *ast.TextBlock:
+				line := "* " + strings.ReplaceAll(writer.inline(child), "\n", " ")
+
+				if list.IsOrdered() {
+					line = fmt.Sprintf("* %d. %s", number, strings.ReplaceAll(writer.inline(child), "\n", " "))
+				}
+
+				if quote {
+					line = "> " + line
+				}
+
+				writer.line(line)
+			case *ast.List:
+				writer.list(child, quote)
+			default:
+				writer.block(child, quote)
+			}
+		}
+
+		number++
+	}
+}
+
+// preformatted writes either the lines of a code block or the given lines as they are.
+func (writer *gemtextWriter) preformatted(alt string, lines *text.Segments, extra ...string) {
+	writer.line("```" + alt)
+
+	if lines != nil {
+		for i := 0; i < lines.Len(); i++ {
+			segment := lines.At(i)
+			extra = append(extra, strings.TrimRight(string(segment.Value(writer.source)), "\n"))
+		}
+	}
+
+	for _, line := range extra {
+		// a line toggling the preformatted mode would end the block early
+		if strings.HasPrefix(line, "```") {
+			line = " " + line
+		}
+
+		writer.line(line)
+	}
+
+	writer.line("```")
+	writer.buf.WriteString("\n")
+}
+
+// inline returns the text of the inline nodes, collecting their links.
+func (writer *gemtextWriter) inline(parent ast.Node) string {
+	var buf strings.Builder
+
+	for node := parent.FirstChild(); node != nil; node = node.NextSibling() {
+		switch node := node.(type) {
+		case *ast.Text:
+			value := node.Segment.Value(writer.source)
+
+			// backslash escapes are kept in the source, readers expect them gone
+			if !node.IsRaw() {
+				value = util.UnescapePunctuations(value)
+			}
+
+			buf.Write(value)
+
+			if node.HardLineBreak() {
+				buf.WriteString("\n")
+			} else if node.SoftLineBreak() {
+				buf.WriteString(" ")
+			}
+		case *ast.String:
+			buf.Write(node.Value)
+		case *ast.CodeSpan:
+			buf.WriteString("`" + writer.inline(node) + "`")
+		case *ast.Link:
+			text := writer.inline(node)
+
+			buf.WriteString(text)
+			writer.links = append(writer.links, gemtextLink{URL: string(node.Destination), Text: text})
+		case *ast.Image:
+			// images only appear as links, their alternative text describes them
+			alt := writer.inline(node)
+			if alt == "" {
+				alt = "Image"
+			}
+
+			writer.links = append(writer.links, gemtextLink{URL: string(node.Destination), Text: alt})
+		case *ast.AutoLink:
+			link := string(node.URL(writer.source))
+
+			buf.WriteString(link)
+			writer.links = append(writer.links, gemtextLink{URL: link, Text: link})
+		case *east.TaskCheckBox:
+			if node.IsChecked {
+				buf.WriteString("[x] ")
+			} else {
+				buf.WriteString("[ ] ")
+			}
+		case *ast.RawHTML:
+			// dropped, gemtext is plain text
+		default:
+			// emphasis and strikethrough keep their text only
+			buf.WriteString(writer.inline(node))
+		}
+	}
+
+	return buf.String()
+}
+
+func (writer *gemtextWriter) flushLinks() {
+	if len(writer.links) == 0 {
+		return
+	}
+
+	for _, link := range writer.links {
+		writer.line("=> " + link.URL + " " + strings.ReplaceAll(link.Text, "\n", " "))
+	}
+
+	writer.links = nil
+	writer.buf.WriteString("\n")
+}
+
+func (writer *gemtextWriter) line(line string) {
+	writer.buf.WriteString(strings.TrimRight(line, " ") + "\n")
+}
+
+// gemtextLine keeps text from being taken for another kind of line,
+// a leading space is ignored by readers yet turns the line into plain text.
+func gemtextLine(line string) string {
+	for _, prefix := range []string{"=>", "```", "#", "*", ">"} {
+		if strings.HasPrefix(line, prefix) {
+			return " " + line
+		}
+	}
+
+	return line
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"encoding/xml"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+var geminiBlogRepoTest = repositories.BlogRepoMock{}
+var geminiServiceTest = GeminiServiceImpl{
+	BlogService: &BlogServiceImpl{
+		UtilService: &utilService,
+		Repository:  &geminiBlogRepoTest,
+	},
+	BaseURL: "gemini://example.com",
+}
+
+func newGeminiBlog(ID string, slug string) entities.SafeBlogAuthor {
+	return entities.SafeBlogAuthor{
+		SafeBlog: entities.SafeBlog{
+			ID:              ID,
+			Title:           "Blog " + ID,
+			Slug:            slug,
+			Summary:         "Summary of " + ID,
+			PublishedAt:     time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			UpdatedAt:       time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC),
+			RendererVersion: constants.RendererVersion,
+		},
+		Author: entities.SafeUser{Username: "author", Bio: "# Writes things"},
+	}
+}
+
+func TestRenderGemtext(t *testing.T) {
+	t.Run("Should convert headings and cap their level", func(t *testing.T) {
+		result := geminiServiceTest.RenderGemtext("# Title\n\n#### Deep *heading*")
+
+		assert.Equal(t, "# Title\n\n### Deep heading\n", result)
+	})
+
+	t.Run("Should pull links out of paragraphs into link lines", func(t *testing.T) {
+		result := geminiServiceTest.RenderGemtext("Read [the docs](https://example.com/docs) and <https://go.dev> now.\n\nNext paragraph")
+
+		assert.Equal(t, "Read the docs and https://go.dev now.\n\n=> https://example.com/docs the docs\n=> https://go.dev https://go.dev\n\nNext paragraph\n", result)
+	})
+
+	t.Run("Should turn images into link lines with their alternative text", func(t *testing.T) {
+		result := geminiServiceTest.RenderGemtext("![A photo](https://example.com/photo.png)\n\n![](https://example.com/other.png)")
+
+		assert.Contains(t, result, "=> https://example.com/photo.png A photo\n")
+		assert.Contains(t, result, "=> https://example.com/other.png Image\n")
+	})
+
+	t.Run("Should flatten nested lists and number ordered ones", func(t *testing.T) {
+		result := geminiServiceTest.RenderGemtext("- one\n  - nested\n- [two](https://example.com)\n\n3. third\n4. fourth")
+
+		assert.Equal(t, "* one\n* nested\n* two\n\n=> https://example.com two\n\n* 3. third\n* 4. fourth\n", result)
+	})
+
+	t.Run("Should keep code in preformatted blocks", func(t *testing.T) {
+		result := geminiServiceTest.RenderGemtext("```go\nfunc main() {}\n\n# not a heading\n```\n\n    indented")
+
+		assert.Equal(t, "```go\nfunc main() {}\n\n# not a heading\n```\n\n```\nindented\n```\n", result)
+	})
+
+	t.Run("Should render tables as preformatted rows", func(t *testing.T) {
+		result := geminiServiceTest.RenderGemtext("| Name | Value |\n| --- | --- |\n| a | 1 |")
+
+		assert.Equal(t, "```\nName | Value\na | 1\n```\n", result)
+	})
+
+	t.Run("Should quote blockquotes and skip raw HTML", func(t *testing.T) {
+		result := geminiServiceTest.RenderGemtext("> quoted\n> text\n\n<div>html</div>\n\nafter")
+
+		assert.Equal(t, "> quoted text\n\nafter\n", result)
+	})
+
+	t.Run("Should escape text looking like gemtext lines", func(t *testing.T) {
+		result := geminiServiceTest.RenderGemtext("\\# not a heading\n\n\\=> not a link")
+
+		assert.Equal(t, " # not a heading\n\n => not a link\n", result)
+	})
+}
+
+func TestGeminiPages(t *testing.T) {
+	t.Cleanup(func() {
+		geminiBlogRepoTest.Mock.ExpectedCalls = nil
+	})
+
+	t.Run("Should list the latest blogs on the index page", func(t *testing.T) {
+		geminiBlogRepoTest.Mock.On("GetBlogs", true, true, "").Return([]entities.SafeBlogAuthor{newGeminiBlog("1", "first")}, nil).Once()
+
+		page, err := geminiServiceTest.GetIndexPage()
+
+		assert.Nil(t, err)
+		assert.Contains(t, page, "# example.com\n")
+		assert.Contains(t, page, "=> gemini://example.com/atom.xml Atom feed\n")
+		assert.Contains(t, page, "=> gemini://example.com/blog/author/first 2024-05-01 Blog 1 by author\n")
+	})
+
+	t.Run("Should list the blogs of the author and escape their bio", func(t *testing.T) {
+		geminiBlogRepoTest.Mock.On("GetBlogs", true, true, "author").Return([]entities.SafeBlogAuthor{newGeminiBlog("1", "first")}, nil).Once()
+
+		page, err := geminiServiceTest.GetAuthorPage("author")
+
+		assert.Nil(t, err)
+		assert.Contains(t, page, "# author\n\n # Writes things\n")
+		assert.Contains(t, page, "=> gemini://example.com/blog/author/atom.xml Atom feed\n")
+		assert.Contains(t, page, "=> gemini://example.com/blog/author/first 2024-05-01 Blog 1\n")
+	})
+
+	t.Run("Should return not found for an author without blogs", func(t *testing.T) {
+		geminiBlogRepoTest.Mock.On("GetBlogs", true, true, "nobody").Return([]entities.SafeBlogAuthor{}, nil).Once()
+
+		_, err := geminiServiceTest.GetAuthorPage("nobody")
+
+		assert.ErrorIs(t, err, ErrAuthorNotFound)
+	})
+
+	t.Run("Should render the blog with links to its series", func(t *testing.T) {
+		blog := newGeminiBlog("1", "first")
+		blog.Content = "Hello [world](https://example.com)"
+		blog.Next = "2"
+
+		next := newGeminiBlog("2", "second")
+
+		geminiBlogRepoTest.Mock.On("GetBlog", mock.MatchedBy(func(opts *types.GetBlogOpts) bool {
+			return opts.BlogAuthor == "author" && opts.BlogSlug == "first" && opts.Published && opts.IncludeContent
+		})).Return(&blog, nil).Once()
+		geminiBlogRepoTest.Mock.On("GetBlog", mock.MatchedBy(func(opts *types.GetBlogOpts) bool {
+			return opts.UseID == "2" && opts.Published
+		})).Return(&next, nil).Once()
+
+		page, err := geminiServiceTest.GetBlogPage("author", "first")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "# Blog 1\n\nSummary of 1\n\n=> gemini://example.com/blog/author author, May 1, 2024\n\nHello world\n\n=> https://example.com world\n\n=> gemini://example.com/blog/author/second Next: Blog 2\n", page)
+	})
+
+	t.Run("Should return the error of a missing blog", func(t *testing.T) {
+		geminiBlogRepoTest.Mock.ExpectedCalls = nil
+		geminiBlogRepoTest.Mock.On("GetBlog", mock.Anything).Return(nil, errors.New("404")).Once()
+
+		_, err := geminiServiceTest.GetBlogPage("author", "missing")
+
+		assert.EqualError(t, err, "404")
+	})
+
+	t.Run("Should return the Atom feed of the author", func(t *testing.T) {
+		geminiBlogRepoTest.Mock.On("GetBlogs", true, true, "author").Return([]entities.SafeBlogAuthor{newGeminiBlog("1", "first")}, nil).Once()
+
+		data, err := geminiServiceTest.GetFeed("author")
+		require.Nil(t, err)
+
+		var feed atomFeed
+		require.Nil(t, xml.Unmarshal(data, &feed))
+
+		assert.Equal(t, "gemini://example.com/blog/author/atom.xml", feed.ID)
+		assert.Equal(t, "2024-05-02T00:00:00Z", feed.Updated)
+		require.Len(t, feed.Entries, 1)
+		assert.Equal(t, "gemini://example.com/blog/author/first", feed.Entries[0].Link.Href)
+		assert.Nil(t, feed.Entries[0].Content)
+	})
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"resqiar.com-server/constants"
+	"resqiar.com-server/types"
+)
+
+// HTTP signatures as ActivityPub servers use them, the draft-cavage flavour signed with RSA and SHA-256.
+
+var ErrInvalidSignature = errors.New("Invalid HTTP signature")
+
+type httpSignature struct {
+	KeyID     string
+	Algorithm string
+	Headers   []string
+	Signature []byte
+}
+
+// signRequest signs the request as the owner of the key, along with the digest of the body if any.
+func signRequest(req *http.Request, keyID string, key *rsa.PrivateKey, body []byte) error {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	req.Header.Set("Host", host)
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+
+	headers := []string{"(request-target)", "host", "date"}
+
+	if body != nil {
+		req.Header.Set("Digest", bodyDigest(body))
+		headers = append(headers, "digest")
+	}
+
+	signing := signingString(req.Method, req.URL.RequestURI(), req.Header, headers)
+	hashed := sha256.Sum256([]byte(signing))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature)))
+
+	return nil
+}
+
+func parseSignature(header string) (*httpSignature, error) {
+	signature := &httpSignature{
+		Headers: []string{"date"}, // the default when headers are not listed
+	}
+
+	for _, param := range strings.Split(header, ",") {
+		name, value, found := strings.Cut(strings.TrimSpace(param), "=")
+		if !found {
+			return nil, ErrInvalidSignature
+		}
+
+		value = strings.Trim(value, `"`)
+
+		switch name {
+		case "keyId":
+			signature.KeyID = value
+		case "algorithm":
+			signature.Algorithm = value
+		case "headers":
+			signature.Headers = strings.Fields(strings.ToLower(value))
+		case "signature":
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return nil, ErrInvalidSignature
+			}
+
+			signature.Signature = decoded
+		}
+	}
+
+	if signature.KeyID == "" || signature.Signature == nil {
+		return nil, ErrInvalidSignature
+	}
+
+	// hs2019 leaves the algorithm to the key, which is always RSA here
+	if signature.Algorithm != "" && signature.Algorithm != "rsa-sha256" && signature.Algorithm != "hs2019" {
+		return nil, ErrInvalidSignature
+	}
+
+	return signature, nil
+}
+
+// verifySignature checks the signature covers the target, the host, a recent date and the body.
+func verifySignature(request *types.SignedRequest, signature *httpSignature, key *rsa.PublicKey) error {
+	covered := make(map[string]bool)
+	for _, header := range signature.Headers {
+		covered[header] = true
+	}
+
+	if !covered["(request-target)"] || !covered["host"] || !covered["date"] {
+		return ErrInvalidSignature
+	}
+
+	if len(request.Body) > 0 && (!covered["digest"] || !strings.EqualFold(request.Header.Get("Digest"), bodyDigest(request.Body))) {
+		return ErrInvalidSignature
+	}
+
+	date, err := http.ParseTime(request.Header.Get("Date"))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	if age := time.Since(date); age > constants.ActivityPubSignatureMaxAge || age < -constants.ActivityPubSignatureMaxAge {
+		return ErrInvalidSignature
+	}
+
+	signing := signingString(request.Method, request.Target, request.Header, signature.Headers)
+	hashed := sha256.Sum256([]byte(signing))
+
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], signature.Signature); err != nil {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func signingString(method string, target string, header http.Header, headers []string) string {
+	lines := make([]string, len(headers))
+
+	for i, name := range headers {
+		value := strings.Join(header.Values(name), ", ")
+
+		if name == "(request-target)" {
+			value = strings.ToLower(method) + " " + target
+		}
+
+		lines[i] = name + ": " + value
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func bodyDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func parsePublicKey(encoded string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		return nil, ErrInvalidSignature
+	}
+
+	// a few servers still publish PKCS #1 keys
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, ErrInvalidSignature
+	}
+
+	return rsaKey, nil
+}
+
+func parsePrivateKey(encoded string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		return nil, errors.New("Invalid private key")
+	}
+
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+// generateKeyPair returns a new RSA key pair encoded as PEM.
+func generateKeyPair() (string, string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, constants.ActivityPubKeyBits)
+	if err != nil {
+		return "", "", err
+	}
+
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public})
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	return string(publicPEM), string(privatePEM), nil
+}
@@ -0,0 +1,553 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/inputs"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+var (
+	ErrInvalidArchive = errors.New("Archive is not a valid zip file")
+	ErrEmptyArchive   = errors.New("Archive has no Markdown files")
+	ErrTooManyFiles   = errors.New("Archive has too many Markdown files")
+	ErrAuthorNotFound = errors.New("Author does not exist")
+
+	errPostTooLarge = errors.New("Post is too large")
+
+	// Jekyll prefixes the file names of posts with their date, e.g. 2023-03-01-hello-world.md
+	jekyllPostRegex = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-(.+)$`)
+)
+
+type ImportService interface {
+	// CreateImport queues the Markdown files of the zip archive to be imported as blogs of the user,
+	// a dry run reports what would be created without creating anything.
+	CreateImport(archive []byte, userID string, dryRun bool) (*entities.ImportJob, error)
+	GetImport(jobID string, userID string) (*entities.ImportJob, error)
+
+	// CreateWordPressImport queues the posts of the WordPress export to be imported as blogs of the author,
+	// they keep their publish state. Posts imported before are skipped, so the same export can be imported again.
+	CreateWordPressImport(export []byte, userID string, author string, dryRun bool) (*entities.ImportJob, error)
+
+	// RunWordPressImport imports the WordPress export right away instead of queueing it.
+	RunWordPressImport(export []byte, author string, dryRun bool) (*entities.ImportJob, error)
+
+	// RunPendingImports runs every queued import, it is safe to run on multiple instances.
+	RunPendingImports() error
+
+	// RunImportJob periodically runs pending imports until the context is done.
+	RunImportJob(ctx context.Context)
+}
+
+type ImportServiceImpl struct {
+	UtilService    UtilService
+	Repository     repositories.ImportRepository
+	BlogRepository repositories.BlogRepository
+	RelatedService RelatedService
+	CacheService   CacheService
+
+	// UserRepository finds the authors WordPress exports are imported for.
+	UserRepository repositories.UserRepository
+}
+
+func (service *ImportServiceImpl) CreateImport(archive []byte, userID string, dryRun bool) (*entities.ImportJob, error) {
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, ErrInvalidArchive
+	}
+
+	files := importFiles(reader)
+
+	if len(files) == 0 {
+		return nil, ErrEmptyArchive
+	}
+
+	if len(files) > constants.ImportMaxFiles {
+		return nil, ErrTooManyFiles
+	}
+
+	job := &entities.ImportJob{
+		UserID:   userID,
+		AuthorID: userID,
+		Format:   constants.ImportMarkdown,
+		DryRun:   dryRun,
+		Archive:  archive,
+		Status:   constants.ImportPending,
+		Total:    len(files),
+	}
+
+	if err := service.Repository.CreateJob(job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (service *ImportServiceImpl) CreateWordPressImport(export []byte, userID string, author string, dryRun bool) (*entities.ImportJob, error) {
+	job, err := service.wordPressJob(export, author, dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	job.UserID = userID
+	job.Status = constants.ImportPending
+
+	if err := service.Repository.CreateJob(job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (service *ImportServiceImpl) RunWordPressImport(export []byte, author string, dryRun bool) (*entities.ImportJob, error) {
+	job, err := service.wordPressJob(export, author, dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	// nobody requested it through the API, the author is the one to see it
+	now := time.Now()
+
+	job.UserID = job.AuthorID
+	job.Status = constants.ImportRunning
+	job.StartedAt = &now
+
+	if err := service.Repository.CreateJob(job); err != nil {
+		return nil, err
+	}
+
+	if err := service.runImport(job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// wordPressJob is the job importing the WordPress export as blogs of the author.
+func (service *ImportServiceImpl) wordPressJob(export []byte, author string, dryRun bool) (*entities.ImportJob, error) {
+	_, posts, err := parseWordPressExport(export)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(posts) == 0 {
+		return nil, ErrEmptyExport
+	}
+
+	if len(posts) > constants.ImportMaxFiles {
+		return nil, ErrTooManyPosts
+	}
+
+	user, err := service.UserRepository.FindByUsername(author)
+	if err != nil {
+		return nil, ErrAuthorNotFound
+	}
+
+	return &entities.ImportJob{
+		AuthorID: user.ID,
+		Format:   constants.ImportWordPress,
+		DryRun:   dryRun,
+		Archive:  export,
+		Total:    len(posts),
+	}, nil
+}
+
+func (service *ImportServiceImpl) GetImport(jobID string, userID string) (*entities.ImportJob, error) {
+	return service.Repository.GetJob(jobID, userID)
+}
+
+func (service *ImportServiceImpl) RunPendingImports() error {
+	for {
+		job, err := service.Repository.ClaimPendingJob(constants.ImportJobTimeout)
+		if err != nil {
+			return err
+		}
+
+		// nothing left to import
+		if job == nil {
+			return nil
+		}
+
+		if err := service.runImport(job); err != nil {
+			// leave the job claimed, it resumes once the claim times out
+			log.Println("Error importing archive:", err)
+		}
+	}
+}
+
+func (service *ImportServiceImpl) RunImportJob(ctx context.Context) {
+	ticker := time.NewTicker(constants.ImportJobInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := service.RunPendingImports(); err != nil {
+				log.Println("Error running imports:", err)
+			}
+		}
+	}
+}
+
+// importEntry is a post of an import, read once its turn comes.
+type importEntry struct {
+	Path string
+	Read func() (*importedPost, error)
+}
+
+// importedPost is the blog an entry turns into, along with what the report tells about it.
+// ExternalID identifies the post where it comes from, posts imported before are skipped.
+type importedPost struct {
+	Blog       *entities.Blog
+	ExternalID string
+	Images     []string
+}
+
+// runImport imports the posts the job has not processed yet, saving the progress after each of them.
+func (service *ImportServiceImpl) runImport(job *entities.ImportJob) error {
+	var entries []importEntry
+	var err error
+
+	switch job.Format {
+	case constants.ImportWordPress:
+		entries, err = service.wordPressEntries(job.Archive, job.AuthorID)
+	default:
+		entries, err = service.markdownEntries(job.Archive, job.AuthorID)
+	}
+
+	if err != nil {
+		job.Status = constants.ImportFailed
+		job.Error = err.Error()
+
+		return service.Repository.FinishJob(job)
+	}
+
+	// slugs of the posts imported before, in case the job is resumed
+	taken := make(map[string]bool)
+	for _, item := range job.Items {
+		if item.Error == "" {
+			taken[item.Slug] = true
+		}
+	}
+
+	for _, entry := range entries[job.Processed:] {
+		item := service.importEntry(entry, job, taken)
+
+		switch {
+		case item.Error != "":
+			job.Failed++
+		case item.Skipped:
+			job.Skipped++
+		case !job.DryRun:
+			job.Created++
+		}
+
+		job.Items = append(job.Items, item)
+		job.Processed++
+
+		if err := service.Repository.SaveProgress(job); err != nil {
+			return err
+		}
+	}
+
+	job.Status = constants.ImportDone
+
+	if err := service.Repository.FinishJob(job); err != nil {
+		return err
+	}
+
+	if job.Created > 0 {
+		invalidate(service.CacheService, AuthorCacheTag(job.AuthorID), constants.CacheTagPublished, constants.CacheTagDrafts)
+
+		if service.RelatedService != nil {
+			if err := service.RelatedService.RecomputeRelated(); err != nil {
+				log.Println("Error recomputing related blogs:", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// importEntry creates the blog of a single post, unless the job is a dry run.
+// Imported blogs are old news, publishing them sends no notifications nor newsletters.
+func (service *ImportServiceImpl) importEntry(entry importEntry, job *entities.ImportJob, taken map[string]bool) types.ImportItem {
+	item := types.ImportItem{
+		Path: entry.Path,
+	}
+
+	post, err := entry.Read()
+	if err != nil {
+		item.Error = err.Error()
+		return item
+	}
+
+	blog := post.Blog
+
+	item.Title = blog.Title
+	item.Slug = blog.Slug
+	item.State = blog.State
+	item.PublishedAt = blog.PublishedAt
+	item.Images = post.Images
+
+	if post.ExternalID != "" {
+		blogID, err := service.Repository.GetImportedBlog(job.Format, blog.AuthorID, post.ExternalID)
+		if err != nil {
+			item.Error = err.Error()
+			return item
+		}
+
+		if blogID != "" {
+			item.BlogID = blogID
+			item.Skipped = true
+			return item
+		}
+	}
+
+	// slugs are kept as they are for old links to keep working, a taken one fails the post
+	if taken[blog.Slug] {
+		item.Error = ErrSlugTaken.Error()
+		return item
+	}
+
+	slugExist, err := service.BlogRepository.GetCurrentUserSlugs(blog.Slug, blog.AuthorID)
+	if err != nil {
+		item.Error = err.Error()
+		return item
+	}
+
+	if len(slugExist) > 0 {
+		item.Error = ErrSlugTaken.Error()
+		return item
+	}
+
+	taken[blog.Slug] = true
+
+	if job.DryRun {
+		return item
+	}
+
+	if post.ExternalID == "" {
+		created, err := service.BlogRepository.CreateBlog(blog)
+		if err != nil {
+			item.Error = err.Error()
+			return item
+		}
+
+		item.BlogID = created.ID
+
+		return item
+	}
+
+	created, err := service.Repository.CreateImportedBlog(blog, &entities.ImportedPost{
+		Source:     job.Format,
+		AuthorID:   blog.AuthorID,
+		ExternalID: post.ExternalID,
+	})
+	if err != nil {
+		item.Error = err.Error()
+		return item
+	}
+
+	// another job imported the same post meanwhile
+	if !created {
+		item.Skipped = true
+		return item
+	}
+
+	item.BlogID = blog.ID
+
+	return item
+}
+
+// markdownEntries lists the Markdown files of the zip archive.
+func (service *ImportServiceImpl) markdownEntries(archive []byte, authorID string) ([]importEntry, error) {
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, ErrInvalidArchive
+	}
+
+	var entries []importEntry
+
+	for _, file := range importFiles(reader) {
+		file := file
+
+		entries = append(entries, importEntry{
+			Path: file.Name,
+			Read: func() (*importedPost, error) {
+				blog, err := service.readPost(file, authorID)
+				if err != nil {
+					return nil, err
+				}
+
+				return &importedPost{Blog: blog}, nil
+			},
+		})
+	}
+
+	return entries, nil
+}
+
+// readPost turns the Markdown file into a blog, its front matter is completed
+// with what static-site generators tell from the path of the file.
+func (service *ImportServiceImpl) readPost(file *zip.File, userID string) (*entities.Blog, error) {
+	if file.UncompressedSize64 > constants.ImportMaxFileSize {
+		return nil, errPostTooLarge
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	// the size in the header may lie
+	source, err := io.ReadAll(io.LimitReader(reader, constants.ImportMaxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(source) > constants.ImportMaxFileSize {
+		return nil, errPostTooLarge
+	}
+
+	matter, content, err := splitFrontMatter(source)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := inputs.CreateBlogInput{
+		Content: string(content),
+	}
+	payload.ApplyFrontMatter(matter)
+
+	dir, name := path.Split(file.Name)
+	name = strings.TrimSuffix(name, path.Ext(name))
+
+	// Hugo page bundles keep the post in an index file named after its folder
+	if name == "index" {
+		name = path.Base(dir)
+	}
+
+	if match := jekyllPostRegex.FindStringSubmatch(name); match != nil {
+		name = match[2]
+
+		if date, err := time.Parse("2006-01-02", match[1]); err == nil && payload.PublishedAt == nil {
+			payload.PublishedAt = &date
+		}
+	}
+
+	if payload.Slug == "" {
+		payload.Slug = service.UtilService.FormatToURL(strings.NewReplacer("-", " ", "_", " ").Replace(name))
+	}
+
+	if payload.Slug == "" {
+		payload.Slug = service.UtilService.FormatToURL(payload.Title)
+	}
+
+	if err := service.UtilService.ValidateInput(payload); err != "" {
+		return nil, errors.New(err)
+	}
+
+	// posts without any date were last touched when they were written
+	publishedAt := file.Modified
+	if payload.PublishedAt != nil {
+		publishedAt = *payload.PublishedAt
+	}
+
+	// published posts stay published, the review is opt-in
+	state := constants.BlogDraft
+	if importPublished(file.Name, matter) {
+		state = constants.BlogPublished
+	}
+
+	return service.importBlog(&payload, userID, state, publishedAt), nil
+}
+
+// importBlog is the blog the post is imported as, dated from when it was published.
+func (service *ImportServiceImpl) importBlog(payload *inputs.CreateBlogInput, authorID string, state string, publishedAt time.Time) *entities.Blog {
+	rendered := service.UtilService.RenderMD(payload.Content)
+
+	return &entities.Blog{
+		Title:    payload.Title,
+		Summary:  payload.Summary,
+		Content:  payload.Content,
+		CoverURL: payload.CoverURL,
+		Tags:     payload.Tags,
+		Slug:     payload.Slug,
+		AuthorID: authorID,
+
+		State:     state,
+		Published: state == constants.BlogPublished,
+
+		CreatedAt:   publishedAt,
+		PublishedAt: publishedAt,
+
+		ContentHTML:     rendered.HTML,
+		RendererVersion: constants.RendererVersion,
+		TOC:             rendered.TOC,
+		WordCount:       rendered.WordCount,
+		ReadingTime:     rendered.ReadingTime,
+	}
+}
+
+// importFiles returns the posts of the archive in a stable order,
+// a resumed job skips as many of them as it processed before.
+func importFiles(reader *zip.Reader) []*zip.File {
+	var files []*zip.File
+
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		ext := strings.ToLower(path.Ext(file.Name))
+		if ext != ".md" && ext != ".markdown" {
+			continue
+		}
+
+		// Hugo list pages, hidden files and macOS metadata are not posts
+		name := path.Base(file.Name)
+		if strings.HasPrefix(name, "_index.") || strings.HasPrefix(name, ".") || strings.HasPrefix(file.Name, "__MACOSX/") {
+			continue
+		}
+
+		files = append(files, file)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+
+	return files
+}
+
+// importPublished reports whether the post was published on the site it comes from.
+func importPublished(filePath string, matter *types.FrontMatter) bool {
+	for _, dir := range strings.Split(path.Dir(filePath), "/") {
+		if dir == "_drafts" {
+			return false
+		}
+	}
+
+	if matter == nil {
+		return true
+	}
+
+	return !matter.Draft && (matter.Published == nil || *matter.Published)
+}
@@ -0,0 +1,484 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+var importRepoTest = repositories.ImportRepoMock{}
+var importBlogRepoTest = repositories.BlogRepoMock{}
+var importUserRepoTest = repositories.UserRepoMock{}
+var importServiceTest = ImportServiceImpl{
+	UtilService:    InitUtilService(),
+	Repository:     &importRepoTest,
+	BlogRepository: &importBlogRepoTest,
+	UserRepository: &importUserRepoTest,
+}
+
+var importModified = time.Date(2024, time.January, 2, 0, 0, 0, 0, time.UTC)
+
+func newArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+
+	writer := zip.NewWriter(&buf)
+
+	for name, content := range files {
+		file, err := writer.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: importModified,
+		})
+		require.Nil(t, err)
+
+		_, err = file.Write([]byte(content))
+		require.Nil(t, err)
+	}
+
+	require.Nil(t, writer.Close())
+
+	return buf.Bytes()
+}
+
+var hugoArchive = map[string]string{
+	"content/posts/_index.md":                 "---\ntitle: Posts\n---",
+	"content/posts/hello-world/index.md":      "---\ntitle: Hello World\ndate: 2023-03-01\ntags: [go]\n---\n# Hello World",
+	"content/posts/hello-world/cover.jpg":     "not a post",
+	"content/posts/work-in-progress.md":       "+++\ntitle = \"Work in Progress\"\ndraft = true\n+++\nSoon",
+	"_posts/2022-05-06-jekyll-post.markdown":  "---\ntitle: Jekyll Post\n---\nHello",
+	"_drafts/unpublished.md":                  "---\ntitle: Unpublished\n---\nHello",
+	"content/posts/custom.md":                 "---\ntitle: Custom\nslug: my-custom-slug\n---\nHello",
+	"content/posts/no-title.md":               "Hello",
+	"__MACOSX/content/posts/._hello-world.md": "metadata",
+}
+
+func TestCreateImport(t *testing.T) {
+	userID := "example-of-user-id"
+
+	t.Run("Should queue the archive with its number of posts", func(t *testing.T) {
+		archive := newArchive(t, hugoArchive)
+
+		firstMock := importRepoTest.Mock.On("CreateJob", &entities.ImportJob{
+			UserID:   userID,
+			AuthorID: userID,
+			Format:   constants.ImportMarkdown,
+			DryRun:   true,
+			Archive:  archive,
+			Status:   constants.ImportPending,
+			Total:    6,
+		}).Return(nil)
+
+		job, err := importServiceTest.CreateImport(archive, userID, true)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 6, job.Total)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+		})
+	})
+
+	t.Run("Should return error when the archive is not a zip file", func(t *testing.T) {
+		job, err := importServiceTest.CreateImport([]byte("not a zip file"), userID, false)
+
+		assert.Nil(t, job)
+		assert.ErrorIs(t, err, ErrInvalidArchive)
+	})
+
+	t.Run("Should return error when the archive has no posts", func(t *testing.T) {
+		job, err := importServiceTest.CreateImport(newArchive(t, map[string]string{"cover.jpg": "not a post"}), userID, false)
+
+		assert.Nil(t, job)
+		assert.ErrorIs(t, err, ErrEmptyArchive)
+	})
+}
+
+func TestRunPendingImports(t *testing.T) {
+	userID := "example-of-user-id"
+
+	t.Run("Should report what would be created without creating anything on a dry run", func(t *testing.T) {
+		job := &entities.ImportJob{
+			ID:       "example-of-job-id",
+			UserID:   userID,
+			AuthorID: userID,
+			DryRun:   true,
+			Archive:  newArchive(t, hugoArchive),
+			Status:   constants.ImportRunning,
+			Total:    6,
+		}
+
+		importRepoTest.Mock.On("ClaimPendingJob", constants.ImportJobTimeout).Return(job, nil).Once()
+		firstMock := importRepoTest.Mock.On("ClaimPendingJob", constants.ImportJobTimeout).Return(nil, nil)
+		secondMock := importRepoTest.Mock.On("SaveProgress", job).Return(nil)
+		thirdMock := importRepoTest.Mock.On("FinishJob", job).Return(nil)
+		fourthMock := importBlogRepoTest.Mock.On("GetCurrentUserSlugs", mock.Anything, userID).Return([]entities.Blog{}, nil)
+
+		err := importServiceTest.RunPendingImports()
+
+		require.Nil(t, err)
+		require.Len(t, job.Items, 6)
+
+		assert.Equal(t, constants.ImportDone, job.Status)
+		assert.Equal(t, 6, job.Processed)
+		assert.Equal(t, 0, job.Created)
+		assert.Equal(t, 1, job.Failed)
+
+		// sorted by path
+		items := job.Items
+
+		assert.Equal(t, "_drafts/unpublished.md", items[0].Path)
+		assert.Equal(t, "unpublished", items[0].Slug)
+		assert.Equal(t, constants.BlogDraft, items[0].State)
+		assert.True(t, importModified.Equal(items[0].PublishedAt))
+
+		assert.Equal(t, "jekyll-post", items[1].Slug)
+		assert.Equal(t, constants.BlogPublished, items[1].State)
+		assert.Equal(t, time.Date(2022, time.May, 6, 0, 0, 0, 0, time.UTC), items[1].PublishedAt)
+
+		assert.Equal(t, "my-custom-slug", items[2].Slug)
+
+		assert.Equal(t, "Hello World", items[3].Title)
+		assert.Equal(t, "hello-world", items[3].Slug)
+		assert.Equal(t, time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC), items[3].PublishedAt)
+
+		assert.Equal(t, "content/posts/no-title.md", items[4].Path)
+		assert.Equal(t, "Title field is required", items[4].Error)
+
+		assert.Equal(t, "work-in-progress", items[5].Slug)
+		assert.Equal(t, constants.BlogDraft, items[5].State)
+
+		importBlogRepoTest.Mock.AssertNotCalled(t, "CreateBlog", mock.Anything)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+			fourthMock.Unset()
+			importBlogRepoTest.Mock.Calls = nil
+		})
+	})
+
+	t.Run("Should create the blogs and keep the published ones published", func(t *testing.T) {
+		job := &entities.ImportJob{
+			ID:       "example-of-job-id",
+			UserID:   userID,
+			AuthorID: userID,
+			Archive: newArchive(t, map[string]string{
+				"posts/hello-world.md": "---\ntitle: Hello World\ndate: 2023-03-01\n---\n# Hello World",
+				"posts/taken.md":       "---\ntitle: Taken\n---\nHello",
+			}),
+			Status: constants.ImportRunning,
+			Total:  2,
+		}
+
+		publishedAt := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+		importRepoTest.Mock.On("ClaimPendingJob", constants.ImportJobTimeout).Return(job, nil).Once()
+		firstMock := importRepoTest.Mock.On("ClaimPendingJob", constants.ImportJobTimeout).Return(nil, nil)
+		secondMock := importRepoTest.Mock.On("SaveProgress", job).Return(nil)
+		thirdMock := importRepoTest.Mock.On("FinishJob", job).Return(nil)
+		fourthMock := importBlogRepoTest.Mock.On("GetCurrentUserSlugs", "hello-world", userID).Return([]entities.Blog{}, nil)
+		fifthMock := importBlogRepoTest.Mock.On("GetCurrentUserSlugs", "taken", userID).Return([]entities.Blog{{ID: "example-of-other-id"}}, nil)
+		importBlogRepoTest.Mock.On("CreateBlog", mock.MatchedBy(func(blog *entities.Blog) bool {
+			return blog.Slug == "hello-world" &&
+				blog.Published &&
+				blog.State == constants.BlogPublished &&
+				blog.CreatedAt.Equal(publishedAt) &&
+				blog.PublishedAt.Equal(publishedAt) &&
+				blog.ContentHTML != ""
+		})).Return(&entities.Blog{ID: "example-of-blog-id"}, nil)
+
+		err := importServiceTest.RunPendingImports()
+
+		require.Nil(t, err)
+		require.Len(t, job.Items, 2)
+
+		assert.Equal(t, constants.ImportDone, job.Status)
+		assert.Equal(t, 1, job.Created)
+		assert.Equal(t, 1, job.Failed)
+		assert.Equal(t, "example-of-blog-id", job.Items[0].BlogID)
+		assert.Equal(t, ErrSlugTaken.Error(), job.Items[1].Error)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+			fourthMock.Unset()
+			fifthMock.Unset()
+
+			// matchers never equal each other, so the call cannot be unset
+			importBlogRepoTest.Mock.ExpectedCalls = nil
+		})
+	})
+
+	t.Run("Should resume a job from the posts it processed before", func(t *testing.T) {
+		job := &entities.ImportJob{
+			ID:       "example-of-job-id",
+			UserID:   userID,
+			AuthorID: userID,
+			DryRun:   true,
+			Archive: newArchive(t, map[string]string{
+				"posts/first.md":  "---\ntitle: First\nslug: same\n---\nHello",
+				"posts/second.md": "---\ntitle: Second\nslug: same\n---\nHello",
+			}),
+			Status:    constants.ImportRunning,
+			Total:     2,
+			Processed: 1,
+			Items: []types.ImportItem{
+				{Path: "posts/first.md", Slug: "same"},
+			},
+		}
+
+		importRepoTest.Mock.On("ClaimPendingJob", constants.ImportJobTimeout).Return(job, nil).Once()
+		firstMock := importRepoTest.Mock.On("ClaimPendingJob", constants.ImportJobTimeout).Return(nil, nil)
+		secondMock := importRepoTest.Mock.On("SaveProgress", job).Return(nil)
+		thirdMock := importRepoTest.Mock.On("FinishJob", job).Return(nil)
+
+		err := importServiceTest.RunPendingImports()
+
+		require.Nil(t, err)
+		require.Len(t, job.Items, 2)
+		assert.Equal(t, "posts/second.md", job.Items[1].Path)
+		assert.Equal(t, ErrSlugTaken.Error(), job.Items[1].Error)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+		})
+	})
+}
+
+var wordPressExport = []byte(`<?xml version="1.0" encoding="UTF-8" ?>
+<rss version="2.0"
+	xmlns:excerpt="http://wordpress.org/export/1.2/excerpt/"
+	xmlns:content="http://purl.org/rss/1.0/modules/content/"
+	xmlns:wp="http://wordpress.org/export/1.2/">
+<channel>
+	<title>Example</title>
+	<link>https://example.com</link>
+	<wp:base_blog_url>https://example.com</wp:base_blog_url>
+	<item>
+		<title>Cover</title>
+		<wp:post_id>10</wp:post_id>
+		<wp:post_type>attachment</wp:post_type>
+		<wp:status>inherit</wp:status>
+		<wp:attachment_url>https://example.com/wp-content/uploads/cover.jpg</wp:attachment_url>
+	</item>
+	<item>
+		<title>Hello &amp; Welcome</title>
+		<link>https://example.com/2023/03/01/hello-world/</link>
+		<guid isPermaLink="false">https://example.com/?p=1</guid>
+		<content:encoded><![CDATA[Hello <strong>world</strong>
+
+<img src="/wp-content/uploads/photo.png" alt="Photo" />]]></content:encoded>
+		<excerpt:encoded><![CDATA[<p>A <em>short</em> welcome</p>]]></excerpt:encoded>
+		<wp:post_id>1</wp:post_id>
+		<wp:post_date>2023-03-01 09:00:00</wp:post_date>
+		<wp:post_date_gmt>2023-03-01 02:00:00</wp:post_date_gmt>
+		<wp:post_name>hello-world</wp:post_name>
+		<wp:status>publish</wp:status>
+		<wp:post_type>post</wp:post_type>
+		<category domain="category" nicename="uncategorized"><![CDATA[Uncategorized]]></category>
+		<category domain="category" nicename="go"><![CDATA[Go]]></category>
+		<category domain="post_tag" nicename="go"><![CDATA[go]]></category>
+		<category domain="post_tag" nicename="web"><![CDATA[Web]]></category>
+		<wp:postmeta>
+			<wp:meta_key>_thumbnail_id</wp:meta_key>
+			<wp:meta_value>10</wp:meta_value>
+		</wp:postmeta>
+	</item>
+	<item>
+		<title>Unfinished</title>
+		<guid isPermaLink="false">https://example.com/?p=2</guid>
+		<content:encoded><![CDATA[<p>Soon</p>]]></content:encoded>
+		<wp:post_id>2</wp:post_id>
+		<wp:post_date>0000-00-00 00:00:00</wp:post_date>
+		<wp:post_date_gmt>0000-00-00 00:00:00</wp:post_date_gmt>
+		<wp:post_name></wp:post_name>
+		<wp:status>draft</wp:status>
+		<wp:post_type>post</wp:post_type>
+	</item>
+	<item>
+		<title>About</title>
+		<wp:post_id>3</wp:post_id>
+		<wp:status>publish</wp:status>
+		<wp:post_type>page</wp:post_type>
+	</item>
+	<item>
+		<title>Deleted</title>
+		<wp:post_id>4</wp:post_id>
+		<wp:status>trash</wp:status>
+		<wp:post_type>post</wp:post_type>
+	</item>
+</channel>
+</rss>`)
+
+func TestCreateWordPressImport(t *testing.T) {
+	userID := "example-of-user-id"
+	authorID := "example-of-author-id"
+
+	t.Run("Should queue the posts of the export for the author", func(t *testing.T) {
+		firstMock := importUserRepoTest.Mock.On("FindByUsername", "author").Return(&entities.SafeUser{ID: authorID}, nil)
+		secondMock := importRepoTest.Mock.On("CreateJob", &entities.ImportJob{
+			UserID:   userID,
+			AuthorID: authorID,
+			Format:   constants.ImportWordPress,
+			Archive:  wordPressExport,
+			Status:   constants.ImportPending,
+			Total:    2,
+		}).Return(nil)
+
+		job, err := importServiceTest.CreateWordPressImport(wordPressExport, userID, "author", false)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, job.Total)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+
+	t.Run("Should return error when the author does not exist", func(t *testing.T) {
+		mock := importUserRepoTest.Mock.On("FindByUsername", "nobody").Return(nil, errors.New("Record not found"))
+
+		job, err := importServiceTest.CreateWordPressImport(wordPressExport, userID, "nobody", false)
+
+		assert.Nil(t, job)
+		assert.ErrorIs(t, err, ErrAuthorNotFound)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			mock.Unset()
+		})
+	})
+
+	t.Run("Should return error when the file is not a WordPress export", func(t *testing.T) {
+		job, err := importServiceTest.CreateWordPressImport([]byte("not an export"), userID, "author", false)
+
+		assert.Nil(t, job)
+		assert.ErrorIs(t, err, ErrInvalidExport)
+	})
+}
+
+func TestRunWordPressImport(t *testing.T) {
+	authorID := "example-of-author-id"
+
+	t.Run("Should convert the posts keeping their dates, slugs and categories", func(t *testing.T) {
+		firstMock := importUserRepoTest.Mock.On("FindByUsername", "author").Return(&entities.SafeUser{ID: authorID}, nil)
+		secondMock := importRepoTest.Mock.On("CreateJob", mock.Anything).Return(nil)
+		thirdMock := importRepoTest.Mock.On("SaveProgress", mock.Anything).Return(nil)
+		fourthMock := importRepoTest.Mock.On("FinishJob", mock.Anything).Return(nil)
+		fifthMock := importRepoTest.Mock.On("GetImportedBlog", constants.ImportWordPress, authorID, "https://example.com/?p=1").Return("", nil)
+		sixthMock := importRepoTest.Mock.On("GetImportedBlog", constants.ImportWordPress, authorID, "https://example.com/?p=2").Return("", nil)
+		seventhMock := importBlogRepoTest.Mock.On("GetCurrentUserSlugs", mock.Anything, authorID).Return([]entities.Blog{}, nil)
+
+		var created []*entities.Blog
+		var imported []*entities.ImportedPost
+		importRepoTest.Mock.On("CreateImportedBlog", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			blog := args.Get(0).(*entities.Blog)
+			blog.ID = "example-of-blog-id"
+
+			created = append(created, blog)
+			imported = append(imported, args.Get(1).(*entities.ImportedPost))
+		}).Return(true, nil)
+
+		job, err := importServiceTest.RunWordPressImport(wordPressExport, "author", false)
+
+		require.Nil(t, err)
+		require.Len(t, job.Items, 2)
+		require.Len(t, created, 2)
+
+		assert.Equal(t, constants.ImportDone, job.Status)
+		assert.Equal(t, 2, job.Created)
+
+		blog := created[0]
+
+		assert.Equal(t, "Hello & Welcome", blog.Title)
+		assert.Equal(t, "A short welcome", blog.Summary)
+		assert.Equal(t, "hello-world", blog.Slug)
+		assert.Equal(t, authorID, blog.AuthorID)
+		assert.Equal(t, constants.BlogPublished, blog.State)
+		assert.True(t, blog.Published)
+		assert.Equal(t, time.Date(2023, time.March, 1, 2, 0, 0, 0, time.UTC), blog.PublishedAt)
+		assert.Equal(t, types.Tags{"Go", "Web"}, blog.Tags)
+		assert.Equal(t, "https://example.com/wp-content/uploads/cover.jpg", blog.CoverURL)
+		assert.Contains(t, blog.Content, "Hello **world**")
+		assert.Contains(t, blog.Content, "![Photo](https://example.com/wp-content/uploads/photo.png)")
+
+		// the same post imported by another author is a post of its own
+		assert.Equal(t, &entities.ImportedPost{
+			Source:     constants.ImportWordPress,
+			AuthorID:   authorID,
+			ExternalID: "https://example.com/?p=1",
+		}, imported[0])
+
+		assert.Equal(t, "example-of-blog-id", job.Items[0].BlogID)
+		assert.Equal(t, []string{
+			"https://example.com/wp-content/uploads/cover.jpg",
+			"https://example.com/wp-content/uploads/photo.png",
+		}, job.Items[0].Images)
+
+		// drafts without a slug are named after their title
+		assert.Equal(t, "unfinished", created[1].Slug)
+		assert.Equal(t, constants.BlogDraft, created[1].State)
+		assert.False(t, created[1].Published)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+			fourthMock.Unset()
+			fifthMock.Unset()
+			sixthMock.Unset()
+			seventhMock.Unset()
+			importRepoTest.Mock.ExpectedCalls = nil
+			importRepoTest.Mock.Calls = nil
+		})
+	})
+
+	t.Run("Should skip the posts imported before", func(t *testing.T) {
+		firstMock := importUserRepoTest.Mock.On("FindByUsername", "author").Return(&entities.SafeUser{ID: authorID}, nil)
+		secondMock := importRepoTest.Mock.On("CreateJob", mock.Anything).Return(nil)
+		thirdMock := importRepoTest.Mock.On("SaveProgress", mock.Anything).Return(nil)
+		fourthMock := importRepoTest.Mock.On("FinishJob", mock.Anything).Return(nil)
+		fifthMock := importRepoTest.Mock.On("GetImportedBlog", constants.ImportWordPress, authorID, mock.Anything).Return("example-of-blog-id", nil)
+
+		job, err := importServiceTest.RunWordPressImport(wordPressExport, "author", false)
+
+		require.Nil(t, err)
+		assert.Equal(t, 2, job.Skipped)
+		assert.Equal(t, 0, job.Created)
+		assert.True(t, job.Items[0].Skipped)
+		assert.Equal(t, "example-of-blog-id", job.Items[0].BlogID)
+
+		importRepoTest.Mock.AssertNotCalled(t, "CreateImportedBlog", mock.Anything, mock.Anything)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+			fourthMock.Unset()
+			fifthMock.Unset()
+			importRepoTest.Mock.Calls = nil
+		})
+	})
+}
@@ -0,0 +1,293 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/inputs"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+var (
+	ErrInvalidAuthorization = errors.New("Authorization request is invalid")
+	ErrInvalidScope         = errors.New("Scope is invalid")
+	ErrInvalidGrant         = errors.New("Authorization code is invalid or expired")
+	ErrInvalidToken         = errors.New("Access token is invalid or expired")
+)
+
+type IndieAuthService interface {
+	GetMetadata() *types.IndieAuthMetadata
+
+	// GetProfile returns the endpoints advertised by the profile URL of the user,
+	// the profile URL is what the user signs in to clients with.
+	GetProfile(username string) (*types.IndieAuthProfile, error)
+
+	// ValidateAuthorization checks the request of a client before the user is asked to consent to it.
+	ValidateAuthorization(payload *inputs.AuthorizationInput, userID string) (*types.AuthorizationPrompt, error)
+
+	// Authorize issues an authorization code for the scopes the user granted,
+	// it returns the redirect URI of the client carrying the code.
+	Authorize(payload *inputs.AuthorizationInput, userID string) (string, error)
+
+	// ExchangeCode redeems an authorization code for an access token,
+	// the client proves it started the authorization with the PKCE verifier.
+	ExchangeCode(payload *inputs.TokenInput) (*types.TokenResponse, error)
+
+	VerifyToken(token string) (*types.TokenResponse, error)
+	RevokeToken(token string) error
+}
+
+type IndieAuthServiceImpl struct {
+	UtilService    UtilService
+	Repository     repositories.IndieAuthRepository
+	UserRepository repositories.UserRepository
+
+	// ServerURL is the issuer, profile URLs and endpoints live under it
+	ServerURL string
+}
+
+// HashToken is how codes and tokens are stored, a leaked table does not leak usable tokens.
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func (service *IndieAuthServiceImpl) GetMetadata() *types.IndieAuthMetadata {
+	return &types.IndieAuthMetadata{
+		Issuer:                        service.issuer(),
+		AuthorizationEndpoint:         service.authorizationEndpoint(),
+		TokenEndpoint:                 service.ServerURL + "/auth/indieauth/token",
+		RevocationEndpoint:            service.ServerURL + "/auth/indieauth/revoke",
+		ScopesSupported:               constants.IndieAuthScopes,
+		ResponseTypesSupported:        []string{"code"},
+		GrantTypesSupported:           []string{"authorization_code"},
+		CodeChallengeMethodsSupported: []string{constants.PKCEMethodS256},
+
+		AuthorizationResponseIssParameterSupported: true,
+	}
+}
+
+func (service *IndieAuthServiceImpl) GetProfile(username string) (*types.IndieAuthProfile, error) {
+	user, err := service.UserRepository.FindByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.IndieAuthProfile{
+		Me:         service.profileURL(user.Username),
+		ProfileURL: strings.TrimSuffix(os.Getenv("CLIENT_URL"), "/") + "/profile/" + url.PathEscape(user.Username),
+		Name:       user.Fullname,
+
+		Metadata:              service.ServerURL + "/.well-known/oauth-authorization-server",
+		AuthorizationEndpoint: service.authorizationEndpoint(),
+		TokenEndpoint:         service.ServerURL + "/auth/indieauth/token",
+		Micropub:              service.ServerURL + "/micropub",
+	}, nil
+}
+
+func (service *IndieAuthServiceImpl) ValidateAuthorization(payload *inputs.AuthorizationInput, userID string) (*types.AuthorizationPrompt, error) {
+	if err := service.UtilService.ValidateInput(payload); err != "" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAuthorization, err)
+	}
+
+	if err := validateRedirect(payload.ClientID, payload.RedirectURI); err != nil {
+		return nil, err
+	}
+
+	scopes := parseScopes(payload.Scope)
+
+	user, err := service.UserRepository.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.AuthorizationPrompt{
+		Me:          service.profileURL(user.Username),
+		ClientID:    payload.ClientID,
+		RedirectURI: payload.RedirectURI,
+		Scopes:      scopes,
+	}, nil
+}
+
+func (service *IndieAuthServiceImpl) Authorize(payload *inputs.AuthorizationInput, userID string) (string, error) {
+	prompt, err := service.ValidateAuthorization(payload, userID)
+	if err != nil {
+		return "", err
+	}
+
+	// signing in without any scope is not worth a token
+	if len(prompt.Scopes) == 0 {
+		return "", fmt.Errorf("%w: at least one scope must be granted", ErrInvalidScope)
+	}
+
+	code := service.UtilService.GenerateRandomID(32)
+
+	if err := service.Repository.CreateCode(&entities.IndieAuthCode{
+		Code:          HashToken(code),
+		UserID:        userID,
+		ClientID:      payload.ClientID,
+		RedirectURI:   payload.RedirectURI,
+		Scope:         strings.Join(prompt.Scopes, " "),
+		CodeChallenge: payload.CodeChallenge,
+		ExpiresAt:     time.Now().Add(constants.IndieAuthCodeLifetime),
+	}); err != nil {
+		return "", err
+	}
+
+	redirect, _ := url.Parse(payload.RedirectURI)
+
+	query := redirect.Query()
+	query.Set("code", code)
+	query.Set("state", payload.State)
+	query.Set("iss", service.issuer())
+	redirect.RawQuery = query.Encode()
+
+	return redirect.String(), nil
+}
+
+func (service *IndieAuthServiceImpl) ExchangeCode(payload *inputs.TokenInput) (*types.TokenResponse, error) {
+	if err := service.UtilService.ValidateInput(payload); err != "" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAuthorization, err)
+	}
+
+	code, err := service.Repository.TakeCode(HashToken(payload.Code))
+	if err != nil {
+		return nil, err
+	}
+
+	// the code is gone either way, a stolen code cannot be tried again
+	if code == nil || code.ClientID != payload.ClientID || code.RedirectURI != payload.RedirectURI {
+		return nil, ErrInvalidGrant
+	}
+
+	challenge := sha256.Sum256([]byte(payload.CodeVerifier))
+	if subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(challenge[:])), []byte(code.CodeChallenge)) != 1 {
+		return nil, ErrInvalidGrant
+	}
+
+	user, err := service.UserRepository.FindByID(code.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	token := service.UtilService.GenerateRandomID(48)
+
+	if err := service.Repository.CreateToken(&entities.AccessToken{
+		Token:     HashToken(token),
+		UserID:    code.UserID,
+		ClientID:  code.ClientID,
+		Scope:     code.Scope,
+		ExpiresAt: time.Now().Add(constants.AccessTokenLifetime),
+	}); err != nil {
+		return nil, err
+	}
+
+	return &types.TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		Scope:       code.Scope,
+		Me:          service.profileURL(user.Username),
+		ExpiresIn:   int(constants.AccessTokenLifetime.Seconds()),
+	}, nil
+}
+
+func (service *IndieAuthServiceImpl) VerifyToken(token string) (*types.TokenResponse, error) {
+	accessToken, err := service.Repository.GetToken(HashToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	if accessToken == nil {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := service.UserRepository.FindByID(accessToken.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.TokenResponse{
+		Scope:    accessToken.Scope,
+		Me:       service.profileURL(user.Username),
+		ClientID: accessToken.ClientID,
+	}, nil
+}
+
+// RevokeToken succeeds for unknown tokens as well, clients cannot tell them apart.
+func (service *IndieAuthServiceImpl) RevokeToken(token string) error {
+	return service.Repository.DeleteToken(HashToken(token))
+}
+
+func (service *IndieAuthServiceImpl) issuer() string {
+	return service.ServerURL + "/"
+}
+
+// authorizationEndpoint is the consent page of the client,
+// it asks the user through the /auth/indieauth/authorize routes of the server.
+func (service *IndieAuthServiceImpl) authorizationEndpoint() string {
+	return strings.TrimSuffix(os.Getenv("CLIENT_URL"), "/") + "/indieauth/authorize"
+}
+
+// profileURL identifies the user to clients, it changes along with the username.
+func (service *IndieAuthServiceImpl) profileURL(username string) string {
+	return service.ServerURL + "/indieauth/users/" + url.PathEscape(username)
+}
+
+// validateRedirect only accepts redirects on the host of the client,
+// the client is identified by its URL and must not send codes elsewhere.
+func validateRedirect(clientID string, redirectURI string) error {
+	client, err := url.Parse(clientID)
+	if err != nil || (client.Scheme != "https" && client.Scheme != "http") || client.Host == "" ||
+		client.User != nil || client.Fragment != "" {
+		return fmt.Errorf("%w: client_id must be the URL of the client", ErrInvalidAuthorization)
+	}
+
+	redirect, err := url.Parse(redirectURI)
+	if err != nil || redirect.Scheme != client.Scheme || redirect.Host != client.Host || redirect.Fragment != "" {
+		return fmt.Errorf("%w: redirect_uri must be on the host of the client", ErrInvalidAuthorization)
+	}
+
+	return nil
+}
+
+// parseScopes returns the supported scopes in their consent order,
+// clients ask for scopes of other servers as well so the unknown ones are left out.
+func parseScopes(scope string) []string {
+	requested := map[string]bool{}
+
+	for _, name := range strings.Fields(scope) {
+		requested[name] = true
+	}
+
+	scopes := []string{}
+
+	for _, name := range constants.IndieAuthScopes {
+		if requested[name] {
+			scopes = append(scopes, name)
+		}
+	}
+
+	return scopes
+}
+
+// hasScope reports whether the space separated scopes include the given one.
+func hasScope(scope string, want string) bool {
+	for _, name := range strings.Fields(scope) {
+		if name == want {
+			return true
+		}
+	}
+
+	return false
+}
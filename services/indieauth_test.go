@@ -0,0 +1,194 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/inputs"
+	"resqiar.com-server/repositories"
+)
+
+var indieAuthRepoTest = repositories.IndieAuthRepoMock{}
+var indieAuthUserRepoTest = repositories.UserRepoMock{}
+var indieAuthServiceTest = IndieAuthServiceImpl{
+	UtilService:    &utilService,
+	Repository:     &indieAuthRepoTest,
+	UserRepository: &indieAuthUserRepoTest,
+	ServerURL:      "https://api.example.com",
+}
+
+const indieAuthVerifier = "example-of-a-code-verifier-which-is-long-enough"
+
+func indieAuthChallenge(verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+func resetIndieAuthMocks() {
+	indieAuthRepoTest.Mock.ExpectedCalls = nil
+	indieAuthRepoTest.Mock.Calls = nil
+	indieAuthUserRepoTest.Mock.ExpectedCalls = nil
+}
+
+func TestAuthorize(t *testing.T) {
+	t.Cleanup(resetIndieAuthMocks)
+
+	userID := "example-of-user-id"
+	payload := func() *inputs.AuthorizationInput {
+		return &inputs.AuthorizationInput{
+			ResponseType:        "code",
+			ClientID:            "https://app.example/",
+			RedirectURI:         "https://app.example/callback?from=login",
+			State:               "example-of-state",
+			Scope:               "profile update create channels",
+			CodeChallenge:       indieAuthChallenge(indieAuthVerifier),
+			CodeChallengeMethod: constants.PKCEMethodS256,
+		}
+	}
+
+	indieAuthUserRepoTest.Mock.On("FindByID", userID).Return(&entities.SafeUser{ID: userID, Username: "author"}, nil)
+
+	t.Run("Should ask for the supported scopes only", func(t *testing.T) {
+		prompt, err := indieAuthServiceTest.ValidateAuthorization(payload(), userID)
+
+		require.Nil(t, err)
+		assert.Equal(t, []string{constants.ScopeCreate, constants.ScopeUpdate}, prompt.Scopes)
+		assert.Equal(t, "https://api.example.com/indieauth/users/author", prompt.Me)
+	})
+
+	t.Run("Should redirect to the client with a code", func(t *testing.T) {
+		indieAuthRepoTest.Mock.On("CreateCode", mock.Anything).Return(nil).Once()
+
+		redirect, err := indieAuthServiceTest.Authorize(payload(), userID)
+		require.Nil(t, err)
+
+		parsed, err := url.Parse(redirect)
+		require.Nil(t, err)
+
+		query := parsed.Query()
+		assert.Equal(t, "app.example", parsed.Host)
+		assert.Equal(t, "login", query.Get("from"))
+		assert.Equal(t, "example-of-state", query.Get("state"))
+		assert.Equal(t, "https://api.example.com/", query.Get("iss"))
+
+		// only the hash of the code is stored
+		code := indieAuthRepoTest.Mock.Calls[0].Arguments.Get(0).(*entities.IndieAuthCode)
+		assert.Equal(t, HashToken(query.Get("code")), code.Code)
+		assert.Equal(t, "create update", code.Scope)
+		assert.Equal(t, userID, code.UserID)
+		assert.WithinDuration(t, time.Now().Add(constants.IndieAuthCodeLifetime), code.ExpiresAt, time.Minute)
+	})
+
+	t.Run("Should refuse redirects away from the client", func(t *testing.T) {
+		for _, redirect := range []string{"https://evil.example/callback", "http://app.example/callback", "https://app.example.evil.example/"} {
+			request := payload()
+			request.RedirectURI = redirect
+
+			_, err := indieAuthServiceTest.Authorize(request, userID)
+
+			assert.ErrorIs(t, err, ErrInvalidAuthorization, redirect)
+		}
+	})
+
+	t.Run("Should refuse plain PKCE challenges", func(t *testing.T) {
+		request := payload()
+		request.CodeChallengeMethod = "plain"
+
+		_, err := indieAuthServiceTest.Authorize(request, userID)
+
+		assert.ErrorIs(t, err, ErrInvalidAuthorization)
+	})
+
+	t.Run("Should not issue a code without any scope", func(t *testing.T) {
+		request := payload()
+		request.Scope = "profile email"
+
+		_, err := indieAuthServiceTest.Authorize(request, userID)
+
+		assert.ErrorIs(t, err, ErrInvalidScope)
+	})
+}
+
+func TestExchangeCode(t *testing.T) {
+	t.Cleanup(resetIndieAuthMocks)
+
+	userID := "example-of-user-id"
+	code := func() *entities.IndieAuthCode {
+		return &entities.IndieAuthCode{
+			Code:          HashToken("example-of-code"),
+			UserID:        userID,
+			ClientID:      "https://app.example/",
+			RedirectURI:   "https://app.example/callback",
+			Scope:         "create media",
+			CodeChallenge: indieAuthChallenge(indieAuthVerifier),
+			ExpiresAt:     time.Now().Add(time.Minute),
+		}
+	}
+	payload := func() *inputs.TokenInput {
+		return &inputs.TokenInput{
+			GrantType:    "authorization_code",
+			Code:         "example-of-code",
+			ClientID:     "https://app.example/",
+			RedirectURI:  "https://app.example/callback",
+			CodeVerifier: indieAuthVerifier,
+		}
+	}
+
+	indieAuthUserRepoTest.Mock.On("FindByID", userID).Return(&entities.SafeUser{ID: userID, Username: "author"}, nil)
+
+	t.Run("Should exchange the code for a token", func(t *testing.T) {
+		indieAuthRepoTest.Mock.On("TakeCode", HashToken("example-of-code")).Return(code(), nil).Once()
+		indieAuthRepoTest.Mock.On("CreateToken", mock.Anything).Return(nil).Once()
+
+		token, err := indieAuthServiceTest.ExchangeCode(payload())
+		require.Nil(t, err)
+
+		assert.Equal(t, "Bearer", token.TokenType)
+		assert.Equal(t, "create media", token.Scope)
+		assert.Equal(t, "https://api.example.com/indieauth/users/author", token.Me)
+
+		stored := indieAuthRepoTest.Mock.Calls[1].Arguments.Get(0).(*entities.AccessToken)
+		assert.Equal(t, HashToken(token.AccessToken), stored.Token)
+		assert.Equal(t, userID, stored.UserID)
+		assert.Equal(t, "https://app.example/", stored.ClientID)
+	})
+
+	t.Run("Should refuse a wrong verifier", func(t *testing.T) {
+		indieAuthRepoTest.Mock.On("TakeCode", HashToken("example-of-code")).Return(code(), nil).Once()
+
+		request := payload()
+		request.CodeVerifier = strings.Repeat("x", 43)
+
+		_, err := indieAuthServiceTest.ExchangeCode(request)
+
+		assert.ErrorIs(t, err, ErrInvalidGrant)
+	})
+
+	t.Run("Should refuse another client", func(t *testing.T) {
+		indieAuthRepoTest.Mock.On("TakeCode", HashToken("example-of-code")).Return(code(), nil).Once()
+
+		request := payload()
+		request.ClientID = "https://other.example/"
+
+		_, err := indieAuthServiceTest.ExchangeCode(request)
+
+		assert.ErrorIs(t, err, ErrInvalidGrant)
+	})
+
+	t.Run("Should refuse a used or expired code", func(t *testing.T) {
+		indieAuthRepoTest.Mock.On("TakeCode", HashToken("example-of-code")).Return(nil, nil).Once()
+
+		_, err := indieAuthServiceTest.ExchangeCode(payload())
+
+		assert.ErrorIs(t, err, ErrInvalidGrant)
+	})
+}
@@ -0,0 +1,418 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/dto"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/inputs"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+var (
+	newPostTextTemplate = texttemplate.Must(texttemplate.New("new-post").Parse(
+		`{{.Author}} just published a new blog: {{.Title}}
+
+{{if .Summary}}{{.Summary}}
+
+{{end}}Read it here: {{.URL}}
+
+--
+You receive this email because you follow {{.Author}}.
+Unsubscribe: {{.UnsubscribeURL}}
+`))
+
+	newPostHTMLTemplate = htmltemplate.Must(htmltemplate.New("new-post").Parse(
+		`<p>{{.Author}} just published a new blog:</p>
+<h2><a href="{{.URL}}">{{.Title}}</a></h2>
+{{if .Summary}}<p>{{.Summary}}</p>{{end}}
+<p><a href="{{.URL}}">Read it here</a></p>
+<hr>
+<p><small>You receive this email because you follow {{.Author}}. <a href="{{.UnsubscribeURL}}">Unsubscribe</a></small></p>
+`))
+
+	digestTextTemplate = texttemplate.Must(texttemplate.New("digest").Parse(
+		`Here is what the authors you follow published this week:
+{{range .Blogs}}
+- {{.Title}} by {{.Author}}
+  {{.URL}}
+{{end}}
+--
+Unsubscribe from the weekly digest: {{.UnsubscribeURL}}
+`))
+
+	digestHTMLTemplate = htmltemplate.Must(htmltemplate.New("digest").Parse(
+		`<p>Here is what the authors you follow published this week:</p>
+<ul>
+{{range .Blogs}}<li><a href="{{.URL}}">{{.Title}}</a> by {{.Author}}{{if .Summary}}<br>{{.Summary}}{{end}}</li>
+{{end}}</ul>
+<hr>
+<p><small><a href="{{.UnsubscribeURL}}">Unsubscribe from the weekly digest</a></small></p>
+`))
+)
+
+type MailService interface {
+	// SendNewPost emails every follower of the blog's author who wants it.
+	SendNewPost(blogID string) error
+
+	// SendWeeklyDigest emails every user the blogs of their followed authors published since the given time.
+	SendWeeklyDigest(since time.Time) error
+
+	// RunDigestScheduler sends the weekly digest every week until the context is done.
+	RunDigestScheduler(ctx context.Context)
+
+	GetPreference(userID string) (*entities.NotificationPreference, error)
+	UpdatePreference(payload *inputs.UpdatePreferenceInput, userID string) error
+
+	// Unsubscribe turns off the email kind encoded in the signed token.
+	Unsubscribe(token string) error
+	UnsubscribeToken(userID string, kind string) string
+}
+
+type MailServiceImpl struct {
+	Mailer         Mailer
+	UtilService    UtilService
+	Repository     repositories.PreferenceRepository
+	BlogRepository repositories.BlogRepository
+
+	// Redis connection used to make sure only one server instance
+	// sends the weekly digest, every instance sends it when nil.
+	Locker redis.UniversalClient
+}
+
+type mailBlog struct {
+	Title   string
+	Summary string
+	Author  string
+	URL     string
+}
+
+// SubscribeEvents registers the mail service to the events it sends emails for.
+func (service *MailServiceImpl) SubscribeEvents(events EventService) {
+	events.Subscribe(constants.EventBlogPublished, func(event types.Event) {
+		if err := service.SendNewPost(event.BlogID); err != nil {
+			log.Println("Error sending new blog emails:", err)
+		}
+	})
+}
+
+func (service *MailServiceImpl) SendNewPost(blogID string) error {
+	blog, err := service.BlogRepository.GetBlog(&types.GetBlogOpts{
+		UseID:     blogID,
+		Published: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	recipients, err := service.Repository.GetNewPostRecipients(blog.Author.ID)
+	if err != nil {
+		return err
+	}
+
+	var failed int
+
+	for _, recipient := range recipients {
+		unsubscribeURL := service.unsubscribeURL(recipient.UserID, constants.UnsubscribeNewPost)
+
+		data := struct {
+			mailBlog
+			UnsubscribeURL string
+		}{
+			mailBlog: mailBlog{
+				Title:   blog.Title,
+				Summary: blog.Summary,
+				Author:  blog.Author.Username,
+				URL:     service.UtilService.BlogURL(blog.Author.Username, blog.Slug),
+			},
+			UnsubscribeURL: unsubscribeURL,
+		}
+
+		mail, err := renderMail(newPostTextTemplate, newPostHTMLTemplate, data)
+		if err != nil {
+			return err
+		}
+
+		mail.To = recipient.Email
+		mail.Subject = fmt.Sprintf("%s published: %s", blog.Author.Username, blog.Title)
+		mail.Headers = unsubscribeHeaders(unsubscribeURL)
+
+		// one failing recipient should not stop the others
+		if err := service.Mailer.Send(mail); err != nil {
+			log.Println("Error sending new blog email:", err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("Failed sending %d of %d emails", failed, len(recipients))
+	}
+
+	return nil
+}
+
+func (service *MailServiceImpl) SendWeeklyDigest(since time.Time) error {
+	recipients, err := service.Repository.GetDigestRecipients()
+	if err != nil {
+		return err
+	}
+
+	var failed int
+
+	for _, recipient := range recipients {
+		if err := service.sendDigest(recipient, since); err != nil {
+			log.Println("Error sending weekly digest:", err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("Failed sending %d of %d digests", failed, len(recipients))
+	}
+
+	return nil
+}
+
+func (service *MailServiceImpl) sendDigest(recipient dto.MailRecipient, since time.Time) error {
+	feed, err := service.BlogRepository.GetFeed(recipient.UserID, nil, constants.MaxDigestBlogs)
+	if err != nil {
+		return err
+	}
+
+	var blogs []mailBlog
+
+	// the feed is sorted newest first
+	for _, blog := range feed {
+		if blog.PublishedAt.Before(since) {
+			break
+		}
+
+		blogs = append(blogs, mailBlog{
+			Title:   blog.Title,
+			Summary: blog.Summary,
+			Author:  blog.Author.Username,
+			URL:     service.UtilService.BlogURL(blog.Author.Username, blog.Slug),
+		})
+	}
+
+	// nothing new this week, do not bother the user
+	if len(blogs) == 0 {
+		return nil
+	}
+
+	unsubscribeURL := service.unsubscribeURL(recipient.UserID, constants.UnsubscribeDigest)
+
+	data := struct {
+		Blogs          []mailBlog
+		UnsubscribeURL string
+	}{
+		Blogs:          blogs,
+		UnsubscribeURL: unsubscribeURL,
+	}
+
+	mail, err := renderMail(digestTextTemplate, digestHTMLTemplate, data)
+	if err != nil {
+		return err
+	}
+
+	mail.To = recipient.Email
+	mail.Subject = fmt.Sprintf("Your weekly digest: %d new blogs", len(blogs))
+	mail.Headers = unsubscribeHeaders(unsubscribeURL)
+
+	return service.Mailer.Send(mail)
+}
+
+func (service *MailServiceImpl) RunDigestScheduler(ctx context.Context) {
+	for {
+		next := NextDigestTime(time.Now())
+
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// only a single server instance gets to send this week's digest
+		if service.Locker != nil {
+			key := "mail:digest:" + next.Format("2006-01-02")
+
+			acquired, err := service.Locker.SetNX(ctx, key, "1", 24*time.Hour).Result()
+			if err != nil {
+				log.Println("Error acquiring digest lock:", err)
+				continue
+			}
+
+			if !acquired {
+				continue
+			}
+		}
+
+		if err := service.SendWeeklyDigest(next.AddDate(0, 0, -7)); err != nil {
+			log.Println("Error sending weekly digest:", err)
+		}
+	}
+}
+
+// NextDigestTime returns the next Monday 08:00 UTC strictly after the given time.
+func NextDigestTime(now time.Time) time.Time {
+	now = now.UTC()
+
+	days := (int(time.Monday) - int(now.Weekday()) + 7) % 7
+	next := time.Date(now.Year(), now.Month(), now.Day()+days, 8, 0, 0, 0, time.UTC)
+
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 7)
+	}
+
+	return next
+}
+
+func (service *MailServiceImpl) GetPreference(userID string) (*entities.NotificationPreference, error) {
+	preference, err := service.Repository.GetPreference(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return preference, nil
+}
+
+func (service *MailServiceImpl) UpdatePreference(payload *inputs.UpdatePreferenceInput, userID string) error {
+	preference := &entities.NotificationPreference{
+		UserID:       userID,
+		EmailNewPost: payload.EmailNewPost,
+		EmailDigest:  payload.EmailDigest,
+	}
+
+	if err := service.Repository.SavePreference(preference); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (service *MailServiceImpl) Unsubscribe(token string) error {
+	userID, kind, err := verifyUnsubscribeToken(token)
+	if err != nil {
+		return err
+	}
+
+	preference, err := service.Repository.GetPreference(userID)
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case constants.UnsubscribeNewPost:
+		preference.EmailNewPost = false
+	case constants.UnsubscribeDigest:
+		preference.EmailDigest = false
+	case constants.UnsubscribeAll:
+		preference.EmailNewPost = false
+		preference.EmailDigest = false
+	default:
+		return errors.New("Invalid token")
+	}
+
+	if err := service.Repository.SavePreference(preference); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UnsubscribeToken signs the user ID and the email kind with MAIL_UNSUBSCRIBE_SECRET,
+// so the token can be verified later without storing it anywhere.
+func (service *MailServiceImpl) UnsubscribeToken(userID string, kind string) string {
+	payload := userID + "." + kind
+
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(signUnsubscribe(payload))
+}
+
+func (service *MailServiceImpl) unsubscribeURL(userID string, kind string) string {
+	SERVER_URL := os.Getenv("SERVER_URL")
+
+	return SERVER_URL + "/mail/unsubscribe?token=" + url.QueryEscape(service.UnsubscribeToken(userID, kind))
+}
+
+func verifyUnsubscribeToken(token string) (string, string, error) {
+	if os.Getenv("MAIL_UNSUBSCRIBE_SECRET") == "" {
+		return "", "", errors.New("Unsubscribe secret is not configured")
+	}
+
+	encodedPayload, encodedSignature, found := strings.Cut(token, ".")
+	if !found {
+		return "", "", errors.New("Invalid token")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return "", "", errors.New("Invalid token")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return "", "", errors.New("Invalid token")
+	}
+
+	if !hmac.Equal(signature, signUnsubscribe(string(payload))) {
+		return "", "", errors.New("Invalid token")
+	}
+
+	userID, kind, found := strings.Cut(string(payload), ".")
+	if !found {
+		return "", "", errors.New("Invalid token")
+	}
+
+	return userID, kind, nil
+}
+
+func signUnsubscribe(payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("MAIL_UNSUBSCRIBE_SECRET")))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// unsubscribeHeaders allows mail clients to offer one-click unsubscribe (RFC 8058)
+func unsubscribeHeaders(unsubscribeURL string) map[string]string {
+	return map[string]string{
+		"List-Unsubscribe":      "<" + unsubscribeURL + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+}
+
+func renderMail(text *texttemplate.Template, html *htmltemplate.Template, data any) (*types.Mail, error) {
+	var textBuf, htmlBuf bytes.Buffer
+
+	if err := text.Execute(&textBuf, data); err != nil {
+		return nil, err
+	}
+
+	if err := html.Execute(&htmlBuf, data); err != nil {
+		return nil, err
+	}
+
+	return &types.Mail{
+		Text: textBuf.String(),
+		HTML: htmlBuf.String(),
+	}, nil
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/dto"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/inputs"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+// recordingMailer keeps every mail instead of delivering it
+type recordingMailer struct {
+	mu    sync.Mutex
+	mails []*types.Mail
+	err   error
+}
+
+func (mailer *recordingMailer) Send(mail *types.Mail) error {
+	mailer.mu.Lock()
+	defer mailer.mu.Unlock()
+
+	mailer.mails = append(mailer.mails, mail)
+	return mailer.err
+}
+
+var preferenceRepoTest = repositories.PreferenceRepoMock{}
+var mailBlogRepoTest = repositories.BlogRepoMock{}
+
+func newMailServiceTest(mailer Mailer) *MailServiceImpl {
+	return &MailServiceImpl{
+		Mailer:         mailer,
+		UtilService:    &utilService,
+		Repository:     &preferenceRepoTest,
+		BlogRepository: &mailBlogRepoTest,
+	}
+}
+
+func TestSendNewPost(t *testing.T) {
+	t.Setenv("CLIENT_URL", "https://client.example.com")
+	t.Setenv("SERVER_URL", "https://server.example.com")
+	t.Setenv("MAIL_UNSUBSCRIBE_SECRET", "example-of-secret")
+
+	blogID := "example-of-blog-id"
+	getBlogOpts := &types.GetBlogOpts{UseID: blogID, Published: true}
+
+	blog := &entities.SafeBlogAuthor{
+		SafeBlog: entities.SafeBlog{
+			ID:      blogID,
+			Slug:    "example-of-slug",
+			Title:   "Example of Title",
+			Summary: "Example of summary",
+		},
+		Author: entities.SafeUser{
+			ID:       "example-of-author-id",
+			Username: "author123",
+		},
+	}
+
+	recipients := []dto.MailRecipient{
+		{UserID: "first-id", Email: "first@example.com"},
+		{UserID: "second-id", Email: "second@example.com"},
+	}
+
+	t.Run("Should email every follower who wants it", func(t *testing.T) {
+		mailer := &recordingMailer{}
+		service := newMailServiceTest(mailer)
+
+		firstMock := mailBlogRepoTest.Mock.On("GetBlog", getBlogOpts).Return(blog, nil)
+		secondMock := preferenceRepoTest.Mock.On("GetNewPostRecipients", blog.Author.ID).Return(recipients, nil)
+
+		err := service.SendNewPost(blogID)
+
+		require.Nil(t, err)
+		require.Len(t, mailer.mails, 2)
+
+		mail := mailer.mails[0]
+		assert.Equal(t, "first@example.com", mail.To)
+		assert.Equal(t, "author123 published: Example of Title", mail.Subject)
+		assert.Contains(t, mail.Text, "https://client.example.com/blog/author123/example-of-slug")
+		assert.Contains(t, mail.HTML, "https://client.example.com/blog/author123/example-of-slug")
+		assert.Contains(t, mail.Headers["List-Unsubscribe"], "https://server.example.com/mail/unsubscribe?token=")
+		assert.Equal(t, "List-Unsubscribe=One-Click", mail.Headers["List-Unsubscribe-Post"])
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+
+	t.Run("Should keep sending when a recipient fails", func(t *testing.T) {
+		mailer := &recordingMailer{err: errors.New("Mailbox unavailable")}
+		service := newMailServiceTest(mailer)
+
+		firstMock := mailBlogRepoTest.Mock.On("GetBlog", getBlogOpts).Return(blog, nil)
+		secondMock := preferenceRepoTest.Mock.On("GetNewPostRecipients", blog.Author.ID).Return(recipients, nil)
+
+		err := service.SendNewPost(blogID)
+
+		assert.EqualError(t, err, "Failed sending 2 of 2 emails")
+		assert.Len(t, mailer.mails, 2)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+
+	t.Run("Should return error if blog not found", func(t *testing.T) {
+		mailer := &recordingMailer{}
+		service := newMailServiceTest(mailer)
+
+		firstMock := mailBlogRepoTest.Mock.On("GetBlog", getBlogOpts).Return(nil, errors.New("Record not found"))
+
+		err := service.SendNewPost(blogID)
+
+		assert.EqualError(t, err, "Record not found")
+		assert.Empty(t, mailer.mails)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+		})
+	})
+}
+
+func TestSendWeeklyDigest(t *testing.T) {
+	t.Setenv("MAIL_UNSUBSCRIBE_SECRET", "example-of-secret")
+
+	since := time.Date(2024, time.January, 1, 8, 0, 0, 0, time.UTC)
+
+	recipients := []dto.MailRecipient{
+		{UserID: "active-reader", Email: "active@example.com"},
+		{UserID: "idle-reader", Email: "idle@example.com"},
+	}
+
+	t.Run("Should only email users with new blogs since the last digest", func(t *testing.T) {
+		mailer := &recordingMailer{}
+		service := newMailServiceTest(mailer)
+
+		var noCursor *types.FeedCursor
+
+		firstMock := preferenceRepoTest.Mock.On("GetDigestRecipients").Return(recipients, nil)
+		secondMock := mailBlogRepoTest.Mock.On("GetFeed", "active-reader", noCursor, constants.MaxDigestBlogs).Return([]entities.SafeBlogAuthor{
+			{SafeBlog: entities.SafeBlog{Title: "This week", PublishedAt: since.AddDate(0, 0, 2)}},
+			{SafeBlog: entities.SafeBlog{Title: "Last month", PublishedAt: since.AddDate(0, -1, 0)}},
+		}, nil)
+		thirdMock := mailBlogRepoTest.Mock.On("GetFeed", "idle-reader", noCursor, constants.MaxDigestBlogs).Return([]entities.SafeBlogAuthor{
+			{SafeBlog: entities.SafeBlog{Title: "Last month", PublishedAt: since.AddDate(0, -1, 0)}},
+		}, nil)
+
+		err := service.SendWeeklyDigest(since)
+
+		require.Nil(t, err)
+		require.Len(t, mailer.mails, 1)
+
+		mail := mailer.mails[0]
+		assert.Equal(t, "active@example.com", mail.To)
+		assert.Equal(t, "Your weekly digest: 1 new blogs", mail.Subject)
+		assert.Contains(t, mail.Text, "This week")
+		assert.NotContains(t, mail.Text, "Last month")
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+		})
+	})
+}
+
+func TestNextDigestTime(t *testing.T) {
+	testCases := []struct {
+		now      time.Time
+		expected time.Time
+	}{
+		// Sunday evening goes to the next day
+		{time.Date(2024, time.January, 7, 20, 0, 0, 0, time.UTC), time.Date(2024, time.January, 8, 8, 0, 0, 0, time.UTC)},
+		// Monday before 08:00 goes to the same day
+		{time.Date(2024, time.January, 8, 7, 0, 0, 0, time.UTC), time.Date(2024, time.January, 8, 8, 0, 0, 0, time.UTC)},
+		// Monday exactly at 08:00 goes to the next week
+		{time.Date(2024, time.January, 8, 8, 0, 0, 0, time.UTC), time.Date(2024, time.January, 15, 8, 0, 0, 0, time.UTC)},
+		// Wednesday goes to the next Monday
+		{time.Date(2024, time.January, 10, 12, 0, 0, 0, time.UTC), time.Date(2024, time.January, 15, 8, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range testCases {
+		t.Run("Should schedule "+tc.now.Format(time.RFC3339)+" INTO "+tc.expected.Format(time.RFC3339), func(t *testing.T) {
+			assert.Equal(t, tc.expected, NextDigestTime(tc.now))
+		})
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	t.Setenv("MAIL_UNSUBSCRIBE_SECRET", "example-of-secret")
+
+	service := newMailServiceTest(&recordingMailer{})
+	userID := "example-of-user-id"
+
+	t.Run("Should turn off the email kind in the token", func(t *testing.T) {
+		token := service.UnsubscribeToken(userID, constants.UnsubscribeDigest)
+
+		expected := &entities.NotificationPreference{
+			UserID:       userID,
+			EmailNewPost: true,
+			EmailDigest:  false,
+		}
+
+		firstMock := preferenceRepoTest.Mock.On("GetPreference", userID).Return(entities.DefaultNotificationPreference(userID), nil)
+		secondMock := preferenceRepoTest.Mock.On("SavePreference", expected).Return(nil)
+
+		err := service.Unsubscribe(token)
+
+		assert.Nil(t, err)
+		preferenceRepoTest.Mock.AssertCalled(t, "SavePreference", expected)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+
+	t.Run("Should reject tampered tokens", func(t *testing.T) {
+		token := service.UnsubscribeToken(userID, constants.UnsubscribeAll)
+
+		t.Setenv("MAIL_UNSUBSCRIBE_SECRET", "example-of-other-secret")
+
+		assert.EqualError(t, service.Unsubscribe(token), "Invalid token")
+		assert.EqualError(t, service.Unsubscribe("not-a-token"), "Invalid token")
+	})
+
+	t.Run("Should reject tokens when the secret is not configured", func(t *testing.T) {
+		token := service.UnsubscribeToken(userID, constants.UnsubscribeAll)
+
+		t.Setenv("MAIL_UNSUBSCRIBE_SECRET", "")
+
+		assert.EqualError(t, service.Unsubscribe(token), "Unsubscribe secret is not configured")
+	})
+}
+
+func TestUpdatePreference(t *testing.T) {
+	t.Run("Should save the given preference", func(t *testing.T) {
+		service := newMailServiceTest(&recordingMailer{})
+		userID := "example-of-user-id"
+
+		expected := &entities.NotificationPreference{
+			UserID:       userID,
+			EmailNewPost: false,
+			EmailDigest:  true,
+		}
+
+		mock := preferenceRepoTest.Mock.On("SavePreference", expected).Return(nil)
+
+		err := service.UpdatePreference(&inputs.UpdatePreferenceInput{
+			EmailNewPost: false,
+			EmailDigest:  true,
+		}, userID)
+
+		assert.Nil(t, err)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			mock.Unset()
+		})
+	})
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"resqiar.com-server/constants"
+	"resqiar.com-server/types"
+)
+
+// Mailer delivers a single email, implementations decide where it goes.
+type Mailer interface {
+	Send(mail *types.Mail) error
+}
+
+// InitMailer picks the mailer implementation based on MAIL_DRIVER,
+// anything other than "smtp" falls back to the log mailer.
+func InitMailer() Mailer {
+	FROM := os.Getenv("MAIL_FROM")
+
+	if os.Getenv("MAIL_DRIVER") == constants.MailSMTP {
+		return &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     FROM,
+		}
+	}
+
+	return &LogMailer{
+		Dir:  os.Getenv("MAIL_LOG_DIR"),
+		From: FROM,
+	}
+}
+
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (mailer *SMTPMailer) Send(mail *types.Mail) error {
+	message, err := BuildMessage(mailer.From, mail)
+	if err != nil {
+		return err
+	}
+
+	// authenticate only when credentials are given,
+	// local SMTP servers usually accept anonymous senders
+	var auth smtp.Auth
+	if mailer.Username != "" {
+		auth = smtp.PlainAuth("", mailer.Username, mailer.Password, mailer.Host)
+	}
+
+	address := net.JoinHostPort(mailer.Host, mailer.Port)
+
+	return smtp.SendMail(address, auth, mailer.From, []string{mail.To}, message)
+}
+
+// LogMailer is meant for development, it writes every email as an .eml file
+// into Dir, or into the log output when Dir is empty.
+type LogMailer struct {
+	Dir  string
+	From string
+}
+
+func (mailer *LogMailer) Send(mail *types.Mail) error {
+	message, err := BuildMessage(mailer.From, mail)
+	if err != nil {
+		return err
+	}
+
+	if mailer.Dir == "" {
+		log.Printf("Mail to %s:\n%s", mail.To, message)
+		return nil
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), mail.To)
+
+	return os.WriteFile(filepath.Join(mailer.Dir, name), message, 0644)
+}
+
+// BuildMessage renders the mail into a MIME message with a plain-text
+// and an HTML alternative, ready to be handed to an SMTP server.
+func BuildMessage(from string, mail *types.Mail) ([]byte, error) {
+	var buf bytes.Buffer
+
+	body := multipart.NewWriter(&buf)
+
+	headers := map[string]string{
+		"From":         from,
+		"To":           mail.To,
+		"Subject":      mime.QEncoding.Encode("utf-8", mail.Subject),
+		"Date":         time.Now().Format(time.RFC1123Z),
+		"MIME-Version": "1.0",
+		"Content-Type": "multipart/alternative; boundary=" + body.Boundary(),
+	}
+
+	for key, value := range mail.Headers {
+		headers[key] = value
+	}
+
+	// keep the header order stable
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var message bytes.Buffer
+	for _, key := range keys {
+		fmt.Fprintf(&message, "%s: %s\r\n", key, headers[key])
+	}
+	message.WriteString("\r\n")
+
+	parts := []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", mail.Text},
+		{"text/html; charset=utf-8", mail.HTML},
+	}
+
+	for _, part := range parts {
+		writer, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := quotedprintable.NewWriter(writer)
+		if _, err := encoder.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+
+	message.Write(buf.Bytes())
+
+	return message.Bytes(), nil
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"bufio"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"resqiar.com-server/types"
+)
+
+var testMail = types.Mail{
+	To:      "reader@example.com",
+	Subject: "Hello from the blog",
+	Text:    "plain text body",
+	HTML:    "<p>html body</p>",
+	Headers: map[string]string{
+		"List-Unsubscribe": "<https://example.com/unsubscribe>",
+	},
+}
+
+// startFakeSMTP runs a minimal local SMTP server accepting a single message,
+// the received DATA is sent into the returned channel.
+func startFakeSMTP(t *testing.T) (string, string, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+
+	received := make(chan string, 1)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 localhost ESMTP")
+
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+
+			command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+			switch command {
+			case "EHLO", "HELO":
+				text.PrintfLine("250 localhost")
+			case "MAIL", "RCPT", "RSET", "NOOP":
+				text.PrintfLine("250 OK")
+			case "DATA":
+				text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+
+				data, err := text.ReadDotBytes()
+				if err != nil {
+					return
+				}
+
+				received <- string(data)
+				text.PrintfLine("250 OK")
+			case "QUIT":
+				text.PrintfLine("221 Bye")
+				return
+			default:
+				text.PrintfLine("502 Command not implemented")
+			}
+		}
+	}()
+
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	return host, port, received
+}
+
+func TestSMTPMailer(t *testing.T) {
+	t.Run("Should deliver the mail to the SMTP server", func(t *testing.T) {
+		host, port, received := startFakeSMTP(t)
+
+		mailer := SMTPMailer{
+			Host: host,
+			Port: port,
+			From: "blog@example.com",
+		}
+
+		err := mailer.Send(&testMail)
+		require.Nil(t, err)
+
+		select {
+		case data := <-received:
+			assert.Contains(t, data, "Subject: Hello from the blog")
+			assert.Contains(t, data, "To: reader@example.com")
+			assert.Contains(t, data, "List-Unsubscribe: <https://example.com/unsubscribe>")
+			assert.Contains(t, data, "plain text body")
+			assert.Contains(t, data, "<p>html body</p>")
+		case <-time.After(time.Second):
+			t.Fatal("mail was never received")
+		}
+	})
+
+	t.Run("Should return error if the SMTP server is unreachable", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.Nil(t, err)
+
+		// close right away so nothing listens on the port
+		host, port, _ := net.SplitHostPort(listener.Addr().String())
+		listener.Close()
+
+		mailer := SMTPMailer{
+			Host: host,
+			Port: port,
+			From: "blog@example.com",
+		}
+
+		assert.Error(t, mailer.Send(&testMail))
+	})
+}
+
+func TestLogMailer(t *testing.T) {
+	t.Run("Should write the mail as an eml file", func(t *testing.T) {
+		dir := t.TempDir()
+
+		mailer := LogMailer{
+			Dir:  dir,
+			From: "blog@example.com",
+		}
+
+		err := mailer.Send(&testMail)
+		require.Nil(t, err)
+
+		files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+		require.Nil(t, err)
+		require.Len(t, files, 1)
+
+		content, err := os.ReadFile(files[0])
+		require.Nil(t, err)
+
+		assert.Contains(t, string(content), "From: blog@example.com")
+		assert.Contains(t, string(content), "plain text body")
+	})
+}
+
+func TestBuildMessage(t *testing.T) {
+	t.Run("Should build a multipart message with both alternatives", func(t *testing.T) {
+		message, err := BuildMessage("blog@example.com", &testMail)
+		require.Nil(t, err)
+
+		reader := textproto.NewReader(bufio.NewReader(strings.NewReader(string(message))))
+		headers, err := reader.ReadMIMEHeader()
+		require.Nil(t, err)
+
+		assert.Equal(t, "1.0", headers.Get("MIME-Version"))
+		assert.True(t, strings.HasPrefix(headers.Get("Content-Type"), "multipart/alternative"))
+		assert.Contains(t, string(message), "Content-Type: text/plain; charset=utf-8")
+		assert.Contains(t, string(message), "Content-Type: text/html; charset=utf-8")
+	})
+
+	t.Run("Should encode non-ASCII subjects", func(t *testing.T) {
+		mail := testMail
+		mail.Subject = "Halo, apa kabar? ✨"
+
+		message, err := BuildMessage("blog@example.com", &mail)
+		require.Nil(t, err)
+
+		assert.Contains(t, string(message), "Subject: =?utf-8?q?")
+	})
+}
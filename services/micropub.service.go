@@ -0,0 +1,623 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/microcosm-cc/bluemonday"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/inputs"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+var (
+	ErrInvalidMicropubRequest = errors.New("Micropub request is invalid")
+	ErrPostNotFound           = errors.New("Post does not exist")
+	ErrInsufficientScope      = errors.New("Access token does not grant the scope of the request")
+	ErrUnsupportedMedia       = errors.New("Media type is not supported")
+	ErrMediaTooLarge          = errors.New("Media is too large")
+)
+
+// notePolicy keeps the text of the content only, untitled notes are titled after it.
+var notePolicy = bluemonday.StrictPolicy()
+
+type MicropubService interface {
+	GetConfig() *types.MicropubConfig
+
+	// GetSource returns the properties of a post of the user, only the given ones when there are any.
+	GetSource(postURL string, properties []string, userID string) (*types.MicropubEntry, error)
+
+	// CreatePost creates a blog from an h-entry and publishes it unless it is a draft.
+	CreatePost(request *types.MicropubRequest, userID string, scope string) (*types.MicropubResult, error)
+
+	// UpdatePost replaces, adds and deletes properties of the post.
+	UpdatePost(request *types.MicropubRequest, userID string, scope string) (*types.MicropubResult, error)
+
+	// DeletePost unpublishes the post, undeleting publishes it again.
+	DeletePost(postURL string, userID string, scope string) error
+	UndeletePost(postURL string, userID string, scope string) (*types.MicropubResult, error)
+
+	// UploadMedia stores an image and returns its URL.
+	UploadMedia(data []byte, userID string, scope string) (string, error)
+	GetMedia(ID string) (*entities.MediaFile, error)
+
+	// GetPostURL returns the page of the published blog.
+	GetPostURL(blogID string) (string, error)
+}
+
+type MicropubServiceImpl struct {
+	UtilService    UtilService
+	BlogService    BlogService
+	BlogRepository repositories.BlogRepository
+	Repository     repositories.MediaRepository
+
+	// ServerURL is where media and drafts are reached
+	ServerURL string
+}
+
+func (service *MicropubServiceImpl) GetConfig() *types.MicropubConfig {
+	return &types.MicropubConfig{
+		MediaEndpoint: service.ServerURL + "/micropub/media",
+		SyndicateTo:   []interface{}{},
+		PostTypes: []types.MicropubPostType{
+			{Type: "note", Name: "Note"},
+			{Type: "article", Name: "Article"},
+			{Type: "photo", Name: "Photo"},
+		},
+		Q: []string{"config", "source", "syndicate-to"},
+	}
+}
+
+func (service *MicropubServiceImpl) GetSource(postURL string, properties []string, userID string) (*types.MicropubEntry, error) {
+	blog, err := service.findPost(postURL, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	source := blogProperties(blog)
+
+	if len(properties) == 0 {
+		return &types.MicropubEntry{
+			Type:       []string{"h-entry"},
+			Properties: source,
+		}, nil
+	}
+
+	// only the properties are returned when some were asked for
+	selected := map[string][]interface{}{}
+
+	for _, name := range properties {
+		if values, ok := source[name]; ok {
+			selected[name] = values
+		}
+	}
+
+	return &types.MicropubEntry{Properties: selected}, nil
+}
+
+func (service *MicropubServiceImpl) CreatePost(request *types.MicropubRequest, userID string, scope string) (*types.MicropubResult, error) {
+	if len(request.Type) > 0 && request.Type[0] != "h-entry" {
+		return nil, fmt.Errorf("%w: only h-entry posts are supported", ErrInvalidMicropubRequest)
+	}
+
+	draft := propertyString(request.Properties["post-status"]) == constants.MicropubDraft
+
+	if !hasScope(scope, constants.ScopeCreate) && !(draft && hasScope(scope, constants.ScopeDraft)) {
+		return nil, ErrInsufficientScope
+	}
+
+	payload, err := service.createInput(request.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	blog, err := service.BlogService.CreateBlog(payload, userID)
+
+	// the slug is only a suggestion, another one is generated on publish
+	if errors.Is(err, ErrSlugTaken) {
+		payload.Slug = ""
+		blog, err = service.BlogService.CreateBlog(payload, userID)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if draft {
+		return &types.MicropubResult{URL: service.draftURL(blog.ID)}, nil
+	}
+
+	return service.publish(blog.ID, userID)
+}
+
+func (service *MicropubServiceImpl) UpdatePost(request *types.MicropubRequest, userID string, scope string) (*types.MicropubResult, error) {
+	if !hasScope(scope, constants.ScopeUpdate) {
+		return nil, ErrInsufficientScope
+	}
+
+	blog, err := service.findPost(request.URL, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	current := blogProperties(blog)
+
+	properties := map[string][]interface{}{}
+	for name, values := range current {
+		properties[name] = values
+	}
+
+	for name, values := range request.Replace {
+		properties[name] = values
+	}
+
+	for name, values := range request.Add {
+		properties[name] = append(properties[name], values...)
+	}
+
+	if err := deleteProperties(properties, request.Delete); err != nil {
+		return nil, err
+	}
+
+	payload, err := service.updateInput(blog, current, properties)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := service.BlogService.EditBlog(payload, userID); err != nil {
+		return nil, err
+	}
+
+	location, err := service.postURL(blog.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.MicropubResult{URL: location}, nil
+}
+
+func (service *MicropubServiceImpl) DeletePost(postURL string, userID string, scope string) error {
+	if !hasScope(scope, constants.ScopeDelete) {
+		return ErrInsufficientScope
+	}
+
+	blog, err := service.findPost(postURL, userID)
+	if err != nil {
+		return err
+	}
+
+	// drafts are as deleted as they get
+	if blog.State == constants.BlogDraft {
+		return nil
+	}
+
+	return service.BlogService.ChangeBlogPublish(&inputs.BlogIDInput{ID: blog.ID}, userID, false)
+}
+
+func (service *MicropubServiceImpl) UndeletePost(postURL string, userID string, scope string) (*types.MicropubResult, error) {
+	if !hasScope(scope, constants.ScopeDelete) {
+		return nil, ErrInsufficientScope
+	}
+
+	blog, err := service.findPost(postURL, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if blog.Published {
+		location, err := service.postURL(blog.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		return &types.MicropubResult{URL: location}, nil
+	}
+
+	return service.publish(blog.ID, userID)
+}
+
+// UploadMedia accepts photos uploaded along with a post under the scope of the post.
+func (service *MicropubServiceImpl) UploadMedia(data []byte, userID string, scope string) (string, error) {
+	if !hasScope(scope, constants.ScopeMedia) && !hasScope(scope, constants.ScopeCreate) && !hasScope(scope, constants.ScopeDraft) {
+		return "", ErrInsufficientScope
+	}
+
+	if len(data) > constants.MicropubMaxMediaSize {
+		return "", ErrMediaTooLarge
+	}
+
+	// the content type given by the client is not trusted, it is served back as is
+	contentType := http.DetectContentType(data)
+	if !constants.MicropubMediaTypes[contentType] {
+		return "", ErrUnsupportedMedia
+	}
+
+	media, err := service.Repository.CreateMedia(&entities.MediaFile{
+		UserID:      userID,
+		ContentType: contentType,
+		Size:        len(data),
+		Data:        data,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return service.ServerURL + "/micropub/media/" + media.ID, nil
+}
+
+func (service *MicropubServiceImpl) GetMedia(ID string) (*entities.MediaFile, error) {
+	return service.Repository.GetMedia(ID)
+}
+
+func (service *MicropubServiceImpl) GetPostURL(blogID string) (string, error) {
+	blog, err := service.BlogRepository.GetBlog(&types.GetBlogOpts{UseID: blogID, Published: true})
+	if err != nil {
+		return "", ErrPostNotFound
+	}
+
+	return service.UtilService.BlogURL(blog.Author.Username, blog.Slug), nil
+}
+
+// publish publishes the blog and returns the URL of its page.
+func (service *MicropubServiceImpl) publish(blogID string, userID string) (*types.MicropubResult, error) {
+	if err := service.BlogService.ChangeBlogPublish(&inputs.BlogIDInput{ID: blogID}, userID, true); err != nil {
+		return nil, err
+	}
+
+	location, err := service.postURL(blogID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.MicropubResult{URL: location}, nil
+}
+
+// findPost returns the blog the URL is the page of, as long as the user may edit it.
+// Drafts have no page yet and are known by their URL on the server instead.
+func (service *MicropubServiceImpl) findPost(postURL string, userID string) (*entities.Blog, error) {
+	parsed, err := url.Parse(postURL)
+	if err != nil || !isWebURL(parsed) {
+		return nil, ErrPostNotFound
+	}
+
+	var blogID string
+
+	if draftID, ok := strings.CutPrefix(postURL, service.ServerURL+"/micropub/posts/"); ok {
+		blogID = draftID
+	} else {
+		// blog pages are at /blog/<author>/<slug> on the client
+		segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+		if len(segments) != 3 || segments[0] != "blog" {
+			return nil, ErrPostNotFound
+		}
+
+		blog, err := service.BlogRepository.GetBlog(&types.GetBlogOpts{
+			BlogAuthor: segments[1],
+			BlogSlug:   segments[2],
+		})
+		if err != nil {
+			return nil, ErrPostNotFound
+		}
+
+		// the path alone matches blogs of any other site
+		blogURL, err := url.Parse(service.UtilService.BlogURL(blog.Author.Username, blog.Slug))
+		if err != nil || !strings.EqualFold(blogURL.Host, parsed.Host) {
+			return nil, ErrPostNotFound
+		}
+
+		blogID = blog.ID
+	}
+
+	blog, err := service.BlogRepository.GetByIDAndAuthor(blogID, userID)
+	if err != nil {
+		return nil, ErrPostNotFound
+	}
+
+	return blog, nil
+}
+
+// postURL is the page of the blog once published, or its URL on the server until then.
+func (service *MicropubServiceImpl) postURL(blogID string) (string, error) {
+	blog, err := service.BlogRepository.GetBlog(&types.GetBlogOpts{UseID: blogID, Published: true})
+	if err != nil && err.Error() == "404" {
+		return service.draftURL(blogID), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return service.UtilService.BlogURL(blog.Author.Username, blog.Slug), nil
+}
+
+func (service *MicropubServiceImpl) draftURL(blogID string) string {
+	return service.ServerURL + "/micropub/posts/" + blogID
+}
+
+func (service *MicropubServiceImpl) createInput(properties map[string][]interface{}) (*inputs.CreateBlogInput, error) {
+	content := propertyContent(properties["content"])
+	photos := propertyStrings(properties["photo"])
+
+	payload := &inputs.CreateBlogInput{
+		Title:    propertyString(properties["name"]),
+		Summary:  propertyString(properties["summary"]),
+		Content:  content,
+		CoverURL: propertyString(properties["featured"]),
+		Tags:     propertyStrings(properties["category"]),
+		Slug:     service.slug(propertyString(properties["mp-slug"])),
+	}
+
+	// the first photo is the cover unless one is featured, the others follow the content
+	if payload.CoverURL == "" && len(photos) > 0 {
+		payload.CoverURL = photos[0]
+		photos = photos[1:]
+	}
+
+	payload.Content = appendPhotos(payload.Content, photos)
+
+	// notes have no name, they are titled after their beginning
+	if payload.Title == "" {
+		payload.Title = noteTitle(content)
+	}
+
+	publishedAt, err := propertyTime(properties["published"])
+	if err != nil {
+		return nil, err
+	}
+	payload.PublishedAt = publishedAt
+
+	if err := service.UtilService.ValidateInput(payload); err != "" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMicropubRequest, err)
+	}
+
+	return payload, nil
+}
+
+// updateInput is the edit turning the current properties of the blog into the updated ones,
+// properties left as they were are not part of it.
+func (service *MicropubServiceImpl) updateInput(blog *entities.Blog, current map[string][]interface{}, updated map[string][]interface{}) (*inputs.UpdateBlogInput, error) {
+	payload := &inputs.UpdateBlogInput{
+		ID:      blog.ID,
+		Version: blog.Version,
+	}
+
+	// an edit leaves empty fields untouched, these properties cannot be removed
+	for _, name := range []string{"name", "content", "summary", "photo"} {
+		if len(current[name]) > 0 && len(updated[name]) == 0 {
+			return nil, fmt.Errorf("%w: %s cannot be removed", ErrInvalidMicropubRequest, name)
+		}
+	}
+
+	if title := propertyString(updated["name"]); title != blog.Title {
+		payload.Title = title
+	}
+
+	if content := propertyContent(updated["content"]); content != blog.Content {
+		payload.Content = content
+	}
+
+	if summary := propertyString(updated["summary"]); summary != blog.Summary {
+		payload.Summary = summary
+	}
+
+	if cover := propertyString(updated["photo"]); cover != blog.CoverURL {
+		payload.CoverURL = cover
+	}
+
+	// an empty list of tags still removes every tag
+	if tags := propertyStrings(updated["category"]); strings.Join(tags, "\n") != strings.Join(blog.Tags, "\n") {
+		payload.Tags = types.Tags{}
+		payload.Tags = append(payload.Tags, tags...)
+	}
+
+	if slug := service.slug(propertyString(updated["mp-slug"])); slug != blog.Slug {
+		payload.Slug = slug
+	}
+
+	publishedAt, err := propertyTime(updated["published"])
+	if err != nil {
+		return nil, err
+	}
+
+	// the source only tells the date to the second
+	if publishedAt != nil && !publishedAt.Equal(blog.PublishedAt.Truncate(time.Second)) {
+		payload.PublishedAt = publishedAt
+	}
+
+	if err := service.UtilService.ValidateInput(payload); err != "" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMicropubRequest, err)
+	}
+
+	return payload, nil
+}
+
+func (service *MicropubServiceImpl) slug(value string) string {
+	return service.UtilService.FormatToURL(strings.NewReplacer("-", " ", "_", " ").Replace(value))
+}
+
+// blogProperties are the properties of the blog as an h-entry, content is the markdown source.
+func blogProperties(blog *entities.Blog) map[string][]interface{} {
+	properties := map[string][]interface{}{
+		"name":        {blog.Title},
+		"content":     {blog.Content},
+		"post-status": {constants.MicropubDraft},
+	}
+
+	if blog.Published {
+		properties["post-status"] = []interface{}{constants.MicropubPublished}
+	}
+
+	if blog.Summary != "" {
+		properties["summary"] = []interface{}{blog.Summary}
+	}
+
+	if blog.CoverURL != "" {
+		properties["photo"] = []interface{}{blog.CoverURL}
+	}
+
+	if blog.Slug != "" {
+		properties["mp-slug"] = []interface{}{blog.Slug}
+	}
+
+	if !blog.PublishedAt.IsZero() {
+		properties["published"] = []interface{}{blog.PublishedAt.Format(time.RFC3339)}
+	}
+
+	if len(blog.Tags) > 0 {
+		categories := []interface{}{}
+		for _, tag := range blog.Tags {
+			categories = append(categories, tag)
+		}
+
+		properties["category"] = categories
+	}
+
+	return properties
+}
+
+// deleteProperties removes whole properties when given a list of names,
+// or the given values of each property when given an object.
+func deleteProperties(properties map[string][]interface{}, remove interface{}) error {
+	switch remove := remove.(type) {
+	case nil:
+		return nil
+
+	case []interface{}:
+		for _, name := range remove {
+			name, ok := name.(string)
+			if !ok {
+				return fmt.Errorf("%w: delete must list property names", ErrInvalidMicropubRequest)
+			}
+
+			delete(properties, name)
+		}
+
+	case map[string]interface{}:
+		for name, values := range remove {
+			values, ok := values.([]interface{})
+			if !ok {
+				return fmt.Errorf("%w: values to delete must be a list", ErrInvalidMicropubRequest)
+			}
+
+			kept := []interface{}{}
+
+			for _, value := range properties[name] {
+				removed := false
+
+				for _, other := range values {
+					if propertyString([]interface{}{value}) == propertyString([]interface{}{other}) {
+						removed = true
+						break
+					}
+				}
+
+				if !removed {
+					kept = append(kept, value)
+				}
+			}
+
+			properties[name] = kept
+		}
+
+	default:
+		return fmt.Errorf("%w: delete must be a list or an object", ErrInvalidMicropubRequest)
+	}
+
+	return nil
+}
+
+// propertyString returns the first value of the property,
+// nested objects such as photos with an alt text are given by their value.
+func propertyString(values []interface{}) string {
+	for _, value := range values {
+		switch value := value.(type) {
+		case string:
+			return strings.TrimSpace(value)
+		case map[string]interface{}:
+			if text, ok := value["value"].(string); ok {
+				return strings.TrimSpace(text)
+			}
+		}
+	}
+
+	return ""
+}
+
+func propertyStrings(values []interface{}) []string {
+	result := []string{}
+
+	for _, value := range values {
+		if text := propertyString([]interface{}{value}); text != "" {
+			result = append(result, text)
+		}
+	}
+
+	return result
+}
+
+// propertyContent prefers the HTML of the content, markdown renders it as it is.
+func propertyContent(values []interface{}) string {
+	for _, value := range values {
+		if value, ok := value.(map[string]interface{}); ok {
+			if content, ok := value["html"].(string); ok {
+				return strings.TrimSpace(content)
+			}
+		}
+	}
+
+	return propertyString(values)
+}
+
+func propertyTime(values []interface{}) (*time.Time, error) {
+	value := propertyString(values)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: published must be an ISO 8601 date", ErrInvalidMicropubRequest)
+	}
+
+	return &parsed, nil
+}
+
+func appendPhotos(content string, photos []string) string {
+	for _, photo := range photos {
+		if content != "" {
+			content += "\n\n"
+		}
+
+		content += "![](" + photo + ")"
+	}
+
+	return content
+}
+
+// noteTitle is the beginning of the text of the content, cut at a word.
+func noteTitle(content string) string {
+	text := strings.Join(strings.Fields(html.UnescapeString(notePolicy.Sanitize(content))), " ")
+	if text == "" {
+		return "Untitled"
+	}
+
+	if utf8.RuneCountInString(text) <= constants.MicropubTitleLength {
+		return text
+	}
+
+	runes := []rune(text)
+	title := string(runes[:constants.MicropubTitleLength])
+
+	if cut := strings.LastIndex(title, " "); cut > 0 {
+		title = title[:cut]
+	}
+
+	return title + "…"
+}
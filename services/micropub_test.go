@@ -0,0 +1,359 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/inputs"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+var micropubBlogRepoTest = repositories.BlogRepoMock{}
+var micropubUserRepoTest = repositories.UserRepoMock{}
+var micropubMediaRepoTest = repositories.MediaRepoMock{}
+var micropubServiceTest = MicropubServiceImpl{
+	UtilService: &utilService,
+	BlogService: &BlogServiceImpl{
+		UtilService:    &utilService,
+		Repository:     &micropubBlogRepoTest,
+		UserRepository: &micropubUserRepoTest,
+	},
+	BlogRepository: &micropubBlogRepoTest,
+	Repository:     &micropubMediaRepoTest,
+	ServerURL:      "https://api.example.com",
+}
+
+const micropubUserID = "example-of-user-id"
+const micropubBlogID = "example-of-blog-id"
+
+var micropubPage = &entities.SafeBlogAuthor{
+	SafeBlog: entities.SafeBlog{ID: micropubBlogID, Slug: "hello"},
+	Author:   entities.SafeUser{Username: "author"},
+}
+
+func resetMicropubMocks() {
+	micropubBlogRepoTest.Mock.ExpectedCalls = nil
+	micropubBlogRepoTest.Mock.Calls = nil
+	micropubUserRepoTest.Mock.ExpectedCalls = nil
+	micropubMediaRepoTest.Mock.ExpectedCalls = nil
+}
+
+func micropubDraft() *entities.Blog {
+	return &entities.Blog{
+		ID:              micropubBlogID,
+		AuthorID:        micropubUserID,
+		Title:           "Hello",
+		Content:         "Hello world",
+		State:           constants.BlogDraft,
+		RendererVersion: constants.RendererVersion,
+		Version:         1,
+	}
+}
+
+func TestCreatePost(t *testing.T) {
+	t.Setenv("CLIENT_URL", "https://blog.example.com")
+
+	note := func(properties map[string][]interface{}) *types.MicropubRequest {
+		return &types.MicropubRequest{
+			MicropubEntry: types.MicropubEntry{
+				Type:       []string{"h-entry"},
+				Properties: properties,
+			},
+		}
+	}
+
+	t.Run("Should create and publish a note of a reviewer", func(t *testing.T) {
+		t.Cleanup(resetMicropubMocks)
+
+		blog := micropubDraft()
+
+		micropubBlogRepoTest.Mock.On("CreateBlog", mock.MatchedBy(func(created *entities.Blog) bool {
+			return created.Title == "Just setting up my blog" &&
+				created.Content == "Just setting up my blog\n\n![](https://cdn.example/2.jpg)" &&
+				created.CoverURL == "https://cdn.example/1.jpg" &&
+				strings.Join(created.Tags, ",") == "indieweb,go" &&
+				created.AuthorID == micropubUserID
+		})).Return(blog, nil).Once()
+		micropubBlogRepoTest.Mock.On("GetByIDAndAuthor", micropubBlogID, micropubUserID).Return(blog, nil)
+		micropubUserRepoTest.Mock.On("IsReviewer", micropubUserID).Return(true, nil)
+		micropubBlogRepoTest.Mock.On("GetCurrentUserSlugs", "hello", micropubUserID).Return([]entities.Blog{}, nil)
+		micropubBlogRepoTest.Mock.On("TransitionBlog", blog, mock.Anything).Return(true, nil).Once()
+		micropubBlogRepoTest.Mock.On("GetBlog", &types.GetBlogOpts{UseID: micropubBlogID, Published: true}).Return(micropubPage, nil)
+
+		result, err := micropubServiceTest.CreatePost(note(map[string][]interface{}{
+			"content":  {"Just setting up my blog"},
+			"category": {"indieweb", "go"},
+			"photo":    {"https://cdn.example/1.jpg", map[string]interface{}{"value": "https://cdn.example/2.jpg", "alt": "A photo"}},
+		}), micropubUserID, "create")
+
+		require.Nil(t, err)
+		assert.Equal(t, "https://blog.example.com/blog/author/hello", result.URL)
+		assert.Equal(t, constants.BlogPublished, blog.State)
+	})
+
+	t.Run("Should publish the post of an author who is not a reviewer", func(t *testing.T) {
+		t.Cleanup(resetMicropubMocks)
+
+		blog := micropubDraft()
+
+		micropubBlogRepoTest.Mock.On("GetCurrentUserSlugs", "hello", micropubUserID).Return([]entities.Blog{}, nil)
+		micropubBlogRepoTest.Mock.On("CreateBlog", mock.Anything).Return(blog, nil).Once()
+		micropubBlogRepoTest.Mock.On("GetByIDAndAuthor", micropubBlogID, micropubUserID).Return(blog, nil)
+		micropubUserRepoTest.Mock.On("IsReviewer", micropubUserID).Return(false, nil)
+		micropubBlogRepoTest.Mock.On("TransitionBlog", blog, mock.MatchedBy(func(transition *entities.BlogTransition) bool {
+			return transition.ToState == constants.BlogPublished
+		})).Return(true, nil).Once()
+		micropubBlogRepoTest.Mock.On("GetBlog", &types.GetBlogOpts{UseID: micropubBlogID, Published: true}).Return(micropubPage, nil)
+
+		result, err := micropubServiceTest.CreatePost(note(map[string][]interface{}{
+			"name":    {"Hello"},
+			"content": {map[string]interface{}{"html": "<p>Hello <b>world</b></p>"}},
+			"mp-slug": {"Hello"},
+		}), micropubUserID, "create")
+
+		require.Nil(t, err)
+		assert.Equal(t, "https://blog.example.com/blog/author/hello", result.URL)
+		assert.Equal(t, constants.BlogPublished, blog.State)
+	})
+
+	t.Run("Should only create drafts with the draft scope", func(t *testing.T) {
+		t.Cleanup(resetMicropubMocks)
+
+		_, err := micropubServiceTest.CreatePost(note(map[string][]interface{}{
+			"content": {"Hello"},
+		}), micropubUserID, "draft media")
+
+		assert.ErrorIs(t, err, ErrInsufficientScope)
+
+		micropubBlogRepoTest.Mock.On("CreateBlog", mock.Anything).Return(micropubDraft(), nil).Once()
+
+		result, err := micropubServiceTest.CreatePost(note(map[string][]interface{}{
+			"content":     {"Hello"},
+			"post-status": {"draft"},
+		}), micropubUserID, "draft media")
+
+		require.Nil(t, err)
+		assert.Equal(t, "https://api.example.com/micropub/posts/"+micropubBlogID, result.URL)
+		micropubBlogRepoTest.Mock.AssertNotCalled(t, "TransitionBlog", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Should refuse other post types and invalid properties", func(t *testing.T) {
+		request := note(map[string][]interface{}{"name": {"Party"}})
+		request.Type = []string{"h-event"}
+
+		_, err := micropubServiceTest.CreatePost(request, micropubUserID, "create")
+		assert.ErrorIs(t, err, ErrInvalidMicropubRequest)
+
+		_, err = micropubServiceTest.CreatePost(note(map[string][]interface{}{
+			"content":   {"Hello"},
+			"published": {"yesterday"},
+		}), micropubUserID, "create")
+		assert.ErrorIs(t, err, ErrInvalidMicropubRequest)
+	})
+}
+
+func TestUpdatePost(t *testing.T) {
+	t.Setenv("CLIENT_URL", "https://blog.example.com")
+
+	postURL := "https://blog.example.com/blog/author/hello"
+
+	published := func() *entities.Blog {
+		blog := micropubDraft()
+		blog.State = constants.BlogPublished
+		blog.Published = true
+		blog.Slug = "hello"
+		blog.Summary = "Greetings"
+		blog.Tags = types.Tags{"indieweb", "go"}
+		blog.Version = 3
+
+		return blog
+	}
+
+	setup := func(blog *entities.Blog) {
+		micropubBlogRepoTest.Mock.On("GetBlog", &types.GetBlogOpts{BlogAuthor: "author", BlogSlug: "hello"}).Return(micropubPage, nil)
+		micropubBlogRepoTest.Mock.On("GetBlog", &types.GetBlogOpts{UseID: micropubBlogID, Published: true}).Return(micropubPage, nil)
+		micropubBlogRepoTest.Mock.On("GetByIDAndAuthor", micropubBlogID, micropubUserID).Return(blog, nil)
+	}
+
+	t.Run("Should replace, add and delete properties", func(t *testing.T) {
+		t.Cleanup(resetMicropubMocks)
+
+		setup(published())
+		micropubBlogRepoTest.Mock.On("UpdateBlog", micropubBlogID, 3, mock.MatchedBy(func(safe *inputs.SafeUpdateBlogInput) bool {
+			return safe.Content == "Hello again" && safe.Title == "" && safe.Summary == "" &&
+				strings.Join(safe.Tags, ",") == "go,micropub" && safe.Version == 4
+		})).Return(true, nil).Once()
+
+		result, err := micropubServiceTest.UpdatePost(&types.MicropubRequest{
+			Action:  "update",
+			URL:     postURL,
+			Replace: map[string][]interface{}{"content": {"Hello again"}},
+			Add:     map[string][]interface{}{"category": {"micropub"}},
+			Delete:  map[string]interface{}{"category": []interface{}{"indieweb"}},
+		}, micropubUserID, "update")
+
+		require.Nil(t, err)
+		assert.Equal(t, postURL, result.URL)
+		micropubBlogRepoTest.Mock.AssertExpectations(t)
+	})
+
+	t.Run("Should remove every tag", func(t *testing.T) {
+		t.Cleanup(resetMicropubMocks)
+
+		setup(published())
+		micropubBlogRepoTest.Mock.On("UpdateBlog", micropubBlogID, 3, mock.MatchedBy(func(safe *inputs.SafeUpdateBlogInput) bool {
+			return safe.Tags != nil && len(safe.Tags) == 0
+		})).Return(true, nil).Once()
+
+		_, err := micropubServiceTest.UpdatePost(&types.MicropubRequest{
+			Action: "update",
+			URL:    postURL,
+			Delete: []interface{}{"category"},
+		}, micropubUserID, "update")
+
+		require.Nil(t, err)
+	})
+
+	t.Run("Should refuse removing properties blogs cannot go without", func(t *testing.T) {
+		t.Cleanup(resetMicropubMocks)
+
+		setup(published())
+
+		_, err := micropubServiceTest.UpdatePost(&types.MicropubRequest{
+			Action: "update",
+			URL:    postURL,
+			Delete: []interface{}{"summary"},
+		}, micropubUserID, "update")
+
+		assert.ErrorIs(t, err, ErrInvalidMicropubRequest)
+		micropubBlogRepoTest.Mock.AssertNotCalled(t, "UpdateBlog", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Should refuse posts of others and of other sites", func(t *testing.T) {
+		t.Cleanup(resetMicropubMocks)
+
+		micropubBlogRepoTest.Mock.On("GetBlog", &types.GetBlogOpts{BlogAuthor: "author", BlogSlug: "hello"}).Return(micropubPage, nil)
+		micropubBlogRepoTest.Mock.On("GetByIDAndAuthor", micropubBlogID, "someone-else").Return(nil, errors.New("record not found"))
+
+		for _, target := range []string{postURL, "https://elsewhere.example/blog/author/hello"} {
+			_, err := micropubServiceTest.UpdatePost(&types.MicropubRequest{
+				Action:  "update",
+				URL:     target,
+				Replace: map[string][]interface{}{"content": {"Hacked"}},
+			}, "someone-else", "update")
+
+			assert.ErrorIs(t, err, ErrPostNotFound, target)
+		}
+	})
+
+	t.Run("Should need the update scope", func(t *testing.T) {
+		_, err := micropubServiceTest.UpdatePost(&types.MicropubRequest{Action: "update", URL: postURL}, micropubUserID, "create delete")
+
+		assert.ErrorIs(t, err, ErrInsufficientScope)
+	})
+}
+
+func TestGetSource(t *testing.T) {
+	t.Setenv("CLIENT_URL", "https://blog.example.com")
+	t.Cleanup(resetMicropubMocks)
+
+	blog := micropubDraft()
+	blog.Tags = types.Tags{"go"}
+
+	micropubBlogRepoTest.Mock.On("GetByIDAndAuthor", micropubBlogID, micropubUserID).Return(blog, nil)
+
+	t.Run("Should return every property of a draft", func(t *testing.T) {
+		source, err := micropubServiceTest.GetSource("https://api.example.com/micropub/posts/"+micropubBlogID, nil, micropubUserID)
+
+		require.Nil(t, err)
+		assert.Equal(t, []string{"h-entry"}, source.Type)
+		assert.Equal(t, []interface{}{"Hello world"}, source.Properties["content"])
+		assert.Equal(t, []interface{}{"go"}, source.Properties["category"])
+		assert.Equal(t, []interface{}{constants.MicropubDraft}, source.Properties["post-status"])
+		assert.NotContains(t, source.Properties, "published")
+	})
+
+	t.Run("Should return the asked properties only", func(t *testing.T) {
+		source, err := micropubServiceTest.GetSource("https://api.example.com/micropub/posts/"+micropubBlogID, []string{"name", "summary"}, micropubUserID)
+
+		require.Nil(t, err)
+		assert.Nil(t, source.Type)
+		assert.Equal(t, map[string][]interface{}{"name": {"Hello"}}, source.Properties)
+	})
+}
+
+func TestDeletePost(t *testing.T) {
+	t.Setenv("CLIENT_URL", "https://blog.example.com")
+
+	t.Run("Should unpublish the post", func(t *testing.T) {
+		t.Cleanup(resetMicropubMocks)
+
+		blog := micropubDraft()
+		blog.State = constants.BlogPublished
+		blog.Published = true
+
+		micropubBlogRepoTest.Mock.On("GetBlog", &types.GetBlogOpts{BlogAuthor: "author", BlogSlug: "hello"}).Return(micropubPage, nil)
+		micropubBlogRepoTest.Mock.On("GetByIDAndAuthor", micropubBlogID, micropubUserID).Return(blog, nil)
+		micropubUserRepoTest.Mock.On("IsReviewer", micropubUserID).Return(false, nil)
+		micropubBlogRepoTest.Mock.On("TransitionBlog", blog, mock.Anything).Return(true, nil).Once()
+
+		err := micropubServiceTest.DeletePost("https://blog.example.com/blog/author/hello", micropubUserID, "delete")
+
+		require.Nil(t, err)
+		assert.Equal(t, constants.BlogDraft, blog.State)
+		assert.False(t, blog.Published)
+	})
+
+	t.Run("Should leave drafts as they are", func(t *testing.T) {
+		t.Cleanup(resetMicropubMocks)
+
+		micropubBlogRepoTest.Mock.On("GetByIDAndAuthor", micropubBlogID, micropubUserID).Return(micropubDraft(), nil)
+
+		err := micropubServiceTest.DeletePost("https://api.example.com/micropub/posts/"+micropubBlogID, micropubUserID, "delete")
+
+		assert.Nil(t, err)
+		micropubBlogRepoTest.Mock.AssertNotCalled(t, "TransitionBlog", mock.Anything, mock.Anything)
+	})
+}
+
+func TestUploadMedia(t *testing.T) {
+	t.Cleanup(resetMicropubMocks)
+
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	t.Run("Should store images", func(t *testing.T) {
+		micropubMediaRepoTest.Mock.On("CreateMedia", mock.MatchedBy(func(media *entities.MediaFile) bool {
+			return media.ContentType == "image/png" && media.Size == len(png) && media.UserID == micropubUserID
+		})).Return(&entities.MediaFile{ID: "example-of-media-id"}, nil).Once()
+
+		location, err := micropubServiceTest.UploadMedia(png, micropubUserID, "media")
+
+		require.Nil(t, err)
+		assert.Equal(t, "https://api.example.com/micropub/media/example-of-media-id", location)
+	})
+
+	t.Run("Should refuse other files", func(t *testing.T) {
+		_, err := micropubServiceTest.UploadMedia([]byte("<html><script>alert(1)</script></html>"), micropubUserID, "media")
+		assert.ErrorIs(t, err, ErrUnsupportedMedia)
+
+		_, err = micropubServiceTest.UploadMedia(png, micropubUserID, "update")
+		assert.ErrorIs(t, err, ErrInsufficientScope)
+	})
+}
+
+func TestNoteTitle(t *testing.T) {
+	t.Run("Should title notes after the beginning of their text", func(t *testing.T) {
+		assert.Equal(t, "Hello world & more", noteTitle("<p>Hello <b>world</b> &amp; more</p>"))
+		assert.Equal(t, "Untitled", noteTitle(""))
+
+		title := noteTitle(strings.Repeat("word ", 30))
+		assert.Equal(t, strings.Repeat("word ", 11)+"word…", title)
+	})
+}
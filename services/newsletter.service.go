@@ -0,0 +1,378 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/inputs"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+var (
+	confirmTextTemplate = texttemplate.Must(texttemplate.New("confirm").Parse(
+		`Please confirm your subscription to {{.Name}} by opening the link below:
+
+{{.ConfirmURL}}
+
+If you did not ask for this, simply ignore this email.
+`))
+
+	confirmHTMLTemplate = htmltemplate.Must(htmltemplate.New("confirm").Parse(
+		`<p>Please confirm your subscription to {{.Name}}:</p>
+<p><a href="{{.ConfirmURL}}">Confirm subscription</a></p>
+<p><small>If you did not ask for this, simply ignore this email.</small></p>
+`))
+
+	issueTextTemplate = texttemplate.Must(texttemplate.New("issue").Parse(
+		`{{.Title}}
+by {{.Author}}
+
+Read it online: {{.URL}}
+
+{{.Markdown}}
+
+--
+Unsubscribe: {{.UnsubscribeURL}}
+`))
+
+	issueHTMLTemplate = htmltemplate.Must(htmltemplate.New("issue").Parse(
+		`<h1><a href="{{.URL}}">{{.Title}}</a></h1>
+<p>by {{.Author}}</p>
+{{.Content}}
+<hr>
+<p><small><a href="{{.URL}}">Read it online</a> &middot; <a href="{{.UnsubscribeURL}}">Unsubscribe</a></small></p>
+`))
+)
+
+type NewsletterService interface {
+	// Subscribe starts the double opt-in by emailing a confirmation link.
+	Subscribe(payload *inputs.SubscribeNewsletterInput) error
+	Confirm(token string) error
+	Unsubscribe(token string) error
+
+	// ExportSubscribers returns the confirmed subscribers of the author as CSV,
+	// an empty authorID exports the site-wide subscribers.
+	ExportSubscribers(authorID string) ([]byte, error)
+
+	// QueueIssue schedules the published blog to be sent to the newsletter.
+	QueueIssue(blogID string) error
+
+	// SendPendingIssues sends every queued issue, it is safe to run on multiple instances.
+	SendPendingIssues() error
+
+	// RunSendingJob periodically sends pending issues until the context is done.
+	RunSendingJob(ctx context.Context)
+}
+
+type NewsletterServiceImpl struct {
+	Mailer         Mailer
+	UtilService    UtilService
+	Repository     repositories.NewsletterRepository
+	BlogRepository repositories.BlogRepository
+	UserRepository repositories.UserRepository
+}
+
+// SubscribeEvents registers the newsletter service to the events it sends issues for.
+func (service *NewsletterServiceImpl) SubscribeEvents(events EventService) {
+	events.Subscribe(constants.EventBlogPublished, func(event types.Event) {
+		if err := service.QueueIssue(event.BlogID); err != nil {
+			log.Println("Error queueing newsletter issue:", err)
+		}
+	})
+}
+
+func (service *NewsletterServiceImpl) Subscribe(payload *inputs.SubscribeNewsletterInput) error {
+	var authorID string
+
+	name := "every author"
+
+	if payload.Author != "" {
+		author, err := service.UserRepository.FindByUsername(payload.Author)
+		if err != nil {
+			return err
+		}
+
+		authorID = author.ID
+		name = author.Username
+	}
+
+	email := strings.ToLower(strings.TrimSpace(payload.Email))
+
+	subscriber, err := service.Repository.FindSubscriber(email, authorID)
+	if err != nil && err.Error() != "404" {
+		return err
+	}
+
+	if err != nil {
+		// first time subscribing
+		subscriber = &entities.Subscriber{
+			Email:    email,
+			AuthorID: authorID,
+			Token:    service.UtilService.GenerateRandomID(32),
+		}
+	} else if subscriber.ConfirmedAt != nil && subscriber.UnsubscribedAt == nil {
+		// already subscribed, do not reveal it to whoever is asking
+		return nil
+	}
+
+	// anyone can enter the address, do not flood its inbox with confirmations
+	if subscriber.ConfirmationSentAt != nil && time.Since(*subscriber.ConfirmationSentAt) < constants.NewsletterConfirmationCooldown {
+		return nil
+	}
+
+	// pending subscriptions keep their link, so any of the emails sent confirms them
+	if subscriber.ConfirmToken == "" || subscriber.UnsubscribedAt != nil {
+		subscriber.ConfirmToken = service.UtilService.GenerateRandomID(32)
+	}
+
+	now := time.Now()
+	subscriber.ConfirmationSentAt = &now
+
+	// resubscribing requires confirming again
+	subscriber.ConfirmedAt = nil
+	subscriber.UnsubscribedAt = nil
+
+	if err := service.Repository.SaveSubscriber(subscriber); err != nil {
+		return err
+	}
+
+	data := struct {
+		Name       string
+		ConfirmURL string
+	}{
+		Name:       name,
+		ConfirmURL: newsletterURL("confirm", subscriber.ConfirmToken),
+	}
+
+	mail, err := renderMail(confirmTextTemplate, confirmHTMLTemplate, data)
+	if err != nil {
+		return err
+	}
+
+	mail.To = subscriber.Email
+	mail.Subject = "Confirm your subscription to " + name
+
+	return service.Mailer.Send(mail)
+}
+
+func (service *NewsletterServiceImpl) Confirm(token string) error {
+	subscriber, err := service.Repository.FindSubscriberByConfirmToken(token)
+	if err != nil {
+		return err
+	}
+
+	if subscriber.UnsubscribedAt != nil {
+		return errors.New("Subscription was cancelled")
+	}
+
+	// confirming twice is a no-op
+	if subscriber.ConfirmedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	subscriber.ConfirmedAt = &now
+
+	if err := service.Repository.SaveSubscriber(subscriber); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (service *NewsletterServiceImpl) Unsubscribe(token string) error {
+	subscriber, err := service.Repository.FindSubscriberByToken(token)
+	if err != nil {
+		return err
+	}
+
+	if subscriber.UnsubscribedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	subscriber.UnsubscribedAt = &now
+
+	if err := service.Repository.SaveSubscriber(subscriber); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (service *NewsletterServiceImpl) ExportSubscribers(authorID string) ([]byte, error) {
+	subscribers, err := service.Repository.GetSubscribers(authorID)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	writer := csv.NewWriter(&buf)
+	writer.Write([]string{"email", "subscribed_at", "confirmed_at"})
+
+	for _, subscriber := range subscribers {
+		var confirmedAt string
+		if subscriber.ConfirmedAt != nil {
+			confirmedAt = subscriber.ConfirmedAt.UTC().Format(time.RFC3339)
+		}
+
+		writer.Write([]string{
+			subscriber.Email,
+			subscriber.CreatedAt.UTC().Format(time.RFC3339),
+			confirmedAt,
+		})
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (service *NewsletterServiceImpl) QueueIssue(blogID string) error {
+	if err := service.Repository.CreateIssue(blogID); err != nil {
+		return err
+	}
+
+	// send right away instead of waiting for the next tick
+	go func() {
+		if err := service.SendPendingIssues(); err != nil {
+			log.Println("Error sending newsletter issues:", err)
+		}
+	}()
+
+	return nil
+}
+
+func (service *NewsletterServiceImpl) SendPendingIssues() error {
+	for {
+		issue, err := service.Repository.ClaimPendingIssue(constants.NewsletterIssueTimeout)
+		if err != nil {
+			return err
+		}
+
+		// nothing left to send
+		if issue == nil {
+			return nil
+		}
+
+		sent, err := service.sendIssue(issue)
+		if err != nil {
+			// leave the issue claimed, it is retried once the claim times out
+			log.Println("Error sending newsletter issue:", err)
+			continue
+		}
+
+		if err := service.Repository.MarkIssueSent(issue.BlogID, sent); err != nil {
+			return err
+		}
+	}
+}
+
+func (service *NewsletterServiceImpl) sendIssue(issue *entities.NewsletterIssue) (int, error) {
+	blog, err := service.BlogRepository.GetBlog(&types.GetBlogOpts{
+		UseID:          issue.BlogID,
+		IncludeContent: true,
+		Published:      true,
+	})
+	if err != nil {
+		// the blog got unpublished or deleted before the issue went out
+		return 0, nil
+	}
+
+	recipients, err := service.Repository.GetIssueRecipients(blog.Author.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	// the blog is rendered and sanitized once saved, only the unsubscribe link differs per subscriber
+	content := htmltemplate.HTML(blog.ContentHTML)
+	blogURL := service.UtilService.BlogURL(blog.Author.Username, blog.Slug)
+
+	// readers subscribed to both the author and the whole site only get it once,
+	// author subscriptions come first so the unsubscribe link targets them
+	seen := make(map[string]struct{})
+	var sent int
+
+	for _, recipient := range recipients {
+		if _, exist := seen[recipient.Email]; exist {
+			continue
+		}
+		seen[recipient.Email] = struct{}{}
+
+		unsubscribeURL := newsletterURL("unsubscribe", recipient.Token)
+
+		data := struct {
+			Title          string
+			Author         string
+			URL            string
+			Markdown       string
+			Content        htmltemplate.HTML
+			UnsubscribeURL string
+		}{
+			Title:          blog.Title,
+			Author:         blog.Author.Username,
+			URL:            blogURL,
+			Markdown:       blog.Content,
+			Content:        content,
+			UnsubscribeURL: unsubscribeURL,
+		}
+
+		mail, err := renderMail(issueTextTemplate, issueHTMLTemplate, data)
+		if err != nil {
+			return sent, err
+		}
+
+		mail.To = recipient.Email
+		mail.Subject = blog.Title
+		mail.Headers = unsubscribeHeaders(unsubscribeURL)
+
+		// one failing recipient should not stop the others
+		if err := service.Mailer.Send(mail); err != nil {
+			log.Println("Error sending newsletter email:", err)
+			continue
+		}
+
+		sent++
+	}
+
+	return sent, nil
+}
+
+func (service *NewsletterServiceImpl) RunSendingJob(ctx context.Context) {
+	ticker := time.NewTicker(constants.NewsletterJobInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := service.SendPendingIssues(); err != nil {
+				log.Println("Error sending newsletter issues:", err)
+			}
+		}
+	}
+}
+
+func newsletterURL(action string, token string) string {
+	SERVER_URL := os.Getenv("SERVER_URL")
+
+	return fmt.Sprintf("%s/newsletter/%s?token=%s", SERVER_URL, action, url.QueryEscape(token))
+}
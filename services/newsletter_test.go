@@ -0,0 +1,373 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/inputs"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+var newsletterRepoTest = repositories.NewsletterRepoMock{}
+var newsletterBlogRepoTest = repositories.BlogRepoMock{}
+var newsletterUserRepoTest = repositories.UserRepoMock{}
+
+func newNewsletterServiceTest(mailer Mailer) *NewsletterServiceImpl {
+	return &NewsletterServiceImpl{
+		Mailer:         mailer,
+		UtilService:    &utilService,
+		Repository:     &newsletterRepoTest,
+		BlogRepository: &newsletterBlogRepoTest,
+		UserRepository: &newsletterUserRepoTest,
+	}
+}
+
+func TestNewsletterSubscribe(t *testing.T) {
+	t.Setenv("SERVER_URL", "https://server.example.com")
+
+	t.Run("Should create a pending subscriber and email a confirmation link", func(t *testing.T) {
+		mailer := &recordingMailer{}
+		service := newNewsletterServiceTest(mailer)
+
+		firstMock := newsletterRepoTest.Mock.On("FindSubscriber", "reader@example.com", "").Return(nil, errors.New("404"))
+		secondMock := newsletterRepoTest.Mock.On("SaveSubscriber", mock.Anything).Return(nil)
+
+		err := service.Subscribe(&inputs.SubscribeNewsletterInput{
+			Email: "  Reader@Example.com ",
+		})
+
+		require.Nil(t, err)
+		require.Len(t, mailer.mails, 1)
+
+		saved := newsletterRepoTest.Mock.Calls[len(newsletterRepoTest.Mock.Calls)-1].Arguments.Get(0).(*entities.Subscriber)
+		assert.Equal(t, "reader@example.com", saved.Email)
+		assert.Nil(t, saved.ConfirmedAt)
+		assert.NotNil(t, saved.ConfirmationSentAt)
+		assert.Len(t, saved.Token, 32)
+		assert.Len(t, saved.ConfirmToken, 32)
+		assert.NotEqual(t, saved.Token, saved.ConfirmToken)
+
+		// the unsubscribe token is only sent along issues
+		assert.Equal(t, "reader@example.com", mailer.mails[0].To)
+		assert.Contains(t, mailer.mails[0].Text, "https://server.example.com/newsletter/confirm?token="+saved.ConfirmToken)
+		assert.NotContains(t, mailer.mails[0].Text, saved.Token)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			newsletterRepoTest.Mock.Calls = nil
+		})
+	})
+
+	t.Run("Should not overwrite the subscriber when looking it up fails", func(t *testing.T) {
+		mailer := &recordingMailer{}
+		service := newNewsletterServiceTest(mailer)
+
+		firstMock := newsletterRepoTest.Mock.On("FindSubscriber", "reader@example.com", "").Return(nil, errors.New("Something went wrong"))
+
+		err := service.Subscribe(&inputs.SubscribeNewsletterInput{
+			Email: "reader@example.com",
+		})
+
+		assert.EqualError(t, err, "Something went wrong")
+		assert.Empty(t, mailer.mails)
+		newsletterRepoTest.Mock.AssertNotCalled(t, "SaveSubscriber", mock.Anything)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			newsletterRepoTest.Mock.Calls = nil
+		})
+	})
+
+	t.Run("Should silently ignore readers already subscribed", func(t *testing.T) {
+		mailer := &recordingMailer{}
+		service := newNewsletterServiceTest(mailer)
+
+		confirmedAt := time.Now()
+
+		firstMock := newsletterRepoTest.Mock.On("FindSubscriber", "reader@example.com", "").Return(&entities.Subscriber{
+			Email:       "reader@example.com",
+			ConfirmedAt: &confirmedAt,
+		}, nil)
+
+		err := service.Subscribe(&inputs.SubscribeNewsletterInput{
+			Email: "reader@example.com",
+		})
+
+		assert.Nil(t, err)
+		assert.Empty(t, mailer.mails)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+		})
+	})
+
+	t.Run("Should not email another confirmation right after the last one", func(t *testing.T) {
+		mailer := &recordingMailer{}
+		service := newNewsletterServiceTest(mailer)
+
+		sentAt := time.Now().Add(-time.Minute)
+
+		firstMock := newsletterRepoTest.Mock.On("FindSubscriber", "reader@example.com", "").Return(&entities.Subscriber{
+			Email:              "reader@example.com",
+			ConfirmToken:       "example-of-confirm-token",
+			ConfirmationSentAt: &sentAt,
+		}, nil)
+
+		err := service.Subscribe(&inputs.SubscribeNewsletterInput{
+			Email: "reader@example.com",
+		})
+
+		assert.Nil(t, err)
+		assert.Empty(t, mailer.mails)
+		newsletterRepoTest.Mock.AssertNotCalled(t, "SaveSubscriber", mock.Anything)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+		})
+	})
+
+	t.Run("Should email the same link again once the cooldown elapsed", func(t *testing.T) {
+		mailer := &recordingMailer{}
+		service := newNewsletterServiceTest(mailer)
+
+		sentAt := time.Now().Add(-constants.NewsletterConfirmationCooldown - time.Minute)
+
+		firstMock := newsletterRepoTest.Mock.On("FindSubscriber", "reader@example.com", "").Return(&entities.Subscriber{
+			Email:              "reader@example.com",
+			ConfirmToken:       "example-of-confirm-token",
+			ConfirmationSentAt: &sentAt,
+		}, nil)
+		secondMock := newsletterRepoTest.Mock.On("SaveSubscriber", mock.Anything).Return(nil)
+
+		err := service.Subscribe(&inputs.SubscribeNewsletterInput{
+			Email: "reader@example.com",
+		})
+
+		require.Nil(t, err)
+		require.Len(t, mailer.mails, 1)
+		assert.Contains(t, mailer.mails[0].Text, "token=example-of-confirm-token")
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			newsletterRepoTest.Mock.Calls = nil
+		})
+	})
+
+	t.Run("Should return error if the author does not exist", func(t *testing.T) {
+		mailer := &recordingMailer{}
+		service := newNewsletterServiceTest(mailer)
+
+		firstMock := newsletterUserRepoTest.Mock.On("FindByUsername", "example-of-invalid-username").Return(nil)
+
+		err := service.Subscribe(&inputs.SubscribeNewsletterInput{
+			Email:  "reader@example.com",
+			Author: "example-of-invalid-username",
+		})
+
+		assert.EqualError(t, err, "Record not found")
+		assert.Empty(t, mailer.mails)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+		})
+	})
+}
+
+func TestNewsletterConfirm(t *testing.T) {
+	t.Run("Should confirm a pending subscriber", func(t *testing.T) {
+		service := newNewsletterServiceTest(&recordingMailer{})
+		subscriber := &entities.Subscriber{ConfirmToken: "example-of-confirm-token"}
+
+		firstMock := newsletterRepoTest.Mock.On("FindSubscriberByConfirmToken", "example-of-confirm-token").Return(subscriber, nil)
+		secondMock := newsletterRepoTest.Mock.On("SaveSubscriber", subscriber).Return(nil)
+
+		err := service.Confirm("example-of-confirm-token")
+
+		assert.Nil(t, err)
+		assert.NotNil(t, subscriber.ConfirmedAt)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+
+	t.Run("Should not confirm a cancelled subscription", func(t *testing.T) {
+		service := newNewsletterServiceTest(&recordingMailer{})
+		unsubscribedAt := time.Now()
+
+		firstMock := newsletterRepoTest.Mock.On("FindSubscriberByConfirmToken", "example-of-confirm-token").Return(&entities.Subscriber{
+			UnsubscribedAt: &unsubscribedAt,
+		}, nil)
+
+		err := service.Confirm("example-of-confirm-token")
+
+		assert.EqualError(t, err, "Subscription was cancelled")
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+		})
+	})
+}
+
+func TestNewsletterUnsubscribe(t *testing.T) {
+	t.Run("Should mark the subscriber as unsubscribed", func(t *testing.T) {
+		service := newNewsletterServiceTest(&recordingMailer{})
+		subscriber := &entities.Subscriber{Token: "example-of-token"}
+
+		firstMock := newsletterRepoTest.Mock.On("FindSubscriberByToken", "example-of-token").Return(subscriber, nil)
+		secondMock := newsletterRepoTest.Mock.On("SaveSubscriber", subscriber).Return(nil)
+
+		err := service.Unsubscribe("example-of-token")
+
+		assert.Nil(t, err)
+		assert.NotNil(t, subscriber.UnsubscribedAt)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+
+	t.Run("Should return error if the token is unknown", func(t *testing.T) {
+		service := newNewsletterServiceTest(&recordingMailer{})
+
+		firstMock := newsletterRepoTest.Mock.On("FindSubscriberByToken", "example-of-wrong-token").Return(nil, errors.New("Record not found"))
+
+		err := service.Unsubscribe("example-of-wrong-token")
+
+		assert.EqualError(t, err, "Record not found")
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+		})
+	})
+}
+
+func TestExportSubscribers(t *testing.T) {
+	t.Run("Should export subscribers as CSV", func(t *testing.T) {
+		service := newNewsletterServiceTest(&recordingMailer{})
+
+		createdAt := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+		confirmedAt := time.Date(2024, time.January, 2, 0, 0, 0, 0, time.UTC)
+
+		mock := newsletterRepoTest.Mock.On("GetSubscribers", "example-of-author-id").Return([]entities.Subscriber{
+			{Email: "first@example.com", CreatedAt: createdAt, ConfirmedAt: &confirmedAt},
+			{Email: "second,with-comma@example.com", CreatedAt: createdAt, ConfirmedAt: &confirmedAt},
+		}, nil)
+
+		result, err := service.ExportSubscribers("example-of-author-id")
+
+		require.Nil(t, err)
+
+		lines := strings.Split(strings.TrimSpace(string(result)), "\n")
+		require.Len(t, lines, 3)
+		assert.Equal(t, "email,subscribed_at,confirmed_at", lines[0])
+		assert.Equal(t, "first@example.com,2024-01-01T00:00:00Z,2024-01-02T00:00:00Z", lines[1])
+		assert.Equal(t, "\"second,with-comma@example.com\",2024-01-01T00:00:00Z,2024-01-02T00:00:00Z", lines[2])
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			mock.Unset()
+		})
+	})
+}
+
+func TestSendPendingIssues(t *testing.T) {
+	t.Setenv("CLIENT_URL", "https://client.example.com")
+	t.Setenv("SERVER_URL", "https://server.example.com")
+
+	blog := &entities.SafeBlogAuthor{
+		SafeBlog: entities.SafeBlog{
+			ID:          "example-of-blog-id",
+			Slug:        "example-of-slug",
+			Title:       "Example of Title",
+			Content:     "# Hello World",
+			ContentHTML: `<h1 id="hello-world">Hello World</h1>`,
+		},
+		Author: entities.SafeUser{
+			ID:       "example-of-author-id",
+			Username: "author123",
+		},
+	}
+
+	getBlogOpts := &types.GetBlogOpts{
+		UseID:          blog.ID,
+		IncludeContent: true,
+		Published:      true,
+	}
+
+	t.Run("Should send the rendered blog once per email and mark the issue as sent", func(t *testing.T) {
+		mailer := &recordingMailer{}
+		service := newNewsletterServiceTest(mailer)
+
+		// the first claim returns the issue, the following ones find nothing left
+		newsletterRepoTest.Mock.On("ClaimPendingIssue", constants.NewsletterIssueTimeout).Return(&entities.NewsletterIssue{BlogID: blog.ID}, nil).Once()
+		secondMock := newsletterRepoTest.Mock.On("ClaimPendingIssue", constants.NewsletterIssueTimeout).Return(nil, nil)
+		thirdMock := newsletterBlogRepoTest.Mock.On("GetBlog", getBlogOpts).Return(blog, nil)
+		fourthMock := newsletterRepoTest.Mock.On("GetIssueRecipients", blog.Author.ID).Return([]entities.Subscriber{
+			{Email: "reader@example.com", AuthorID: blog.Author.ID, Token: "author-token"},
+			{Email: "reader@example.com", Token: "site-token"},
+			{Email: "other@example.com", Token: "other-token"},
+		}, nil)
+		fifthMock := newsletterRepoTest.Mock.On("MarkIssueSent", blog.ID, 2).Return(nil)
+
+		err := service.SendPendingIssues()
+
+		require.Nil(t, err)
+		require.Len(t, mailer.mails, 2)
+
+		mail := mailer.mails[0]
+		assert.Equal(t, "reader@example.com", mail.To)
+		assert.Equal(t, "Example of Title", mail.Subject)
+		assert.Contains(t, mail.HTML, "<h1 id=\"hello-world\">Hello World")
+		assert.Contains(t, mail.Text, "# Hello World")
+		assert.Contains(t, mail.Text, "https://server.example.com/newsletter/unsubscribe?token=author-token")
+		assert.Contains(t, mail.Headers["List-Unsubscribe"], "author-token")
+
+		newsletterRepoTest.Mock.AssertCalled(t, "MarkIssueSent", blog.ID, 2)
+
+		t.Cleanup(func() {
+			// Cleanup mocking, this also removes the first claim since they share arguments
+			secondMock.Unset()
+			thirdMock.Unset()
+			fourthMock.Unset()
+			fifthMock.Unset()
+		})
+	})
+
+	t.Run("Should return error if claiming fails", func(t *testing.T) {
+		service := newNewsletterServiceTest(&recordingMailer{})
+
+		firstMock := newsletterRepoTest.Mock.On("ClaimPendingIssue", constants.NewsletterIssueTimeout).Return(nil, errors.New("Something went wrong"))
+
+		err := service.SendPendingIssues()
+
+		assert.Error(t, err)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+		})
+	})
+}
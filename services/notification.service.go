@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+type NotificationService interface {
+	GetNotifications(userID string, unreadOnly bool, limit int) ([]entities.Notification, int64, error)
+	MarkRead(notificationID string, userID string) error
+	MarkAllRead(userID string) error
+
+	// Notify stores a notification for the user affected by the event
+	// and pushes it to every stream of that user on every server instance.
+	Notify(event types.Event) error
+
+	// Stream returns a channel receiving the user's new notifications
+	// until the given context is done.
+	Stream(ctx context.Context, userID string) <-chan entities.Notification
+}
+
+type NotificationServiceImpl struct {
+	Repository repositories.NotificationRepository
+
+	// Redis connection used to fan out notifications across server instances,
+	// real-time delivery is disabled when it is nil.
+	PubSub redis.UniversalClient
+}
+
+// SubscribeEvents registers the notification service to every notifiable event.
+func (service *NotificationServiceImpl) SubscribeEvents(events EventService) {
+	for _, eventType := range []string{constants.EventFollow, constants.EventComment, constants.EventReaction, constants.EventCoAuthorInvite} {
+		events.Subscribe(eventType, func(event types.Event) {
+			if err := service.Notify(event); err != nil {
+				log.Println("Error creating notification:", err)
+			}
+		})
+	}
+}
+
+func (service *NotificationServiceImpl) GetNotifications(userID string, unreadOnly bool, limit int) ([]entities.Notification, int64, error) {
+	notifications, err := service.Repository.GetNotifications(userID, unreadOnly, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	unread, err := service.Repository.CountUnread(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return notifications, unread, nil
+}
+
+func (service *NotificationServiceImpl) MarkRead(notificationID string, userID string) error {
+	if err := service.Repository.MarkRead(notificationID, userID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (service *NotificationServiceImpl) MarkAllRead(userID string) error {
+	if err := service.Repository.MarkAllRead(userID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (service *NotificationServiceImpl) Notify(event types.Event) error {
+	// nobody to notify, or users acting on their own content
+	if event.UserID == "" || event.UserID == event.ActorID {
+		return nil
+	}
+
+	notification, err := service.Repository.CreateNotification(&entities.Notification{
+		UserID:  event.UserID,
+		ActorID: event.ActorID,
+		Type:    event.Type,
+		BlogID:  event.BlogID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if service.PubSub == nil {
+		return nil
+	}
+
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	// the notification is already stored, failing to push it
+	// only means the user will see it on the next fetch.
+	if err := service.PubSub.Publish(context.Background(), notificationChannel(event.UserID), payload).Err(); err != nil {
+		log.Println("Error publishing notification:", err)
+	}
+
+	return nil
+}
+
+func (service *NotificationServiceImpl) Stream(ctx context.Context, userID string) <-chan entities.Notification {
+	stream := make(chan entities.Notification)
+
+	if service.PubSub == nil {
+		go func() {
+			<-ctx.Done()
+			close(stream)
+		}()
+
+		return stream
+	}
+
+	subscription := service.PubSub.Subscribe(ctx, notificationChannel(userID))
+
+	go func() {
+		defer close(stream)
+		defer subscription.Close()
+
+		messages := subscription.Channel()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+
+				var notification entities.Notification
+				if err := json.Unmarshal([]byte(message.Payload), &notification); err != nil {
+					log.Println("Error decoding notification:", err)
+					continue
+				}
+
+				select {
+				case stream <- notification:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return stream
+}
+
+func notificationChannel(userID string) string {
+	return "notifications:" + userID
+}
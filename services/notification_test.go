@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+var notificationRepoTest = repositories.NotificationRepoMock{}
+var notificationServiceTest = NotificationServiceImpl{
+	Repository: &notificationRepoTest,
+}
+
+func TestNotify(t *testing.T) {
+	t.Run("Should store a notification for the affected user", func(t *testing.T) {
+		event := types.Event{
+			Type:    constants.EventFollow,
+			ActorID: "example-of-actor-id",
+			UserID:  "example-of-user-id",
+		}
+
+		expected := &entities.Notification{
+			UserID:  event.UserID,
+			ActorID: event.ActorID,
+			Type:    event.Type,
+		}
+
+		mock := notificationRepoTest.Mock.On("CreateNotification", expected).Return(expected, nil)
+
+		err := notificationServiceTest.Notify(event)
+
+		assert.Nil(t, err)
+		notificationRepoTest.Mock.AssertCalled(t, "CreateNotification", expected)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			mock.Unset()
+		})
+	})
+
+	t.Run("Should not notify users about their own actions", func(t *testing.T) {
+		event := types.Event{
+			Type:    constants.EventComment,
+			ActorID: "example-of-same-id",
+			UserID:  "example-of-same-id",
+		}
+
+		err := notificationServiceTest.Notify(event)
+
+		assert.Nil(t, err)
+		notificationRepoTest.Mock.AssertNotCalled(t, "CreateNotification", mock.MatchedBy(func(n *entities.Notification) bool {
+			return n.UserID == event.UserID
+		}))
+	})
+
+	t.Run("Should return error if query fails", func(t *testing.T) {
+		event := types.Event{
+			Type:    constants.EventReaction,
+			ActorID: "example-of-actor-id",
+			UserID:  "example-of-other-user-id",
+			BlogID:  "example-of-blog-id",
+		}
+
+		mock := notificationRepoTest.Mock.On("CreateNotification", mock.Anything).Return(nil, errors.New("Something went wrong"))
+
+		err := notificationServiceTest.Notify(event)
+
+		assert.NotNil(t, err)
+		assert.Error(t, err)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			mock.Unset()
+		})
+	})
+}
+
+func TestSubscribeEvents(t *testing.T) {
+	t.Run("Should create notification when an event is published", func(t *testing.T) {
+		events := InitEventService()
+		notificationServiceTest.SubscribeEvents(events)
+
+		created := make(chan *entities.Notification, 1)
+
+		mock := notificationRepoTest.Mock.On("CreateNotification", mock.Anything).Run(func(args mock.Arguments) {
+			created <- args.Get(0).(*entities.Notification)
+		}).Return(&entities.Notification{}, nil)
+
+		events.Publish(types.Event{
+			Type:    constants.EventFollow,
+			ActorID: "example-of-actor-id",
+			UserID:  "example-of-followed-id",
+		})
+
+		select {
+		case notification := <-created:
+			assert.Equal(t, "example-of-followed-id", notification.UserID)
+			assert.Equal(t, constants.EventFollow, notification.Type)
+		case <-time.After(time.Second):
+			t.Fatal("notification was never created")
+		}
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			mock.Unset()
+		})
+	})
+}
+
+func TestGetNotifications(t *testing.T) {
+	t.Run("Should return notifications with the unread count", func(t *testing.T) {
+		userID := "example-of-user-id"
+
+		expected := []entities.Notification{
+			{ID: "first", UserID: userID},
+			{ID: "second", UserID: userID, Read: true},
+		}
+
+		firstMock := notificationRepoTest.Mock.On("GetNotifications", userID, false, 20).Return(expected, nil)
+		secondMock := notificationRepoTest.Mock.On("CountUnread", userID).Return(int64(1), nil)
+
+		result, unread, err := notificationServiceTest.GetNotifications(userID, false, 20)
+
+		assert.Nil(t, err)
+		assert.Equal(t, expected, result)
+		assert.Equal(t, int64(1), unread)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+
+	t.Run("Should return error if query fails", func(t *testing.T) {
+		userID := "example-of-user-id"
+
+		firstMock := notificationRepoTest.Mock.On("GetNotifications", userID, true, 20).Return(nil, errors.New("Something went wrong"))
+
+		result, _, err := notificationServiceTest.GetNotifications(userID, true, 20)
+
+		assert.Nil(t, result)
+		assert.Error(t, err)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+		})
+	})
+}
+
+func TestMarkRead(t *testing.T) {
+	t.Run("Should mark a notification as read", func(t *testing.T) {
+		mock := notificationRepoTest.Mock.On("MarkRead", "example-of-id", "example-of-user-id").Return(nil)
+
+		err := notificationServiceTest.MarkRead("example-of-id", "example-of-user-id")
+
+		assert.Nil(t, err)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			mock.Unset()
+		})
+	})
+
+	t.Run("Should return error if notification not found", func(t *testing.T) {
+		mock := notificationRepoTest.Mock.On("MarkRead", "example-of-id", "example-of-other-user-id").Return(errors.New("Record not found"))
+
+		err := notificationServiceTest.MarkRead("example-of-id", "example-of-other-user-id")
+
+		assert.EqualError(t, err, "Record not found")
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			mock.Unset()
+		})
+	})
+}
+
+func TestMarkAllRead(t *testing.T) {
+	t.Run("Should mark every notification as read", func(t *testing.T) {
+		mock := notificationRepoTest.Mock.On("MarkAllRead", "example-of-user-id").Return(nil)
+
+		err := notificationServiceTest.MarkAllRead("example-of-user-id")
+
+		assert.Nil(t, err)
+		notificationRepoTest.Mock.AssertCalled(t, "MarkAllRead", "example-of-user-id")
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			mock.Unset()
+		})
+	})
+}
+
+func TestStream(t *testing.T) {
+	t.Run("Should close the stream when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		stream := notificationServiceTest.Stream(ctx, "example-of-user-id")
+		cancel()
+
+		select {
+		case _, ok := <-stream:
+			assert.False(t, ok)
+		case <-time.After(time.Second):
+			t.Fatal("stream was never closed")
+		}
+	})
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"resqiar.com-server/types"
+)
+
+// The functions below implement operational transformation on plain text,
+// following ot.js so its client library can be used as-is against the server.
+
+var errOperationLength = errors.New("Operation does not match the document length")
+
+// operationLengths returns the length of the document the operation applies to
+// and the length of the document it produces.
+func operationLengths(op types.TextOperation) (int, int) {
+	var base, target int
+
+	for _, component := range op {
+		switch {
+		case component.Retain > 0:
+			base += component.Retain
+			target += component.Retain
+		case component.Delete > 0:
+			base += component.Delete
+		default:
+			target += utf8.RuneCountInString(component.Insert)
+		}
+	}
+
+	return base, target
+}
+
+func appendRetain(op types.TextOperation, n int) types.TextOperation {
+	if n <= 0 {
+		return op
+	}
+
+	if last := len(op) - 1; last >= 0 && op[last].Retain > 0 {
+		op[last].Retain += n
+		return op
+	}
+
+	return append(op, types.OperationComponent{Retain: n})
+}
+
+func appendDelete(op types.TextOperation, n int) types.TextOperation {
+	if n <= 0 {
+		return op
+	}
+
+	if last := len(op) - 1; last >= 0 && op[last].Delete > 0 {
+		op[last].Delete += n
+		return op
+	}
+
+	return append(op, types.OperationComponent{Delete: n})
+}
+
+// appendInsert keeps inserts before deletes, inserting then deleting is the same
+// as deleting then inserting and having a single form makes operations comparable.
+func appendInsert(op types.TextOperation, text string) types.TextOperation {
+	if text == "" {
+		return op
+	}
+
+	last := len(op) - 1
+
+	if last >= 0 && op[last].Insert != "" {
+		op[last].Insert += text
+		return op
+	}
+
+	if last >= 0 && op[last].Delete > 0 {
+		if last > 0 && op[last-1].Insert != "" {
+			op[last-1].Insert += text
+			return op
+		}
+
+		deleted := op[last]
+		op[last] = types.OperationComponent{Insert: text}
+
+		return append(op, deleted)
+	}
+
+	return append(op, types.OperationComponent{Insert: text})
+}
+
+// applyOperation returns the document the operation turns the given document into.
+func applyOperation(document string, op types.TextOperation) (string, error) {
+	runes := []rune(document)
+
+	if base, _ := operationLengths(op); base != len(runes) {
+		return "", errOperationLength
+	}
+
+	var result strings.Builder
+	var position int
+
+	for _, component := range op {
+		switch {
+		case component.Retain > 0:
+			result.WriteString(string(runes[position : position+component.Retain]))
+			position += component.Retain
+		case component.Delete > 0:
+			position += component.Delete
+		default:
+			result.WriteString(component.Insert)
+		}
+	}
+
+	return result.String(), nil
+}
+
+// transformOperations takes two operations applying to the same document and returns them
+// rewritten to apply after each other: a then b' and b then a' lead to the same document.
+// Inserts of a go first when both insert at the same position.
+func transformOperations(a types.TextOperation, b types.TextOperation) (types.TextOperation, types.TextOperation, error) {
+	baseA, _ := operationLengths(a)
+	baseB, _ := operationLengths(b)
+
+	if baseA != baseB {
+		return nil, nil, errors.New("Operations do not apply to the same document")
+	}
+
+	var primeA, primeB types.TextOperation
+	var i, j int
+
+	// the components are consumed piece by piece, keep the remainders around
+	var componentA, componentB *types.OperationComponent
+
+	next := func(op types.TextOperation, index *int) *types.OperationComponent {
+		if *index >= len(op) {
+			return nil
+		}
+
+		component := op[*index]
+		*index++
+
+		return &component
+	}
+
+	componentA = next(a, &i)
+	componentB = next(b, &j)
+
+	for componentA != nil || componentB != nil {
+		if componentA != nil && componentA.Insert != "" {
+			primeA = appendInsert(primeA, componentA.Insert)
+			primeB = appendRetain(primeB, utf8.RuneCountInString(componentA.Insert))
+			componentA = next(a, &i)
+			continue
+		}
+
+		if componentB != nil && componentB.Insert != "" {
+			primeA = appendRetain(primeA, utf8.RuneCountInString(componentB.Insert))
+			primeB = appendInsert(primeB, componentB.Insert)
+			componentB = next(b, &j)
+			continue
+		}
+
+		// the base lengths match, so both run out at the same time
+		if componentA == nil || componentB == nil {
+			return nil, nil, errOperationLength
+		}
+
+		lengthA := componentA.Retain + componentA.Delete
+		lengthB := componentB.Retain + componentB.Delete
+
+		length := lengthA
+		if lengthB < length {
+			length = lengthB
+		}
+
+		switch {
+		case componentA.Retain > 0 && componentB.Retain > 0:
+			primeA = appendRetain(primeA, length)
+			primeB = appendRetain(primeB, length)
+		case componentA.Delete > 0 && componentB.Retain > 0:
+			primeA = appendDelete(primeA, length)
+		case componentA.Retain > 0 && componentB.Delete > 0:
+			primeB = appendDelete(primeB, length)
+		}
+
+		// both deleting the same characters leaves nothing to do for either
+
+		componentA = consume(componentA, length, func() *types.OperationComponent { return next(a, &i) })
+		componentB = consume(componentB, length, func() *types.OperationComponent { return next(b, &j) })
+	}
+
+	return primeA, primeB, nil
+}
+
+// consume shortens the retain or delete component by length, moving on once it is used up.
+func consume(component *types.OperationComponent, length int, next func() *types.OperationComponent) *types.OperationComponent {
+	if component.Retain > 0 {
+		component.Retain -= length
+		if component.Retain > 0 {
+			return component
+		}
+	} else {
+		component.Delete -= length
+		if component.Delete > 0 {
+			return component
+		}
+	}
+
+	return next()
+}
+
+// transformIndex moves a position in the document along with the operation,
+// text inserted right at the position ends up before it.
+func transformIndex(index int, op types.TextOperation) int {
+	result := index
+
+	for _, component := range op {
+		if index < 0 {
+			break
+		}
+
+		switch {
+		case component.Retain > 0:
+			index -= component.Retain
+		case component.Delete > 0:
+			if index < component.Delete {
+				result -= index
+			} else {
+				result -= component.Delete
+			}
+
+			index -= component.Delete
+		default:
+			result += utf8.RuneCountInString(component.Insert)
+		}
+	}
+
+	return result
+}
+
+// replaceOperation returns the operation turning the document from into to,
+// touching only what changed between their common prefix and suffix.
+func replaceOperation(from string, to string) types.TextOperation {
+	a := []rune(from)
+	b := []rune(to)
+
+	var prefix int
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	var suffix int
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var op types.TextOperation
+
+	op = appendRetain(op, prefix)
+	op = appendInsert(op, string(b[prefix:len(b)-suffix]))
+	op = appendDelete(op, len(a)-prefix-suffix)
+	op = appendRetain(op, suffix)
+
+	return op
+}
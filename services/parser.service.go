@@ -13,6 +13,7 @@ import (
 	"github.com/yuin/goldmark/parser"
 	html "github.com/yuin/goldmark/renderer/html"
 	"go.abhg.dev/goldmark/anchor"
+	"resqiar.com-server/types"
 )
 
 var (
@@ -48,6 +49,11 @@ var (
 
 type ParserService interface {
 	ParseMDByte(s []byte) []byte
+	ParseMDDocument(s []byte) *types.RenderedMarkdown
+
+	// ParseFrontMatter splits the YAML ("---") or TOML ("+++") front matter off the content,
+	// the front matter is nil when the content does not start with one.
+	ParseFrontMatter(s []byte) (*types.FrontMatter, []byte, error)
 }
 
 type ParserServiceImpl struct{}
@@ -73,3 +79,22 @@ func (service *ParserServiceImpl) ParseMDByte(s []byte) []byte {
 
 	return parserSanitization.SanitizeBytes(buf.Bytes())
 }
+
+func (service *ParserServiceImpl) ParseMDDocument(s []byte) *types.RenderedMarkdown {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	defer bufferPool.Put(buf)
+
+	buf.Reset()
+
+	rendered, err := renderMarkdown(parserEngine, parserSanitization, s, buf)
+	if err != nil {
+		log.Println("Error parsing MD:", err)
+		return nil
+	}
+
+	return rendered
+}
+
+func (service *ParserServiceImpl) ParseFrontMatter(s []byte) (*types.FrontMatter, []byte, error) {
+	return splitFrontMatter(s)
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"resqiar.com-server/types"
 )
 
 var parserService = ParserServiceImpl{}
@@ -55,3 +56,29 @@ func TestParseMDByte(t *testing.T) {
 		})
 	}
 }
+
+func TestParseMDDocument(t *testing.T) {
+	t.Run("Should return the HTML along with the table of contents", func(t *testing.T) {
+		generated := parserService.ParseMDDocument([]byte("# Hello World\n## Hello Again"))
+
+		require.NotNil(t, generated)
+		assert.Equal(t, string(parserService.ParseMDByte([]byte("# Hello World\n## Hello Again"))), generated.HTML)
+		assert.Equal(t, types.TableOfContents{
+			{ID: "hello-world", Text: "Hello World", Level: 1, Children: []types.Heading{
+				{ID: "hello-again", Text: "Hello Again", Level: 2},
+			}},
+		}, generated.TOC)
+		assert.Equal(t, 4, generated.WordCount)
+		assert.Equal(t, 1, generated.ReadingTime)
+	})
+}
+
+func TestParseFrontMatterBytes(t *testing.T) {
+	t.Run("Should return the content following the front matter", func(t *testing.T) {
+		matter, content, err := parserService.ParseFrontMatter([]byte("---\ntitle: Hello World\n---\n# Hello World"))
+
+		require.Nil(t, err)
+		assert.Equal(t, "Hello World", matter.Title)
+		assert.Equal(t, []byte("# Hello World"), content)
+	})
+}
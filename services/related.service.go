@@ -0,0 +1,254 @@
+package services
+
+import (
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"resqiar.com-server/entities"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+const (
+	// maximum number of neighbours stored and served for every blog
+	MaxRelatedBlogs = 5
+
+	// a scheduled recompute waits this long, covering every change made meanwhile
+	RelatedRecomputeDelay = 1 * time.Minute
+
+	// how much more a term weighs depending on where it appears
+	titleTermWeight   = 3
+	summaryTermWeight = 2
+	contentTermWeight = 1
+)
+
+var (
+	fencedCodeRegex = regexp.MustCompile("(?s)```.*?```")
+	stopWords       = map[string]struct{}{
+		"the": {}, "and": {}, "for": {}, "are": {}, "but": {}, "not": {}, "you": {}, "all": {},
+		"can": {}, "her": {}, "was": {}, "one": {}, "our": {}, "out": {}, "has": {}, "have": {},
+		"this": {}, "that": {}, "with": {}, "from": {}, "they": {}, "will": {}, "what": {},
+		"when": {}, "your": {}, "which": {}, "their": {}, "there": {}, "been": {}, "were": {},
+		"into": {}, "than": {}, "then": {}, "them": {}, "these": {}, "some": {}, "would": {},
+		"about": {}, "also": {}, "just": {}, "like": {}, "more": {}, "only": {}, "other": {},
+		"how": {}, "why": {}, "its": {}, "it's": {}, "use": {}, "using": {}, "used": {},
+		"yang": {}, "dan": {}, "dari": {}, "untuk": {}, "dengan": {}, "ini": {}, "itu": {},
+		"pada": {}, "adalah": {}, "akan": {}, "dalam": {}, "tidak": {}, "juga": {}, "kita": {},
+	}
+)
+
+type RelatedService interface {
+	// GetRelatedBlogs returns the stored nearest neighbours of a published blog.
+	GetRelatedBlogs(author string, slug string) ([]entities.SafeBlogAuthor, error)
+
+	// RecomputeRelated rebuilds the neighbours of every published blog.
+	// Since the IDF of every term shifts whenever a single blog changes,
+	// the whole corpus is always recomputed.
+	RecomputeRelated() error
+
+	// ScheduleRecompute recomputes in the background after RelatedRecomputeDelay,
+	// unless a recompute is already scheduled.
+	ScheduleRecompute()
+}
+
+type RelatedServiceImpl struct {
+	Repository     repositories.RelatedRepository
+	BlogRepository repositories.BlogRepository
+
+	// only allow one recompute at a time
+	mu sync.Mutex
+
+	// whether a recompute is waiting to run
+	scheduled atomic.Bool
+}
+
+func (service *RelatedServiceImpl) GetRelatedBlogs(author string, slug string) ([]entities.SafeBlogAuthor, error) {
+	blog, err := service.BlogRepository.GetBlog(&types.GetBlogOpts{
+		BlogAuthor: author,
+		BlogSlug:   slug,
+		Published:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	related, err := service.Repository.GetRelated(blog.ID, MaxRelatedBlogs)
+	if err != nil {
+		return nil, err
+	}
+
+	return related, nil
+}
+
+func (service *RelatedServiceImpl) RecomputeRelated() error {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	corpus, err := service.Repository.GetPublishedCorpus()
+	if err != nil {
+		return err
+	}
+
+	related := ComputeRelated(corpus, MaxRelatedBlogs)
+
+	if err := service.Repository.ReplaceRelated(related); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (service *RelatedServiceImpl) ScheduleRecompute() {
+	if !service.scheduled.CompareAndSwap(false, true) {
+		return
+	}
+
+	time.AfterFunc(RelatedRecomputeDelay, func() {
+		// changes made while recomputing schedule the next one
+		service.scheduled.Store(false)
+
+		if err := service.RecomputeRelated(); err != nil {
+			log.Println("Error recomputing related blogs:", err)
+		}
+	})
+}
+
+// ComputeRelated builds a TF-IDF vector for every blog out of its title, summary
+// and Markdown content, then returns at most k nearest neighbours per blog ID
+// ranked by cosine similarity. Blogs sharing no terms are never related.
+func ComputeRelated(blogs []entities.Blog, k int) map[string][]entities.RelatedBlog {
+	result := make(map[string][]entities.RelatedBlog, len(blogs))
+
+	// raw term frequencies for every blog
+	frequencies := make([]map[string]float64, len(blogs))
+
+	// number of blogs every term appears in
+	documentFrequency := make(map[string]int)
+
+	for i, blog := range blogs {
+		tf := make(map[string]float64)
+
+		addTerms(tf, blog.Title, titleTermWeight)
+		addTerms(tf, blog.Summary, summaryTermWeight)
+		addTerms(tf, fencedCodeRegex.ReplaceAllString(blog.Content, " "), contentTermWeight)
+
+		for term := range tf {
+			documentFrequency[term]++
+		}
+
+		frequencies[i] = tf
+	}
+
+	// turn frequencies into L2 normalized TF-IDF vectors,
+	// the cosine similarity then becomes a simple dot product
+	total := float64(len(blogs))
+	vectors := make([]map[string]float64, len(blogs))
+
+	for i, tf := range frequencies {
+		vector := make(map[string]float64, len(tf))
+		var norm float64
+
+		for term, count := range tf {
+			idf := math.Log((1+total)/(1+float64(documentFrequency[term]))) + 1
+			weight := (1 + math.Log(count)) * idf
+
+			vector[term] = weight
+			norm += weight * weight
+		}
+
+		norm = math.Sqrt(norm)
+		for term := range vector {
+			vector[term] /= norm
+		}
+
+		vectors[i] = vector
+	}
+
+	for i, blog := range blogs {
+		var candidates []entities.RelatedBlog
+
+		for j, other := range blogs {
+			if i == j {
+				continue
+			}
+
+			score := dotProduct(vectors[i], vectors[j])
+			if score <= 0 {
+				continue
+			}
+
+			candidates = append(candidates, entities.RelatedBlog{
+				BlogID:    blog.ID,
+				RelatedID: other.ID,
+				Score:     score,
+			})
+		}
+
+		// highest score first, use the ID as tie breaker to keep the output stable
+		sort.Slice(candidates, func(a, b int) bool {
+			if candidates[a].Score == candidates[b].Score {
+				return candidates[a].RelatedID < candidates[b].RelatedID
+			}
+			return candidates[a].Score > candidates[b].Score
+		})
+
+		if len(candidates) > k {
+			candidates = candidates[:k]
+		}
+
+		for rank := range candidates {
+			candidates[rank].Rank = rank + 1
+		}
+
+		result[blog.ID] = candidates
+	}
+
+	return result
+}
+
+// addTerms tokenizes the given text and adds every meaningful term into tf
+func addTerms(tf map[string]float64, text string, weight float64) {
+	tokens := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+
+	for _, token := range tokens {
+		token = strings.Trim(token, "'")
+
+		// skip short tokens, plain numbers and common words
+		if len([]rune(token)) < 3 {
+			continue
+		}
+
+		if _, stop := stopWords[token]; stop {
+			continue
+		}
+
+		if strings.IndexFunc(token, unicode.IsLetter) < 0 {
+			continue
+		}
+
+		tf[token] += weight
+	}
+}
+
+func dotProduct(a map[string]float64, b map[string]float64) float64 {
+	// always iterate the smaller vector
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+
+	var sum float64
+	for term, weight := range a {
+		sum += weight * b[term]
+	}
+
+	return sum
+}
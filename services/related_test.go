@@ -0,0 +1,187 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+var relatedRepoTest = repositories.RelatedRepoMock{}
+var relatedBlogRepoTest = repositories.BlogRepoMock{}
+var relatedServiceTest = RelatedServiceImpl{
+	Repository:     &relatedRepoTest,
+	BlogRepository: &relatedBlogRepoTest,
+}
+
+var relatedCorpus = []entities.Blog{
+	{
+		ID:      "go-concurrency",
+		Title:   "Concurrency Patterns in Golang",
+		Summary: "Goroutines and channels explained",
+		Content: "Golang goroutines communicate through channels. Channels make concurrency simple.",
+	},
+	{
+		ID:      "go-channels",
+		Title:   "Deep Dive into Golang Channels",
+		Summary: "Buffered and unbuffered channels",
+		Content: "Channels are the backbone of goroutines communication in Golang.",
+	},
+	{
+		ID:      "css-grid",
+		Title:   "Mastering CSS Grid",
+		Summary: "Layouts for the modern web",
+		Content: "Grid templates, areas and responsive layouts with CSS.",
+	},
+	{
+		ID:      "css-flexbox",
+		Title:   "Flexbox versus CSS Grid",
+		Summary: "Choosing the right layouts",
+		Content: "Flexbox handles one dimension while grid handles two dimensional layouts.",
+	},
+}
+
+func TestComputeRelated(t *testing.T) {
+	t.Run("Should relate blogs which share the most terms", func(t *testing.T) {
+		result := ComputeRelated(relatedCorpus, MaxRelatedBlogs)
+
+		require.Len(t, result, len(relatedCorpus))
+
+		assert.Equal(t, "go-channels", result["go-concurrency"][0].RelatedID)
+		assert.Equal(t, "go-concurrency", result["go-channels"][0].RelatedID)
+		assert.Equal(t, "css-flexbox", result["css-grid"][0].RelatedID)
+		assert.Equal(t, "css-grid", result["css-flexbox"][0].RelatedID)
+	})
+
+	t.Run("Should never relate blogs without shared terms", func(t *testing.T) {
+		result := ComputeRelated(relatedCorpus, MaxRelatedBlogs)
+
+		for _, related := range result["go-concurrency"] {
+			assert.NotEqual(t, "css-grid", related.RelatedID)
+			assert.NotEqual(t, "css-flexbox", related.RelatedID)
+		}
+	})
+
+	t.Run("Should never relate a blog to itself", func(t *testing.T) {
+		result := ComputeRelated(relatedCorpus, MaxRelatedBlogs)
+
+		for blogID, neighbours := range result {
+			for _, related := range neighbours {
+				assert.Equal(t, blogID, related.BlogID)
+				assert.NotEqual(t, blogID, related.RelatedID)
+			}
+		}
+	})
+
+	t.Run("Should rank by descending score and respect the limit", func(t *testing.T) {
+		result := ComputeRelated(relatedCorpus, 1)
+
+		for _, neighbours := range result {
+			require.LessOrEqual(t, len(neighbours), 1)
+
+			for i, related := range neighbours {
+				assert.Equal(t, i+1, related.Rank)
+				assert.Greater(t, related.Score, 0.0)
+			}
+		}
+	})
+
+	t.Run("Should return empty neighbours for a single blog", func(t *testing.T) {
+		result := ComputeRelated(relatedCorpus[:1], MaxRelatedBlogs)
+
+		assert.Empty(t, result["go-concurrency"])
+	})
+}
+
+func TestRecomputeRelated(t *testing.T) {
+	t.Run("Should store the computed neighbours", func(t *testing.T) {
+		firstMock := relatedRepoTest.Mock.On("GetPublishedCorpus").Return(relatedCorpus, nil)
+		secondMock := relatedRepoTest.Mock.On("ReplaceRelated", mock.Anything).Return(nil)
+
+		err := relatedServiceTest.RecomputeRelated()
+
+		assert.Nil(t, err)
+		relatedRepoTest.Mock.AssertCalled(t, "ReplaceRelated", mock.MatchedBy(func(related map[string][]entities.RelatedBlog) bool {
+			return len(related) == len(relatedCorpus) && related["go-concurrency"][0].RelatedID == "go-channels"
+		}))
+
+		t.Cleanup(func() {
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+
+	t.Run("Should return error if query fails", func(t *testing.T) {
+		firstMock := relatedRepoTest.Mock.On("GetPublishedCorpus").Return(nil, errors.New("Something went wrong"))
+
+		err := relatedServiceTest.RecomputeRelated()
+
+		assert.NotNil(t, err)
+		assert.Error(t, err)
+
+		t.Cleanup(func() {
+			firstMock.Unset()
+		})
+	})
+}
+
+func TestScheduleRecompute(t *testing.T) {
+	t.Run("Should only schedule one recompute for edits made in a row", func(t *testing.T) {
+		service := RelatedServiceImpl{
+			Repository:     &relatedRepoTest,
+			BlogRepository: &relatedBlogRepoTest,
+		}
+
+		service.ScheduleRecompute()
+		service.ScheduleRecompute()
+
+		assert.True(t, service.scheduled.Load())
+	})
+}
+
+func TestGetRelatedBlogs(t *testing.T) {
+	getBlogOpts := &types.GetBlogOpts{
+		BlogAuthor: "user123",
+		BlogSlug:   "example-of-slug",
+		Published:  true,
+	}
+
+	t.Run("Should return related blogs of a published blog", func(t *testing.T) {
+		expected := []entities.SafeBlogAuthor{
+			{SafeBlog: entities.SafeBlog{ID: "go-channels"}},
+		}
+
+		firstMock := relatedBlogRepoTest.Mock.On("GetBlog", getBlogOpts).Return(&entities.SafeBlogAuthor{
+			SafeBlog: entities.SafeBlog{ID: "go-concurrency"},
+		}, nil)
+		secondMock := relatedRepoTest.Mock.On("GetRelated", "go-concurrency", MaxRelatedBlogs).Return(expected, nil)
+
+		result, err := relatedServiceTest.GetRelatedBlogs(getBlogOpts.BlogAuthor, getBlogOpts.BlogSlug)
+
+		assert.Nil(t, err)
+		assert.Equal(t, expected, result)
+
+		t.Cleanup(func() {
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+
+	t.Run("Should return error if blog not found", func(t *testing.T) {
+		firstMock := relatedBlogRepoTest.Mock.On("GetBlog", getBlogOpts).Return(nil, errors.New("Record not found"))
+
+		result, err := relatedServiceTest.GetRelatedBlogs(getBlogOpts.BlogAuthor, getBlogOpts.BlogSlug)
+
+		assert.Nil(t, result)
+		assert.EqualError(t, err, "Record not found")
+
+		t.Cleanup(func() {
+			firstMock.Unset()
+		})
+	})
+}
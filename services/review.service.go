@@ -0,0 +1,191 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"resqiar.com-server/constants"
+	"resqiar.com-server/dto"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/inputs"
+	"resqiar.com-server/repositories"
+)
+
+var (
+	// ErrInvalidTransition is returned when the blog cannot move to the requested state from its current one.
+	ErrInvalidTransition = errors.New("Blog cannot move to the given state from its current state")
+
+	// ErrTransitionForbidden is returned when none of the user's roles may perform the transition.
+	ErrTransitionForbidden = errors.New("Not allowed to move the blog to the given state")
+
+	// ErrApprovalOutdated is returned when publishing an approved blog edited after its approval.
+	ErrApprovalOutdated = errors.New("Blog was modified since it was approved")
+
+	// ErrNotInReview is returned when reviewing a blog nobody submitted for review.
+	ErrNotInReview = errors.New("Blog is not in review")
+)
+
+type ReviewService interface {
+	// GetReviewQueue lists the blogs waiting for a reviewer.
+	GetReviewQueue() ([]entities.Blog, error)
+
+	// GetReviewBlog returns a blog submitted for review, with its content, to a reviewer.
+	GetReviewBlog(blogID string, userID string) (*entities.Blog, error)
+
+	// CreateNote leaves a note on lines of a blog in review, only reviewers may leave notes.
+	CreateNote(payload *inputs.CreateReviewNoteInput, userID string) (*entities.ReviewNote, error)
+
+	// GetNotes and ResolveNote are available to the authors and reviewers of the blog.
+	GetNotes(blogID string, userID string) ([]dto.ReviewNoteOutput, error)
+	ResolveNote(payload *inputs.ResolveReviewNoteInput, userID string) error
+
+	// GetTransitions returns every state the blog went through.
+	GetTransitions(blogID string, userID string) ([]dto.TransitionOutput, error)
+}
+
+type ReviewServiceImpl struct {
+	Repository     repositories.ReviewRepository
+	BlogRepository repositories.BlogRepository
+	UserRepository repositories.UserRepository
+}
+
+func (service *ReviewServiceImpl) GetReviewQueue() ([]entities.Blog, error) {
+	return service.Repository.GetReviewQueue()
+}
+
+func (service *ReviewServiceImpl) GetReviewBlog(blogID string, userID string) (*entities.Blog, error) {
+	blog, roles, err := blogRoles(service.BlogRepository, service.UserRepository, blogID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !roles[constants.RoleReviewer] {
+		return nil, ErrTransitionForbidden
+	}
+
+	// drafts stay private to their authors until they are submitted
+	if blog.State == constants.BlogDraft || blog.State == constants.BlogArchived {
+		return nil, ErrNotInReview
+	}
+
+	return blog, nil
+}
+
+func (service *ReviewServiceImpl) CreateNote(payload *inputs.CreateReviewNoteInput, userID string) (*entities.ReviewNote, error) {
+	blog, roles, err := blogRoles(service.BlogRepository, service.UserRepository, payload.BlogID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !roles[constants.RoleReviewer] {
+		return nil, ErrTransitionForbidden
+	}
+
+	if blog.State != constants.BlogInReview {
+		return nil, ErrNotInReview
+	}
+
+	if payload.EndLine > strings.Count(blog.Content, "\n")+1 {
+		return nil, errors.New("Lines are out of the blog content")
+	}
+
+	return service.Repository.CreateNote(&entities.ReviewNote{
+		BlogID:     blog.ID,
+		ReviewerID: userID,
+		Version:    blog.Version,
+		StartLine:  payload.StartLine,
+		EndLine:    payload.EndLine,
+		Body:       payload.Body,
+	})
+}
+
+func (service *ReviewServiceImpl) GetNotes(blogID string, userID string) ([]dto.ReviewNoteOutput, error) {
+	blog, _, err := blogRoles(service.BlogRepository, service.UserRepository, blogID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.Repository.GetNotes(blog.ID)
+}
+
+func (service *ReviewServiceImpl) ResolveNote(payload *inputs.ResolveReviewNoteInput, userID string) error {
+	blog, _, err := blogRoles(service.BlogRepository, service.UserRepository, payload.BlogID, userID)
+	if err != nil {
+		return err
+	}
+
+	resolved, err := service.Repository.ResolveNote(payload.ID, blog.ID)
+	if err != nil {
+		return err
+	}
+
+	if !resolved {
+		return errors.New("Note not found or already resolved")
+	}
+
+	return nil
+}
+
+func (service *ReviewServiceImpl) GetTransitions(blogID string, userID string) ([]dto.TransitionOutput, error) {
+	blog, _, err := blogRoles(service.BlogRepository, service.UserRepository, blogID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.BlogRepository.GetTransitions(blog.ID)
+}
+
+// blogRoles loads the blog along with the roles the user holds on it,
+// users holding no role get the error of looking the blog up as its author.
+func blogRoles(blogRepository repositories.BlogRepository, userRepository repositories.UserRepository, blogID string, userID string) (*entities.Blog, map[string]bool, error) {
+	roles := map[string]bool{}
+
+	blog, err := blogRepository.GetByIDAndAuthor(blogID, userID)
+	if err == nil {
+		roles[constants.RoleAuthor] = true
+	}
+
+	// without a user repository nobody is known to be a reviewer
+	if userRepository != nil {
+		reviewer, reviewerErr := userRepository.IsReviewer(userID)
+		if reviewerErr != nil {
+			return nil, nil, reviewerErr
+		}
+
+		if reviewer {
+			roles[constants.RoleReviewer] = true
+
+			if blog == nil {
+				blog, err = blogRepository.GetByID(blogID)
+
+				// drafts stay private to their authors until they are submitted
+				if err == nil && (blog.State == constants.BlogDraft || blog.State == constants.BlogArchived) {
+					return nil, nil, ErrNotInReview
+				}
+			}
+		}
+	}
+
+	if blog == nil {
+		return nil, nil, err
+	}
+
+	return blog, roles, nil
+}
+
+// canTransition reports whether any of the roles may move a blog between the given states,
+// ErrInvalidTransition is returned when no one may.
+func canTransition(roles map[string]bool, from string, to string) error {
+	allowed, ok := constants.BlogTransitions[from][to]
+	if !ok {
+		return ErrInvalidTransition
+	}
+
+	for _, role := range allowed {
+		if roles[role] {
+			return nil
+		}
+	}
+
+	return ErrTransitionForbidden
+}
@@ -0,0 +1,406 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/inputs"
+	"resqiar.com-server/repositories"
+)
+
+var reviewRepoTest = repositories.ReviewRepoMock{}
+var reviewBlogRepoTest = repositories.BlogRepoMock{}
+var reviewUserRepoTest = repositories.UserRepoMock{}
+var reviewServiceTest = ReviewServiceImpl{
+	Repository:     &reviewRepoTest,
+	BlogRepository: &reviewBlogRepoTest,
+	UserRepository: &reviewUserRepoTest,
+}
+var reviewBlogServiceTest = BlogServiceImpl{
+	UtilService:    &utilService,
+	Repository:     &reviewBlogRepoTest,
+	UserRepository: &reviewUserRepoTest,
+}
+
+func TestTransitionBlog(t *testing.T) {
+	authorID := "example-of-author-id"
+	reviewerID := "example-of-reviewer-id"
+	blogID := "example-of-blog-id"
+
+	t.Run("Should let a reviewer approve a blog in review", func(t *testing.T) {
+		blog := &entities.Blog{
+			ID:       blogID,
+			AuthorID: authorID,
+			State:    constants.BlogInReview,
+			Version:  4,
+		}
+
+		payload := &inputs.TransitionBlogInput{
+			ID:    blogID,
+			State: constants.BlogApproved,
+			Note:  "Good to go",
+		}
+
+		firstMock := reviewBlogRepoTest.Mock.On("GetByIDAndAuthor", blogID, reviewerID).Return(nil, errors.New("Record not found"))
+		secondMock := reviewUserRepoTest.Mock.On("IsReviewer", reviewerID).Return(true, nil)
+		thirdMock := reviewBlogRepoTest.Mock.On("GetByID", blogID).Return(blog, nil)
+		fourthMock := reviewBlogRepoTest.Mock.On("TransitionBlog", blog, &entities.BlogTransition{
+			BlogID:    blogID,
+			ActorID:   reviewerID,
+			FromState: constants.BlogInReview,
+			ToState:   constants.BlogApproved,
+			Note:      payload.Note,
+		}).Return(true, nil)
+
+		err := reviewBlogServiceTest.TransitionBlog(payload, reviewerID)
+
+		assert.Nil(t, err)
+		assert.Equal(t, constants.BlogApproved, blog.State)
+		assert.Equal(t, 4, blog.ApprovedVersion)
+		assert.False(t, blog.Published)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+			fourthMock.Unset()
+		})
+	})
+
+	t.Run("Should not let an author approve their own blog", func(t *testing.T) {
+		blog := &entities.Blog{
+			ID:       blogID,
+			AuthorID: authorID,
+			State:    constants.BlogInReview,
+		}
+
+		firstMock := reviewBlogRepoTest.Mock.On("GetByIDAndAuthor", blogID, authorID).Return(blog, nil)
+		secondMock := reviewUserRepoTest.Mock.On("IsReviewer", authorID).Return(false, nil)
+
+		err := reviewBlogServiceTest.TransitionBlog(&inputs.TransitionBlogInput{
+			ID:    blogID,
+			State: constants.BlogApproved,
+		}, authorID)
+
+		assert.ErrorIs(t, err, ErrTransitionForbidden)
+		assert.Equal(t, constants.BlogInReview, blog.State)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+
+	t.Run("Should let a reviewer publish their own draft directly", func(t *testing.T) {
+		blog := &entities.Blog{
+			ID:       blogID,
+			Title:    "Example of Title",
+			AuthorID: reviewerID,
+			State:    constants.BlogDraft,
+		}
+
+		firstMock := reviewBlogRepoTest.Mock.On("GetByIDAndAuthor", blogID, reviewerID).Return(blog, nil)
+		secondMock := reviewUserRepoTest.Mock.On("IsReviewer", reviewerID).Return(true, nil)
+		thirdMock := reviewBlogRepoTest.Mock.On("GetCurrentUserSlugs", "example-of-title", reviewerID).Return([]entities.Blog{}, nil)
+		fourthMock := reviewBlogRepoTest.Mock.On("TransitionBlog", blog, &entities.BlogTransition{
+			BlogID:    blogID,
+			ActorID:   reviewerID,
+			FromState: constants.BlogDraft,
+			ToState:   constants.BlogPublished,
+		}).Return(true, nil)
+
+		err := reviewBlogServiceTest.TransitionBlog(&inputs.TransitionBlogInput{
+			ID:    blogID,
+			State: constants.BlogPublished,
+		}, reviewerID)
+
+		assert.Nil(t, err)
+		assert.True(t, blog.Published)
+		assert.Equal(t, "example-of-title", blog.Slug)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+			fourthMock.Unset()
+		})
+	})
+
+	t.Run("Should not let a reviewer publish the draft of someone else", func(t *testing.T) {
+		blog := &entities.Blog{
+			ID:       blogID,
+			Title:    "Example of Title",
+			AuthorID: authorID,
+			State:    constants.BlogDraft,
+		}
+
+		firstMock := reviewBlogRepoTest.Mock.On("GetByIDAndAuthor", blogID, reviewerID).Return(nil, errors.New("Record not found"))
+		secondMock := reviewUserRepoTest.Mock.On("IsReviewer", reviewerID).Return(true, nil)
+		thirdMock := reviewBlogRepoTest.Mock.On("GetByID", blogID).Return(blog, nil)
+
+		err := reviewBlogServiceTest.TransitionBlog(&inputs.TransitionBlogInput{
+			ID:    blogID,
+			State: constants.BlogPublished,
+		}, reviewerID)
+
+		assert.ErrorIs(t, err, ErrNotInReview)
+		assert.False(t, blog.Published)
+		assert.Equal(t, constants.BlogDraft, blog.State)
+		reviewBlogRepoTest.Mock.AssertNotCalled(t, "TransitionBlog", blog, mock.Anything)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+		})
+	})
+
+	t.Run("Should reject transitions the workflow does not have", func(t *testing.T) {
+		blog := &entities.Blog{
+			ID:       blogID,
+			AuthorID: authorID,
+			State:    constants.BlogArchived,
+		}
+
+		firstMock := reviewBlogRepoTest.Mock.On("GetByIDAndAuthor", blogID, authorID).Return(blog, nil)
+		secondMock := reviewUserRepoTest.Mock.On("IsReviewer", authorID).Return(false, nil)
+
+		err := reviewBlogServiceTest.TransitionBlog(&inputs.TransitionBlogInput{
+			ID:    blogID,
+			State: constants.BlogPublished,
+		}, authorID)
+
+		assert.ErrorIs(t, err, ErrInvalidTransition)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+
+	t.Run("Should return error when the user holds no role on the blog", func(t *testing.T) {
+		userID := "example-of-stranger-id"
+
+		firstMock := reviewBlogRepoTest.Mock.On("GetByIDAndAuthor", blogID, userID).Return(nil, errors.New("Record not found"))
+		secondMock := reviewUserRepoTest.Mock.On("IsReviewer", userID).Return(false, nil)
+
+		err := reviewBlogServiceTest.TransitionBlog(&inputs.TransitionBlogInput{
+			ID:    blogID,
+			State: constants.BlogInReview,
+		}, userID)
+
+		assert.EqualError(t, err, "Record not found")
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+}
+
+func TestCreateReviewNote(t *testing.T) {
+	reviewerID := "example-of-reviewer-id"
+	blogID := "example-of-blog-id"
+
+	blog := &entities.Blog{
+		ID:      blogID,
+		Content: "# Title\nfirst paragraph\nsecond paragraph",
+		State:   constants.BlogInReview,
+		Version: 2,
+	}
+
+	t.Run("Should leave a note on lines of the current version", func(t *testing.T) {
+		payload := &inputs.CreateReviewNoteInput{
+			BlogID:    blogID,
+			StartLine: 2,
+			EndLine:   3,
+			Body:      "Merge these paragraphs",
+		}
+
+		note := &entities.ReviewNote{
+			BlogID:     blogID,
+			ReviewerID: reviewerID,
+			Version:    2,
+			StartLine:  2,
+			EndLine:    3,
+			Body:       payload.Body,
+		}
+
+		firstMock := reviewBlogRepoTest.Mock.On("GetByIDAndAuthor", blogID, reviewerID).Return(nil, errors.New("Record not found"))
+		secondMock := reviewUserRepoTest.Mock.On("IsReviewer", reviewerID).Return(true, nil)
+		thirdMock := reviewBlogRepoTest.Mock.On("GetByID", blogID).Return(blog, nil)
+		fourthMock := reviewRepoTest.Mock.On("CreateNote", note).Return(note, nil)
+
+		result, err := reviewServiceTest.CreateNote(payload, reviewerID)
+
+		assert.Nil(t, err)
+		assert.Equal(t, note, result)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+			fourthMock.Unset()
+		})
+	})
+
+	t.Run("Should return error when the lines are out of the content", func(t *testing.T) {
+		firstMock := reviewBlogRepoTest.Mock.On("GetByIDAndAuthor", blogID, reviewerID).Return(nil, errors.New("Record not found"))
+		secondMock := reviewUserRepoTest.Mock.On("IsReviewer", reviewerID).Return(true, nil)
+		thirdMock := reviewBlogRepoTest.Mock.On("GetByID", blogID).Return(blog, nil)
+
+		result, err := reviewServiceTest.CreateNote(&inputs.CreateReviewNoteInput{
+			BlogID:    blogID,
+			StartLine: 3,
+			EndLine:   4,
+			Body:      "Too far",
+		}, reviewerID)
+
+		assert.Nil(t, result)
+		assert.EqualError(t, err, "Lines are out of the blog content")
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+		})
+	})
+
+	t.Run("Should return error when the blog is not in review", func(t *testing.T) {
+		approved := &entities.Blog{
+			ID:    blogID,
+			State: constants.BlogApproved,
+		}
+
+		firstMock := reviewBlogRepoTest.Mock.On("GetByIDAndAuthor", blogID, reviewerID).Return(nil, errors.New("Record not found"))
+		secondMock := reviewUserRepoTest.Mock.On("IsReviewer", reviewerID).Return(true, nil)
+		thirdMock := reviewBlogRepoTest.Mock.On("GetByID", blogID).Return(approved, nil)
+
+		result, err := reviewServiceTest.CreateNote(&inputs.CreateReviewNoteInput{
+			BlogID:    blogID,
+			StartLine: 1,
+			EndLine:   1,
+			Body:      "Late note",
+		}, reviewerID)
+
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, ErrNotInReview)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+		})
+	})
+
+	t.Run("Should not let authors leave notes", func(t *testing.T) {
+		authorID := "example-of-author-id"
+
+		firstMock := reviewBlogRepoTest.Mock.On("GetByIDAndAuthor", blogID, authorID).Return(blog, nil)
+		secondMock := reviewUserRepoTest.Mock.On("IsReviewer", authorID).Return(false, nil)
+
+		result, err := reviewServiceTest.CreateNote(&inputs.CreateReviewNoteInput{
+			BlogID:    blogID,
+			StartLine: 1,
+			EndLine:   1,
+			Body:      "Note to self",
+		}, authorID)
+
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, ErrTransitionForbidden)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+		})
+	})
+}
+
+func TestGetReviewBlog(t *testing.T) {
+	reviewerID := "example-of-reviewer-id"
+	blogID := "example-of-blog-id"
+
+	t.Run("Should keep drafts private to their authors", func(t *testing.T) {
+		firstMock := reviewBlogRepoTest.Mock.On("GetByIDAndAuthor", blogID, reviewerID).Return(nil, errors.New("Record not found"))
+		secondMock := reviewUserRepoTest.Mock.On("IsReviewer", reviewerID).Return(true, nil)
+		thirdMock := reviewBlogRepoTest.Mock.On("GetByID", blogID).Return(&entities.Blog{
+			ID:    blogID,
+			State: constants.BlogDraft,
+		}, nil)
+
+		result, err := reviewServiceTest.GetReviewBlog(blogID, reviewerID)
+
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, ErrNotInReview)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+		})
+	})
+}
+
+func TestResolveReviewNote(t *testing.T) {
+	authorID := "example-of-author-id"
+
+	payload := &inputs.ResolveReviewNoteInput{
+		ID:     "example-of-note-id",
+		BlogID: "example-of-blog-id",
+	}
+
+	blog := &entities.Blog{
+		ID:       payload.BlogID,
+		AuthorID: authorID,
+		State:    constants.BlogChangesRequested,
+	}
+
+	t.Run("Should let the author resolve a note", func(t *testing.T) {
+		firstMock := reviewBlogRepoTest.Mock.On("GetByIDAndAuthor", payload.BlogID, authorID).Return(blog, nil)
+		secondMock := reviewUserRepoTest.Mock.On("IsReviewer", authorID).Return(false, nil)
+		thirdMock := reviewRepoTest.Mock.On("ResolveNote", payload.ID, payload.BlogID).Return(true, nil)
+
+		err := reviewServiceTest.ResolveNote(payload, authorID)
+
+		assert.Nil(t, err)
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+		})
+	})
+
+	t.Run("Should return error if the note was already resolved", func(t *testing.T) {
+		firstMock := reviewBlogRepoTest.Mock.On("GetByIDAndAuthor", payload.BlogID, authorID).Return(blog, nil)
+		secondMock := reviewUserRepoTest.Mock.On("IsReviewer", authorID).Return(false, nil)
+		thirdMock := reviewRepoTest.Mock.On("ResolveNote", payload.ID, payload.BlogID).Return(false, nil)
+
+		err := reviewServiceTest.ResolveNote(payload, authorID)
+
+		assert.EqualError(t, err, "Note not found or already resolved")
+
+		t.Cleanup(func() {
+			// Cleanup mocking
+			firstMock.Unset()
+			secondMock.Unset()
+			thirdMock.Unset()
+		})
+	})
+}
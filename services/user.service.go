@@ -7,6 +7,7 @@ import (
 	"resqiar.com-server/entities"
 	"resqiar.com-server/inputs"
 	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
 )
 
 type UserService interface {
@@ -17,11 +18,13 @@ type UserService interface {
 	FindUserByUsername(username string) (*entities.SafeUser, error)
 	CheckUsernameExist(username string) bool
 	UpdateUser(payload *inputs.UpdateUserInput, userID string) error
+	GetProfileValidator(username string) (*types.Validator, error)
 }
 
 type UserServiceImpl struct {
-	UtilService UtilService
-	Repository  repositories.UserRepository
+	UtilService  UtilService
+	Repository   repositories.UserRepository
+	CacheService CacheService
 }
 
 func (service *UserServiceImpl) GetUsernameList() ([]string, error) {
@@ -102,5 +105,22 @@ func (service *UserServiceImpl) UpdateUser(payload *inputs.UpdateUserInput, user
 		return err
 	}
 
+	// cached blogs embed the author, and lists are looked up by username
+	tags := []string{AuthorCacheTag(user.ID), UsernameCacheTag(user.Username)}
+	if payload.Username != "" {
+		tags = append(tags, UsernameCacheTag(payload.Username))
+	}
+
+	invalidate(service.CacheService, tags...)
+
 	return nil
 }
+
+func (service *UserServiceImpl) GetProfileValidator(username string) (*types.Validator, error) {
+	validator, err := service.Repository.GetProfileValidator(username)
+	if err != nil {
+		return nil, err
+	}
+
+	return validator, nil
+}
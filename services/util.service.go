@@ -2,24 +2,46 @@ package services
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
+	"net/url"
+	"os"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/BurntSushi/toml"
 	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gosimple/unidecode"
 	gonanoid "github.com/matoous/go-nanoid/v2"
 	"resqiar.com-server/config"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/types"
 
 	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
 	"github.com/microcosm-cc/bluemonday"
 	"github.com/yuin/goldmark"
 	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
 	html "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
 	"go.abhg.dev/goldmark/anchor"
+	"gopkg.in/yaml.v3"
 )
 
+// ErrInvalidFrontMatter is returned when the front matter of the content cannot be decoded.
+var ErrInvalidFrontMatter = errors.New("Front matter is invalid")
+
 var (
 	validate                  = validator.New()
 	removeNonAlphaNumRegex    = regexp.MustCompile("[^ a-zA-Z0-9]")
@@ -55,9 +77,37 @@ type UtilService interface {
 	GenerateRandomID(length int) string
 	ValidateInput(payload any) string
 
+	// BlogURL returns the public URL of a published blog on the client.
+	BlogURL(author string, slug string) string
+
 	// ParseMD converts Markdown content into safe & sanitized HTML.
 	// If error happens, it will merely returns empty string.
 	ParseMD(s string) string
+
+	// RenderMD is ParseMD along with the table of contents, word count and reading time,
+	// If error happens, it will merely returns an empty result.
+	RenderMD(s string) *types.RenderedMarkdown
+
+	// ParseFrontMatter splits the YAML ("---") or TOML ("+++") front matter off the content,
+	// the front matter is nil when the content does not start with one.
+	ParseFrontMatter(s string) (*types.FrontMatter, string, error)
+
+	// GenerateETag returns a strong ETag identifying the given parts.
+	GenerateETag(parts ...string) string
+
+	// IsNotModified reports whether the conditional request headers match the
+	// current validators, If-None-Match takes precedence over If-Modified-Since.
+	IsNotModified(ifNoneMatch string, ifModifiedSince string, etag string, lastModified time.Time) bool
+
+	// CheckNotModified sets the validators and the caching policy of the response,
+	// it reports whether the client copy is fresh so the body can be skipped.
+	// The variant covers whatever else the response depends on, e.g. query params.
+	CheckNotModified(c *fiber.Ctx, validator *types.Validator, cacheControl string, variant ...string) bool
+
+	// VersionETag turns a blog version into the ETag expected back in If-Match,
+	// ParseVersionETag reverses it and returns 0 when the ETag is not a version.
+	VersionETag(version int) string
+	ParseVersionETag(etag string) int
 }
 
 type UtilServiceImpl struct{}
@@ -72,7 +122,7 @@ func (service *UtilServiceImpl) FormatUsername(name string) string {
 	// remove any non-alphanumeric characters from the string
 	// example "?-_!" should be ""
 	// example "a?!;';';'b" should be "ab"
-	validChars := removeNonAlphaNumRegex.ReplaceAllString(name, "")
+	validChars := removeNonAlphaNumRegex.ReplaceAllString(transliterate(name), "")
 	formatted := validChars
 
 	// trim spaces
@@ -89,18 +139,56 @@ func (service *UtilServiceImpl) FormatUsername(name string) string {
 	// format name to replace all spaces into _ (underscore)
 	formatted = strings.ReplaceAll(formatted, " ", "_")
 
+	if formatted == "" && hasAlphaNum(name) {
+		return shortID(name)
+	}
+
 	return formatted
 }
 
 func (service *UtilServiceImpl) FormatToURL(value string) string {
-	formatted := removeNonAlphaNumRegex.ReplaceAllString(value, "")
+	formatted := removeNonAlphaNumRegex.ReplaceAllString(transliterate(value), "")
 	formatted = strings.ToLower(formatted)
 	formatted = strings.TrimSpace(formatted)
 	formatted = removeMultipleSpacesRegex.ReplaceAllString(formatted, " ")
 	formatted = strings.ReplaceAll(formatted, " ", "-")
+
+	if formatted == "" && hasAlphaNum(value) {
+		return shortID(value)
+	}
+
 	return formatted
 }
 
+// transliterate spells the value in ASCII, e.g. "Café" as "Cafe" and "Привет" as "Privet",
+// characters with no ASCII spelling are dropped. ASCII values are returned as they are.
+func transliterate(value string) string {
+	for i := 0; i < len(value); i++ {
+		if value[i] >= utf8.RuneSelf {
+			return unidecode.Unidecode(value)
+		}
+	}
+
+	return value
+}
+
+func hasAlphaNum(value string) bool {
+	for _, r := range value {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// shortID identifies values written in scripts that cannot be transliterated,
+// the same value always gets the same ID.
+func shortID(value string) string {
+	hash := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(hash[:])[:8]
+}
+
 func (service *UtilServiceImpl) GenerateRandomID(length int) string {
 	// generate random string id using nanoid package
 	id, _ := gonanoid.New(length)
@@ -126,7 +214,7 @@ func (service *UtilServiceImpl) ValidateInput(payload any) string {
 				break
 			}
 
-			if err.Tag() == "username" {
+			if err.Tag() == "username" || err.Tag() == "slug" {
 				errMessage = err.StructField() + " contains illegal characters"
 				break
 			}
@@ -141,6 +229,11 @@ func (service *UtilServiceImpl) ValidateInput(payload any) string {
 				break
 			}
 
+			if err.Tag() == "email" {
+				errMessage = err.StructField() + " field is not a valid email"
+				break
+			}
+
 			if err.Tag() == "url" || err.Tag() == "media_url" {
 				errMessage = err.StructField() + " field is not a valid URL"
 				break
@@ -154,14 +247,353 @@ func (service *UtilServiceImpl) ValidateInput(payload any) string {
 	return errMessage
 }
 
+func (service *UtilServiceImpl) BlogURL(author string, slug string) string {
+	CLIENT_URL := os.Getenv("CLIENT_URL")
+
+	return strings.TrimSuffix(CLIENT_URL, "/") + "/blog/" + url.PathEscape(author) + "/" + url.PathEscape(slug)
+}
+
 func (service *UtilServiceImpl) ParseMD(s string) string {
+	return service.RenderMD(s).HTML
+}
+
+func (service *UtilServiceImpl) RenderMD(s string) *types.RenderedMarkdown {
 	var buf bytes.Buffer
 
-	if err := engine.Convert([]byte(s), &buf); err != nil {
+	rendered, err := renderMarkdown(engine, sanitizePolicy, []byte(s), &buf)
+	if err != nil {
 		log.Println("Error parsing MD:", err)
-		return ""
+		return &types.RenderedMarkdown{}
+	}
+
+	return rendered
+}
+
+func (service *UtilServiceImpl) ParseFrontMatter(s string) (*types.FrontMatter, string, error) {
+	matter, content, err := splitFrontMatter([]byte(s))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return matter, string(content), nil
+}
+
+// frontMatterKeys are the YAML keys of types.FrontMatter.
+var frontMatterKeys = map[string]struct{}{
+	"title": {}, "summary": {}, "cover": {}, "tags": {}, "slug": {}, "date": {}, "draft": {}, "published": {},
+}
+
+// splitFrontMatter returns the front matter of the source along with the content following it.
+// An opening delimiter without a closing one is a thematic break rather than front matter,
+// so is a YAML block which is not a mapping, e.g. a paragraph between two thematic breaks.
+// A paragraph such as "Note: read this first" is a mapping too, so YAML front matter
+// has to set at least one of frontMatterKeys.
+func splitFrontMatter(source []byte) (*types.FrontMatter, []byte, error) {
+	source = bytes.TrimPrefix(source, []byte("\uFEFF"))
+
+	firstLine, rest, _ := bytes.Cut(source, []byte("\n"))
+	delimiter := string(bytes.TrimRight(firstLine, " \t\r"))
+
+	if delimiter != "---" && delimiter != "+++" {
+		return nil, source, nil
+	}
+
+	var raw []byte
+	var found bool
+
+	for offset := 0; offset < len(rest); {
+		line, _, _ := bytes.Cut(rest[offset:], []byte("\n"))
+
+		if string(bytes.TrimRight(line, " \t\r")) == delimiter {
+			raw = rest[:offset]
+			found = true
+
+			// the closing delimiter may be the last line
+			offset += len(line) + 1
+			if offset > len(rest) {
+				offset = len(rest)
+			}
+
+			rest = rest[offset:]
+			break
+		}
+
+		offset += len(line) + 1
+	}
+
+	if !found {
+		return nil, source, nil
+	}
+
+	matter := &types.FrontMatter{}
+
+	var err error
+	if delimiter == "---" {
+		var document yaml.Node
+
+		if err := yaml.Unmarshal(raw, &document); err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidFrontMatter, err)
+		}
+
+		if len(document.Content) == 0 || !isFrontMatter(document.Content[0]) {
+			return nil, source, nil
+		}
+
+		err = document.Decode(matter)
+	} else {
+		err = toml.Unmarshal(raw, matter)
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidFrontMatter, err)
+	}
+
+	return matter, bytes.TrimLeft(rest, "\r\n"), nil
+}
+
+// isFrontMatter reports whether the YAML node is a mapping of at least one of frontMatterKeys.
+func isFrontMatter(node *yaml.Node) bool {
+	if node.Kind != yaml.MappingNode {
+		return false
+	}
+
+	// keys and values alternate
+	for i := 0; i < len(node.Content); i += 2 {
+		if _, exist := frontMatterKeys[node.Content[i].Value]; exist {
+			return true
+		}
+	}
+
+	return false
+}
+
+// renderMarkdown renders the source into sanitized HTML, walking its AST in between
+// to collect the headings and the words a reader goes through.
+func renderMarkdown(md goldmark.Markdown, policy *bluemonday.Policy, source []byte, buf *bytes.Buffer) (*types.RenderedMarkdown, error) {
+	doc := md.Parser().Parse(text.NewReader(source))
+
+	var headings []types.Heading
+	var words strings.Builder
+
+	err := ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		// words never run across blocks
+		if node.Type() == ast.TypeBlock {
+			words.WriteByte(' ')
+		}
+
+		switch node := node.(type) {
+		case *ast.Heading:
+			// the ID generated by parser.WithAutoHeadingID
+			id, _ := node.AttributeString("id")
+			idBytes, _ := id.([]byte)
+
+			headings = append(headings, types.Heading{
+				ID:    string(idBytes),
+				Text:  headingText(node, source),
+				Level: node.Level,
+			})
+		case *ast.FencedCodeBlock, *ast.CodeBlock, *ast.HTMLBlock:
+			// code is skimmed rather than read
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			words.Write(node.Segment.Value(source))
+
+			if node.SoftLineBreak() || node.HardLineBreak() {
+				words.WriteByte(' ')
+			}
+		case *ast.String:
+			words.Write(node.Value)
+		}
+
+		if node.Kind() == anchor.Kind {
+			return ast.WalkSkipChildren, nil
+		}
+
+		return ast.WalkContinue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := md.Renderer().Render(buf, source, doc); err != nil {
+		return nil, err
+	}
+
+	wordCount := countWords(words.String())
+
+	return &types.RenderedMarkdown{
+		HTML:        string(policy.SanitizeBytes(buf.Bytes())),
+		TOC:         nestHeadings(headings),
+		WordCount:   wordCount,
+		ReadingTime: (wordCount + constants.ReadingWordsPerMinute - 1) / constants.ReadingWordsPerMinute,
+	}, nil
+}
+
+// headingText returns the text of the heading without its anchor.
+func headingText(heading ast.Node, source []byte) string {
+	var buf bytes.Buffer
+
+	ast.Walk(heading, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		if node.Kind() == anchor.Kind {
+			return ast.WalkSkipChildren, nil
+		}
+
+		switch node := node.(type) {
+		case *ast.Text:
+			buf.Write(node.Segment.Value(source))
+
+			if node.SoftLineBreak() {
+				buf.WriteByte(' ')
+			}
+		case *ast.String:
+			buf.Write(node.Value)
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	return strings.TrimSpace(buf.String())
+}
+
+// nestHeadings turns the headings in document order into a tree,
+// every heading goes under the closest previous heading with a higher level.
+func nestHeadings(headings []types.Heading) types.TableOfContents {
+	// never nil, an update must clear the headings of a blog that no longer has any
+	toc := types.TableOfContents{}
+
+	// the chain of headings the next one may nest under
+	var path []*types.Heading
+
+	for _, heading := range headings {
+		for len(path) > 0 && path[len(path)-1].Level >= heading.Level {
+			path = path[:len(path)-1]
+		}
+
+		if len(path) == 0 {
+			toc = append(toc, heading)
+			path = append(path, &toc[len(toc)-1])
+			continue
+		}
+
+		parent := path[len(path)-1]
+		parent.Children = append(parent.Children, heading)
+		path = append(path, &parent.Children[len(parent.Children)-1])
+	}
+
+	return toc
+}
+
+// countWords counts the words of the text, every character of the scripts
+// written without spaces between words is counted as a word of its own.
+func countWords(value string) int {
+	words := 0
+	inWord := false
+
+	for _, r := range value {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana):
+			words++
+			inWord = false
+		case unicode.IsSpace(r):
+			inWord = false
+		case !inWord && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			words++
+			inWord = true
+		}
+	}
+
+	return words
+}
+
+func (service *UtilServiceImpl) GenerateETag(parts ...string) string {
+	hash := sha256.New()
+
+	for _, part := range parts {
+		// separate the parts so ("ab", "c") and ("a", "bc") differ
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+
+	return `"` + hex.EncodeToString(hash.Sum(nil))[:32] + `"`
+}
+
+func (service *UtilServiceImpl) IsNotModified(ifNoneMatch string, ifModifiedSince string, etag string, lastModified time.Time) bool {
+	if ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimSpace(candidate)
+
+			// GET requests use the weak comparison
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if ifModifiedSince != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ifModifiedSince)
+		if err != nil {
+			return false
+		}
+
+		// HTTP dates have no sub-second precision
+		return !lastModified.Truncate(time.Second).After(since)
+	}
+
+	return false
+}
+
+func (service *UtilServiceImpl) CheckNotModified(c *fiber.Ctx, validator *types.Validator, cacheControl string, variant ...string) bool {
+	parts := append([]string{
+		strconv.FormatInt(validator.LastModified.UnixNano(), 10),
+		strconv.FormatInt(validator.Count, 10),
+		validator.Digest,
+		// stored HTML is re-rendered whenever the renderer changes
+		strconv.Itoa(constants.RendererVersion),
+	}, variant...)
+
+	etag := service.GenerateETag(parts...)
+
+	c.Set(fiber.HeaderETag, etag)
+	c.Set(fiber.HeaderCacheControl, cacheControl)
+
+	if !validator.LastModified.IsZero() {
+		c.Set(fiber.HeaderLastModified, validator.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	// only safe methods can be answered with a 304
+	if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+		return false
+	}
+
+	return service.IsNotModified(c.Get(fiber.HeaderIfNoneMatch), c.Get(fiber.HeaderIfModifiedSince), etag, validator.LastModified)
+}
+
+func (service *UtilServiceImpl) VersionETag(version int) string {
+	return `"v` + strconv.Itoa(version) + `"`
+}
+
+func (service *UtilServiceImpl) ParseVersionETag(etag string) int {
+	etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+
+	value, found := strings.CutPrefix(strings.Trim(etag, `"`), "v")
+	if !found {
+		return 0
+	}
+
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 0 {
+		return 0
 	}
 
-	sanitized := string(sanitizePolicy.SanitizeBytes(buf.Bytes()))
-	return sanitized
+	return version
 }
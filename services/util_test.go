@@ -2,8 +2,17 @@ package services
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/inputs"
+	"resqiar.com-server/types"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -24,6 +33,12 @@ func TestFormatUsername(t *testing.T) {
 		{"UPPERCASE USER", "uppercase_user"},
 		{"a                   b     user", "a_b_user"},
 		{"L 0 ? ] / Y ;  <script>", "l_0_y_script"},
+		{"José García", "jose_garcia"},
+		{"Ñoman Sulistyawati", "noman_sulistyawati"},
+		{"Дмитрий", "dmitrii"},
+		{"さくら", "sakura"},
+		{"🚀 !!", ""},
+		{"𐌰𐌹𐌽𐍃", shortID("𐌰𐌹𐌽𐍃")},
 	}
 
 	for _, tc := range testCases {
@@ -52,6 +67,13 @@ func TestFormatToURL(t *testing.T) {
 		{"<><><?><><)_(*&&^%^&%^$%#$%%^&(()##$%^&*(&", ""},
 		{"TROUBLE MAKER IS ON THE 666 W4Y", "trouble-maker-is-on-the-666-w4y"},
 		{"Wh  y  ? ev 3       erything    mu    s-t be not th e    best			         ", "wh-y-ev-3-erything-mu-st-be-not-th-e-best"},
+		{"Cara Membuat Kopi Tubruk yang Nikmat à la Bāli", "cara-membuat-kopi-tubruk-yang-nikmat-a-la-bali"},
+		{"Привет, мир!", "privet-mir"},
+		{"こんにちは", "konnichiha"},
+		{"東京 2024", "dong-jing-2024"},
+		{"Straße und Größe", "strasse-und-grosse"},
+		{"🚀🚀🚀", ""},
+		{"𐌰𐌹𐌽𐍃", shortID("𐌰𐌹𐌽𐍃")},
 	}
 
 	for _, tc := range testCases {
@@ -67,6 +89,16 @@ func TestFormatToURL(t *testing.T) {
 	}
 }
 
+func TestShortID(t *testing.T) {
+	t.Run("Should generate the same short ID for the same value", func(t *testing.T) {
+		generated := shortID("𐌰𐌹𐌽𐍃")
+
+		assert.Len(t, generated, 8)
+		assert.Equal(t, generated, shortID("𐌰𐌹𐌽𐍃"))
+		assert.NotEqual(t, generated, shortID("𐌰𐌹𐌽"))
+	})
+}
+
 func TestGenerateRandomID(t *testing.T) {
 	t.Run("Should not return Nil or Empty", func(t *testing.T) {
 		generated := utilService.GenerateRandomID(8)
@@ -281,3 +313,269 @@ func TestParseMD(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderMD(t *testing.T) {
+	t.Run("Should nest the headings under the closest higher one", func(t *testing.T) {
+		rendered := utilService.RenderMD("# Intro\n## Setup\n### Install\n## Usage\n# Outro")
+
+		assert.Equal(t, types.TableOfContents{
+			{ID: "intro", Text: "Intro", Level: 1, Children: []types.Heading{
+				{ID: "setup", Text: "Setup", Level: 2, Children: []types.Heading{
+					{ID: "install", Text: "Install", Level: 3},
+				}},
+				{ID: "usage", Text: "Usage", Level: 2},
+			}},
+			{ID: "outro", Text: "Outro", Level: 1},
+		}, rendered.TOC)
+	})
+
+	t.Run("Should keep headings skipping a level under their parent", func(t *testing.T) {
+		rendered := utilService.RenderMD("## Setup\n#### Install\n# Outro")
+
+		assert.Equal(t, types.TableOfContents{
+			{ID: "setup", Text: "Setup", Level: 2, Children: []types.Heading{
+				{ID: "install", Text: "Install", Level: 4},
+			}},
+			{ID: "outro", Text: "Outro", Level: 1},
+		}, rendered.TOC)
+	})
+
+	t.Run("Should return an empty table of contents without headings", func(t *testing.T) {
+		rendered := utilService.RenderMD("hello paragraph")
+
+		assert.NotNil(t, rendered.TOC)
+		assert.Empty(t, rendered.TOC)
+	})
+
+	t.Run("Should count words without the code blocks and anchors", func(t *testing.T) {
+		rendered := utilService.RenderMD("# Hello World\nsome **bold** text\nacross lines\n\n```go\nfunc main() {}\n```")
+
+		assert.Equal(t, 7, rendered.WordCount)
+		assert.Equal(t, 1, rendered.ReadingTime)
+	})
+
+	t.Run("Should count each CJK character as a word", func(t *testing.T) {
+		rendered := utilService.RenderMD("日本語 and text")
+
+		assert.Equal(t, 5, rendered.WordCount)
+	})
+
+	t.Run("Should round the reading time up", func(t *testing.T) {
+		rendered := utilService.RenderMD(strings.Repeat("word ", constants.ReadingWordsPerMinute+1))
+
+		assert.Equal(t, constants.ReadingWordsPerMinute+1, rendered.WordCount)
+		assert.Equal(t, 2, rendered.ReadingTime)
+	})
+}
+
+func TestParseFrontMatter(t *testing.T) {
+	date := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Should split the YAML front matter off the content", func(t *testing.T) {
+		matter, content, err := utilService.ParseFrontMatter("---\ntitle: Hello World\nsummary: A summary\ncover: https://www.example.com/cover.jpg\ntags: [go, markdown]\nslug: hello-world\ndate: 2023-03-01\n---\n\n# Hello World")
+
+		require.Nil(t, err)
+		assert.Equal(t, &types.FrontMatter{
+			Title:    "Hello World",
+			Summary:  "A summary",
+			CoverURL: "https://www.example.com/cover.jpg",
+			Tags:     types.Tags{"go", "markdown"},
+			Slug:     "hello-world",
+			Date:     &date,
+		}, matter)
+		assert.Equal(t, "# Hello World", content)
+	})
+
+	t.Run("Should split the TOML front matter off the content", func(t *testing.T) {
+		matter, content, err := utilService.ParseFrontMatter("+++\r\ntitle = \"Hello World\"\r\ntags = [\"go\"]\r\ndate = 2023-03-01T00:00:00Z\r\n+++\r\n# Hello World")
+
+		require.Nil(t, err)
+		assert.Equal(t, &types.FrontMatter{
+			Title: "Hello World",
+			Tags:  types.Tags{"go"},
+			Date:  &date,
+		}, matter)
+		assert.Equal(t, "# Hello World", content)
+	})
+
+	t.Run("Should leave content without front matter untouched", func(t *testing.T) {
+		for _, input := range []string{"# Hello World", "---\nHello World", "Hello\n---\ntitle: World\n---"} {
+			matter, content, err := utilService.ParseFrontMatter(input)
+
+			assert.Nil(t, err)
+			assert.Nil(t, matter)
+			assert.Equal(t, input, content)
+		}
+	})
+
+	t.Run("Should keep a post starting with a horizontal rule as content", func(t *testing.T) {
+		for _, input := range []string{
+			"---\nA paragraph between two rules.\n---\n\n# Hello World",
+			"---\n- first\n- second\n---\n# Hello World",
+			"---\n---\n# Hello World",
+			"---\nNote: this post assumes some Go.\n---\n\n# Hello World",
+		} {
+			matter, content, err := utilService.ParseFrontMatter(input)
+
+			assert.Nil(t, err)
+			assert.Nil(t, matter)
+			assert.Equal(t, input, content)
+		}
+	})
+
+	t.Run("Should return error when the front matter is invalid", func(t *testing.T) {
+		_, _, err := utilService.ParseFrontMatter("---\ntitle: [Hello\n---\n# Hello World")
+
+		assert.ErrorIs(t, err, ErrInvalidFrontMatter)
+	})
+
+	t.Run("Should keep the explicit fields over the front matter", func(t *testing.T) {
+		payload := inputs.CreateBlogInput{
+			Title: "Explicit Title",
+			Tags:  types.Tags{},
+		}
+
+		payload.ApplyFrontMatter(&types.FrontMatter{
+			Title:   "Front Matter Title",
+			Summary: "Front matter summary",
+			Tags:    types.Tags{"go"},
+			Date:    &date,
+		})
+
+		assert.Equal(t, "Explicit Title", payload.Title)
+		assert.Equal(t, "Front matter summary", payload.Summary)
+		assert.Equal(t, types.Tags{}, payload.Tags)
+		assert.Equal(t, &date, payload.PublishedAt)
+	})
+}
+
+func TestGenerateETag(t *testing.T) {
+	t.Run("Should generate the same strong ETag for the same parts", func(t *testing.T) {
+		first := utilService.GenerateETag("a", "b")
+		second := utilService.GenerateETag("a", "b")
+
+		assert.Equal(t, first, second)
+		assert.Regexp(t, regexp.MustCompile(`^"[0-9a-f]{32}"$`), first)
+	})
+
+	t.Run("Should generate different ETags when the parts are split differently", func(t *testing.T) {
+		assert.NotEqual(t, utilService.GenerateETag("ab", "c"), utilService.GenerateETag("a", "bc"))
+	})
+}
+
+func TestIsNotModified(t *testing.T) {
+	etag := `"example-of-etag"`
+	lastModified := time.Date(2024, time.January, 1, 10, 0, 0, 500, time.UTC)
+
+	testCases := []struct {
+		name            string
+		ifNoneMatch     string
+		ifModifiedSince string
+		expected        bool
+	}{
+		{"unconditional request", "", "", false},
+		{"matching ETag", `"example-of-etag"`, "", true},
+		{"matching weak ETag", `W/"example-of-etag"`, "", true},
+		{"matching ETag in a list", `"other", "example-of-etag"`, "", true},
+		{"wildcard ETag", "*", "", true},
+		{"different ETag", `"other"`, "", false},
+		{"different ETag takes precedence over the date", `"other"`, "Mon, 01 Jan 2024 11:00:00 GMT", false},
+		{"same modification date", "", "Mon, 01 Jan 2024 10:00:00 GMT", true},
+		{"later modification date", "", "Mon, 01 Jan 2024 11:00:00 GMT", true},
+		{"earlier modification date", "", "Mon, 01 Jan 2024 09:00:00 GMT", false},
+		{"invalid modification date", "", "yesterday", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("Should return %t for %s", tc.expected, tc.name), func(t *testing.T) {
+			result := utilService.IsNotModified(tc.ifNoneMatch, tc.ifModifiedSince, etag, lastModified)
+
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestCheckNotModified(t *testing.T) {
+	validator := &types.Validator{
+		LastModified: time.Date(2024, time.January, 1, 10, 0, 0, 0, time.UTC),
+		Count:        3,
+	}
+
+	app := fiber.New()
+	handler := func(c *fiber.Ctx) error {
+		if utilService.CheckNotModified(c, validator, constants.CacheControlPublic, c.Query("order")) {
+			return c.SendStatus(fiber.StatusNotModified)
+		}
+
+		return c.SendString("example-of-body")
+	}
+	app.Get("/", handler)
+	app.Post("/", handler)
+
+	t.Run("Should set the validators on unconditional requests", func(t *testing.T) {
+		res, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Nil(t, err)
+
+		assert.Equal(t, fiber.StatusOK, res.StatusCode)
+		assert.NotEmpty(t, res.Header.Get(fiber.HeaderETag))
+		assert.Equal(t, "Mon, 01 Jan 2024 10:00:00 GMT", res.Header.Get(fiber.HeaderLastModified))
+		assert.Equal(t, constants.CacheControlPublic, res.Header.Get(fiber.HeaderCacheControl))
+	})
+
+	t.Run("Should answer 304 when the ETag matches", func(t *testing.T) {
+		res, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Nil(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(fiber.HeaderIfNoneMatch, res.Header.Get(fiber.HeaderETag))
+
+		res, err = app.Test(req)
+		require.Nil(t, err)
+
+		assert.Equal(t, fiber.StatusNotModified, res.StatusCode)
+	})
+
+	t.Run("Should not match the ETag of another variant", func(t *testing.T) {
+		res, err := app.Test(httptest.NewRequest(http.MethodGet, "/?order=DESC", nil))
+		require.Nil(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/?order=ASC", nil)
+		req.Header.Set(fiber.HeaderIfNoneMatch, res.Header.Get(fiber.HeaderETag))
+
+		res, err = app.Test(req)
+		require.Nil(t, err)
+
+		assert.Equal(t, fiber.StatusOK, res.StatusCode)
+	})
+
+	t.Run("Should never answer 304 to unsafe methods", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set(fiber.HeaderIfModifiedSince, "Mon, 01 Jan 2024 10:00:00 GMT")
+
+		res, err := app.Test(req)
+		require.Nil(t, err)
+
+		assert.Equal(t, fiber.StatusOK, res.StatusCode)
+	})
+}
+
+func TestParseVersionETag(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int
+	}{
+		{utilService.VersionETag(3), 3},
+		{`W/"v12"`, 12},
+		{` "v7" `, 7},
+		{"", 0},
+		{`"7"`, 0},
+		{`"vx"`, 0},
+		{`"v-1"`, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("Should parse: %s INTO %d", tc.input, tc.expected), func(t *testing.T) {
+			assert.Equal(t, tc.expected, utilService.ParseVersionETag(tc.input))
+		})
+	}
+}
@@ -0,0 +1,552 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+var (
+	ErrInvalidSource   = errors.New("Source must be an http(s) URL other than the target")
+	ErrInvalidTarget   = errors.New("Target is not a published blog")
+	ErrTooManyMentions = errors.New("Too many webmentions from this host, try again later")
+
+	// the source answered but does not mention the target, trying again would not change that
+	errNotMentioned = errors.New("Source does not link to the target")
+)
+
+type WebmentionService interface {
+	// ReceiveWebmention queues the webmention for verification once the target is known to be a published blog,
+	// the source is only fetched later by the verification job.
+	ReceiveWebmention(source string, target string) error
+
+	// GetMentions returns the verified webmentions of the blog.
+	GetMentions(blogID string) ([]entities.Webmention, error)
+
+	// VerifyPending verifies every webmention which is due, it is safe to run on multiple instances.
+	VerifyPending() error
+
+	// RunVerifyJob periodically verifies pending webmentions until the context is done.
+	RunVerifyJob(ctx context.Context)
+
+	// SendWebmentions notifies the pages the published blog links to,
+	// along with the pages it linked to before an edit removed the link.
+	SendWebmentions(blogID string) error
+}
+
+type WebmentionServiceImpl struct {
+	UtilService    UtilService
+	Repository     repositories.WebmentionRepository
+	BlogRepository repositories.BlogRepository
+	CacheService   CacheService
+	Client         *http.Client
+}
+
+// InitWebmentionClient returns a client reaching other sites on public addresses only,
+// sources and targets are user input and must not reach into the network of the server.
+func InitWebmentionClient() *http.Client {
+	return publicClient(constants.WebmentionRequestTimeout)
+}
+
+// SubscribeEvents registers the service to the events of blogs whose links are sent webmentions.
+func (service *WebmentionServiceImpl) SubscribeEvents(events EventService) {
+	for _, eventType := range []string{constants.EventBlogPublished, constants.EventBlogUpdated} {
+		events.Subscribe(eventType, func(event types.Event) {
+			if err := service.SendWebmentions(event.BlogID); err != nil {
+				log.Println("Error sending webmentions:", err)
+			}
+		})
+	}
+}
+
+func (service *WebmentionServiceImpl) ReceiveWebmention(source string, target string) error {
+	sourceURL, err := url.Parse(source)
+	if err != nil || !isWebURL(sourceURL) || source == target {
+		return ErrInvalidSource
+	}
+
+	blog, err := service.targetBlog(target)
+	if err != nil {
+		return err
+	}
+
+	sourceHost := strings.ToLower(sourceURL.Hostname())
+
+	count, err := service.Repository.CountRecentMentions(sourceHost, time.Now().Add(-constants.WebmentionHostWindow))
+	if err != nil {
+		return err
+	}
+
+	if count >= constants.WebmentionHostLimit {
+		return ErrTooManyMentions
+	}
+
+	return service.Repository.SaveMention(&entities.Webmention{
+		Source:        source,
+		Target:        target,
+		SourceHost:    sourceHost,
+		BlogID:        blog.ID,
+		Status:        constants.WebmentionPending,
+		NextAttemptAt: time.Now(),
+	})
+}
+
+func (service *WebmentionServiceImpl) GetMentions(blogID string) ([]entities.Webmention, error) {
+	var mentions []entities.Webmention
+
+	err := remember(service.CacheService, "webmentions:"+blogID, &mentions, func() (interface{}, []string, error) {
+		mentions, err := service.Repository.GetMentions(blogID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return mentions, []string{BlogCacheTag(blogID)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mentions, nil
+}
+
+func (service *WebmentionServiceImpl) VerifyPending() error {
+	for {
+		mention, err := service.Repository.ClaimPendingMention(constants.WebmentionJobTimeout)
+		if err != nil {
+			return err
+		}
+
+		if mention == nil {
+			return nil
+		}
+
+		now := time.Now()
+		mention.Attempts++
+
+		title, retry, err := service.verify(mention)
+
+		switch {
+		case err == nil:
+			mention.Status = constants.WebmentionVerified
+			mention.Title = title
+			mention.VerifiedAt = &now
+			mention.Error = ""
+		case !retry || mention.Attempts >= constants.WebmentionMaxAttempts:
+			// a mention verified before is hidden once its source stops linking to the blog
+			mention.Status = constants.WebmentionRejected
+			mention.Error = err.Error()
+		default:
+			mention.NextAttemptAt = now.Add(constants.WebmentionRetryDelay << (mention.Attempts - 1))
+			mention.Error = err.Error()
+		}
+
+		if err := service.Repository.FinishMention(mention); err != nil {
+			return err
+		}
+
+		invalidate(service.CacheService, BlogCacheTag(mention.BlogID))
+	}
+}
+
+func (service *WebmentionServiceImpl) RunVerifyJob(ctx context.Context) {
+	ticker := time.NewTicker(constants.WebmentionJobInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := service.VerifyPending(); err != nil {
+				log.Println("Error verifying webmentions:", err)
+			}
+		}
+	}
+}
+
+func (service *WebmentionServiceImpl) SendWebmentions(blogID string) error {
+	blog, err := service.BlogRepository.GetBlog(&types.GetBlogOpts{
+		UseID:          blogID,
+		IncludeContent: true,
+		Published:      true,
+	})
+	if err != nil {
+		return err
+	}
+
+	source := service.UtilService.BlogURL(blog.Author.Username, blog.Slug)
+
+	sent, err := service.Repository.GetSentWebmentions(blog.ID)
+	if err != nil {
+		return err
+	}
+
+	targets := markdownLinks(blog.Content, source)
+
+	// pages which lost their link are told as well, verifying it they drop the mention
+	linked := make(map[string]bool)
+	for _, target := range targets {
+		linked[target] = true
+	}
+
+	lastSent := make(map[string]time.Time)
+	for _, previous := range sent {
+		lastSent[previous.Target] = previous.SentAt
+
+		if !linked[previous.Target] {
+			targets = append(targets, previous.Target)
+		}
+	}
+
+	var failed int
+
+	for _, target := range targets {
+		// edits come in bursts, the pages linked to are not told about every one of them
+		if time.Since(lastSent[target]) < constants.WebmentionResendInterval {
+			continue
+		}
+
+		endpoint, status, err := service.send(source, target)
+		if err != nil {
+			failed++
+		}
+
+		if err := service.Repository.SaveSentWebmention(&entities.SentWebmention{
+			BlogID:     blog.ID,
+			Target:     target,
+			Endpoint:   endpoint,
+			StatusCode: status,
+			SentAt:     time.Now(),
+		}); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		log.Printf("Failed sending %d webmentions of blog %s", failed, blog.ID)
+	}
+
+	return nil
+}
+
+// targetBlog returns the published blog the URL is the page of.
+func (service *WebmentionServiceImpl) targetBlog(target string) (*entities.SafeBlogAuthor, error) {
+	targetURL, err := url.Parse(target)
+	if err != nil || !isWebURL(targetURL) {
+		return nil, ErrInvalidTarget
+	}
+
+	// blog pages are at /blog/<author>/<slug> on the client
+	segments := strings.Split(strings.Trim(targetURL.Path, "/"), "/")
+	if len(segments) != 3 || segments[0] != "blog" {
+		return nil, ErrInvalidTarget
+	}
+
+	blog, err := service.BlogRepository.GetBlog(&types.GetBlogOpts{
+		BlogAuthor: segments[1],
+		BlogSlug:   segments[2],
+		Published:  true,
+	})
+	if err != nil {
+		return nil, ErrInvalidTarget
+	}
+
+	// the path alone matches blogs of any other site
+	blogURL, err := url.Parse(service.UtilService.BlogURL(blog.Author.Username, blog.Slug))
+	if err != nil || !strings.EqualFold(blogURL.Host, targetURL.Host) {
+		return nil, ErrInvalidTarget
+	}
+
+	return blog, nil
+}
+
+// verify fetches the source to make sure it links to the target,
+// it returns the title of the source and whether a failed verification is worth retrying.
+func (service *WebmentionServiceImpl) verify(mention *entities.Webmention) (string, bool, error) {
+	req, err := http.NewRequest(http.MethodGet, mention.Source, nil)
+	if err != nil {
+		return "", false, err
+	}
+
+	req.Header.Set("Accept", "text/html, text/plain;q=0.9, */*;q=0.5")
+
+	res, err := service.Client.Do(req)
+	if err != nil {
+		return "", true, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests {
+		return "", true, fmt.Errorf("Source answered %s", res.Status)
+	}
+
+	// a deleted source, 410 Gone, removes the mention
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return "", false, fmt.Errorf("Source answered %s", res.Status)
+	}
+
+	body := io.LimitReader(res.Body, constants.WebmentionMaxBodySize)
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		content, err := io.ReadAll(body)
+		if err != nil {
+			return "", true, err
+		}
+
+		if !strings.Contains(string(content), mention.Target) {
+			return "", false, errNotMentioned
+		}
+
+		return "", false, nil
+	}
+
+	title, found := htmlMentions(body, res.Request.URL, mention.Target)
+	if !found {
+		return "", false, errNotMentioned
+	}
+
+	return title, false, nil
+}
+
+// send discovers the webmention endpoint of the target and notifies it,
+// the endpoint is empty when the target does not accept webmentions.
+func (service *WebmentionServiceImpl) send(source string, target string) (string, int, error) {
+	endpoint, err := service.discoverEndpoint(target)
+	if err != nil || endpoint == "" {
+		return "", 0, err
+	}
+
+	form := url.Values{"source": {source}, "target": {target}}
+
+	res, err := service.Client.PostForm(endpoint, form)
+	if err != nil {
+		return endpoint, 0, err
+	}
+	defer res.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(res.Body, constants.WebmentionMaxBodySize))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return endpoint, res.StatusCode, fmt.Errorf("Endpoint answered %s", res.Status)
+	}
+
+	return endpoint, res.StatusCode, nil
+}
+
+// discoverEndpoint looks for the endpoint in the Link headers first, then in the HTML,
+// relative endpoints are resolved against the URL the target redirected to, if it did.
+func (service *WebmentionServiceImpl) discoverEndpoint(target string) (string, error) {
+	res, err := service.Client.Get(target)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return "", nil
+	}
+
+	base := res.Request.URL
+
+	for _, header := range res.Header.Values("Link") {
+		if endpoint := linkHeaderEndpoint(header); endpoint != "" {
+			return resolveEndpoint(base, endpoint)
+		}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType != "text/html" {
+		return "", nil
+	}
+
+	tokenizer := html.NewTokenizer(io.LimitReader(res.Body, constants.WebmentionMaxBodySize))
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return "", nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+
+			if token.DataAtom != atom.Link && token.DataAtom != atom.A {
+				continue
+			}
+
+			href, hasHref := htmlAttribute(token, "href")
+			rel, _ := htmlAttribute(token, "rel")
+
+			// an empty href is the target itself
+			if hasHref && hasRel(rel, "webmention") {
+				return resolveEndpoint(base, href)
+			}
+		}
+	}
+}
+
+func linkHeaderEndpoint(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+
+		endpoint := strings.TrimSpace(parts[0])
+		if !strings.HasPrefix(endpoint, "<") || !strings.HasSuffix(endpoint, ">") {
+			continue
+		}
+
+		for _, param := range parts[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+
+			if strings.EqualFold(name, "rel") && hasRel(strings.Trim(value, `"`), "webmention") {
+				return strings.Trim(endpoint, "<>")
+			}
+		}
+	}
+
+	return ""
+}
+
+func resolveEndpoint(base *url.URL, endpoint string) (string, error) {
+	resolved, err := base.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	if !isWebURL(resolved) {
+		return "", nil
+	}
+
+	return resolved.String(), nil
+}
+
+// htmlMentions reports whether the page links to the target, along with its title.
+func htmlMentions(body io.Reader, base *url.URL, target string) (string, bool) {
+	var title strings.Builder
+	var inTitle, found bool
+
+	tokenizer := html.NewTokenizer(body)
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return strings.TrimSpace(title.String()), found
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+
+			if token.DataAtom == atom.Title {
+				inTitle = true
+			}
+
+			// links, images and embeds all count as mentions
+			for _, name := range []string{"href", "src"} {
+				if value, ok := htmlAttribute(token, name); ok && !found {
+					if resolved, err := base.Parse(value); err == nil {
+						found = sameWebURL(resolved.String(), target)
+					}
+				}
+			}
+		case html.EndTagToken:
+			if tokenizer.Token().DataAtom == atom.Title {
+				inTitle = false
+			}
+		case html.TextToken:
+			if inTitle && title.Len() < 300 {
+				title.Write(tokenizer.Text())
+			}
+		}
+	}
+}
+
+// markdownLinks returns the distinct web links of the Markdown, other than those to the source itself.
+func markdownLinks(markdown string, source string) []string {
+	var links []string
+	seen := map[string]bool{source: true}
+
+	content := []byte(markdown)
+
+	ast.Walk(markdownParser.Parse(text.NewReader(content)), func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering || len(links) == constants.WebmentionMaxLinks {
+			return ast.WalkContinue, nil
+		}
+
+		var link string
+
+		switch node := node.(type) {
+		case *ast.Link:
+			link = string(node.Destination)
+		case *ast.AutoLink:
+			link = string(node.URL(content))
+		default:
+			return ast.WalkContinue, nil
+		}
+
+		parsed, err := url.Parse(link)
+		if err != nil || !isWebURL(parsed) {
+			return ast.WalkContinue, nil
+		}
+
+		// the same page linked to different sections is mentioned once
+		parsed.Fragment = ""
+		link = parsed.String()
+
+		if !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	return links
+}
+
+func htmlAttribute(token html.Token, name string) (string, bool) {
+	for _, attr := range token.Attr {
+		if attr.Key == name {
+			return attr.Val, true
+		}
+	}
+
+	return "", false
+}
+
+func hasRel(rel string, value string) bool {
+	for _, field := range strings.Fields(rel) {
+		if strings.EqualFold(field, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isWebURL(parsed *url.URL) bool {
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// sameWebURL compares URLs regardless of their fragment and trailing slash.
+func sameWebURL(a string, b string) bool {
+	normalize := func(link string) string {
+		link, _, _ = strings.Cut(link, "#")
+
+		return strings.TrimSuffix(link, "/")
+	}
+
+	return normalize(a) == normalize(b)
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/entities"
+	"resqiar.com-server/repositories"
+	"resqiar.com-server/types"
+)
+
+var webmentionRepoTest = repositories.WebmentionRepoMock{}
+var webmentionBlogRepoTest = repositories.BlogRepoMock{}
+var webmentionServiceTest = WebmentionServiceImpl{
+	UtilService:    InitUtilService(),
+	Repository:     &webmentionRepoTest,
+	BlogRepository: &webmentionBlogRepoTest,
+	Client:         http.DefaultClient,
+}
+
+var webmentionBlog = &entities.SafeBlogAuthor{
+	SafeBlog: entities.SafeBlog{ID: "example-of-blog-id", Slug: "hello"},
+	Author:   entities.SafeUser{Username: "author"},
+}
+
+func resetWebmentionMocks() {
+	webmentionRepoTest.Mock.ExpectedCalls = nil
+	webmentionRepoTest.Mock.Calls = nil
+	webmentionBlogRepoTest.Mock.ExpectedCalls = nil
+}
+
+func TestReceiveWebmention(t *testing.T) {
+	t.Setenv("CLIENT_URL", "https://blog.example.com")
+	t.Cleanup(resetWebmentionMocks)
+
+	target := "https://blog.example.com/blog/author/hello"
+	blogOpts := &types.GetBlogOpts{BlogAuthor: "author", BlogSlug: "hello", Published: true}
+
+	t.Run("Should queue the webmention of a published blog", func(t *testing.T) {
+		webmentionBlogRepoTest.Mock.On("GetBlog", blogOpts).Return(webmentionBlog, nil).Once()
+		webmentionRepoTest.Mock.On("CountRecentMentions", "other.example", mock.Anything).Return(int64(0), nil).Once()
+		webmentionRepoTest.Mock.On("SaveMention", mock.MatchedBy(func(mention *entities.Webmention) bool {
+			return mention.Source == "https://other.example/reply" && mention.Target == target &&
+				mention.BlogID == webmentionBlog.ID && mention.Status == constants.WebmentionPending
+		})).Return(nil).Once()
+
+		err := webmentionServiceTest.ReceiveWebmention("https://other.example/reply", target)
+
+		assert.Nil(t, err)
+		webmentionRepoTest.Mock.AssertExpectations(t)
+	})
+
+	t.Run("Should refuse invalid sources", func(t *testing.T) {
+		for _, source := range []string{"ftp://other.example/reply", "not a url", target} {
+			err := webmentionServiceTest.ReceiveWebmention(source, target)
+
+			assert.ErrorIs(t, err, ErrInvalidSource, source)
+		}
+	})
+
+	t.Run("Should refuse targets which are not blogs of this site", func(t *testing.T) {
+		webmentionBlogRepoTest.Mock.On("GetBlog", blogOpts).Return(webmentionBlog, nil).Once()
+		webmentionBlogRepoTest.Mock.On("GetBlog", &types.GetBlogOpts{BlogAuthor: "author", BlogSlug: "missing", Published: true}).Return(nil, errors.New("404")).Once()
+
+		for _, target := range []string{
+			"https://elsewhere.example/blog/author/hello",
+			"https://blog.example.com/blog/author/missing",
+			"https://blog.example.com/profile/author",
+		} {
+			err := webmentionServiceTest.ReceiveWebmention("https://other.example/reply", target)
+
+			assert.ErrorIs(t, err, ErrInvalidTarget, target)
+		}
+	})
+
+	t.Run("Should limit the webmentions of a host", func(t *testing.T) {
+		webmentionRepoTest.Mock.Calls = nil
+		webmentionBlogRepoTest.Mock.On("GetBlog", blogOpts).Return(webmentionBlog, nil).Once()
+		webmentionRepoTest.Mock.On("CountRecentMentions", "spam.example", mock.Anything).Return(int64(constants.WebmentionHostLimit), nil).Once()
+
+		err := webmentionServiceTest.ReceiveWebmention("https://spam.example/reply", target)
+
+		assert.ErrorIs(t, err, ErrTooManyMentions)
+		webmentionRepoTest.Mock.AssertNotCalled(t, "SaveMention", mock.Anything)
+	})
+}
+
+func TestVerifyPending(t *testing.T) {
+	t.Cleanup(resetWebmentionMocks)
+
+	target := "https://blog.example.com/blog/author/hello"
+
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/links":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprintf(w, `<html><head><title>A reply</title></head><body><p>I agree with <a href="%s/">this post</a></p></body></html>`, target)
+		case "/plain":
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprintf(w, "See %s", target)
+		case "/unlinked":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<a href="https://blog.example.com/blog/author/other">another post</a>`)
+		case "/gone":
+			w.WriteHeader(http.StatusGone)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(source.Close)
+
+	verify := func(t *testing.T, path string) *entities.Webmention {
+		mention := &entities.Webmention{
+			ID:     "example-of-mention-id",
+			Source: source.URL + path,
+			Target: target,
+			BlogID: webmentionBlog.ID,
+			Status: constants.WebmentionPending,
+		}
+
+		webmentionRepoTest.Mock.ExpectedCalls = nil
+		webmentionRepoTest.Mock.On("ClaimPendingMention", constants.WebmentionJobTimeout).Return(mention, nil).Once()
+		webmentionRepoTest.Mock.On("ClaimPendingMention", constants.WebmentionJobTimeout).Return(nil, nil).Once()
+		webmentionRepoTest.Mock.On("FinishMention", mention).Return(nil).Once()
+
+		require.Nil(t, webmentionServiceTest.VerifyPending())
+		webmentionRepoTest.Mock.AssertExpectations(t)
+
+		return mention
+	}
+
+	t.Run("Should verify a page linking to the target", func(t *testing.T) {
+		mention := verify(t, "/links")
+
+		assert.Equal(t, constants.WebmentionVerified, mention.Status)
+		assert.Equal(t, "A reply", mention.Title)
+		assert.NotNil(t, mention.VerifiedAt)
+	})
+
+	t.Run("Should verify a plain text mentioning the target", func(t *testing.T) {
+		mention := verify(t, "/plain")
+
+		assert.Equal(t, constants.WebmentionVerified, mention.Status)
+	})
+
+	t.Run("Should reject a page not linking to the target", func(t *testing.T) {
+		mention := verify(t, "/unlinked")
+
+		assert.Equal(t, constants.WebmentionRejected, mention.Status)
+		assert.Equal(t, errNotMentioned.Error(), mention.Error)
+	})
+
+	t.Run("Should reject a deleted source", func(t *testing.T) {
+		mention := verify(t, "/gone")
+
+		assert.Equal(t, constants.WebmentionRejected, mention.Status)
+	})
+
+	t.Run("Should retry later when the source fails", func(t *testing.T) {
+		mention := verify(t, "/error")
+
+		assert.Equal(t, constants.WebmentionPending, mention.Status)
+		assert.Equal(t, 1, mention.Attempts)
+		assert.WithinDuration(t, time.Now().Add(constants.WebmentionRetryDelay), mention.NextAttemptAt, time.Minute)
+	})
+}
+
+func TestSendWebmentions(t *testing.T) {
+	t.Setenv("CLIENT_URL", "https://blog.example.com")
+	t.Cleanup(resetWebmentionMocks)
+
+	var mu sync.Mutex
+	var received []string
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/header":
+			w.Header().Add("Link", `<https://other.example/feed>; rel="alternate", </endpoint>; rel="webmention"`)
+		case "/html", "/removed":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><head><link rel="stylesheet" href="/style.css"><link rel="webmention" href="endpoint"></head></html>`)
+		case "/redirect":
+			http.Redirect(w, r, "/html", http.StatusFound)
+		case "/endpoint":
+			r.ParseForm()
+
+			mu.Lock()
+			received = append(received, r.PostForm.Get("target"))
+			mu.Unlock()
+
+			assert.Equal(t, "https://blog.example.com/blog/author/hello", r.PostForm.Get("source"))
+
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<p>no endpoint</p>`)
+		}
+	}))
+	t.Cleanup(target.Close)
+
+	blog := *webmentionBlog
+	blog.Content = fmt.Sprintf("Read [this](%[1]s/header) and [that](%[1]s/html#section), again [that](%[1]s/html).\n\n"+
+		"Also <%[1]s/redirect>, <%[1]s/none> and [myself](https://blog.example.com/blog/author/hello).", target.URL)
+
+	t.Run("Should send webmentions to the links of the blog and to the removed ones", func(t *testing.T) {
+		webmentionBlogRepoTest.Mock.On("GetBlog", &types.GetBlogOpts{UseID: blog.ID, IncludeContent: true, Published: true}).Return(&blog, nil).Once()
+		webmentionRepoTest.Mock.On("GetSentWebmentions", blog.ID).Return([]entities.SentWebmention{
+			{BlogID: blog.ID, Target: target.URL + "/removed", SentAt: time.Now().Add(-time.Hour)},
+			{BlogID: blog.ID, Target: target.URL + "/recent", SentAt: time.Now()},
+		}, nil).Once()
+		webmentionRepoTest.Mock.On("SaveSentWebmention", mock.Anything).Return(nil)
+
+		err := webmentionServiceTest.SendWebmentions(blog.ID)
+		require.Nil(t, err)
+
+		sort.Strings(received)
+
+		assert.Equal(t, []string{
+			target.URL + "/header",
+			target.URL + "/html",
+			target.URL + "/redirect",
+			target.URL + "/removed",
+		}, received)
+
+		var saved []string
+		for _, call := range webmentionRepoTest.Mock.Calls {
+			if call.Method == "SaveSentWebmention" {
+				sent := call.Arguments.Get(0).(*entities.SentWebmention)
+				saved = append(saved, sent.Target)
+
+				if sent.Target == target.URL+"/none" {
+					assert.Empty(t, sent.Endpoint)
+				} else {
+					assert.Equal(t, http.StatusAccepted, sent.StatusCode)
+				}
+			}
+		}
+
+		// the recently sent target waits for the next edit
+		assert.Len(t, saved, 5)
+		assert.NotContains(t, saved, target.URL+"/recent")
+	})
+}
+
+func TestMarkdownLinks(t *testing.T) {
+	t.Run("Should return the distinct web links", func(t *testing.T) {
+		links := markdownLinks("[a](https://a.example/x#one) [b](https://a.example/x#two) https://b.example/page [mail](mailto:me@example.com) [rel](/relative) ![img](https://c.example/img.png)", "https://self.example")
+
+		assert.Equal(t, []string{"https://a.example/x", "https://b.example/page"}, links)
+	})
+
+	t.Run("Should resolve endpoints against the target", func(t *testing.T) {
+		base, _ := url.Parse("https://a.example/posts/1")
+
+		endpoint, err := resolveEndpoint(base, "")
+		assert.Nil(t, err)
+		assert.Equal(t, "https://a.example/posts/1", endpoint)
+
+		endpoint, err = resolveEndpoint(base, "../webmention?x=1")
+		assert.Nil(t, err)
+		assert.Equal(t, "https://a.example/webmention?x=1", endpoint)
+	})
+}
@@ -0,0 +1,335 @@
+package services
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+	"github.com/JohannesKaufmann/html-to-markdown/plugin"
+	"github.com/PuerkitoBio/goquery"
+	"resqiar.com-server/constants"
+	"resqiar.com-server/inputs"
+)
+
+// The functions below read WordPress exports (WXR), the RSS feed WordPress
+// exports its posts, pages and attachments as from Tools > Export.
+
+var (
+	ErrInvalidExport = errors.New("File is not a valid WordPress export")
+	ErrEmptyExport   = errors.New("Export has no posts")
+	ErrTooManyPosts  = errors.New("Export has too many posts")
+
+	// WordPress separates paragraphs by blank lines rather than wrapping them in <p>
+	blankLineRegex  = regexp.MustCompile(`\n\s*\n`)
+	blockStartRegex = regexp.MustCompile(`^<(?i:h[1-6]|p|ul|ol|li|blockquote|pre|figure|div|table|hr|!--)`)
+)
+
+type wxrExport struct {
+	Channel wxrChannel `xml:"channel"`
+}
+
+type wxrChannel struct {
+	Link        string    `xml:"link"`
+	BaseBlogURL string    `xml:"base_blog_url"`
+	Items       []wxrItem `xml:"item"`
+}
+
+type wxrItem struct {
+	Title         string        `xml:"title"`
+	Link          string        `xml:"link"`
+	GUID          string        `xml:"guid"`
+	Encoded       []wxrEncoded  `xml:"encoded"`
+	PostID        string        `xml:"post_id"`
+	PostDate      string        `xml:"post_date"`
+	PostDateGMT   string        `xml:"post_date_gmt"`
+	PostName      string        `xml:"post_name"`
+	Status        string        `xml:"status"`
+	PostType      string        `xml:"post_type"`
+	AttachmentURL string        `xml:"attachment_url"`
+	Categories    []wxrCategory `xml:"category"`
+	Meta          []wxrMeta     `xml:"postmeta"`
+}
+
+// wxrEncoded is either the content or the excerpt of the item, told apart by their namespace.
+type wxrEncoded struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+type wxrCategory struct {
+	Domain   string `xml:"domain,attr"`
+	Nicename string `xml:"nicename,attr"`
+	Name     string `xml:",chardata"`
+}
+
+type wxrMeta struct {
+	Key   string `xml:"meta_key"`
+	Value string `xml:"meta_value"`
+}
+
+// wordPressStates maps the status of the posts worth importing to the state of their blog,
+// scheduled posts are imported as drafts keeping their publish date.
+var wordPressStates = map[string]string{
+	"publish": constants.BlogPublished,
+	"future":  constants.BlogDraft,
+	"pending": constants.BlogInReview,
+	"draft":   constants.BlogDraft,
+	"private": constants.BlogDraft,
+}
+
+// parseWordPressExport returns the channel of the export along with the posts worth importing.
+func parseWordPressExport(export []byte) (*wxrChannel, []wxrItem, error) {
+	var parsed wxrExport
+
+	decoder := xml.NewDecoder(bytes.NewReader(export))
+	decoder.Entity = xml.HTMLEntity
+
+	if err := decoder.Decode(&parsed); err != nil {
+		return nil, nil, ErrInvalidExport
+	}
+
+	var posts []wxrItem
+
+	for _, item := range parsed.Channel.Items {
+		if _, exist := wordPressStates[item.Status]; item.PostType == "post" && exist {
+			posts = append(posts, item)
+		}
+	}
+
+	return &parsed.Channel, posts, nil
+}
+
+// wordPressEntries lists the posts of the WordPress export,
+// all of them are created for the author keeping their status.
+func (service *ImportServiceImpl) wordPressEntries(export []byte, authorID string) ([]importEntry, error) {
+	channel, posts, err := parseWordPressExport(export)
+	if err != nil {
+		return nil, err
+	}
+
+	siteURL := channel.Link
+	if siteURL == "" {
+		siteURL = channel.BaseBlogURL
+	}
+
+	// featured images are attachments referenced by their ID
+	attachments := make(map[string]string)
+	for _, item := range channel.Items {
+		if item.PostType == "attachment" && item.AttachmentURL != "" {
+			attachments[item.PostID] = item.AttachmentURL
+		}
+	}
+
+	entries := make([]importEntry, 0, len(posts))
+
+	for _, post := range posts {
+		post := post
+
+		path := post.Link
+		if path == "" {
+			path = post.GUID
+		}
+
+		entries = append(entries, importEntry{
+			Path: path,
+			Read: func() (*importedPost, error) {
+				return service.readWordPressPost(&post, siteURL, attachments, authorID)
+			},
+		})
+	}
+
+	return entries, nil
+}
+
+func (service *ImportServiceImpl) readWordPressPost(post *wxrItem, siteURL string, attachments map[string]string, authorID string) (*importedPost, error) {
+	var content, excerpt string
+
+	for _, encoded := range post.Encoded {
+		switch {
+		case strings.HasPrefix(encoded.XMLName.Space, "http://purl.org/rss/1.0/modules/content"):
+			content = encoded.Value
+		case strings.Contains(encoded.XMLName.Space, "/excerpt/"):
+			excerpt = encoded.Value
+		}
+	}
+
+	markdown, images, err := wordPressMarkdown(content, siteURL)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := inputs.CreateBlogInput{
+		Title:   strings.TrimSpace(post.Title),
+		Summary: wordPressSummary(excerpt),
+		Content: markdown,
+		Tags:    wordPressTags(post.Categories),
+	}
+
+	for _, meta := range post.Meta {
+		if cover, exist := attachments[meta.Value]; meta.Key == "_thumbnail_id" && exist {
+			payload.CoverURL = cover
+			images = append([]string{cover}, images...)
+		}
+	}
+
+	// WordPress escapes the slugs of titles written in other scripts
+	slug, err := url.PathUnescape(post.PostName)
+	if err != nil {
+		slug = post.PostName
+	}
+
+	payload.Slug = service.UtilService.FormatToURL(strings.ReplaceAll(slug, "-", " "))
+	if payload.Slug == "" {
+		payload.Slug = service.UtilService.FormatToURL(payload.Title)
+	}
+
+	if err := service.UtilService.ValidateInput(payload); err != "" {
+		return nil, errors.New(err)
+	}
+
+	externalID := post.GUID
+	if externalID == "" {
+		externalID = siteURL + "?p=" + post.PostID
+	}
+
+	return &importedPost{
+		Blog:       service.importBlog(&payload, authorID, wordPressStates[post.Status], wordPressDate(post)),
+		ExternalID: externalID,
+		Images:     images,
+	}, nil
+}
+
+// wordPressMarkdown converts the HTML of the post into Markdown,
+// it also returns the URLs of the images the post shows.
+func wordPressMarkdown(content string, siteURL string) (string, []string, error) {
+	document, err := goquery.NewDocumentFromReader(strings.NewReader(wordPressParagraphs(content)))
+	if err != nil {
+		return "", nil, err
+	}
+
+	// uploads are usually linked relative to the site, they would break once moved here
+	if base, err := url.Parse(siteURL); err == nil {
+		document.Find("img[src], a[href]").Each(func(_ int, element *goquery.Selection) {
+			attr := "src"
+			if goquery.NodeName(element) == "a" {
+				attr = "href"
+			}
+
+			value, _ := element.Attr(attr)
+
+			// anchors within the post stay as they are
+			if strings.HasPrefix(value, "#") {
+				return
+			}
+
+			if ref, err := url.Parse(value); err == nil {
+				element.SetAttr(attr, base.ResolveReference(ref).String())
+			}
+		})
+	}
+
+	var images []string
+	seen := make(map[string]bool)
+
+	document.Find("img[src]").Each(func(_ int, image *goquery.Selection) {
+		src, _ := image.Attr("src")
+
+		if !seen[src] {
+			seen[src] = true
+			images = append(images, src)
+		}
+	})
+
+	converter := md.NewConverter(md.DomainFromURL(siteURL), true, nil)
+	converter.Use(plugin.GitHubFlavored())
+
+	return converter.Convert(document.Selection), images, nil
+}
+
+// wordPressParagraphs wraps the paragraphs WordPress adds when displaying the post,
+// block elements and posts written with the block editor are left as they are.
+func wordPressParagraphs(content string) string {
+	if strings.Contains(content, "<p") {
+		return content
+	}
+
+	blocks := blankLineRegex.Split(strings.ReplaceAll(content, "\r\n", "\n"), -1)
+
+	for i, block := range blocks {
+		block = strings.TrimSpace(block)
+
+		if block != "" && !blockStartRegex.MatchString(block) {
+			block = "<p>" + strings.ReplaceAll(block, "\n", "<br>\n") + "</p>"
+		}
+
+		blocks[i] = block
+	}
+
+	return strings.Join(blocks, "\n")
+}
+
+// wordPressSummary is the excerpt as plain text, cut to fit the summary of a blog.
+func wordPressSummary(excerpt string) string {
+	document, err := goquery.NewDocumentFromReader(strings.NewReader(excerpt))
+	if err != nil {
+		return ""
+	}
+
+	summary := strings.Join(strings.Fields(document.Text()), " ")
+
+	if utf8.RuneCountInString(summary) > 300 {
+		summary = string([]rune(summary)[:297]) + "..."
+	}
+
+	return summary
+}
+
+// wordPressTags maps both categories and tags of the post to tags, apart from the default category.
+func wordPressTags(categories []wxrCategory) []string {
+	var tags []string
+	seen := make(map[string]bool)
+
+	for _, category := range categories {
+		name := strings.TrimSpace(category.Name)
+		key := strings.ToLower(name)
+
+		if category.Domain != "category" && category.Domain != "post_tag" {
+			continue
+		}
+
+		if name == "" || category.Nicename == "uncategorized" || utf8.RuneCountInString(name) > 30 || seen[key] {
+			continue
+		}
+
+		seen[key] = true
+		tags = append(tags, name)
+
+		if len(tags) == constants.ImportMaxTags {
+			break
+		}
+	}
+
+	return tags
+}
+
+// wordPressDate returns when the post was published, drafts may not have a date yet.
+func wordPressDate(post *wxrItem) time.Time {
+	const layout = "2006-01-02 15:04:05"
+
+	if date, err := time.Parse(layout, post.PostDateGMT); err == nil {
+		return date
+	}
+
+	// the local time of the site, its time zone is not part of the export
+	if date, err := time.Parse(layout, post.PostDate); err == nil {
+		return date
+	}
+
+	return time.Now()
+}
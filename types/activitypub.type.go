@@ -0,0 +1,101 @@
+package types
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Documents of ActivityPub, only the properties used by the server are listed.
+
+type Actor struct {
+	Context           []string        `json:"@context,omitempty"`
+	ID                string          `json:"id"`
+	Type              string          `json:"type"`
+	PreferredUsername string          `json:"preferredUsername"`
+	Name              string          `json:"name,omitempty"`
+	Summary           string          `json:"summary,omitempty"`
+	URL               string          `json:"url,omitempty"`
+	Icon              *ActorIcon      `json:"icon,omitempty"`
+	Inbox             string          `json:"inbox"`
+	Outbox            string          `json:"outbox,omitempty"`
+	Followers         string          `json:"followers,omitempty"`
+	PublicKey         ActorPublicKey  `json:"publicKey"`
+	Endpoints         *ActorEndpoints `json:"endpoints,omitempty"`
+}
+
+type ActorIcon struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type ActorPublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+type ActorEndpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+// Article is a blog as seen by other servers, a Tombstone once it is unpublished.
+type Article struct {
+	Context      string       `json:"@context,omitempty"`
+	ID           string       `json:"id"`
+	Type         string       `json:"type"`
+	AttributedTo string       `json:"attributedTo,omitempty"`
+	Name         string       `json:"name,omitempty"`
+	Summary      string       `json:"summary,omitempty"`
+	Content      string       `json:"content,omitempty"`
+	URL          string       `json:"url,omitempty"`
+	Published    string       `json:"published,omitempty"`
+	Updated      string       `json:"updated,omitempty"`
+	To           []string     `json:"to,omitempty"`
+	Cc           []string     `json:"cc,omitempty"`
+	Tag          []ArticleTag `json:"tag,omitempty"`
+}
+
+type ArticleTag struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+// Activity is what actors send each other, the object is either embedded or its ID.
+type Activity struct {
+	Context   string          `json:"@context,omitempty"`
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Actor     string          `json:"actor"`
+	Published string          `json:"published,omitempty"`
+	To        []string        `json:"to,omitempty"`
+	Cc        []string        `json:"cc,omitempty"`
+	Object    json.RawMessage `json:"object"`
+}
+
+type OrderedCollection struct {
+	Context      string     `json:"@context,omitempty"`
+	ID           string     `json:"id"`
+	Type         string     `json:"type"`
+	TotalItems   int64      `json:"totalItems"`
+	OrderedItems []Activity `json:"orderedItems,omitempty"`
+}
+
+type WebFinger struct {
+	Subject string          `json:"subject"`
+	Aliases []string        `json:"aliases,omitempty"`
+	Links   []WebFingerLink `json:"links"`
+}
+
+type WebFingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href"`
+}
+
+// SignedRequest is a request received by an inbox, its signature covers these parts.
+type SignedRequest struct {
+	Method string
+	Target string // path and query
+	Header http.Header
+	Body   []byte
+}
@@ -0,0 +1,21 @@
+package types
+
+import "time"
+
+// Autosave is a draft snapshot of a blog kept in Redis until it is flushed next to the blog.
+type Autosave struct {
+	BlogID string
+	UserID string
+
+	Title    string
+	Summary  string
+	Content  string
+	CoverURL string
+	Prev     string
+	Next     string
+
+	// Base is the version the editor started from, the explicit save is based on it
+	Base int
+
+	SavedAt time.Time
+}
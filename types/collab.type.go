@@ -0,0 +1,46 @@
+package types
+
+// CollabSession is the shared document of a blog being edited collaboratively.
+type CollabSession struct {
+	BlogID    string
+	Content   string
+	Revision  int // how many operations were applied since the session started
+	Version   int // version of the blog the session was last persisted to
+	Persisted int // revision that was last persisted to the blog
+}
+
+// CollabOperation is an operation applied to the session, it turned the document into Revision.
+type CollabOperation struct {
+	Revision  int
+	ClientID  string
+	Operation TextOperation
+}
+
+// CollabCursor positions are relative to the document at Revision.
+type CollabCursor struct {
+	Revision     int
+	Position     int
+	SelectionEnd int
+}
+
+// CollabPresence is a client connected to the session, a user may have several.
+type CollabPresence struct {
+	ClientID   string
+	UserID     string
+	Username   string
+	PictureURL string
+	Cursor     *CollabCursor
+}
+
+// CollabMessage is exchanged with the clients over the WebSocket and between server instances,
+// Type tells which of the other fields are set.
+type CollabMessage struct {
+	Type      string
+	ClientID  string
+	Revision  int
+	Operation TextOperation
+	Cursor    *CollabCursor
+	Presence  []CollabPresence
+	Content   string
+	Error     string
+}
@@ -0,0 +1,8 @@
+package types
+
+type Event struct {
+	Type    string
+	ActorID string // user who triggered the event
+	UserID  string // user affected by the event, if any
+	BlogID  string // blog affected by the event, if any
+}
@@ -0,0 +1,10 @@
+package types
+
+import "time"
+
+// FeedCursor points to the last blog of the previous feed page,
+// the next page starts right after it.
+type FeedCursor struct {
+	PublishedAt time.Time
+	ID          string
+}
@@ -0,0 +1,54 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// FrontMatter is the metadata written at the top of a Markdown document,
+// either as YAML between "---" lines or as TOML between "+++" lines.
+// Exports write it back out, leaving out what the blog does not have.
+type FrontMatter struct {
+	Title    string     `yaml:"title,omitempty" toml:"title,omitempty"`
+	Summary  string     `yaml:"summary,omitempty" toml:"summary,omitempty"`
+	CoverURL string     `yaml:"cover,omitempty" toml:"cover,omitempty"`
+	Tags     Tags       `yaml:"tags,omitempty" toml:"tags,omitempty"`
+	Slug     string     `yaml:"slug,omitempty" toml:"slug,omitempty"`
+	Date     *time.Time `yaml:"date,omitempty" toml:"date,omitempty"`
+
+	// Hugo marks unpublished posts as drafts, Jekyll as not published
+	Draft     bool  `yaml:"draft,omitempty" toml:"draft,omitempty"`
+	Published *bool `yaml:"published,omitempty" toml:"published,omitempty"`
+}
+
+// Tags is stored as JSON along with the blog.
+type Tags []string
+
+func (tags Tags) Value() (driver.Value, error) {
+	if tags == nil {
+		return nil, nil
+	}
+
+	value, err := json.Marshal(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(value), nil
+}
+
+func (tags *Tags) Scan(value interface{}) error {
+	switch value := value.(type) {
+	case nil:
+		*tags = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(value, tags)
+	case string:
+		return json.Unmarshal([]byte(value), tags)
+	}
+
+	return errors.New("Unsupported tags value")
+}
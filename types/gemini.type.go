@@ -0,0 +1,9 @@
+package types
+
+// GeminiResponse is written back as its header line "<Status> <Meta>",
+// followed by the body on success.
+type GeminiResponse struct {
+	Status int
+	Meta   string
+	Body   []byte
+}
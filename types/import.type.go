@@ -0,0 +1,55 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ImportItem is the outcome of importing a single post,
+// a dry run reports what would have been created without a BlogID.
+// Skipped posts were imported before, BlogID is the blog they were imported as.
+type ImportItem struct {
+	Path        string
+	Title       string
+	Slug        string
+	State       string
+	PublishedAt time.Time
+	BlogID      string `json:",omitempty"`
+	Skipped     bool   `json:",omitempty"`
+	Error       string `json:",omitempty"`
+
+	// images the post links to, they are still hosted where the post comes from
+	Images []string `json:",omitempty"`
+}
+
+// ImportItems is stored as JSON along with the import job.
+type ImportItems []ImportItem
+
+func (items ImportItems) Value() (driver.Value, error) {
+	if items == nil {
+		return nil, nil
+	}
+
+	value, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(value), nil
+}
+
+func (items *ImportItems) Scan(value interface{}) error {
+	switch value := value.(type) {
+	case nil:
+		*items = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(value, items)
+	case string:
+		return json.Unmarshal([]byte(value), items)
+	}
+
+	return errors.New("Unsupported import items value")
+}
@@ -0,0 +1,44 @@
+package types
+
+// IndieAuthMetadata is the OAuth authorization server metadata clients discover the endpoints with.
+type IndieAuthMetadata struct {
+	Issuer                        string   `json:"issuer"`
+	AuthorizationEndpoint         string   `json:"authorization_endpoint"`
+	TokenEndpoint                 string   `json:"token_endpoint"`
+	RevocationEndpoint            string   `json:"revocation_endpoint"`
+	ScopesSupported               []string `json:"scopes_supported"`
+	ResponseTypesSupported        []string `json:"response_types_supported"`
+	GrantTypesSupported           []string `json:"grant_types_supported"`
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported"`
+
+	AuthorizationResponseIssParameterSupported bool `json:"authorization_response_iss_parameter_supported"`
+}
+
+// IndieAuthProfile lists the endpoints advertised by the profile URL of a user.
+type IndieAuthProfile struct {
+	Me         string // the profile URL itself
+	ProfileURL string // the profile page on the client
+	Name       string
+
+	Metadata              string
+	AuthorizationEndpoint string
+	TokenEndpoint         string
+	Micropub              string
+}
+
+// AuthorizationPrompt is what the user is asked to consent to.
+type AuthorizationPrompt struct {
+	Me          string   `json:"me"`
+	ClientID    string   `json:"client_id"`
+	RedirectURI string   `json:"redirect_uri"`
+	Scopes      []string `json:"scopes"`
+}
+
+type TokenResponse struct {
+	AccessToken string `json:"access_token,omitempty"`
+	TokenType   string `json:"token_type,omitempty"`
+	Scope       string `json:"scope"`
+	Me          string `json:"me"`
+	ClientID    string `json:"client_id,omitempty"`
+	ExpiresIn   int    `json:"expires_in,omitempty"` // seconds
+}
@@ -0,0 +1,11 @@
+package types
+
+type Mail struct {
+	To      string
+	Subject string
+	Text    string // plain-text alternative
+	HTML    string
+
+	// extra headers such as List-Unsubscribe
+	Headers map[string]string
+}
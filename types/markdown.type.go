@@ -0,0 +1,55 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// Heading is an entry of the table of contents, nested under the closest heading above it
+// with a higher level. ID is the anchor generated for the heading in the rendered HTML.
+type Heading struct {
+	ID       string
+	Text     string
+	Level    int
+	Children []Heading `json:",omitempty"`
+}
+
+// TableOfContents is stored as JSON along with the rendered blog.
+type TableOfContents []Heading
+
+func (toc TableOfContents) Value() (driver.Value, error) {
+	if toc == nil {
+		return nil, nil
+	}
+
+	value, err := json.Marshal(toc)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(value), nil
+}
+
+func (toc *TableOfContents) Scan(value interface{}) error {
+	switch value := value.(type) {
+	case nil:
+		*toc = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(value, toc)
+	case string:
+		return json.Unmarshal([]byte(value), toc)
+	}
+
+	return errors.New("Unsupported table of contents value")
+}
+
+// RenderedMarkdown is Markdown rendered into sanitized HTML,
+// along with what was collected walking its AST.
+type RenderedMarkdown struct {
+	HTML        string
+	TOC         TableOfContents
+	WordCount   int
+	ReadingTime int // in minutes
+}
@@ -0,0 +1,40 @@
+package types
+
+// MicropubEntry is a post in the microformats2 JSON syntax,
+// property values are either strings or nested objects such as {"html": "..."}.
+type MicropubEntry struct {
+	Type       []string                 `json:"type,omitempty"`
+	Properties map[string][]interface{} `json:"properties"`
+}
+
+// MicropubRequest is a create, update, delete or undelete request,
+// form encoded requests are converted to it as well.
+type MicropubRequest struct {
+	Action string `json:"action,omitempty"` // empty when creating
+	URL    string `json:"url,omitempty"`
+
+	MicropubEntry
+
+	Replace map[string][]interface{} `json:"replace,omitempty"`
+	Add     map[string][]interface{} `json:"add,omitempty"`
+
+	// Delete is either a list of property names or values to remove per property
+	Delete interface{} `json:"delete,omitempty"`
+}
+
+type MicropubConfig struct {
+	MediaEndpoint string             `json:"media-endpoint"`
+	SyndicateTo   []interface{}      `json:"syndicate-to"`
+	PostTypes     []MicropubPostType `json:"post-types"`
+	Q             []string           `json:"q"`
+}
+
+type MicropubPostType struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+// MicropubResult is where the post lives after a request.
+type MicropubResult struct {
+	URL string
+}
@@ -0,0 +1,76 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// TextOperation is a list of components covering the whole document from start to end,
+// lengths are counted in characters (runes) rather than bytes.
+//
+// It is encoded the way ot.js does: a positive integer retains that many characters,
+// a negative integer deletes that many characters and a string inserts itself.
+type TextOperation []OperationComponent
+
+// OperationComponent has exactly one of its fields set.
+type OperationComponent struct {
+	Retain int
+	Insert string
+	Delete int
+}
+
+func (op TextOperation) MarshalJSON() ([]byte, error) {
+	components := make([]interface{}, 0, len(op))
+
+	for _, component := range op {
+		switch {
+		case component.Retain > 0:
+			components = append(components, component.Retain)
+		case component.Delete > 0:
+			components = append(components, -component.Delete)
+		default:
+			components = append(components, component.Insert)
+		}
+	}
+
+	return json.Marshal(components)
+}
+
+func (op *TextOperation) UnmarshalJSON(data []byte) error {
+	var components []json.RawMessage
+	if err := json.Unmarshal(data, &components); err != nil {
+		return err
+	}
+
+	result := make(TextOperation, 0, len(components))
+
+	for _, raw := range components {
+		var insert string
+		if err := json.Unmarshal(raw, &insert); err == nil {
+			if insert == "" {
+				return errors.New("Operation inserts an empty string")
+			}
+
+			result = append(result, OperationComponent{Insert: insert})
+			continue
+		}
+
+		var length int
+		if err := json.Unmarshal(raw, &length); err != nil {
+			return errors.New("Operation components must be integers or strings")
+		}
+
+		switch {
+		case length > 0:
+			result = append(result, OperationComponent{Retain: length})
+		case length < 0:
+			result = append(result, OperationComponent{Delete: -length})
+		default:
+			return errors.New("Operation retains or deletes nothing")
+		}
+	}
+
+	*op = result
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package types
+
+import "time"
+
+// Validator identifies the current version of a resource, it is cheap to load
+// and changes whenever the representation of the resource changes.
+// Additions bump LastModified while removals lower Count.
+type Validator struct {
+	LastModified time.Time
+	Count        int64
+
+	// Digest hashes the IDs within the resource in order,
+	// lists trading a blog for another one differ by it only.
+	Digest string
+}
+
+// BlogValidator is the validator of a single blog along with the users it embeds,
+// they are part of its digest and cache tags.
+type BlogValidator struct {
+	Validator
+
+	BlogID    string
+	AuthorIDs string // the author then the editing co-authors, comma separated
+}